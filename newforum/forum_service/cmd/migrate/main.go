@@ -45,8 +45,38 @@ func main() {
 		if _, err := db.Exec(`
 			DROP TABLE IF EXISTS schema_migrations CASCADE;
 			DROP TABLE IF EXISTS global_messages CASCADE;
+			DROP TABLE IF EXISTS held_attachments CASCADE;
+			DROP TABLE IF EXISTS held_messages CASCADE;
+			DROP TABLE IF EXISTS spam_samples CASCADE;
+			DROP TABLE IF EXISTS link_rules CASCADE;
+			DROP TABLE IF EXISTS word_filters CASCADE;
+			DROP TABLE IF EXISTS attachments CASCADE;
+			DROP TABLE IF EXISTS blobs CASCADE;
+			DROP TABLE IF EXISTS poll_votes CASCADE;
+			DROP TABLE IF EXISTS poll_options CASCADE;
+			DROP TABLE IF EXISTS polls CASCADE;
+			DROP TABLE IF EXISTS user_ignores CASCADE;
+			DROP TABLE IF EXISTS user_blocks CASCADE;
+			DROP TABLE IF EXISTS conversation_messages CASCADE;
+			DROP TABLE IF EXISTS conversation_participants CASCADE;
+			DROP TABLE IF EXISTS conversations CASCADE;
+			DROP TABLE IF EXISTS read_positions CASCADE;
+			DROP TABLE IF EXISTS forum_subscriptions CASCADE;
+			DROP TABLE IF EXISTS notification_preferences CASCADE;
+			DROP TABLE IF EXISTS notifications CASCADE;
+			DROP TABLE IF EXISTS message_mentions CASCADE;
+			DROP TABLE IF EXISTS message_reactions CASCADE;
+			DROP TABLE IF EXISTS topic_tags CASCADE;
+			DROP TABLE IF EXISTS forum_tags CASCADE;
+			DROP TABLE IF EXISTS tags CASCADE;
+			DROP TABLE IF EXISTS moderation_log CASCADE;
+			DROP TABLE IF EXISTS user_warnings CASCADE;
+			DROP TABLE IF EXISTS reports CASCADE;
+			DROP TABLE IF EXISTS message_revisions CASCADE;
 			DROP TABLE IF EXISTS messages CASCADE;
+			DROP TABLE IF EXISTS topics CASCADE;
 			DROP TABLE IF EXISTS forums CASCADE;
+			DROP TABLE IF EXISTS categories CASCADE;
 		`); err != nil {
 			log.Fatalf("Error dropping tables: %v", err)
 		}
@@ -2,10 +2,14 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,12 +20,148 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/jaxxiy/newforum/core/logger"
 	"github.com/jaxxiy/newforum/forum_service/internal/handlers"
+	"github.com/jaxxiy/newforum/forum_service/internal/mail"
+	"github.com/jaxxiy/newforum/forum_service/internal/ratelimit"
 	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/jaxxiy/newforum/forum_service/internal/storage"
 	"google.golang.org/grpc"
 )
 
 var log = logger.GetLogger()
 
+const (
+	defaultTrashRetention     = 30 * 24 * time.Hour
+	defaultTrashPurgeInterval = time.Hour
+	defaultForumArchiveAfter  = 90 * 24 * time.Hour
+	defaultForumArchiveCheck  = time.Hour
+	defaultMaxPinnedMessages  = 5
+	defaultMaxReactions       = 20
+	defaultDigestInterval     = 5 * time.Minute
+	defaultMailFrom           = "forum@localhost"
+
+	defaultAttachmentsDir     = "./data/attachments"
+	defaultMaxAttachmentSize  = 10 << 20
+	defaultMaxAttachments     = 5
+	defaultAttachmentTypes    = "image/jpeg,image/png,image/gif,image/webp,application/pdf,text/plain,application/zip"
+	defaultAttachmentURLTTL   = time.Hour
+	defaultBlobSweepGrace     = time.Hour
+	defaultBlobSweepInterval  = time.Hour
+	defaultSpamHoldThreshold  = 0.7
+	defaultMessageRateLimit   = "5/10s"
+	defaultMessageIPRateLimit = "20/10s"
+	defaultChatRateLimit      = "5/10s"
+	defaultChatIPRateLimit    = "10/10s"
+	defaultAttachmentS3Region = "us-east-1"
+)
+
+// intEnv reads a non-negative integer from the environment, falling back to
+// def when the variable is unset or malformed.
+func intEnv(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Error("Invalid number, using default", logger.String("key", key), logger.String("value", v))
+		return def
+	}
+	return n
+}
+
+// floatEnv reads a number between 0 and 1 from the environment, falling
+// back to def when the variable is unset or malformed.
+func floatEnv(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > 1 {
+		log.Error("Invalid fraction, using default", logger.String("key", key), logger.String("value", v))
+		return def
+	}
+	return f
+}
+
+// limitEnv reads a rate limit such as "5/10s" from the environment, falling
+// back to def when the variable is unset or malformed. "0" turns the limit
+// off.
+func limitEnv(key, def string) ratelimit.Limit {
+	v := stringEnv(key, def)
+	l, err := ratelimit.ParseLimit(v)
+	if err != nil {
+		log.Error("Invalid rate limit, using default", logger.String("key", key), logger.String("value", v))
+		l, _ = ratelimit.ParseLimit(def)
+	}
+	return l
+}
+
+// stringEnv reads a string from the environment, falling back to def when
+// the variable is unset.
+func stringEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// secretEnv reads a signing secret from the environment. When the variable
+// is unset it makes up a random one for this run of the service, so what it
+// signed stops verifying once the service restarts.
+func secretEnv(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("Failed to generate secret", logger.String("key", key), logger.Error(err))
+	}
+	log.Warn("Secret is not set, using a random one until restart", logger.String("key", key))
+	return hex.EncodeToString(b)
+}
+
+// mailSender returns the sender for outgoing mail: the SMTP server at
+// SMTP_ADDR, such as a local MailHog, or a sender that only logs when it is
+// unset.
+func mailSender() mail.Sender {
+	addr := os.Getenv("SMTP_ADDR")
+	if addr == "" {
+		log.Info("SMTP_ADDR is not set, mail will only be logged")
+		return mail.LogSender{}
+	}
+	return mail.NewSMTPSender(addr, stringEnv("MAIL_FROM", defaultMailFrom),
+		os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+}
+
+// fileStorage returns where attachments are stored: the S3-compatible
+// service at S3_ENDPOINT, such as a local MinIO, or the directory
+// ATTACHMENTS_DIR when it is unset.
+func fileStorage() storage.Storage {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		return storage.NewLocal(stringEnv("ATTACHMENTS_DIR", defaultAttachmentsDir))
+	}
+	return storage.NewS3(endpoint, stringEnv("S3_BUCKET", "attachments"),
+		stringEnv("S3_REGION", defaultAttachmentS3Region),
+		os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"))
+}
+
+// durationEnv reads a duration such as "720h" from the environment, falling
+// back to def when the variable is unset or malformed.
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Error("Invalid duration, using default", logger.String("key", key), logger.String("value", v))
+		return def
+	}
+	return d
+}
+
 type Server struct {
 	httpServer *http.Server
 	grpcServer *grpc.Server
@@ -59,7 +199,94 @@ func NewServer(port string) (*Server, error) {
 	repo := repository.NewForumsRepo(db)
 	router := mux.NewRouter()
 
+	messages := handlers.RateLimit{
+		User: limitEnv("RATE_LIMIT_MESSAGES", defaultMessageRateLimit),
+		IP:   limitEnv("RATE_LIMIT_MESSAGES_IP", defaultMessageIPRateLimit),
+	}
+	trustProxy, _ := strconv.ParseBool(os.Getenv("RATE_LIMIT_TRUST_PROXY"))
+	handlers.RegisterRateLimits(router, handlers.RateLimitConfig{
+		Routes: map[string]handlers.RateLimit{
+			"POST /api/forums/{id}/messages":                   messages,
+			"POST /api/forums/{id}/topics/{topic_id}/messages": messages,
+			"POST /api/forums/{id}/topics":                     messages,
+			"POST /api/global-chat": {
+				User: limitEnv("RATE_LIMIT_GLOBAL_CHAT", defaultChatRateLimit),
+				IP:   limitEnv("RATE_LIMIT_GLOBAL_CHAT_IP", defaultChatIPRateLimit),
+			},
+		},
+		TrustProxy: trustProxy,
+	})
+
+	// Messages from before Markdown was rendered get their HTML in the
+	// background; until then clients show their content as text.
+	go func() {
+		rendered, err := repo.RenderMissingHTML(500)
+		if err != nil {
+			log.Error("Failed to render messages", logger.Error(err))
+		} else if rendered > 0 {
+			log.Info("Rendered stored messages", logger.Int("messages", rendered))
+		}
+	}()
+
 	handlers.RegisterForumHandlers(router, repo)
+	handlers.RegisterSearchHandlers(router, repository.NewSearchRepo(db))
+	reports := repository.NewReportsRepo(db)
+	handlers.RegisterReportHandlers(router, repo, reports)
+	handlers.RegisterContentFilterHandlers(router, repo, repository.NewContentFiltersRepo(db), reports)
+	handlers.RegisterModerationHandlers(router, repo, repository.NewModerationLogRepo(db))
+	handlers.RegisterPinHandlers(router, repo, intEnv("MAX_PINNED_MESSAGES", defaultMaxPinnedMessages))
+	handlers.RegisterTagHandlers(router, repo)
+	handlers.RegisterReactionHandlers(router, repo, intEnv("MAX_REACTIONS_PER_MESSAGE", defaultMaxReactions))
+	handlers.RegisterPollHandlers(router, repo, repository.NewPollsRepo(db))
+
+	attachments := repository.NewAttachmentsRepo(db)
+	files := fileStorage()
+	handlers.RegisterAttachmentHandlers(router, repo, attachments, files, handlers.AttachmentConfig{
+		MaxSize:  int64(intEnv("MAX_ATTACHMENT_SIZE", defaultMaxAttachmentSize)),
+		MaxFiles: intEnv("MAX_ATTACHMENTS_PER_MESSAGE", defaultMaxAttachments),
+		Types:    strings.Split(stringEnv("ATTACHMENT_TYPES", defaultAttachmentTypes), ","),
+		Secret:   []byte(secretEnv("ATTACHMENT_SECRET")),
+		URLTTL:   durationEnv("ATTACHMENT_URL_TTL", defaultAttachmentURLTTL),
+	})
+
+	spam := repository.NewSpamRepo(db)
+	handlers.RegisterSpamHandlers(router, repo, spam, floatEnv("SPAM_HOLD_THRESHOLD", defaultSpamHoldThreshold))
+	// Until the classifier has learnt from the stored samples, messages
+	// are scored by the heuristics alone.
+	go func() {
+		model, err := handlers.TrainSpamClassifier(spam)
+		if err != nil {
+			log.Error("Failed to train spam classifier", logger.Error(err))
+			return
+		}
+		log.Info("Trained spam classifier",
+			logger.Int("spamSamples", model.SpamSamples),
+			logger.Int("hamSamples", model.HamSamples))
+	}()
+
+	handlers.RegisterMentionHandlers(router, repo)
+	handlers.RegisterReadPositionHandlers(router, repo)
+
+	blocks := repository.NewBlocksRepo(db)
+	handlers.RegisterBlockHandlers(router, repo, blocks)
+	handlers.RegisterConversationHandlers(router, repo, repository.NewConversationsRepo(db), blocks)
+
+	handlers.RegisterNotificationHandlers(router, repo, repository.NewNotificationsRepo(db))
+
+	subs := repository.NewSubscriptionsRepo(db)
+	handlers.RegisterSubscriptionHandlers(router, repo, subs, secretEnv("UNSUBSCRIBE_SECRET"))
+	handlers.StartDigestMailer(subs, mailSender(),
+		stringEnv("PUBLIC_URL", "http://localhost:"+port),
+		durationEnv("DIGEST_INTERVAL", defaultDigestInterval))
+	handlers.StartTrashPurge(repo,
+		durationEnv("TRASH_RETENTION", defaultTrashRetention),
+		durationEnv("TRASH_PURGE_INTERVAL", defaultTrashPurgeInterval))
+	handlers.StartForumArchiver(repo,
+		durationEnv("FORUM_ARCHIVE_AFTER", defaultForumArchiveAfter),
+		durationEnv("FORUM_ARCHIVE_INTERVAL", defaultForumArchiveCheck))
+	handlers.StartBlobSweep(attachments, files,
+		durationEnv("BLOB_SWEEP_GRACE", defaultBlobSweepGrace),
+		durationEnv("BLOB_SWEEP_INTERVAL", defaultBlobSweepInterval))
 
 	return &Server{
 		httpServer: &http.Server{
@@ -0,0 +1,300 @@
+// Package filter checks messages against the banned words and link rules
+// moderators set up for a forum.
+//
+// Words are matched after both the message and the filter are normalised:
+// case, accents, full-width and other stylised letters, lookalike letters
+// from other scripts, common leetspeak and invisible characters are all
+// folded away, so "Ｂ4Ð" and "bаd" with a Cyrillic а match a filter for
+// "bad". Masking replaces the original characters of a match.
+package filter
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+// MaxPatternLength is the longest word filter, in characters.
+const MaxPatternLength = 100
+
+var (
+	ErrEmptyPattern    = errors.New("pattern must contain a letter or digit")
+	ErrPatternTooLong  = errors.New("pattern too long")
+	ErrInvalidWildcard = errors.New("* can only start or end a pattern")
+	ErrInvalidDomain   = errors.New("invalid domain")
+)
+
+// Result is what the filters make of a message.
+type Result struct {
+	// Content is the message with masked words replaced by asterisks.
+	Content string
+	// Blocked and Flagged are the block and flag filters the message
+	// matched.
+	Blocked []models.WordFilter
+	Flagged []models.WordFilter
+	// Links are the linked domains the link rules refuse.
+	Links []string
+}
+
+// Rejected reports whether the message must not be posted.
+func (r Result) Rejected() bool {
+	return len(r.Blocked) > 0 || len(r.Links) > 0
+}
+
+// Reason tells the author why a rejected message was refused, without
+// giving away the filter list.
+func (r Result) Reason() string {
+	if len(r.Links) > 0 {
+		return "Links to " + strings.Join(r.Links, ", ") + " are not allowed here"
+	}
+	return "Message contains a banned word"
+}
+
+// FlagReason describes for moderators which filters flagged the message.
+func (r Result) FlagReason() string {
+	patterns := make([]string, len(r.Flagged))
+	for i, f := range r.Flagged {
+		patterns[i] = f.Pattern
+	}
+	return "Matched word filters: " + strings.Join(patterns, ", ")
+}
+
+// Apply checks content against rules. A nil rules lets everything through.
+func Apply(content string, rules *models.ContentFilters) Result {
+	res := Result{Content: content}
+	if rules == nil {
+		return res
+	}
+
+	if len(rules.Words) > 0 {
+		t := normalize(content, leet)
+		var masks [][2]int
+		for _, f := range rules.Words {
+			matches := compile(f.Pattern).find(t)
+			if len(matches) == 0 {
+				continue
+			}
+			switch f.Action {
+			case models.FilterBlock:
+				res.Blocked = append(res.Blocked, f)
+			case models.FilterFlag:
+				res.Flagged = append(res.Flagged, f)
+			case models.FilterMask:
+				for _, m := range matches {
+					masks = append(masks, [2]int{t.spans[m[0]][0], t.spans[m[1]-1][1]})
+				}
+			}
+		}
+		res.Content = mask(content, masks)
+	}
+
+	res.Links = refusedLinks(content, rules.Links)
+	return res
+}
+
+// pattern is a word filter ready for matching.
+type pattern struct {
+	runes []rune
+	// anyStart and anyEnd are set by a * at the start or end, which lets
+	// the pattern match inside longer words.
+	anyStart, anyEnd bool
+}
+
+func compile(p string) pattern {
+	var c pattern
+	p = strings.TrimSpace(p)
+	if strings.HasPrefix(p, "*") {
+		c.anyStart = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "*") {
+		c.anyEnd = true
+		p = p[:len(p)-1]
+	}
+	c.runes = normalize(strings.TrimSpace(p), leet).runes
+	return c
+}
+
+// find returns the rune ranges of t that p matches. A match covers whole
+// words.
+func (p pattern) find(t text) [][2]int {
+	var matches [][2]int
+	n := len(p.runes)
+	if n == 0 {
+		return nil
+	}
+	for i := 0; i+n <= len(t.runes); i++ {
+		if !equalRunes(t.runes[i:i+n], p.runes) {
+			continue
+		}
+		start, end := i, i+n
+		if p.anyStart {
+			for start > 0 && isWord(t.runes[start-1]) {
+				start--
+			}
+		} else if start > 0 && isWord(t.runes[start-1]) {
+			continue
+		}
+		if p.anyEnd {
+			for end < len(t.runes) && isWord(t.runes[end]) {
+				end++
+			}
+		} else if end < len(t.runes) && isWord(t.runes[end]) {
+			continue
+		}
+		matches = append(matches, [2]int{start, end})
+		i = end - 1
+	}
+	return matches
+}
+
+func equalRunes(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mask replaces the byte ranges of s with one asterisk per character.
+func mask(s string, ranges [][2]int) string {
+	if len(ranges) == 0 {
+		return s
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	var b strings.Builder
+	pos := 0
+	for _, r := range ranges {
+		if r[1] <= pos {
+			continue
+		}
+		if r[0] < pos {
+			r[0] = pos
+		}
+		b.WriteString(s[pos:r[0]])
+		b.WriteString(strings.Repeat("*", visibleLength(s[r[0]:r[1]])))
+		pos = r[1]
+	}
+	b.WriteString(s[pos:])
+	return b.String()
+}
+
+func visibleLength(s string) int {
+	n := 0
+	for _, r := range s {
+		if !invisible(r) {
+			n++
+		}
+	}
+	return n
+}
+
+// linkRe finds what the Markdown renderer turns into links, and addresses
+// starting with www. that readers would paste into a browser.
+var linkRe = regexp.MustCompile(`\bhttps?://(` + hostChars + `)|\b(www\.` + hostChars + `)`)
+
+const hostChars = `[^\s/?#<>"'()\[\]\\` + "`" + `]+`
+
+// refusedLinks returns the domains linked from content that rules refuse.
+// A deny rule matches lookalikes of its domain too; an allow rule only
+// the domain itself, so a lookalike of an allowed domain is not allowed.
+func refusedLinks(content string, rules []models.LinkRule) []string {
+	if len(rules) == 0 {
+		return nil
+	}
+	allowList := false
+	for _, rule := range rules {
+		if rule.Policy == models.LinkAllow {
+			allowList = true
+		}
+	}
+
+	var refused []string
+	seen := make(map[string]bool)
+	for _, m := range linkRe.FindAllStringSubmatch(normalize(content, folded).String(), -1) {
+		host := linkHost(m[1] + m[2])
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		denied, allowed := false, false
+		for _, rule := range rules {
+			switch rule.Policy {
+			case models.LinkDeny:
+				denied = denied || inDomain(normalize(host, lookalike).String(), normalize(rule.Domain, lookalike).String())
+			case models.LinkAllow:
+				allowed = allowed || inDomain(host, rule.Domain)
+			}
+		}
+		if denied || allowList && !allowed {
+			refused = append(refused, host)
+		}
+	}
+	return refused
+}
+
+// linkHost takes the host name out of the authority part of a URL.
+func linkHost(authority string) string {
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		authority = authority[i+1:]
+	}
+	if i := strings.LastIndexByte(authority, ':'); i >= 0 {
+		authority = authority[:i]
+	}
+	return strings.TrimRight(authority, ".,;!?")
+}
+
+func inDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// CleanPattern trims a word filter and checks it can match something.
+func CleanPattern(p string) (string, error) {
+	p = strings.ToLower(strings.TrimSpace(p))
+	if utf8.RuneCountInString(p) > MaxPatternLength {
+		return "", ErrPatternTooLong
+	}
+	if strings.Contains(strings.TrimSuffix(strings.TrimPrefix(p, "*"), "*"), "*") {
+		return "", ErrInvalidWildcard
+	}
+	for _, r := range compile(p).runes {
+		if isWord(r) {
+			return p, nil
+		}
+	}
+	return "", ErrEmptyPattern
+}
+
+// CleanDomain turns what a moderator typed for a link rule, a domain or a
+// URL, into a lower-case domain name.
+func CleanDomain(d string) (string, error) {
+	d = strings.ToLower(strings.TrimSpace(d))
+	if i := strings.Index(d, "://"); i >= 0 {
+		d = d[i+3:]
+	}
+	if i := strings.IndexAny(d, "/?#"); i >= 0 {
+		d = d[:i]
+	}
+	d = linkHost(strings.TrimPrefix(d, "*."))
+	if len(d) > 253 || !strings.Contains(d, ".") {
+		return "", ErrInvalidDomain
+	}
+	for _, label := range strings.Split(d, ".") {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", ErrInvalidDomain
+		}
+		for _, r := range label {
+			if r != '-' && !isWord(r) {
+				return "", ErrInvalidDomain
+			}
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func words(action string, patterns ...string) *models.ContentFilters {
+	rules := &models.ContentFilters{}
+	for _, p := range patterns {
+		rules.Words = append(rules.Words, models.WordFilter{Pattern: p, Action: action})
+	}
+	return rules
+}
+
+func TestApplyBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		blocked bool
+	}{
+		{"plain", "this is bad", true},
+		{"upper case", "BAD idea", true},
+		{"inside a word", "badminton", false},
+		{"punctuation", "bad!", true},
+		{"cyrillic lookalike", "bаd", true},
+		{"full width", "ｂａｄ", true},
+		{"math bold", "\U0001d41b\U0001d41a\U0001d41d", true},
+		{"accents", "bäd", true},
+		{"combining accent", "ba\u0301d", true},
+		{"zero width", "b\u200bad", true},
+		{"leetspeak", "b4d", true},
+		{"mixed", "Ｂ4Ð", true},
+		{"clean", "good", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Apply(tt.content, words(models.FilterBlock, "bad"))
+			assert.Equal(t, tt.blocked, res.Rejected())
+			assert.Equal(t, tt.content, res.Content)
+		})
+	}
+}
+
+func TestApplyCyrillicFilter(t *testing.T) {
+	rules := words(models.FilterBlock, "плохо")
+
+	assert.True(t, Apply("это плохо", rules).Rejected())
+	// The same word typed with Latin lookalikes.
+	assert.True(t, Apply("это nлoxo", rules).Rejected())
+	assert.False(t, Apply("это хорошо", rules).Rejected())
+}
+
+func TestApplyPhraseAndWildcards(t *testing.T) {
+	assert.True(t, Apply("you are  really\nbad", words(models.FilterBlock, "really bad")).Rejected())
+	assert.True(t, Apply("spammers", words(models.FilterBlock, "spam*")).Rejected())
+	assert.True(t, Apply("antispam", words(models.FilterBlock, "*spam")).Rejected())
+	assert.False(t, Apply("antispam", words(models.FilterBlock, "spam*")).Rejected())
+}
+
+func TestApplyMask(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"word", "that is darn good", "that is **** good"},
+		{"every match", "darn, DARN", "****, ****"},
+		{"lookalike", "dаrn it", "**** it"},
+		{"invisible characters are masked too", "da\u200brn!", "****!"},
+		{"wildcard masks the whole word", "darnedest", "*********"},
+		{"untouched", "darling", "darling"},
+	}
+	rules := words(models.FilterMask, "darn", "darne*")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Apply(tt.content, rules)
+			assert.False(t, res.Rejected())
+			assert.Equal(t, tt.want, res.Content)
+		})
+	}
+}
+
+func TestApplyFlag(t *testing.T) {
+	rules := &models.ContentFilters{Words: []models.WordFilter{
+		{Pattern: "casino", Action: models.FilterFlag},
+		{Pattern: "crypto", Action: models.FilterFlag},
+		{Pattern: "darn", Action: models.FilterMask},
+	}}
+
+	res := Apply("darn casino", rules)
+	assert.False(t, res.Rejected())
+	assert.Equal(t, "**** casino", res.Content)
+	assert.Len(t, res.Flagged, 1)
+	assert.Equal(t, "Matched word filters: casino", res.FlagReason())
+
+	assert.Empty(t, Apply("nothing here", rules).Flagged)
+}
+
+func TestApplyLinks(t *testing.T) {
+	deny := &models.ContentFilters{Links: []models.LinkRule{
+		{Domain: "evil.com", Policy: models.LinkDeny},
+	}}
+	allow := &models.ContentFilters{Links: []models.LinkRule{
+		{Domain: "example.com", Policy: models.LinkAllow},
+		{Domain: "bad.example.com", Policy: models.LinkDeny},
+	}}
+
+	tests := []struct {
+		name    string
+		rules   *models.ContentFilters
+		content string
+		refused []string
+	}{
+		{"denied", deny, "see https://evil.com/page", []string{"evil.com"}},
+		{"denied subdomain", deny, "see http://www.evil.com", []string{"www.evil.com"}},
+		{"markdown link", deny, "[click](https://EVIL.com)", []string{"evil.com"}},
+		{"user info and port", deny, "https://user@evil.com:8080/", []string{"evil.com"}},
+		{"lookalike of a denied domain", deny, "https://еvil.com", []string{"еvil.com"}},
+		{"other domain", deny, "https://devil.com and https://evil.community", nil},
+		{"domain without link", deny, "evil.com", nil},
+		{"allowed", allow, "https://example.com and https://docs.example.com.", nil},
+		{"not on the allow-list", allow, "https://other.org", []string{"other.org"}},
+		{"lookalike of an allowed domain", allow, "https://exаmple.com", []string{"exаmple.com"}},
+		{"deny beats allow", allow, "https://bad.example.com", []string{"bad.example.com"}},
+		{"www without scheme", allow, "www.other.org", []string{"www.other.org"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Apply(tt.content, tt.rules)
+			assert.Equal(t, tt.refused, res.Links)
+			assert.Equal(t, len(tt.refused) > 0, res.Rejected())
+		})
+	}
+
+	assert.Equal(t, "Links to other.org are not allowed here", Apply("https://other.org", allow).Reason())
+}
+
+func TestApplyWithoutRules(t *testing.T) {
+	res := Apply("anything https://evil.com", nil)
+	assert.False(t, res.Rejected())
+	assert.Equal(t, "anything https://evil.com", res.Content)
+}
+
+func TestCleanPattern(t *testing.T) {
+	p, err := CleanPattern("  Spam* ")
+	assert.NoError(t, err)
+	assert.Equal(t, "spam*", p)
+
+	_, err = CleanPattern("***")
+	assert.ErrorIs(t, err, ErrInvalidWildcard)
+	_, err = CleanPattern("sp*am")
+	assert.ErrorIs(t, err, ErrInvalidWildcard)
+	_, err = CleanPattern("!!")
+	assert.ErrorIs(t, err, ErrEmptyPattern)
+	_, err = CleanPattern(string(make([]byte, MaxPatternLength+1)))
+	assert.ErrorIs(t, err, ErrPatternTooLong)
+}
+
+func TestCleanDomain(t *testing.T) {
+	for in, want := range map[string]string{
+		"Example.com":                  "example.com",
+		"https://www.example.com/path": "www.example.com",
+		"*.example.com":                "example.com",
+		"user@example.com:443":         "example.com",
+		"пример.рф":                    "пример.рф",
+	} {
+		got, err := CleanDomain(in)
+		assert.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+
+	for _, in := range []string{"", "localhost", "example..com", "-example.com", "exa mple.com", "example.com_"} {
+		_, err := CleanDomain(in)
+		assert.ErrorIs(t, err, ErrInvalidDomain, in)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "heiio worid", normalize("HeLLo \t\u200b WORLD", leet).String())
+	assert.Equal(t, "paypai.com", normalize("pаypal.com", lookalike).String())
+	assert.Equal(t, "g00gle", normalize("ｇ00ｇｌｅ", folded).String())
+}
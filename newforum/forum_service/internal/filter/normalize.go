@@ -0,0 +1,154 @@
+package filter
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// form is how far text is normalised before it is matched.
+type form int
+
+const (
+	// folded text is lower case, with full-width, mathematical and circled
+	// letters turned into plain ones and invisible characters dropped.
+	folded form = iota
+	// lookalike text is folded, and letters from other scripts and with
+	// accents are replaced by the ASCII letters they look like.
+	lookalike
+	// leet text is lookalike, and digits and symbols written for letters
+	// are replaced too.
+	leet
+)
+
+// confusables maps lower-case letters to the ASCII letters they are
+// mistaken for. Both filters and messages go through it, so a Cyrillic
+// word still matches itself.
+var confusables = map[rune]string{
+	// Cyrillic
+	'а': "a", 'б': "6", 'в': "b", 'г': "r", 'д': "d", 'е': "e", 'ё': "e", 'з': "3", 'и': "u", 'й': "u",
+	'к': "k", 'м': "m", 'н': "h", 'о': "o", 'п': "n", 'р': "p", 'с': "c", 'т': "t", 'у': "y",
+	'х': "x", 'ч': "4", 'ш': "w", 'щ': "w", 'ъ': "b", 'ы': "bi", 'ь': "b", 'є': "e", 'ѕ': "s",
+	'і': "i", 'ї': "i", 'ј': "j", 'ԁ': "d", 'ԛ': "q", 'ԝ': "w", 'һ': "h", 'ү': "y", 'ӏ': "i",
+	// Greek
+	'α': "a", 'β': "b", 'γ': "y", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "n", 'ι': "i", 'κ': "k",
+	'μ': "u", 'ν': "v", 'ο': "o", 'π': "n", 'ρ': "p", 'σ': "o", 'ς': "c", 'τ': "t", 'υ': "u",
+	'χ': "x", 'ω': "w",
+	// Latin with accents and ligatures
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a", 'ɑ': "a",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ɡ': "g",
+	'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k",
+	'ĺ': "i", 'ļ': "i", 'ľ': "i", 'ŀ': "i", 'ł': "i", 'ℓ': "i",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r",
+	'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s", 'ſ': "s",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th",
+	// l, I and 1 are told apart by the font alone, so every l is an i.
+	'l': "i",
+}
+
+// leetspeak maps the digits and symbols written for letters. Cyrillic
+// letters that look like digits map to the same letters through it.
+var leetspeak = map[rune]string{
+	'0': "o", '1': "i", '3': "e", '4': "a", '5': "s", '6': "b", '7': "t", '@': "a", '$': "s",
+}
+
+// text is normalised text that remembers where each of its runes came
+// from, so matches can be masked in the original.
+type text struct {
+	runes []rune
+	// spans holds, for each rune, the byte range of the source it came from.
+	spans [][2]int
+}
+
+func (t text) String() string {
+	return string(t.runes)
+}
+
+// normalize brings s to form f. Runs of white space become one space.
+func normalize(s string, f form) text {
+	var t text
+	add := func(r rune, start, end int) {
+		t.runes = append(t.runes, r)
+		t.spans = append(t.spans, [2]int{start, end})
+	}
+
+	for i, r := range s {
+		end := i + utf8.RuneLen(r)
+		if r == utf8.RuneError {
+			end = i + 1
+		}
+		last := len(t.runes) - 1
+
+		if invisible(r) || unicode.IsSpace(r) && last >= 0 && t.runes[last] == ' ' {
+			if last >= 0 {
+				t.spans[last][1] = end
+			}
+			continue
+		}
+		if unicode.IsSpace(r) {
+			add(' ', i, end)
+			continue
+		}
+
+		r = unicode.ToLower(plain(r))
+		repl := string(r)
+		if f >= lookalike {
+			if c, ok := confusables[r]; ok {
+				repl = c
+			}
+		}
+		for _, c := range repl {
+			if f >= leet {
+				if l, ok := leetspeak[c]; ok {
+					for _, lc := range l {
+						add(lc, i, end)
+					}
+					continue
+				}
+			}
+			add(c, i, end)
+		}
+	}
+	return t
+}
+
+// invisible reports whether r takes no space on screen: zero-width
+// characters, direction marks, soft hyphens and combining accents.
+func invisible(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf)
+}
+
+// plain turns full-width, mathematical and circled letters and digits into
+// ASCII ones.
+func plain(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		return r - 0xFF01 + '!'
+	case r >= 0x1D400 && r <= 0x1D6A3:
+		n := (r - 0x1D400) % 52
+		if n < 26 {
+			return 'A' + n
+		}
+		return 'a' + n - 26
+	case r >= 0x1D7CE && r <= 0x1D7FF:
+		return '0' + (r-0x1D7CE)%10
+	case r >= 0x24B6 && r <= 0x24CF:
+		return 'A' + r - 0x24B6
+	case r >= 0x24D0 && r <= 0x24E9:
+		return 'a' + r - 0x24D0
+	}
+	return r
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/jaxxiy/newforum/forum_service/internal/storage"
+)
+
+const (
+	// thumbnailSize bounds the width and height of image thumbnails.
+	thumbnailSize = 320
+	// maxThumbnailPixels stops huge images from being decoded into memory
+	// just to be shrunk.
+	maxThumbnailPixels = 40_000_000
+	maxFilenameLength  = 255
+)
+
+// AttachmentConfig limits the files that can be attached to messages and
+// signs the links they are downloaded through.
+type AttachmentConfig struct {
+	MaxSize  int64
+	MaxFiles int
+	// Types are the allowed MIME types, matched against the content of
+	// the file rather than what the client claims.
+	Types []string
+	// Secret signs download links, which expire after URLTTL.
+	Secret []byte
+	URLTTL time.Duration
+}
+
+var (
+	// attachmentStore and blobStorage are set by RegisterAttachmentHandlers;
+	// without them messages cannot carry files.
+	attachmentStore  repository.AttachmentsRepository
+	blobStorage      storage.Storage
+	attachmentConfig AttachmentConfig
+)
+
+func RegisterAttachmentHandlers(r *mux.Router, repo repository.ForumsRepository, attachments repository.AttachmentsRepository, store storage.Storage, cfg AttachmentConfig) {
+	attachmentStore = attachments
+	blobStorage = store
+	attachmentConfig = cfg
+
+	r.HandleFunc("/attachments/{id:[0-9]+}", DownloadAttachment(repo, attachments, store, false)).Methods("GET")
+	r.HandleFunc("/attachments/{id:[0-9]+}/thumbnail", DownloadAttachment(repo, attachments, store, true)).Methods("GET")
+}
+
+// attachmentSignature signs a download link. kind tells the file from its
+// thumbnail, so a link to one cannot be turned into a link to the other.
+func attachmentSignature(id int, kind string, expires int64) string {
+	mac := hmac.New(sha256.New, attachmentConfig.Secret)
+	fmt.Fprintf(mac, "%d:%s:%d", id, kind, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func attachmentURL(id int, kind string, expires int64) string {
+	path := "/attachments/" + strconv.Itoa(id)
+	if kind == "thumbnail" {
+		path += "/thumbnail"
+	}
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", attachmentSignature(id, kind, expires))
+	return path + "?" + q.Encode()
+}
+
+// signAttachments fills in the download links of attachments. Links are
+// handed out only along with the message, so whoever can read the message
+// can download its files, until the links expire.
+func signAttachments(attachments []models.Attachment) {
+	// Rounding keeps the links of a message stable for a while, so
+	// browsers can cache the files.
+	expires := time.Now().Add(attachmentConfig.URLTTL).Truncate(time.Minute).Unix()
+	for i := range attachments {
+		a := &attachments[i]
+		a.URL = attachmentURL(a.ID, "file", expires)
+		if a.ThumbnailType != "" {
+			a.ThumbnailURL = attachmentURL(a.ID, "thumbnail", expires)
+		}
+	}
+}
+
+// attachFiles fills in the attachments of msgs with signed download links.
+func attachFiles(msgs ...[]models.Message) error {
+	ids := messageIDs(msgs...)
+	if attachmentStore == nil || len(ids) == 0 {
+		return nil
+	}
+
+	attachments, err := attachmentStore.GetAttachments(ids)
+	if err != nil {
+		return err
+	}
+	for _, list := range msgs {
+		for i := range list {
+			list[i].Attachments = attachments[list[i].ID]
+			signAttachments(list[i].Attachments)
+		}
+	}
+	return nil
+}
+
+// readMultipartMessage reads a message posted as multipart/form-data into
+// req and returns the files attached to it.
+func readMultipartMessage(w http.ResponseWriter, r *http.Request, req *messageRequest) ([]*multipart.FileHeader, error) {
+	if blobStorage == nil {
+		return nil, errors.New("Attachments are not enabled")
+	}
+
+	// Leave room for the text fields and the multipart framing.
+	limit := attachmentConfig.MaxSize*int64(attachmentConfig.MaxFiles) + 1<<20
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, errors.New("Attachments are too large")
+		}
+		return nil, errors.New("Invalid multipart form")
+	}
+
+	req.Author = r.FormValue("author")
+	req.Content = r.FormValue("content")
+	req.Quote = r.FormValue("quote")
+	if v := r.FormValue("reply_to"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.New("Invalid reply_to")
+		}
+		req.ReplyTo = &id
+	}
+
+	files := r.MultipartForm.File["files"]
+	if len(files) > attachmentConfig.MaxFiles {
+		return nil, fmt.Errorf("At most %d files can be attached", attachmentConfig.MaxFiles)
+	}
+	return files, nil
+}
+
+// checkUploads validates the files uploaded by uploader and describes the
+// attachments they will become. The returned errors are meant for the
+// client.
+func checkUploads(files []*multipart.FileHeader, uploader string) ([]models.Attachment, error) {
+	attachments := make([]models.Attachment, 0, len(files))
+	for _, fh := range files {
+		a, err := checkUpload(fh)
+		if err != nil {
+			return nil, err
+		}
+		a.Uploader = uploader
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+func checkUpload(fh *multipart.FileHeader) (models.Attachment, error) {
+	a := models.Attachment{Filename: cleanFilename(fh.Filename), Size: fh.Size}
+	if a.Size == 0 {
+		return a, fmt.Errorf("%s is empty", a.Filename)
+	}
+	if a.Size > attachmentConfig.MaxSize {
+		return a, fmt.Errorf("%s is larger than %d bytes", a.Filename, attachmentConfig.MaxSize)
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return a, fmt.Errorf("%s could not be read", a.Filename)
+	}
+	defer f.Close()
+
+	// The type is sniffed from the content: the one the client sends is
+	// whatever it claims.
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	a.ContentType, _, _ = mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if !allowedType(a.ContentType) {
+		return a, fmt.Errorf("%s has a type that cannot be attached (%s)", a.Filename, a.ContentType)
+	}
+
+	hash := sha256.New()
+	hash.Write(head[:n])
+	if _, err := io.Copy(hash, f); err != nil {
+		return a, fmt.Errorf("%s could not be read", a.Filename)
+	}
+	a.Hash = hex.EncodeToString(hash.Sum(nil))
+	return a, nil
+}
+
+func allowedType(contentType string) bool {
+	for _, t := range attachmentConfig.Types {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFilename keeps the base name of an uploaded file, without the
+// directories some browsers send, and caps its length.
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || strings.TrimSpace(name) == "" {
+		name = "file"
+	}
+	for len(name) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// storeUploads stores the content of the files checked by checkUploads.
+// Content that is already stored is only marked as used again.
+func storeUploads(ctx context.Context, files []*multipart.FileHeader, attachments []models.Attachment) error {
+	for i, fh := range files {
+		a := &attachments[i]
+		blob, err := attachmentStore.TouchBlob(a.Hash)
+		if err == nil {
+			a.ThumbnailType = blob.ThumbnailType
+			continue
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		if err := storeBlob(ctx, fh, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func storeBlob(ctx context.Context, fh *multipart.FileHeader, a *models.Attachment) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	blob := a.Blob()
+	if err := blobStorage.Put(ctx, blob.Key(), f, blob.Size, blob.ContentType); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(blob.ContentType, "image/") {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		thumb, thumbType, err := makeThumbnail(f)
+		if err != nil {
+			// The file is still attached, just without a preview.
+			log.Info("No thumbnail for attachment", logger.String("hash", blob.Hash), logger.Error(err))
+		} else if err := blobStorage.Put(ctx, blob.ThumbnailKey(), bytes.NewReader(thumb), int64(len(thumb)), thumbType); err != nil {
+			return err
+		} else {
+			blob.ThumbnailType = thumbType
+		}
+	}
+
+	a.ThumbnailType = blob.ThumbnailType
+	return attachmentStore.SaveBlob(blob)
+}
+
+// makeThumbnail shrinks an image to fit thumbnailSize. JPEG photos stay
+// JPEG; everything else becomes PNG to keep transparency.
+func makeThumbnail(r io.ReadSeeker) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return nil, "", fmt.Errorf("image is %dx%d", cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	var src image.Image
+	switch format {
+	case "jpeg":
+		src, err = jpeg.Decode(r)
+	case "png":
+		src, err = png.Decode(r)
+	case "gif":
+		src, err = gif.Decode(r)
+	default:
+		err = fmt.Errorf("unsupported image format %q", format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var buf bytes.Buffer
+	thumb := shrink(src, thumbnailSize)
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+		return buf.Bytes(), "image/jpeg", err
+	}
+	err = png.Encode(&buf, thumb)
+	return buf.Bytes(), "image/png", err
+}
+
+// shrink scales src down to fit a size×size box, averaging the pixels each
+// thumbnail pixel covers. Smaller images are copied as they are.
+func shrink(src image.Image, size int) *image.RGBA64 {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewRGBA64(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// StartBlobSweep deletes the stored files no attachment refers to any
+// more, checking every interval. Attachments go away with their messages
+// when the trash is purged; files that were used within grace are kept,
+// as an upload may be about to attach them.
+func StartBlobSweep(attachments repository.AttachmentsRepository, store storage.Storage, grace, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sweepBlobs(context.Background(), attachments, store, time.Now().Add(-grace))
+			<-ticker.C
+		}
+	}()
+}
+
+func sweepBlobs(ctx context.Context, attachments repository.AttachmentsRepository, store storage.Storage, unusedSince time.Time) {
+	blobs, err := attachments.DeleteOrphanedBlobs(unusedSince)
+	if err != nil {
+		log.Error("Blob sweep failed", logger.Error(err))
+		return
+	}
+	for _, b := range blobs {
+		keys := []string{b.Key()}
+		if b.ThumbnailType != "" {
+			keys = append(keys, b.ThumbnailKey())
+		}
+		for _, key := range keys {
+			if err := store.Delete(ctx, key); err != nil {
+				log.Error("Failed to delete blob", logger.String("key", key), logger.Error(err))
+			}
+		}
+	}
+	if len(blobs) > 0 {
+		log.Info("Orphaned blobs deleted", logger.Int("blobs", len(blobs)))
+	}
+}
+
+// DownloadAttachment godoc
+// @Summary Download attachment
+// @Description Download a file attached to a message, or its thumbnail. Links are signed and expire; they come with the message
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path int true "Attachment ID"
+// @Param expires query int true "Expiry of the link, in Unix seconds"
+// @Param sig query string true "Signature of the link"
+// @Success 200 {file} file
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /attachments/{id} [get]
+// @Router /attachments/{id}/thumbnail [get]
+func DownloadAttachment(repo repository.ForumsRepository, attachments repository.AttachmentsRepository, store storage.Storage, thumbnail bool) http.HandlerFunc {
+	kind := "file"
+	if thumbnail {
+		kind = "thumbnail"
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid attachment ID")
+			return
+		}
+
+		q := r.URL.Query()
+		expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+		if err != nil || time.Now().Unix() > expires ||
+			!hmac.Equal([]byte(q.Get("sig")), []byte(attachmentSignature(id, kind, expires))) {
+			sendError(w, http.StatusForbidden, "Invalid or expired link")
+			return
+		}
+
+		a, err := attachments.GetAttachment(id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Attachment not found")
+				return
+			}
+			log.Error("Failed to load attachment", logger.Error(err), logger.Int("attachmentID", id))
+			sendError(w, http.StatusInternalServerError, "Failed to load attachment")
+			return
+		}
+		// Files of deleted messages stay stored until the trash is
+		// purged, but cannot be downloaded.
+		if _, err := repo.GetMessageByID(a.MessageID); err != nil {
+			sendError(w, http.StatusNotFound, "Attachment not found")
+			return
+		}
+
+		blob := a.Blob()
+		key, contentType := blob.Key(), blob.ContentType
+		if thumbnail {
+			if blob.ThumbnailType == "" {
+				sendError(w, http.StatusNotFound, "Attachment has no thumbnail")
+				return
+			}
+			key, contentType = blob.ThumbnailKey(), blob.ThumbnailType
+		}
+
+		body, err := store.Get(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Attachment not found")
+				return
+			}
+			log.Error("Failed to read attachment", logger.Error(err), logger.String("key", key))
+			sendError(w, http.StatusInternalServerError, "Failed to read attachment")
+			return
+		}
+		defer body.Close()
+
+		// Only images are shown in the browser; anything else is saved,
+		// so an uploaded page cannot run in the forum's origin.
+		disposition := "attachment"
+		if strings.HasPrefix(contentType, "image/") {
+			disposition = "inline"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.FormatInt(max(0, expires-time.Now().Unix()), 10))
+		if !thumbnail {
+			w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+		}
+		if _, err := io.Copy(w, body); err != nil {
+			log.Error("Failed to send attachment", logger.Error(err), logger.Int("attachmentID", id))
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/pkg/jwt"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/jaxxiy/newforum/forum_service/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// attachmentRoutes registers the attachment handlers and the route that
+// posts messages with attachments.
+func attachmentRoutes(repo *mocks.MockForumsRepo, attachments *mocks.MockAttachmentsRepo, store storage.Storage) func(*mux.Router) {
+	return func(r *mux.Router) {
+		RegisterAttachmentHandlers(r, repo, attachments, store, AttachmentConfig{
+			MaxSize:  1 << 20,
+			MaxFiles: 2,
+			Types:    []string{"image/png", "text/plain"},
+			Secret:   []byte("secret"),
+			URLTTL:   time.Hour,
+		})
+		r.HandleFunc("/api/forums/{id}/messages", PostMessage(repo)).Methods("POST")
+	}
+}
+
+type testFile struct {
+	name    string
+	content []byte
+}
+
+func multipartRequest(t *testing.T, url string, fields map[string]string, files ...testFile) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		assert.NoError(t, mw.WriteField(k, v))
+	}
+	for _, f := range files {
+		fw, err := mw.CreateFormFile("files", f.name)
+		assert.NoError(t, err)
+		fw.Write(f.content)
+	}
+	assert.NoError(t, mw.Close())
+
+	req := httptest.NewRequest("POST", url, &body)
+	token, err := jwt.GenerateToken(1, testSecretKey, 24*time.Hour)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 200, 255})
+		}
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func readObject(t *testing.T, store storage.Storage, key string) []byte {
+	t.Helper()
+	r, err := store.Get(context.Background(), key)
+	if !assert.NoError(t, err, key) {
+		return nil
+	}
+	defer r.Close()
+	body, _ := io.ReadAll(r)
+	return body
+}
+
+func TestPostMessageWithAttachments(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockAttachments := new(mocks.MockAttachmentsRepo)
+	store := storage.NewLocal(t.TempDir())
+	router := testRouter(t, attachmentRoutes(mockRepo, mockAttachments, store))
+
+	picture := testPNG(t, 640, 480)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(5, nil)
+	mockAttachments.On("TouchBlob", mock.Anything).Return(nil, repository.ErrNotFound)
+	mockAttachments.On("SaveBlob", mock.AnythingOfType("models.Blob")).Return(nil)
+	var added []models.Attachment
+	mockAttachments.On("AddAttachments", 5, mock.Anything).
+		Run(func(args mock.Arguments) { added = args.Get(1).([]models.Attachment) }).
+		Return([]models.Attachment{
+			{ID: 1, MessageID: 5, ThumbnailType: "image/png"},
+			{ID: 2, MessageID: 5},
+		}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, multipartRequest(t, "/api/forums/1/messages",
+		map[string]string{"author": "alice", "content": ""},
+		testFile{`C:\Photos\cat.png`, picture},
+		testFile{"notes.txt", []byte("hello")}))
+
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	if assert.Len(t, added, 2) {
+		assert.Equal(t, "cat.png", added[0].Filename)
+		assert.Equal(t, "image/png", added[0].ContentType)
+		assert.Equal(t, "alice", added[0].Uploader)
+		assert.Equal(t, "image/png", added[0].ThumbnailType)
+		assert.Equal(t, "text/plain", added[1].ContentType)
+		assert.Empty(t, added[1].ThumbnailType)
+
+		assert.Equal(t, picture, readObject(t, store, added[0].Blob().Key()))
+		thumb, err := png.Decode(bytes.NewReader(readObject(t, store, added[0].Blob().ThumbnailKey())))
+		if assert.NoError(t, err) {
+			assert.Equal(t, image.Rect(0, 0, 320, 240), thumb.Bounds())
+		}
+	}
+
+	var msg models.Message
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+	if assert.Len(t, msg.Attachments, 2) {
+		assert.Contains(t, msg.Attachments[0].URL, "/attachments/1?")
+		assert.Contains(t, msg.Attachments[0].ThumbnailURL, "/attachments/1/thumbnail?")
+		assert.Empty(t, msg.Attachments[1].ThumbnailURL)
+	}
+}
+
+func TestPostMessageDeduplicatesAttachments(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockAttachments := new(mocks.MockAttachmentsRepo)
+	dir := t.TempDir()
+	router := testRouter(t, attachmentRoutes(mockRepo, mockAttachments, storage.NewLocal(dir)))
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(5, nil)
+	mockAttachments.On("TouchBlob", mock.Anything).Return(&models.Blob{ThumbnailType: "image/png"}, nil)
+	mockAttachments.On("AddAttachments", 5, mock.Anything).Return([]models.Attachment{{ID: 1}}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, multipartRequest(t, "/api/forums/1/messages",
+		map[string]string{"author": "alice", "content": "again"},
+		testFile{"cat.png", testPNG(t, 10, 10)}))
+
+	assert.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	mockAttachments.AssertNotCalled(t, "SaveBlob", mock.Anything)
+	stored, _ := os.ReadDir(dir)
+	assert.Empty(t, stored, "known content is not stored again")
+}
+
+func TestPostMessageAttachmentRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []testFile
+	}{
+		{name: "Type Not Allowed", files: []testFile{{"tool.exe", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00")}}},
+		{name: "Too Large", files: []testFile{{"big.txt", bytes.Repeat([]byte("a"), 1<<20+1)}}},
+		{name: "Empty", files: []testFile{{"empty.txt", nil}}},
+		{name: "Too Many", files: []testFile{{"a.txt", []byte("a")}, {"b.txt", []byte("b")}, {"c.txt", []byte("c")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockAttachments := new(mocks.MockAttachmentsRepo)
+			router := testRouter(t, attachmentRoutes(mockRepo, mockAttachments, storage.NewLocal(t.TempDir())))
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+			mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, multipartRequest(t, "/api/forums/1/messages",
+				map[string]string{"author": "alice", "content": "files"}, tt.files...))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+			mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything)
+		})
+	}
+}
+
+func TestPostMessageAttachmentsDisabled(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	router := mux.NewRouter()
+	router.HandleFunc("/api/forums/{id}/messages", PostMessage(mockRepo)).Methods("POST")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, multipartRequest(t, "/api/forums/1/messages",
+		map[string]string{"author": "alice", "content": "files"}, testFile{"a.txt", []byte("a")}))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "not enabled")
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockAttachments := new(mocks.MockAttachmentsRepo)
+	store := storage.NewLocal(t.TempDir())
+	router := testRouter(t, attachmentRoutes(mockRepo, mockAttachments, store))
+
+	hash := strings.Repeat("ab", 32)
+	file := &models.Attachment{ID: 1, MessageID: 5, Filename: "notes.txt", ContentType: "text/plain", Size: 5, Hash: hash}
+	picture := &models.Attachment{ID: 2, MessageID: 6, Filename: "cat.png", ContentType: "image/png", Size: 3, Hash: hash, ThumbnailType: "image/png"}
+	assert.NoError(t, store.Put(context.Background(), file.Blob().Key(), strings.NewReader("hello"), 5, "text/plain"))
+	assert.NoError(t, store.Put(context.Background(), picture.Blob().ThumbnailKey(), strings.NewReader("png"), 3, "image/png"))
+	mockAttachments.On("GetAttachment", 1).Return(file, nil)
+	mockAttachments.On("GetAttachment", 2).Return(picture, nil)
+	mockAttachments.On("GetAttachment", 3).Return(nil, repository.ErrNotFound)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5}, nil)
+	mockRepo.On("GetMessageByID", 6).Return(nil, repository.ErrNotFound)
+
+	expires := time.Now().Add(time.Minute).Unix()
+	past := time.Now().Add(-time.Minute).Unix()
+	tests := []struct {
+		name     string
+		url      string
+		wantCode int
+		wantBody string
+	}{
+		{name: "Downloaded", url: attachmentURL(1, "file", expires), wantCode: http.StatusOK, wantBody: "hello"},
+		{name: "Expired", url: attachmentURL(1, "file", past), wantCode: http.StatusForbidden},
+		{name: "Unsigned", url: "/attachments/1?expires=9999999999", wantCode: http.StatusForbidden},
+		{name: "Signed For Another File", url: strings.Replace(attachmentURL(1, "file", expires), "/1?", "/3?", 1), wantCode: http.StatusForbidden},
+		{name: "Thumbnail Link For File", url: strings.Replace(attachmentURL(1, "thumbnail", expires), "/thumbnail", "", 1), wantCode: http.StatusForbidden},
+		{name: "No Thumbnail", url: attachmentURL(1, "thumbnail", expires), wantCode: http.StatusNotFound},
+		{name: "Unknown", url: attachmentURL(3, "file", expires), wantCode: http.StatusNotFound},
+		{name: "Message Deleted", url: attachmentURL(2, "thumbnail", expires), wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.url, nil))
+
+			assert.Equal(t, tt.wantCode, rr.Code, rr.Body.String())
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rr.Body.String())
+				assert.Equal(t, "text/plain", rr.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename=notes.txt`, rr.Header().Get("Content-Disposition"))
+				assert.Equal(t, "nosniff", rr.Header().Get("X-Content-Type-Options"))
+			}
+		})
+	}
+}
+
+func TestSweepBlobs(t *testing.T) {
+	mockAttachments := new(mocks.MockAttachmentsRepo)
+	store := storage.NewLocal(t.TempDir())
+	ctx := context.Background()
+
+	blob := models.Blob{Hash: strings.Repeat("cd", 32), Size: 3, ContentType: "image/png", ThumbnailType: "image/png"}
+	assert.NoError(t, store.Put(ctx, blob.Key(), strings.NewReader("png"), 3, "image/png"))
+	assert.NoError(t, store.Put(ctx, blob.ThumbnailKey(), strings.NewReader("png"), 3, "image/png"))
+	unusedSince := time.Now().Add(-time.Hour)
+	mockAttachments.On("DeleteOrphanedBlobs", unusedSince).Return([]models.Blob{blob}, nil)
+
+	sweepBlobs(ctx, mockAttachments, store, unusedSince)
+
+	for _, key := range []string{blob.Key(), blob.ThumbnailKey()} {
+		_, err := store.Get(ctx, key)
+		assert.ErrorIs(t, err, storage.ErrNotFound, key)
+	}
+	mockAttachments.AssertExpectations(t)
+}
+
+func TestRedactHiddenAttachments(t *testing.T) {
+	messages := []models.Message{
+		{ID: 1, Hidden: true, Content: "spam", ContentHTML: "<p>spam</p>", Attachments: []models.Attachment{{ID: 1}}},
+		{ID: 2, Content: "ok", Attachments: []models.Attachment{{ID: 2}}},
+	}
+	redactHidden(messages, false)
+	assert.Empty(t, messages[0].ContentHTML)
+	assert.Nil(t, messages[0].Attachments)
+	assert.Len(t, messages[1].Attachments, 1)
+}
+
+func TestCleanFilename(t *testing.T) {
+	assert.Equal(t, "cat.png", cleanFilename(`C:\Photos\cat.png`))
+	assert.Equal(t, "passwd", cleanFilename("../../etc/passwd"))
+	assert.Equal(t, "file", cleanFilename(""))
+	assert.Equal(t, "ab.txt", cleanFilename("a\x00b.txt"))
+	assert.Len(t, cleanFilename(strings.Repeat("é", 200)), 254)
+}
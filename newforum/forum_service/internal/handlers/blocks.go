@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+// socketViewer is the signed-in user reading a forum or topic socket and
+// the users they ignore.
+type socketViewer struct {
+	username string
+	ignored  map[string]bool
+}
+
+var (
+	// blockStore answers who blocked or ignores whom, and blockUsers
+	// identifies the readers of forum and topic sockets. Both are set by
+	// RegisterBlockHandlers; without them nobody is blocked or ignored.
+	blockStore repository.BlocksRepository
+	blockUsers repository.ForumsRepository
+
+	// socketViewers holds the viewers of forum and topic sockets opened
+	// with a token. Anonymous sockets see every message.
+	socketViewers   = make(map[*websocket.Conn]*socketViewer)
+	socketViewersMu sync.RWMutex
+)
+
+func RegisterBlockHandlers(r *mux.Router, repo repository.ForumsRepository, blocks repository.BlocksRepository) {
+	blockStore = blocks
+	blockUsers = repo
+
+	r.HandleFunc("/settings/blocks", BlocksPage).Methods("GET")
+
+	r.HandleFunc("/api/blocks", GetBlockedUsers(repo, blocks)).Methods("GET")
+	r.HandleFunc("/api/blocks/{username}", BlockUser(repo, blocks)).Methods("PUT")
+	r.HandleFunc("/api/blocks/{username}", UnblockUser(repo, blocks)).Methods("DELETE")
+	r.HandleFunc("/api/ignores", GetIgnoredUsers(repo, blocks)).Methods("GET")
+	r.HandleFunc("/api/ignores/{username}", IgnoreUser(repo, blocks)).Methods("PUT")
+	r.HandleFunc("/api/ignores/{username}", UnignoreUser(repo, blocks)).Methods("DELETE")
+}
+
+// watchViewer remembers who reads on a forum or topic socket, so
+// broadcasts can collapse the messages of users they ignore. Browsers
+// cannot set headers on WebSocket requests, so the token comes in the
+// query string.
+func watchViewer(conn *websocket.Conn, r *http.Request) {
+	if blockStore == nil || blockUsers == nil {
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return
+	}
+	user := tokenUser(token, blockUsers)
+	if user == nil {
+		return
+	}
+	ignored, err := blockStore.GetIgnoredUsers(user.Username)
+	if err != nil {
+		log.Error("Failed to load ignored users", logger.Error(err), logger.String("username", user.Username))
+		return
+	}
+
+	v := &socketViewer{username: user.Username, ignored: make(map[string]bool, len(ignored))}
+	for _, name := range ignored {
+		v.ignored[name] = true
+	}
+	socketViewersMu.Lock()
+	socketViewers[conn] = v
+	socketViewersMu.Unlock()
+}
+
+func forgetViewer(conn *websocket.Conn) {
+	socketViewersMu.Lock()
+	delete(socketViewers, conn)
+	socketViewersMu.Unlock()
+}
+
+// setIgnored updates the sockets username has open after they start or
+// stop ignoring another user.
+func setIgnored(username, other string, ignored bool) {
+	socketViewersMu.Lock()
+	defer socketViewersMu.Unlock()
+
+	for _, v := range socketViewers {
+		if v.username != username {
+			continue
+		}
+		if ignored {
+			v.ignored[other] = true
+		} else {
+			delete(v.ignored, other)
+		}
+	}
+}
+
+// forViewer returns event as the viewer on conn should see it: messages by
+// users they ignore are marked so the client collapses them.
+func forViewer(conn *websocket.Conn, event WSMessage) WSMessage {
+	socketViewersMu.RLock()
+	defer socketViewersMu.RUnlock()
+
+	v := socketViewers[conn]
+	if v == nil || len(v.ignored) == 0 {
+		return event
+	}
+	switch p := event.Payload.(type) {
+	case messageEvent:
+		if v.ignored[p.Author] {
+			p.Ignored = true
+			event.Payload = p
+		}
+	case models.Message:
+		if v.ignored[p.Author] {
+			p.Ignored = true
+			event.Payload = p
+		}
+	case *models.Message:
+		if p != nil && v.ignored[p.Author] {
+			m := *p
+			m.Ignored = true
+			event.Payload = &m
+		}
+	}
+	return event
+}
+
+// markIgnored flags the messages viewer sees by users they ignore. Errors
+// are logged only; the messages are then shown as usual.
+func markIgnored(viewer string, msgs ...[]models.Message) {
+	if blockStore == nil || viewer == "" {
+		return
+	}
+	ignored, err := blockStore.GetIgnoredUsers(viewer)
+	if err != nil {
+		log.Error("Failed to load ignored users", logger.Error(err), logger.String("username", viewer))
+		return
+	}
+	if len(ignored) == 0 {
+		return
+	}
+	for _, list := range msgs {
+		for i := range list {
+			for _, name := range ignored {
+				if list[i].Author == name {
+					list[i].Ignored = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// withoutBlockers drops the users who blocked author from names.
+func withoutBlockers(author string, names []string) ([]string, error) {
+	if blockStore == nil || len(names) == 0 {
+		return names, nil
+	}
+	blockers, err := blockStore.GetBlockers(author, names)
+	if err != nil {
+		return nil, err
+	}
+	if len(blockers) == 0 {
+		return names, nil
+	}
+	blocked := make(map[string]bool, len(blockers))
+	for _, name := range blockers {
+		blocked[name] = true
+	}
+	kept := []string{}
+	for _, name := range names {
+		if !blocked[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept, nil
+}
+
+// targetUser returns the other user named in the route, failing if it is
+// the current user or nobody by that name is registered.
+func targetUser(w http.ResponseWriter, r *http.Request, repo repository.ForumsRepository, user *models.User) (string, bool) {
+	name := mux.Vars(r)["username"]
+	if name == user.Username {
+		sendError(w, http.StatusBadRequest, "You cannot block or ignore yourself")
+		return "", false
+	}
+	known, err := repo.ResolveUsernames([]string{name})
+	if err != nil {
+		log.Error("Failed to resolve username", logger.Error(err), logger.String("username", name))
+		sendError(w, http.StatusInternalServerError, "Failed to find user")
+		return "", false
+	}
+	if len(known) == 0 {
+		sendError(w, http.StatusNotFound, "User not found")
+		return "", false
+	}
+	return name, true
+}
+
+func BlocksPage(w http.ResponseWriter, r *http.Request) {
+	renderTemplate(w, "blocks.html", nil)
+}
+
+// GetBlockedUsers godoc
+// @Summary List blocked users
+// @Description Get the users the current user has blocked from messaging or mentioning them
+// @Tags blocks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 401 {object} map[string]string
+// @Router /blocks [get]
+func GetBlockedUsers(repo repository.ForumsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		blocked, err := blocks.GetBlockedUsers(user.Username)
+		if err != nil {
+			log.Error("Failed to load blocked users", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to load blocked users")
+			return
+		}
+		json.NewEncoder(w).Encode(blocked)
+	}
+}
+
+// BlockUser godoc
+// @Summary Block user
+// @Description Stop a user from sending the current user private messages or mentioning them
+// @Tags blocks
+// @Param username path string true "Username"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /blocks/{username} [put]
+func BlockUser(repo repository.ForumsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		name, ok := targetUser(w, r, repo, user)
+		if !ok {
+			return
+		}
+
+		if err := blocks.BlockUser(user.Username, name); err != nil {
+			log.Error("Failed to block user", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to block user")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UnblockUser godoc
+// @Summary Unblock user
+// @Description Let a blocked user message and mention the current user again
+// @Tags blocks
+// @Param username path string true "Username"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /blocks/{username} [delete]
+func UnblockUser(repo repository.ForumsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		if err := blocks.UnblockUser(user.Username, mux.Vars(r)["username"]); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "User is not blocked")
+				return
+			}
+			log.Error("Failed to unblock user", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to unblock user")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetIgnoredUsers godoc
+// @Summary List ignored users
+// @Description Get the users whose messages are collapsed for the current user
+// @Tags blocks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 401 {object} map[string]string
+// @Router /ignores [get]
+func GetIgnoredUsers(repo repository.ForumsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		ignored, err := blocks.GetIgnoredUsers(user.Username)
+		if err != nil {
+			log.Error("Failed to load ignored users", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to load ignored users")
+			return
+		}
+		json.NewEncoder(w).Encode(ignored)
+	}
+}
+
+// IgnoreUser godoc
+// @Summary Ignore user
+// @Description Collapse a user's messages for the current user, in message lists and live updates
+// @Tags blocks
+// @Param username path string true "Username"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /ignores/{username} [put]
+func IgnoreUser(repo repository.ForumsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		name, ok := targetUser(w, r, repo, user)
+		if !ok {
+			return
+		}
+
+		if err := blocks.IgnoreUser(user.Username, name); err != nil {
+			log.Error("Failed to ignore user", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to ignore user")
+			return
+		}
+		setIgnored(user.Username, name, true)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UnignoreUser godoc
+// @Summary Stop ignoring user
+// @Description Show an ignored user's messages to the current user again
+// @Tags blocks
+// @Param username path string true "Username"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /ignores/{username} [delete]
+func UnignoreUser(repo repository.ForumsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		name := mux.Vars(r)["username"]
+		if err := blocks.UnignoreUser(user.Username, name); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "User is not ignored")
+				return
+			}
+			log.Error("Failed to stop ignoring user", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to stop ignoring user")
+			return
+		}
+		setIgnored(user.Username, name, false)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/core/pkg/jwt"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockUser(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockBlocks := new(mocks.MockBlocksRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterBlockHandlers(r, mockRepo, mockBlocks) })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"mallory"}).Return([]string{"mallory"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"ghost"}).Return([]string{}, nil)
+	mockBlocks.On("BlockUser", "bob", "mallory").Return(nil)
+	mockBlocks.On("UnblockUser", "bob", "mallory").Return(nil)
+	mockBlocks.On("UnblockUser", "bob", "alice").Return(repository.ErrNotFound)
+	mockBlocks.On("GetBlockedUsers", "bob").Return([]string{"mallory"}, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+	}{
+		{name: "Block", method: "PUT", url: "/api/blocks/mallory", wantStatus: http.StatusNoContent},
+		{name: "Block Self", method: "PUT", url: "/api/blocks/bob", wantStatus: http.StatusBadRequest},
+		{name: "Block Unknown", method: "PUT", url: "/api/blocks/ghost", wantStatus: http.StatusNotFound},
+		{name: "Unblock", method: "DELETE", url: "/api/blocks/mallory", wantStatus: http.StatusNoContent},
+		{name: "Unblock Not Blocked", method: "DELETE", url: "/api/blocks/alice", wantStatus: http.StatusNotFound},
+		{name: "List", method: "GET", url: "/api/blocks", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, tt.method, tt.url, ""))
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+	mockBlocks.AssertNumberOfCalls(t, "BlockUser", 1)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/blocks", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestIgnoreUser(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockBlocks := new(mocks.MockBlocksRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterBlockHandlers(r, mockRepo, mockBlocks) })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"mallory"}).Return([]string{"mallory"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"ghost"}).Return([]string{}, nil)
+	mockBlocks.On("IgnoreUser", "bob", "mallory").Return(nil)
+	mockBlocks.On("UnignoreUser", "bob", "mallory").Return(nil)
+	mockBlocks.On("UnignoreUser", "bob", "alice").Return(repository.ErrNotFound)
+	mockBlocks.On("GetIgnoredUsers", "bob").Return([]string{"mallory"}, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+	}{
+		{name: "Ignore", method: "PUT", url: "/api/ignores/mallory", wantStatus: http.StatusNoContent},
+		{name: "Ignore Self", method: "PUT", url: "/api/ignores/bob", wantStatus: http.StatusBadRequest},
+		{name: "Ignore Unknown", method: "PUT", url: "/api/ignores/ghost", wantStatus: http.StatusNotFound},
+		{name: "Unignore", method: "DELETE", url: "/api/ignores/mallory", wantStatus: http.StatusNoContent},
+		{name: "Unignore Not Ignored", method: "DELETE", url: "/api/ignores/alice", wantStatus: http.StatusNotFound},
+		{name: "List", method: "GET", url: "/api/ignores", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, tt.method, tt.url, ""))
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+	mockBlocks.AssertNumberOfCalls(t, "IgnoreUser", 1)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("PUT", "/api/ignores/mallory", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestForViewer(t *testing.T) {
+	conn := &websocket.Conn{}
+	socketViewersMu.Lock()
+	socketViewers[conn] = &socketViewer{username: "bob", ignored: map[string]bool{"mallory": true}}
+	socketViewersMu.Unlock()
+	t.Cleanup(func() { forgetViewer(conn) })
+
+	msg := &models.Message{ID: 1, Author: "mallory", Content: "hi"}
+	got := forViewer(conn, WSMessage{Type: "message_updated", Payload: msg})
+	assert.True(t, got.Payload.(*models.Message).Ignored)
+	assert.False(t, msg.Ignored, "the shared message must not change")
+
+	got = forViewer(conn, WSMessage{Type: "message_created", Payload: messageEvent{Message: *msg}})
+	assert.True(t, got.Payload.(messageEvent).Ignored)
+
+	got = forViewer(conn, WSMessage{Type: "message_created", Payload: messageEvent{Message: models.Message{Author: "alice"}}})
+	assert.False(t, got.Payload.(messageEvent).Ignored)
+
+	got = forViewer(&websocket.Conn{}, WSMessage{Type: "message_updated", Payload: msg})
+	assert.False(t, got.Payload.(*models.Message).Ignored, "anonymous viewers see every message")
+}
+
+func TestIgnoredMessagesOverWebSocket(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockBlocks := new(mocks.MockBlocksRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterBlockHandlers(r, mockRepo, mockBlocks) })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"carol"}).Return([]string{"carol"}, nil)
+	mockBlocks.On("GetIgnoredUsers", "bob").Return([]string{"mallory"}, nil)
+	mockBlocks.On("IgnoreUser", "bob", "carol").Return(nil)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveWebSocket(w, mux.SetURLVars(r, map[string]string{"forum_id": "44"}))
+	}))
+	t.Cleanup(server.Close)
+	token, err := jwt.GenerateToken(1, testSecretKey, time.Hour)
+	assert.NoError(t, err)
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token="+token, nil)
+	if err != nil {
+		t.Fatalf("could not open a ws connection: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	time.Sleep(100 * time.Millisecond)
+
+	// Ignoring someone applies to the sockets that are already open.
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/ignores/carol", ""))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	for _, author := range []string{"mallory", "carol", "alice"} {
+		broadcastToForum(44, WSMessage{Type: "message_created", Payload: messageEvent{Message: models.Message{ForumID: 44, Author: author}}})
+	}
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	for _, want := range []bool{true, true, false} {
+		var event struct {
+			Payload models.Message `json:"payload"`
+		}
+		assert.NoError(t, ws.ReadJSON(&event))
+		assert.Equal(t, want, event.Payload.Ignored, event.Payload.Author)
+	}
+}
+
+func TestMarkIgnored(t *testing.T) {
+	mockBlocks := new(mocks.MockBlocksRepo)
+	blockStore = mockBlocks
+	t.Cleanup(func() { blockStore = nil })
+
+	mockBlocks.On("GetIgnoredUsers", "bob").Return([]string{"mallory"}, nil)
+
+	messages := []models.Message{{ID: 1, Author: "alice"}, {ID: 2, Author: "mallory"}}
+	pinned := []models.Message{{ID: 2, Author: "mallory"}}
+	markIgnored("bob", messages, pinned)
+
+	assert.False(t, messages[0].Ignored)
+	assert.True(t, messages[1].Ignored)
+	assert.True(t, pinned[0].Ignored)
+
+	markIgnored("", messages)
+	mockBlocks.AssertNumberOfCalls(t, "GetIgnoredUsers", 1)
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+type categoryRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type orderRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// pinnedNodes collects the pinned forums anywhere in the tree so the forum
+// list can show them above the categories.
+func pinnedNodes(tree *models.ForumTree) []*models.ForumNode {
+	pinned := []*models.ForumNode{}
+	eachForumNode(tree, func(n *models.ForumNode) {
+		if n.Pinned {
+			pinned = append(pinned, n)
+		}
+	})
+	return pinned
+}
+
+// eachForumNode calls fn for every forum in tree, parents before their
+// subforums.
+func eachForumNode(tree *models.ForumTree, fn func(n *models.ForumNode)) {
+	var walk func(nodes []*models.ForumNode)
+	walk = func(nodes []*models.ForumNode) {
+		for _, n := range nodes {
+			fn(n)
+			walk(n.Subforums)
+		}
+	}
+	for _, c := range tree.Categories {
+		walk(c.Forums)
+	}
+	walk(tree.Uncategorized)
+}
+
+// GetForumTree godoc
+// @Summary Forum tree
+// @Description Get every forum arranged by category and parent forum, with message counts and the latest post. Signed-in users also get their unread counts
+// @Tags categories
+// @Produce json
+// @Success 200 {object} models.ForumTree
+// @Router /forums-tree [get]
+func GetForumTree(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		tree, err := repo.GetForumTree()
+		if err != nil {
+			log.Error("Failed to load forum tree", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load forums")
+			return
+		}
+		if err := attachUnreadTree(repo, requestUser(r, repo), tree); err != nil {
+			log.Error("Failed to count unread messages", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load forums")
+			return
+		}
+		json.NewEncoder(w).Encode(tree)
+	}
+}
+
+// ListCategories godoc
+// @Summary List categories
+// @Description Get all categories in display order
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.Category
+// @Router /categories [get]
+func ListCategories(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		categories, err := repo.GetCategories()
+		if err != nil {
+			log.Error("Failed to load categories", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load categories")
+			return
+		}
+		json.NewEncoder(w).Encode(categories)
+	}
+}
+
+// CreateCategory godoc
+// @Summary Create category
+// @Description Add a category after the existing ones. Admins only
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param category body categoryRequest true "Category info"
+// @Security BearerAuth
+// @Success 201 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /categories [post]
+func CreateCategory(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req categoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			sendError(w, http.StatusBadRequest, "Name is required")
+			return
+		}
+
+		category := models.Category{Name: req.Name, Description: req.Description}
+		id, err := repo.CreateCategory(category)
+		if err != nil {
+			log.Error("Failed to create category", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to create category")
+			return
+		}
+		category.ID = id
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(category)
+	}
+}
+
+// UpdateCategory godoc
+// @Summary Update category
+// @Description Rename a category or change its description. Admins only
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "Category ID"
+// @Param category body categoryRequest true "Category info"
+// @Security BearerAuth
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /categories/{id} [put]
+func UpdateCategory(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid category ID")
+			return
+		}
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req categoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			sendError(w, http.StatusBadRequest, "Name is required")
+			return
+		}
+
+		category := models.Category{ID: id, Name: req.Name, Description: req.Description}
+		if err := repo.UpdateCategory(id, category); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Category not found")
+				return
+			}
+			log.Error("Failed to update category", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to update category")
+			return
+		}
+		json.NewEncoder(w).Encode(category)
+	}
+}
+
+// DeleteCategory godoc
+// @Summary Delete category
+// @Description Delete a category. Its forums are kept and become uncategorised. Admins only
+// @Tags categories
+// @Param id path int true "Category ID"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /categories/{id} [delete]
+func DeleteCategory(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid category ID")
+			return
+		}
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		if err := repo.DeleteCategory(id); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Category not found")
+				return
+			}
+			log.Error("Failed to delete category", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to delete category")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ReorderCategories godoc
+// @Summary Reorder categories
+// @Description Set the display order of categories. Admins only
+// @Tags categories
+// @Accept json
+// @Param order body orderRequest true "Category IDs in display order"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /categories/order [put]
+func ReorderCategories(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req orderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.IDs) == 0 {
+			sendError(w, http.StatusBadRequest, "A list of category IDs is required")
+			return
+		}
+
+		if err := repo.ReorderCategories(req.IDs); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Category not found")
+				return
+			}
+			log.Error("Failed to reorder categories", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to reorder categories")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MoveForums godoc
+// @Summary Move and reorder forums
+// @Description Place forums in a category or under a parent forum, in the given order. Admins only
+// @Tags categories
+// @Accept json
+// @Param placement body models.ForumPlacement true "Target and forum IDs in display order"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/order [put]
+func MoveForums(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req models.ForumPlacement
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.ForumIDs) == 0 {
+			sendError(w, http.StatusBadRequest, "A list of forum IDs is required")
+			return
+		}
+
+		if err := repo.MoveForums(req); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrForumCycle):
+				sendError(w, http.StatusBadRequest, err.Error())
+			case errors.Is(err, repository.ErrNotFound):
+				sendError(w, http.StatusNotFound, "Forum not found")
+			default:
+				log.Error("Failed to move forums", logger.Error(err))
+				sendError(w, http.StatusInternalServerError, "Failed to move forums")
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// categoryRoutes registers the category and forum tree routes, which
+// RegisterForumHandlers adds in production.
+func categoryRoutes(repo *mocks.MockForumsRepo) func(*mux.Router) {
+	return func(r *mux.Router) {
+		api := r.PathPrefix("/api").Subrouter()
+		api.HandleFunc("/forums/order", MoveForums(repo)).Methods("PUT")
+		api.HandleFunc("/forums-tree", GetForumTree(repo)).Methods("GET")
+		api.HandleFunc("/categories", ListCategories(repo)).Methods("GET")
+		api.HandleFunc("/categories", CreateCategory(repo)).Methods("POST")
+		api.HandleFunc("/categories/order", ReorderCategories(repo)).Methods("PUT")
+		api.HandleFunc("/categories/{id:[0-9]+}", UpdateCategory(repo)).Methods("PUT")
+		api.HandleFunc("/categories/{id:[0-9]+}", DeleteCategory(repo)).Methods("DELETE")
+	}
+}
+
+func TestCategoryAdminOnly(t *testing.T) {
+	requests := []struct{ method, url, body string }{
+		{"POST", "/api/categories", `{"name":"Jobs"}`},
+		{"PUT", "/api/categories/1", `{"name":"Jobs"}`},
+		{"DELETE", "/api/categories/1", ""},
+		{"PUT", "/api/categories/order", `{"ids":[2,1]}`},
+		{"PUT", "/api/forums/order", `{"category_id":1,"forum_ids":[3]}`},
+	}
+
+	for _, req := range requests {
+		t.Run(req.method+" "+req.url, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+
+			router := testRouter(t, categoryRoutes(mockRepo))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, req.method, req.url, req.body))
+
+			assert.Equal(t, http.StatusForbidden, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateCategory(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("CreateCategory", models.Category{Name: "Jobs", Description: "Vacancies"}).Return(4, nil)
+
+	router := testRouter(t, categoryRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/categories", `{"name":"Jobs","description":"Vacancies"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.Category
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, 4, got.ID)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/categories", `{"name":"  "}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReorderCategories(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("ReorderCategories", []int{2, 1}).Return(nil)
+	mockRepo.On("ReorderCategories", []int{9}).Return(repository.ErrNotFound)
+
+	router := testRouter(t, categoryRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/categories/order", `{"ids":[2,1]}`))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/categories/order", `{"ids":[9]}`))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMoveForums(t *testing.T) {
+	parentID := 1
+	tests := []struct {
+		name     string
+		moveErr  error
+		wantCode int
+	}{
+		{name: "Success", wantCode: http.StatusNoContent},
+		{name: "Cycle", moveErr: repository.ErrForumCycle, wantCode: http.StatusBadRequest},
+		{name: "Missing Forum", moveErr: repository.ErrNotFound, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+			mockRepo.On("MoveForums", models.ForumPlacement{ParentID: &parentID, ForumIDs: []int{3, 2}}).Return(tt.moveErr)
+
+			router := testRouter(t, categoryRoutes(mockRepo))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/order", `{"parent_id":1,"forum_ids":[3,2]}`))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Empty List", func(t *testing.T) {
+		mockRepo := new(mocks.MockForumsRepo)
+		mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+
+		router := testRouter(t, categoryRoutes(mockRepo))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/order", `{"category_id":1}`))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockRepo.AssertNotCalled(t, "MoveForums", mock.Anything)
+	})
+}
+
+func TestListForumsTree(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	lastAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	mockRepo.On("GetForumTree").Return(&models.ForumTree{
+		Categories: []*models.CategoryNode{{
+			Category: models.Category{ID: 1, Name: "Languages", Description: "Everything about languages"},
+			Forums: []*models.ForumNode{{
+				Forum:        models.Forum{ID: 1, Title: "Go", Pinned: true},
+				MessageCount: 12,
+				LastPost:     &models.LastPost{MessageID: 40, Author: "gopher", CreatedAt: lastAt},
+				Subforums:    []*models.ForumNode{{Forum: models.Forum{ID: 2, Title: "Generics"}}},
+			}},
+		}},
+	}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums", ListForums(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(t, body, "Everything about languages")
+	assert.Contains(t, body, "Сообщений: 12")
+	assert.Contains(t, body, "gopher, 2024-05-01 12:30")
+	assert.Contains(t, body, `data-parent-id="1"`)
+	assert.Contains(t, body, "Generics")
+	assert.Contains(t, body, "Закреплён")
+}
+
+func TestGetForumTreeAPI(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetForumTree").Return(&models.ForumTree{
+		Categories:    []*models.CategoryNode{},
+		Uncategorized: []*models.ForumNode{{Forum: models.Forum{ID: 5, Title: "Misc"}, MessageCount: 3}},
+	}, nil)
+
+	router := testRouter(t, categoryRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums-tree", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.ForumTree
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	if assert.Len(t, got.Uncategorized, 1) {
+		assert.Equal(t, 3, got.Uncategorized[0].MessageCount)
+	}
+}
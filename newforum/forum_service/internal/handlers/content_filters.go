@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/filter"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+var (
+	// filterStore holds the content filters messages are checked against
+	// and filterReports receives the messages flag filters match. Both
+	// are set by RegisterContentFilterHandlers; without them messages are
+	// not filtered.
+	filterStore   repository.ContentFiltersRepository
+	filterReports repository.ReportsRepository
+)
+
+type wordFilterRequest struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+type linkRuleRequest struct {
+	Domain string `json:"domain"`
+	Policy string `json:"policy"`
+}
+
+func RegisterContentFilterHandlers(r *mux.Router, repo repository.ForumsRepository, filters repository.ContentFiltersRepository, reports repository.ReportsRepository) {
+	filterStore = filters
+	filterReports = reports
+
+	for _, prefix := range []string{"/api/filters", "/api/forums/{id:[0-9]+}/filters"} {
+		r.HandleFunc(prefix, GetContentFilters(repo, filters)).Methods("GET")
+		r.HandleFunc(prefix+"/words", AddWordFilter(repo, filters)).Methods("POST")
+		r.HandleFunc(prefix+"/words/{filter_id:[0-9]+}", DeleteWordFilter(repo, filters)).Methods("DELETE")
+		r.HandleFunc(prefix+"/links", AddLinkRule(repo, filters)).Methods("POST")
+		r.HandleFunc(prefix+"/links/{rule_id:[0-9]+}", DeleteLinkRule(repo, filters)).Methods("DELETE")
+	}
+}
+
+// checkContent applies the content filters of forumID, or the site-wide
+// ones when forumID is nil, to content.
+func checkContent(forumID *int, content string) (filter.Result, error) {
+	if filterStore == nil {
+		return filter.Result{Content: content}, nil
+	}
+	rules, err := filterStore.GetContentFilters(forumID)
+	if err != nil {
+		return filter.Result{}, err
+	}
+	return filter.Apply(content, rules), nil
+}
+
+// filterMessage checks content like checkContent and writes the error
+// response if the message is refused.
+func filterMessage(w http.ResponseWriter, forumID *int, content string) (filter.Result, bool) {
+	res, err := checkContent(forumID, content)
+	if err != nil {
+		log.Error("Failed to load content filters", logger.Error(err))
+		sendError(w, http.StatusInternalServerError, "Failed to check message")
+		return res, false
+	}
+	if res.Rejected() {
+		sendError(w, http.StatusUnprocessableEntity, res.Reason())
+		return res, false
+	}
+	return res, true
+}
+
+// flagMessage puts a message a flag filter matched in the report queue.
+// The filter reports a message once while the report is open.
+func flagMessage(msg *models.Message, res filter.Result) {
+	if len(res.Flagged) == 0 || filterReports == nil {
+		return
+	}
+	report, err := filterReports.CreateReport(models.Report{
+		MessageID: msg.ID,
+		Reporter:  models.FilterReporter,
+		Reason:    models.ReportReasonFilter,
+		Details:   res.FlagReason(),
+	})
+	if err != nil {
+		if !errors.Is(err, repository.ErrAlreadyReported) {
+			log.Error("Failed to report filtered message", logger.Error(err), logger.Int("messageID", msg.ID))
+		}
+		return
+	}
+	go broadcastToModerators(report.ForumID, WSMessage{
+		Type:    "report_created",
+		Payload: report,
+	})
+}
+
+// filterScope reads which filters a request is about: a forum's, or the
+// site-wide ones when the path has no forum. Moderators manage the filters
+// of forums and can read the site-wide ones; changing those takes an admin.
+func filterScope(w http.ResponseWriter, r *http.Request, repo repository.ForumsRepository, change bool) (*int, *models.User, bool) {
+	user := requestUser(r, repo)
+	if !isModerator(user) {
+		sendError(w, http.StatusForbidden, "Forbidden")
+		return nil, nil, false
+	}
+
+	v, ok := mux.Vars(r)["id"]
+	if !ok {
+		if change && !isAdmin(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return nil, nil, false
+		}
+		return nil, user, true
+	}
+	forumID, err := strconv.Atoi(v)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid forum ID")
+		return nil, nil, false
+	}
+	if _, err := repo.GetByID(forumID); err != nil {
+		sendError(w, http.StatusNotFound, "Forum not found")
+		return nil, nil, false
+	}
+	return &forumID, user, true
+}
+
+// GetContentFilters godoc
+// @Summary Content filters
+// @Description List the banned words and link rules that apply to a forum, its own and the site-wide ones (forum_id null), or only the site-wide ones. Moderators only
+// @Tags filters
+// @Produce json
+// @Param id path int false "Forum ID"
+// @Security BearerAuth
+// @Success 200 {object} models.ContentFilters
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /filters [get]
+// @Router /forums/{id}/filters [get]
+func GetContentFilters(repo repository.ForumsRepository, filters repository.ContentFiltersRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, _, ok := filterScope(w, r, repo, false)
+		if !ok {
+			return
+		}
+
+		rules, err := filters.GetContentFilters(forumID)
+		if err != nil {
+			log.Error("Failed to load content filters", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load filters")
+			return
+		}
+		json.NewEncoder(w).Encode(rules)
+	}
+}
+
+// AddWordFilter godoc
+// @Summary Add word filter
+// @Description Ban a word or phrase in a forum, or site-wide and in the global chat. A * at the start or end also matches longer words. Messages containing it are refused (block), posted with the word masked (mask) or posted and reported for review (flag). Matching ignores case, accents and lookalike letters. Moderators only; site-wide filters admins only
+// @Tags filters
+// @Accept json
+// @Produce json
+// @Param id path int false "Forum ID"
+// @Param filter body wordFilterRequest true "Pattern and action (block, mask, flag)"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 201 {object} models.WordFilter
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /filters/words [post]
+// @Router /forums/{id}/filters/words [post]
+func AddWordFilter(repo repository.ForumsRepository, filters repository.ContentFiltersRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, user, ok := filterScope(w, r, repo, true)
+		if !ok {
+			return
+		}
+
+		var req wordFilterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		switch req.Action {
+		case models.FilterBlock, models.FilterMask, models.FilterFlag:
+		default:
+			sendError(w, http.StatusBadRequest, "Action must be block, mask or flag")
+			return
+		}
+		pattern, err := filter.CleanPattern(req.Pattern)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		created, err := filters.AddWordFilter(models.WordFilter{
+			ForumID:   forumID,
+			Pattern:   pattern,
+			Action:    req.Action,
+			CreatedBy: user.Username,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrFilterExists):
+				sendError(w, http.StatusConflict, "Filter already exists")
+			case errors.Is(err, repository.ErrNotFound):
+				sendError(w, http.StatusNotFound, "Forum not found")
+			default:
+				log.Error("Failed to add word filter", logger.Error(err))
+				sendError(w, http.StatusInternalServerError, "Failed to add filter")
+			}
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationFilterAdd,
+			TargetType: models.ModerationTargetFilter,
+			TargetID:   created.ID,
+			ForumID:    forumID,
+			Reason:     moderationReason(r),
+		}, nil, created)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// DeleteWordFilter godoc
+// @Summary Delete word filter
+// @Description Remove a banned word from a forum, or a site-wide one. Moderators only; site-wide filters admins only
+// @Tags filters
+// @Produce json
+// @Param id path int false "Forum ID"
+// @Param filter_id path int true "Filter ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /filters/words/{filter_id} [delete]
+// @Router /forums/{id}/filters/words/{filter_id} [delete]
+func DeleteWordFilter(repo repository.ForumsRepository, filters repository.ContentFiltersRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, user, ok := filterScope(w, r, repo, true)
+		if !ok {
+			return
+		}
+		id, err := strconv.Atoi(mux.Vars(r)["filter_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid filter ID")
+			return
+		}
+
+		deleted, err := filters.DeleteWordFilter(forumID, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Filter not found")
+				return
+			}
+			log.Error("Failed to delete word filter", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to delete filter")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationFilterDelete,
+			TargetType: models.ModerationTargetFilter,
+			TargetID:   deleted.ID,
+			ForumID:    forumID,
+			Reason:     moderationReason(r),
+		}, deleted, nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AddLinkRule godoc
+// @Summary Add link rule
+// @Description Allow or deny links to a domain and its subdomains in a forum, or site-wide and in the global chat. Once any allow rule applies, links to domains not allowed are refused too; deny rules win over allow rules and also catch lookalike domains. Moderators only; site-wide rules admins only
+// @Tags filters
+// @Accept json
+// @Produce json
+// @Param id path int false "Forum ID"
+// @Param rule body linkRuleRequest true "Domain and policy (allow, deny)"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 201 {object} models.LinkRule
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /filters/links [post]
+// @Router /forums/{id}/filters/links [post]
+func AddLinkRule(repo repository.ForumsRepository, filters repository.ContentFiltersRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, user, ok := filterScope(w, r, repo, true)
+		if !ok {
+			return
+		}
+
+		var req linkRuleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if req.Policy != models.LinkAllow && req.Policy != models.LinkDeny {
+			sendError(w, http.StatusBadRequest, "Policy must be allow or deny")
+			return
+		}
+		domain, err := filter.CleanDomain(req.Domain)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid domain")
+			return
+		}
+
+		created, err := filters.AddLinkRule(models.LinkRule{
+			ForumID:   forumID,
+			Domain:    domain,
+			Policy:    req.Policy,
+			CreatedBy: user.Username,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrFilterExists):
+				sendError(w, http.StatusConflict, "A rule for this domain already exists")
+			case errors.Is(err, repository.ErrNotFound):
+				sendError(w, http.StatusNotFound, "Forum not found")
+			default:
+				log.Error("Failed to add link rule", logger.Error(err))
+				sendError(w, http.StatusInternalServerError, "Failed to add rule")
+			}
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationFilterAdd,
+			TargetType: models.ModerationTargetLink,
+			TargetID:   created.ID,
+			ForumID:    forumID,
+			Reason:     moderationReason(r),
+		}, nil, created)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// DeleteLinkRule godoc
+// @Summary Delete link rule
+// @Description Remove a link rule from a forum, or a site-wide one. Moderators only; site-wide rules admins only
+// @Tags filters
+// @Produce json
+// @Param id path int false "Forum ID"
+// @Param rule_id path int true "Rule ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /filters/links/{rule_id} [delete]
+// @Router /forums/{id}/filters/links/{rule_id} [delete]
+func DeleteLinkRule(repo repository.ForumsRepository, filters repository.ContentFiltersRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, user, ok := filterScope(w, r, repo, true)
+		if !ok {
+			return
+		}
+		id, err := strconv.Atoi(mux.Vars(r)["rule_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid rule ID")
+			return
+		}
+
+		deleted, err := filters.DeleteLinkRule(forumID, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Rule not found")
+				return
+			}
+			log.Error("Failed to delete link rule", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to delete rule")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationFilterDelete,
+			TargetType: models.ModerationTargetLink,
+			TargetID:   deleted.ID,
+			ForumID:    forumID,
+			Reason:     moderationReason(r),
+		}, deleted, nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
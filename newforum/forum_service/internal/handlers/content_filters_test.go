@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// filterRoutes registers the content filter handlers, which also install
+// filters and reports, and the routes whose content is filtered.
+func filterRoutes(repo *mocks.MockForumsRepo, filters *mocks.MockContentFiltersRepo, reports *mocks.MockReportsRepo) func(*mux.Router) {
+	return func(r *mux.Router) {
+		RegisterContentFilterHandlers(r, repo, filters, reports)
+		r.HandleFunc("/api/forums/{id}/messages", PostMessage(repo)).Methods("POST")
+		r.HandleFunc("/api/forums/{id}/messages/{message_id}", UpdateMessage(repo)).Methods("PUT")
+		r.HandleFunc("/api/global-chat", handleGlobalChatMessage(repo)).Methods("POST")
+	}
+}
+
+func TestManageContentFilters(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockFilters := new(mocks.MockContentFiltersRepo)
+	router := testRouter(t, filterRoutes(mockRepo, mockFilters, new(mocks.MockReportsRepo)))
+
+	forumID := 1
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetByID", 9).Return(nil, repository.ErrNotFound)
+	mockFilters.On("GetContentFilters", &forumID).Return(&models.ContentFilters{}, nil)
+	mockFilters.On("GetContentFilters", (*int)(nil)).Return(&models.ContentFilters{}, nil)
+	mockFilters.On("AddWordFilter", models.WordFilter{ForumID: &forumID, Pattern: "spam*", Action: "block", CreatedBy: "mod"}).
+		Return(&models.WordFilter{ID: 3, ForumID: &forumID, Pattern: "spam*", Action: "block"}, nil)
+	mockFilters.On("AddWordFilter", models.WordFilter{ForumID: &forumID, Pattern: "darn", Action: "mask", CreatedBy: "mod"}).
+		Return(nil, repository.ErrFilterExists)
+	mockFilters.On("DeleteWordFilter", &forumID, 3).Return(&models.WordFilter{ID: 3, ForumID: &forumID}, nil)
+	mockFilters.On("DeleteWordFilter", &forumID, 4).Return(nil, repository.ErrNotFound)
+	mockFilters.On("AddLinkRule", models.LinkRule{ForumID: &forumID, Domain: "example.com", Policy: "allow", CreatedBy: "mod"}).
+		Return(&models.LinkRule{ID: 5, ForumID: &forumID, Domain: "example.com", Policy: "allow"}, nil)
+	mockFilters.On("DeleteLinkRule", &forumID, 5).Return(&models.LinkRule{ID: 5, ForumID: &forumID}, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{"List", "GET", "/api/forums/1/filters", "", http.StatusOK},
+		{"List Site-Wide", "GET", "/api/filters", "", http.StatusOK},
+		{"List Unknown Forum", "GET", "/api/forums/9/filters", "", http.StatusNotFound},
+		{"Add Word", "POST", "/api/forums/1/filters/words", `{"pattern":" Spam* ","action":"block"}`, http.StatusCreated},
+		{"Add Duplicate Word", "POST", "/api/forums/1/filters/words", `{"pattern":"darn","action":"mask"}`, http.StatusConflict},
+		{"Add Word Bad Action", "POST", "/api/forums/1/filters/words", `{"pattern":"darn","action":"ban"}`, http.StatusBadRequest},
+		{"Add Word Bad Wildcard", "POST", "/api/forums/1/filters/words", `{"pattern":"da*rn","action":"mask"}`, http.StatusBadRequest},
+		{"Add Site-Wide Word", "POST", "/api/filters/words", `{"pattern":"darn","action":"mask"}`, http.StatusForbidden},
+		{"Delete Word", "DELETE", "/api/forums/1/filters/words/3", "", http.StatusNoContent},
+		{"Delete Missing Word", "DELETE", "/api/forums/1/filters/words/4", "", http.StatusNotFound},
+		{"Add Link", "POST", "/api/forums/1/filters/links", `{"domain":"https://Example.com/page","policy":"allow"}`, http.StatusCreated},
+		{"Add Link Bad Domain", "POST", "/api/forums/1/filters/links", `{"domain":"localhost","policy":"deny"}`, http.StatusBadRequest},
+		{"Add Link Bad Policy", "POST", "/api/forums/1/filters/links", `{"domain":"example.com","policy":"block"}`, http.StatusBadRequest},
+		{"Delete Link", "DELETE", "/api/forums/1/filters/links/5", "", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, tt.method, tt.url, tt.body))
+			assert.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+		})
+	}
+	mockFilters.AssertExpectations(t)
+}
+
+func TestManageContentFiltersForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockFilters := new(mocks.MockContentFiltersRepo)
+	router := testRouter(t, filterRoutes(mockRepo, mockFilters, new(mocks.MockReportsRepo)))
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+
+	for _, url := range []string{"/api/filters", "/api/forums/1/filters"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "GET", url, ""))
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/forums/1/filters/words", `{"pattern":"darn","action":"mask"}`))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockFilters.AssertNotCalled(t, "AddWordFilter", mock.Anything)
+}
+
+func TestPostMessageContentFilters(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockFilters := new(mocks.MockContentFiltersRepo)
+	mockReports := new(mocks.MockReportsRepo)
+	router := testRouter(t, filterRoutes(mockRepo, mockFilters, mockReports))
+
+	forumID := 1
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockFilters.On("GetContentFilters", &forumID).Return(&models.ContentFilters{
+		Words: []models.WordFilter{
+			{Pattern: "scam", Action: models.FilterBlock},
+			{Pattern: "darn", Action: models.FilterMask},
+			{Pattern: "casino", Action: models.FilterFlag},
+		},
+		Links: []models.LinkRule{{Domain: "evil.com", Policy: models.LinkDeny}},
+	}, nil)
+
+	var saved models.Message
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).
+		Run(func(args mock.Arguments) { saved = args.Get(0).(models.Message) }).
+		Return(12, nil)
+	mockReports.On("CreateReport", models.Report{
+		MessageID: 12,
+		Reporter:  models.FilterReporter,
+		Reason:    models.ReportReasonFilter,
+		Details:   "Matched word filters: casino",
+	}).Return(&models.Report{ID: 1, MessageID: 12, ForumID: 1}, nil)
+
+	post := func(content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"author": "bob", "content": content})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/forums/1/messages", string(body)))
+		return rr
+	}
+
+	rr := post("a ѕсаm offer")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Message contains a banned word")
+
+	rr = post("see https://www.evil.com/x")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Links to www.evil.com are not allowed here")
+	mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything)
+
+	rr = post("darn, the casino")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "****, the casino", saved.Content)
+	var msg models.Message
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+	assert.Equal(t, "****, the casino", msg.Content)
+	mockReports.AssertExpectations(t)
+}
+
+func TestUpdateMessageContentFilters(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockFilters := new(mocks.MockContentFiltersRepo)
+	router := testRouter(t, filterRoutes(mockRepo, mockFilters, new(mocks.MockReportsRepo)))
+
+	forumID := 2
+	message := &models.Message{ID: 7, ForumID: 2, Author: "bob", Content: "hello"}
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 7).Return(message, nil)
+	mockRepo.On("GetByID", 2).Return(&models.Forum{ID: 2}, nil)
+	mockFilters.On("GetContentFilters", &forumID).Return(&models.ContentFilters{Words: []models.WordFilter{
+		{Pattern: "scam", Action: models.FilterBlock},
+		{Pattern: "darn", Action: models.FilterMask},
+	}}, nil)
+	mockRepo.On("PutMessage", 7, "hello ****", "bob").Return(&models.Message{ID: 7, ForumID: 2, Author: "bob", Content: "hello ****"}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/2/messages/7", `{"content":"hello SC4M"}`))
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/2/messages/7", `{"content":"hello darn"}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertNumberOfCalls(t, "PutMessage", 1)
+}
+
+func TestGlobalChatContentFilters(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockFilters := new(mocks.MockContentFiltersRepo)
+	router := testRouter(t, filterRoutes(mockRepo, mockFilters, new(mocks.MockReportsRepo)))
+
+	mockFilters.On("GetContentFilters", (*int)(nil)).Return(&models.ContentFilters{Words: []models.WordFilter{
+		{Pattern: "scam", Action: models.FilterBlock},
+	}}, nil)
+
+	req := httptest.NewRequest("POST", "/api/global-chat", strings.NewReader(`{"username":"bob","text":"total s\u200bcam"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	mockRepo.AssertNotCalled(t, "CreateGlobalMessage", mock.Anything)
+}
+
+func TestServeGlobalChatContentFilters(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockFilters := new(mocks.MockContentFiltersRepo)
+	router := testRouter(t, filterRoutes(mockRepo, mockFilters, new(mocks.MockReportsRepo)))
+	router.HandleFunc("/ws/global", func(w http.ResponseWriter, r *http.Request) {
+		serveGlobalChat(w, r, mockRepo)
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	mockRepo.On("GetGlobalChatHistory", 100).Return([]models.GlobalMessage{}, nil)
+	mockFilters.On("GetContentFilters", (*int)(nil)).Return(&models.ContentFilters{Words: []models.WordFilter{
+		{Pattern: "scam", Action: models.FilterBlock},
+	}}, nil)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws/global", nil)
+	if err != nil {
+		t.Fatalf("could not open a ws connection: %v", err)
+	}
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteJSON(GlobalChatMessage{Author: "bob", Content: "total scam"}))
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var frame struct {
+		Type    string  `json:"type"`
+		Payload wsError `json:"payload"`
+	}
+	if err := ws.ReadJSON(&frame); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "error", frame.Type)
+	assert.Equal(t, wsError{Error: "Message contains a banned word"}, frame.Payload)
+	mockRepo.AssertNotCalled(t, "CreateGlobalMessage", mock.Anything)
+}
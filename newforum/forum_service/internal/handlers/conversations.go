@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+type conversationRequest struct {
+	Participants []string `json:"participants"`
+	Title        string   `json:"title"`
+	Content      string   `json:"content"`
+}
+
+// RegisterConversationHandlers adds private conversations. Their messages
+// are delivered over each participant's notification channel,
+// /ws/notifications, so nobody else ever receives them.
+func RegisterConversationHandlers(r *mux.Router, repo repository.ForumsRepository, convs repository.ConversationsRepository, blocks repository.BlocksRepository) {
+	r.HandleFunc("/conversations", ConversationsPage).Methods("GET")
+
+	r.HandleFunc("/api/conversations", GetConversations(repo, convs)).Methods("GET")
+	r.HandleFunc("/api/conversations", StartConversation(repo, convs, blocks)).Methods("POST")
+	r.HandleFunc("/api/conversations/unread-count", GetDirectUnreadCount(repo, convs)).Methods("GET")
+	r.HandleFunc("/api/conversations/{id:[0-9]+}", GetConversation(repo, convs)).Methods("GET")
+	r.HandleFunc("/api/conversations/{id:[0-9]+}/messages", GetDirectMessages(repo, convs)).Methods("GET")
+	r.HandleFunc("/api/conversations/{id:[0-9]+}/messages", PostDirectMessage(repo, convs, blocks)).Methods("POST")
+	r.HandleFunc("/api/conversations/{id:[0-9]+}/read", MarkConversationRead(repo, convs)).Methods("POST")
+}
+
+// otherParticipants returns the participants of c except username.
+func otherParticipants(c *models.Conversation, username string) []string {
+	others := []string{}
+	for _, p := range c.Participants {
+		if p != username {
+			others = append(others, p)
+		}
+	}
+	return others
+}
+
+// checkNotBlocked fails with 403 if any of recipients blocked sender from
+// messaging them.
+func checkNotBlocked(w http.ResponseWriter, blocks repository.BlocksRepository, sender string, recipients []string) bool {
+	blockers, err := blocks.GetBlockers(sender, recipients)
+	if err != nil {
+		log.Error("Failed to check blocks", logger.Error(err), logger.String("username", sender))
+		sendError(w, http.StatusInternalServerError, "Failed to send message")
+		return false
+	}
+	if len(blockers) > 0 {
+		sendError(w, http.StatusForbidden, fmt.Sprintf("%s does not accept private messages from you", strings.Join(blockers, ", ")))
+		return false
+	}
+	return true
+}
+
+// sendDirectMessage stores a message in c and delivers it to every
+// participant's open notification channels, the author's other tabs
+// included. Delivery goes through sendToUser, which serialises writes to
+// each connection.
+func sendDirectMessage(convs repository.ConversationsRepository, c *models.Conversation, author, content string) (*models.DirectMessage, error) {
+	msg, err := convs.AddConversationMessage(models.DirectMessage{
+		ConversationID: c.ID,
+		Author:         author,
+		Content:        content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	event := WSMessage{Type: "direct_message", Payload: msg}
+	go func() {
+		for _, p := range c.Participants {
+			sendToUser(p, event)
+		}
+	}()
+	return msg, nil
+}
+
+// participantConversation loads the conversation in the request path for
+// user. Conversations user does not take part in are reported as not found.
+func participantConversation(w http.ResponseWriter, r *http.Request, convs repository.ConversationsRepository, user *models.User) (*models.Conversation, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid conversation ID")
+		return nil, false
+	}
+	c, err := convs.GetConversation(id, user.Username)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			sendError(w, http.StatusNotFound, "Conversation not found")
+			return nil, false
+		}
+		log.Error("Failed to load conversation", logger.Error(err), logger.Int("conversationID", id))
+		sendError(w, http.StatusInternalServerError, "Failed to load conversation")
+		return nil, false
+	}
+	return c, true
+}
+
+func ConversationsPage(w http.ResponseWriter, r *http.Request) {
+	renderTemplate(w, "conversations.html", nil)
+}
+
+// GetConversations godoc
+// @Summary List conversations
+// @Description Get the current user's private conversations, most recently active first, with their unread counts
+// @Tags conversations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Conversation
+// @Failure 401 {object} map[string]string
+// @Router /conversations [get]
+func GetConversations(repo repository.ForumsRepository, convs repository.ConversationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		conversations, err := convs.GetConversations(user.Username)
+		if err != nil {
+			log.Error("Failed to load conversations", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to load conversations")
+			return
+		}
+		json.NewEncoder(w).Encode(conversations)
+	}
+}
+
+// StartConversation godoc
+// @Summary Start conversation
+// @Description Start a private conversation with one or more users, optionally with a first message. Starting a conversation without a title with a single user reuses the conversation the two already have
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Param conversation body conversationRequest true "Participants, title and first message"
+// @Security BearerAuth
+// @Success 200 {object} models.Conversation
+// @Success 201 {object} models.Conversation
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /conversations [post]
+func StartConversation(repo repository.ForumsRepository, convs repository.ConversationsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req conversationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+
+		seen := map[string]bool{user.Username: true}
+		names := []string{}
+		for _, name := range req.Participants {
+			name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			sendError(w, http.StatusBadRequest, "At least one other participant is required")
+			return
+		}
+		if len(names)+1 > models.MaxConversationParticipants {
+			sendError(w, http.StatusBadRequest,
+				fmt.Sprintf("A conversation can have at most %d participants", models.MaxConversationParticipants))
+			return
+		}
+
+		known, err := repo.ResolveUsernames(names)
+		if err != nil {
+			log.Error("Failed to resolve participants", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to start conversation")
+			return
+		}
+		if len(known) != len(names) {
+			for _, name := range names {
+				if !slices.Contains(known, name) {
+					sendError(w, http.StatusNotFound, "User not found: "+name)
+					return
+				}
+			}
+		}
+		if !checkNotBlocked(w, blocks, user.Username, names) {
+			return
+		}
+
+		title := strings.TrimSpace(req.Title)
+		status := http.StatusCreated
+		var c *models.Conversation
+		if len(names) == 1 && title == "" {
+			c, err = convs.FindDirectConversation(user.Username, names[0])
+			if err == nil {
+				status = http.StatusOK
+			} else if !errors.Is(err, repository.ErrNotFound) {
+				log.Error("Failed to load conversation", logger.Error(err), logger.String("username", user.Username))
+				sendError(w, http.StatusInternalServerError, "Failed to start conversation")
+				return
+			}
+		}
+		if c == nil {
+			c, err = convs.CreateConversation(user.Username, title, append([]string{user.Username}, names...))
+			if err != nil {
+				log.Error("Failed to create conversation", logger.Error(err), logger.String("username", user.Username))
+				sendError(w, http.StatusInternalServerError, "Failed to start conversation")
+				return
+			}
+		}
+
+		if content := strings.TrimSpace(req.Content); content != "" {
+			msg, err := sendDirectMessage(convs, c, user.Username, content)
+			if err != nil {
+				log.Error("Failed to send message", logger.Error(err), logger.Int("conversationID", c.ID))
+				sendError(w, http.StatusInternalServerError, "Failed to send message")
+				return
+			}
+			c.LastMessage = msg
+			c.LastReadMessageID = msg.ID
+		}
+
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(c)
+	}
+}
+
+// GetConversation godoc
+// @Summary Get conversation
+// @Description Get one of the current user's private conversations
+// @Tags conversations
+// @Produce json
+// @Param id path int true "Conversation ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Conversation
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /conversations/{id} [get]
+func GetConversation(repo repository.ForumsRepository, convs repository.ConversationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		c, ok := participantConversation(w, r, convs, user)
+		if !ok {
+			return
+		}
+		json.NewEncoder(w).Encode(c)
+	}
+}
+
+// GetDirectMessages godoc
+// @Summary List conversation messages
+// @Description Get a page of messages of one of the current user's private conversations, oldest first. Without a cursor the newest messages are returned
+// @Tags conversations
+// @Produce json
+// @Param id path int true "Conversation ID"
+// @Param before query string false "Cursor to read older messages"
+// @Param after query string false "Cursor to read newer messages"
+// @Param limit query int false "Page size (max 100)"
+// @Security BearerAuth
+// @Success 200 {object} models.DirectMessagePage
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /conversations/{id}/messages [get]
+func GetDirectMessages(repo repository.ForumsRepository, convs repository.ConversationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		c, ok := participantConversation(w, r, convs, user)
+		if !ok {
+			return
+		}
+
+		result, err := convs.GetConversationMessages(c.ID, page)
+		if err != nil {
+			log.Error("Failed to load conversation messages", logger.Error(err), logger.Int("conversationID", c.ID))
+			sendError(w, http.StatusInternalServerError, "Failed to load messages")
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// PostDirectMessage godoc
+// @Summary Send private message
+// @Description Send a message to one of the current user's private conversations. Fails if another participant blocked the sender
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Param id path int true "Conversation ID"
+// @Param message body map[string]string true "Message content"
+// @Security BearerAuth
+// @Success 201 {object} models.DirectMessage
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /conversations/{id}/messages [post]
+func PostDirectMessage(repo repository.ForumsRepository, convs repository.ConversationsRepository, blocks repository.BlocksRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		content := strings.TrimSpace(req.Content)
+		if content == "" {
+			sendError(w, http.StatusBadRequest, "Content is required")
+			return
+		}
+
+		c, ok := participantConversation(w, r, convs, user)
+		if !ok {
+			return
+		}
+		if !checkNotBlocked(w, blocks, user.Username, otherParticipants(c, user.Username)) {
+			return
+		}
+
+		msg, err := sendDirectMessage(convs, c, user.Username, content)
+		if err != nil {
+			log.Error("Failed to send message", logger.Error(err), logger.Int("conversationID", c.ID))
+			sendError(w, http.StatusInternalServerError, "Failed to send message")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(msg)
+	}
+}
+
+// MarkConversationRead godoc
+// @Summary Mark conversation read
+// @Description Mark one of the current user's private conversations read up to a message. The position never moves back
+// @Tags conversations
+// @Accept json
+// @Produce json
+// @Param id path int true "Conversation ID"
+// @Param position body readRequest true "Last message read"
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /conversations/{id}/read [post]
+func MarkConversationRead(repo repository.ForumsRepository, convs repository.ConversationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid conversation ID")
+			return
+		}
+		var req readRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if req.MessageID <= 0 {
+			sendError(w, http.StatusBadRequest, "message_id is required")
+			return
+		}
+
+		lastRead, err := convs.MarkConversationRead(id, user.Username, req.MessageID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Conversation not found")
+				return
+			}
+			log.Error("Failed to mark conversation read", logger.Error(err), logger.Int("conversationID", id))
+			sendError(w, http.StatusInternalServerError, "Failed to mark conversation read")
+			return
+		}
+
+		result := map[string]int{"conversation_id": id, "last_read_message_id": lastRead}
+		go sendToUser(user.Username, WSMessage{Type: "conversation_read", Payload: result})
+
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// GetDirectUnreadCount godoc
+// @Summary Unread private messages
+// @Description Get how many private messages the current user has not read
+// @Tags conversations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Failure 401 {object} map[string]string
+// @Router /conversations/unread-count [get]
+func GetDirectUnreadCount(repo repository.ForumsRepository, convs repository.ConversationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		count, err := convs.CountUnreadDirectMessages(user.Username)
+		if err != nil {
+			log.Error("Failed to count private messages", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to count private messages")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"unread": count})
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestConversationOnlyParticipantsCanRead(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockConvs := new(mocks.MockConversationsRepo)
+	mockBlocks := new(mocks.MockBlocksRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterConversationHandlers(r, mockRepo, mockConvs, mockBlocks) })
+
+	// User 1 is mallory, who is not part of conversation 5 between alice
+	// and bob.
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mallory"}, nil)
+	mockConvs.On("GetConversation", 5, "mallory").Return(nil, repository.ErrNotFound)
+	mockConvs.On("MarkConversationRead", 5, "mallory", 3).Return(0, repository.ErrNotFound)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+	}{
+		{name: "Conversation", method: "GET", url: "/api/conversations/5"},
+		{name: "Messages", method: "GET", url: "/api/conversations/5/messages"},
+		{name: "Post Message", method: "POST", url: "/api/conversations/5/messages", body: `{"content":"hi"}`},
+		{name: "Mark Read", method: "POST", url: "/api/conversations/5/read", body: `{"message_id":3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, tt.method, tt.url, tt.body))
+
+			assert.Equal(t, http.StatusNotFound, rr.Code)
+			assert.NotContains(t, rr.Body.String(), "alice")
+		})
+	}
+
+	t.Run("Anonymous", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/conversations/5/messages", nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	mockConvs.AssertNotCalled(t, "GetConversationMessages", mock.Anything, mock.Anything)
+	mockConvs.AssertNotCalled(t, "AddConversationMessage", mock.Anything)
+}
+
+func TestDirectMessageDeliveredOnlyToParticipants(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockConvs := new(mocks.MockConversationsRepo)
+	conversation := &models.Conversation{ID: 5, Participants: []string{"alice", "bob"}}
+	mockConvs.On("AddConversationMessage", mock.AnythingOfType("models.DirectMessage")).Return(&models.DirectMessage{
+		ID: 9, ConversationID: 5, Author: "alice", Content: "secret",
+	}, nil)
+
+	// The notification channel of user 1 belongs to mallory, who must not
+	// receive the message.
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mallory"}, nil).Once()
+	outsider := dialNotifications(t, mockRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	participant := dialNotifications(t, mockRepo)
+
+	_, err := sendDirectMessage(mockConvs, conversation, "alice", "secret")
+	assert.NoError(t, err)
+
+	participant.SetReadDeadline(time.Now().Add(time.Second))
+	var event struct {
+		Type    string               `json:"type"`
+		Payload models.DirectMessage `json:"payload"`
+	}
+	if err := participant.ReadJSON(&event); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "direct_message", event.Type)
+	assert.Equal(t, "secret", event.Payload.Content)
+
+	outsider.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	_, _, err = outsider.ReadMessage()
+	assert.Error(t, err, "outsider received a private message")
+}
+
+func TestDirectMessagesInQuickSuccession(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockConvs := new(mocks.MockConversationsRepo)
+	conversation := &models.Conversation{ID: 5, Participants: []string{"alice", "bob"}}
+	mockConvs.On("AddConversationMessage", mock.AnythingOfType("models.DirectMessage")).Return(&models.DirectMessage{
+		ID: 9, ConversationID: 5, Author: "alice", Content: "hi",
+	}, nil)
+
+	// bob has two tabs open; both messages reach both of them.
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	tabs := []*websocket.Conn{dialNotifications(t, mockRepo), dialNotifications(t, mockRepo)}
+
+	for i := 0; i < 2; i++ {
+		_, err := sendDirectMessage(mockConvs, conversation, "alice", "hi")
+		assert.NoError(t, err)
+	}
+
+	for _, tab := range tabs {
+		tab.SetReadDeadline(time.Now().Add(time.Second))
+		for i := 0; i < 2; i++ {
+			var event WSMessage
+			if err := tab.ReadJSON(&event); err != nil {
+				t.Fatalf("could not read message %d: %v", i, err)
+			}
+			assert.Equal(t, "direct_message", event.Type)
+		}
+	}
+}
+
+func TestStartConversation(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockConvs := new(mocks.MockConversationsRepo)
+	mockBlocks := new(mocks.MockBlocksRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterConversationHandlers(r, mockRepo, mockConvs, mockBlocks) })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"bob"}).Return([]string{"bob"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"carol"}).Return([]string{"carol"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"bob", "carol"}).Return([]string{"bob", "carol"}, nil)
+	mockRepo.On("ResolveUsernames", []string{"ghost"}).Return([]string{}, nil)
+	mockBlocks.On("GetBlockers", "alice", []string{"bob"}).Return([]string{}, nil)
+	mockBlocks.On("GetBlockers", "alice", []string{"bob", "carol"}).Return([]string{}, nil)
+	mockBlocks.On("GetBlockers", "alice", []string{"carol"}).Return([]string{"carol"}, nil)
+
+	existing := &models.Conversation{ID: 3, CreatedBy: "bob", Participants: []string{"alice", "bob"}}
+	mockConvs.On("FindDirectConversation", "alice", "bob").Return(existing, nil)
+	mockConvs.On("CreateConversation", "alice", "Team", []string{"alice", "bob", "carol"}).Return(&models.Conversation{
+		ID: 4, Title: "Team", CreatedBy: "alice", Participants: []string{"alice", "bob", "carol"},
+	}, nil)
+	mockConvs.On("AddConversationMessage", models.DirectMessage{ConversationID: 3, Author: "alice", Content: "hello"}).
+		Return(&models.DirectMessage{ID: 7, ConversationID: 3, Author: "alice", Content: "hello"}, nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantID     int
+	}{
+		{name: "Reuses Direct Conversation", body: `{"participants":["@bob","alice"],"content":" hello "}`, wantStatus: http.StatusOK, wantID: 3},
+		{name: "Group", body: `{"participants":["bob","carol","bob"],"title":"Team"}`, wantStatus: http.StatusCreated, wantID: 4},
+		{name: "No Participants", body: `{"participants":["alice"]}`, wantStatus: http.StatusBadRequest},
+		{name: "Unknown User", body: `{"participants":["ghost"]}`, wantStatus: http.StatusNotFound},
+		{name: "Blocked", body: `{"participants":["carol"]}`, wantStatus: http.StatusForbidden},
+		{name: "Too Many", body: `{"participants":["a","b","c","d","e","f","g","h","i","j"]}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/conversations", tt.body))
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantID != 0 {
+				var c models.Conversation
+				assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &c))
+				assert.Equal(t, tt.wantID, c.ID)
+			}
+		})
+	}
+	mockConvs.AssertNumberOfCalls(t, "CreateConversation", 1)
+	mockConvs.AssertNumberOfCalls(t, "AddConversationMessage", 1)
+}
+
+func TestPostDirectMessage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockConvs := new(mocks.MockConversationsRepo)
+	mockBlocks := new(mocks.MockBlocksRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterConversationHandlers(r, mockRepo, mockConvs, mockBlocks) })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice"}, nil)
+	mockConvs.On("GetConversation", 3, "alice").Return(&models.Conversation{ID: 3, Participants: []string{"alice", "bob"}}, nil)
+	mockConvs.On("GetConversation", 4, "alice").Return(&models.Conversation{ID: 4, Participants: []string{"alice", "carol"}}, nil)
+	mockBlocks.On("GetBlockers", "alice", []string{"bob"}).Return([]string{}, nil)
+	mockBlocks.On("GetBlockers", "alice", []string{"carol"}).Return([]string{"carol"}, nil)
+	mockConvs.On("AddConversationMessage", models.DirectMessage{ConversationID: 3, Author: "alice", Content: "hi"}).
+		Return(&models.DirectMessage{ID: 8, ConversationID: 3, Author: "alice", Content: "hi"}, nil)
+
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{name: "Success", url: "/api/conversations/3/messages", body: `{"content":"hi"}`, wantStatus: http.StatusCreated},
+		{name: "Empty", url: "/api/conversations/3/messages", body: `{"content":"  "}`, wantStatus: http.StatusBadRequest},
+		{name: "Blocked", url: "/api/conversations/4/messages", body: `{"content":"hi"}`, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "POST", tt.url, tt.body))
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+	mockConvs.AssertNumberOfCalls(t, "AddConversationMessage", 1)
+}
+
+func TestGetDirectMessages(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockConvs := new(mocks.MockConversationsRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterConversationHandlers(r, mockRepo, mockConvs, new(mocks.MockBlocksRepo)) })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockConvs.On("GetConversation", 3, "bob").Return(&models.Conversation{ID: 3, Participants: []string{"alice", "bob"}}, nil)
+	mockConvs.On("GetConversationMessages", 3, models.PageRequest{Limit: 20}).Return(&models.DirectMessagePage{
+		Messages: []models.DirectMessage{{ID: 1, ConversationID: 3, Author: "alice", Content: "hello"}},
+	}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/conversations/3/messages?limit=20", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"content":"hello"`)
+}
+
+func TestMarkConversationRead(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockConvs := new(mocks.MockConversationsRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterConversationHandlers(r, mockRepo, mockConvs, new(mocks.MockBlocksRepo)) })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockConvs.On("MarkConversationRead", 3, "bob", 7).Return(9, nil)
+	mockConvs.On("CountUnreadDirectMessages", "bob").Return(2, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/conversations/3/read", `{"message_id":7}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"conversation_id":3,"last_read_message_id":9}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/conversations/3/read", `{}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/conversations/unread-count", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"unread":2}`, rr.Body.String())
+}
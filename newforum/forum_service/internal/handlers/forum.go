@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
@@ -15,6 +16,7 @@ import (
 	"github.com/jaxxiy/newforum/core/logger"
 	"github.com/jaxxiy/newforum/core/pkg/jwt"
 	"github.com/jaxxiy/newforum/forum_service/internal/grpc"
+	"github.com/jaxxiy/newforum/forum_service/internal/markdown"
 	"github.com/jaxxiy/newforum/forum_service/internal/models"
 	"github.com/jaxxiy/newforum/forum_service/internal/repository"
 )
@@ -28,7 +30,10 @@ var (
 			return true
 		},
 	}
-	clients   = make(map[int]map[*websocket.Conn]bool)
+	// clients holds the forum channels, keyed by forum ID. Like the topic
+	// and moderation channels, each connection has a mutex that is held
+	// while writing to it, since broadcasts run in their own goroutines.
+	clients   = make(map[int]map[*websocket.Conn]*sync.Mutex)
 	clientsMu sync.RWMutex
 
 	//mini-chat
@@ -57,6 +62,12 @@ func init() {
 	}
 }
 
+// maxContentLength is the longest message content accepted, in bytes, the
+// same limit ForumService enforces.
+const maxContentLength = 5000
+
+var errContentTooLong = fmt.Sprintf("Content is longer than %d bytes", maxContentLength)
+
 type GlobalChatMessageRequest struct {
 	Author  string `json:"username"`
 	Content string `json:"text"`
@@ -84,6 +95,7 @@ func RegisterForumHandlers(r *mux.Router, repo repository.ForumsRepository) {
 	r.HandleFunc("/ws/{forum_id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
 		serveWebSocket(w, r)
 	})
+	r.HandleFunc("/ws/{forum_id:[0-9]+}/topics/{topic_id:[0-9]+}", serveTopicWebSocket(repo))
 	go handleGlobalChatMessages()
 
 	api := r.PathPrefix("/api").Subrouter()
@@ -92,21 +104,48 @@ func RegisterForumHandlers(r *mux.Router, repo repository.ForumsRepository) {
 	r.HandleFunc("/auth/register", RegisterPage).Methods("GET")
 
 	api.HandleFunc("/forums", ListForums(repo)).Methods("GET")
+	api.HandleFunc("/forums-list", GetAllForums(repo)).Methods("GET")
 	api.HandleFunc("/forums/new", NewForumForm()).Methods("GET")
 	api.HandleFunc("/forums", CreateForum(repo)).Methods("POST")
 	api.HandleFunc("/forums/{id:[0-9]+}", GetForum(repo)).Methods("GET")
 	api.HandleFunc("/forums/{id:[0-9]+}", UpdateForum(repo)).Methods("PUT")
 	api.HandleFunc("/forums/{id:[0-9]+}", DeleteForum(repo)).Methods("DELETE")
+	api.HandleFunc("/forums/{id:[0-9]+}/state", SetForumState(repo)).Methods("PUT")
+	api.HandleFunc("/forums/order", MoveForums(repo)).Methods("PUT")
+	api.HandleFunc("/forums-tree", GetForumTree(repo)).Methods("GET")
+
+	api.HandleFunc("/categories", ListCategories(repo)).Methods("GET")
+	api.HandleFunc("/categories", CreateCategory(repo)).Methods("POST")
+	api.HandleFunc("/categories/order", ReorderCategories(repo)).Methods("PUT")
+	api.HandleFunc("/categories/{id:[0-9]+}", UpdateCategory(repo)).Methods("PUT")
+	api.HandleFunc("/categories/{id:[0-9]+}", DeleteCategory(repo)).Methods("DELETE")
 
 	api.HandleFunc("/forums/{id:[0-9]+}/messages", GetMessages(repo)).Methods("GET")
 	api.HandleFunc("/forums/{id:[0-9]+}/messages", PostMessage(repo)).Methods("POST")
 	api.HandleFunc("/forums/{forum_id:[0-9]+}/messages/{message_id:[0-9]+}", DeleteMessage(repo)).Methods("DELETE")
 	api.HandleFunc("/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}", UpdateMessage(repo)).Methods("PUT")
+	api.HandleFunc("/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/thread", GetMessageThread(repo)).Methods("GET")
+	api.HandleFunc("/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/revisions", GetMessageRevisions(repo)).Methods("GET")
 
 	api.HandleFunc("/global-chat", handleGlobalChatMessage(repo)).Methods("POST")
 
 	api.HandleFunc("/forums/{id:[0-9]+}/messages-list", GetMessagesAPI(repo)).Methods("GET")
 
+	api.HandleFunc("/forums/{id:[0-9]+}/topics", ListTopics(repo)).Methods("GET")
+	api.HandleFunc("/forums/{id:[0-9]+}/topics", CreateTopic(repo)).Methods("POST")
+	api.HandleFunc("/forums/{id:[0-9]+}/topics-list", GetTopicsAPI(repo)).Methods("GET")
+	api.HandleFunc("/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}", UpdateTopic(repo)).Methods("PUT")
+	api.HandleFunc("/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}", DeleteTopic(repo)).Methods("DELETE")
+	api.HandleFunc("/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}/messages", GetMessages(repo)).Methods("GET")
+	api.HandleFunc("/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}/messages", PostMessage(repo)).Methods("POST")
+	api.HandleFunc("/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}/messages-list", GetMessagesAPI(repo)).Methods("GET")
+
+	api.HandleFunc("/trash/forums", GetDeletedForums(repo)).Methods("GET")
+	api.HandleFunc("/trash/forums/{id:[0-9]+}/restore", RestoreForum(repo)).Methods("POST")
+	api.HandleFunc("/trash/topics", GetDeletedTopics(repo)).Methods("GET")
+	api.HandleFunc("/trash/topics/{topic_id:[0-9]+}/restore", RestoreTopic(repo)).Methods("POST")
+	api.HandleFunc("/trash/messages", GetDeletedMessages(repo)).Methods("GET")
+	api.HandleFunc("/trash/messages/{message_id:[0-9]+}/restore", RestoreMessage(repo)).Methods("POST")
 }
 
 func LoginPage(w http.ResponseWriter, r *http.Request) {
@@ -132,10 +171,12 @@ func serveWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer func() {
 		unregisterClient(forumID, conn)
+		forgetViewer(conn)
 		conn.Close()
 	}()
 
 	registerClient(forumID, conn)
+	watchViewer(conn, r)
 
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -158,8 +199,8 @@ func broadcastToForum(forumID int, message WSMessage) {
 	defer clientsMu.RUnlock()
 
 	if conns, ok := clients[forumID]; ok {
-		for conn := range conns {
-			if err := conn.WriteJSON(message); err != nil {
+		for conn, mu := range conns {
+			if err := writeJSON(conn, mu, forViewer(conn, message)); err != nil {
 				log.Error("WS send error",
 					logger.Error(err),
 					logger.Int("forumID", forumID))
@@ -169,6 +210,13 @@ func broadcastToForum(forumID int, message WSMessage) {
 	}
 }
 
+// writeJSON sends v on conn while holding mu, the connection's write lock.
+func writeJSON(conn *websocket.Conn, mu *sync.Mutex, v interface{}) error {
+	mu.Lock()
+	defer mu.Unlock()
+	return conn.WriteJSON(v)
+}
+
 func handleFailedConnection(forumID int, conn *websocket.Conn) {
 	clientsMu.Lock()
 	defer clientsMu.Unlock()
@@ -185,9 +233,9 @@ func registerClient(forumID int, conn *websocket.Conn) {
 	defer clientsMu.Unlock()
 
 	if clients[forumID] == nil {
-		clients[forumID] = make(map[*websocket.Conn]bool)
+		clients[forumID] = make(map[*websocket.Conn]*sync.Mutex)
 	}
-	clients[forumID][conn] = true
+	clients[forumID][conn] = &sync.Mutex{}
 	log.Info("New client connected",
 		logger.Int("forumID", forumID),
 		logger.Int("totalClients", len(clients[forumID])))
@@ -213,22 +261,33 @@ func PostMessage(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
-		if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
-			sendError(w, http.StatusBadRequest, "Content-Type must be application/json")
+		// Messages with attachments are posted as multipart/form-data.
+		var req messageRequest
+		var files []*multipart.FileHeader
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case strings.Contains(contentType, "application/json"):
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				sendError(w, http.StatusBadRequest, "Invalid JSON format")
+				return
+			}
+		case strings.HasPrefix(contentType, "multipart/form-data"):
+			if files, err = readMultipartMessage(w, r, &req); err != nil {
+				sendError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		default:
+			sendError(w, http.StatusBadRequest, "Content-Type must be application/json or multipart/form-data")
 			return
 		}
 
-		var req struct {
-			Author  string `json:"author"`
-			Content string `json:"content"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		// A message may be just files.
+		if strings.TrimSpace(req.Author) == "" || (strings.TrimSpace(req.Content) == "" && len(files) == 0) {
+			sendError(w, http.StatusBadRequest, "Author and content are required")
 			return
 		}
-
-		if strings.TrimSpace(req.Author) == "" || strings.TrimSpace(req.Content) == "" {
-			sendError(w, http.StatusBadRequest, "Author and content are required")
+		if len(req.Content) > maxContentLength {
+			sendError(w, http.StatusBadRequest, errContentTooLong)
 			return
 		}
 
@@ -252,12 +311,66 @@ func PostMessage(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
+		forum := writableForum(w, repo, forumID, user)
+		if forum == nil {
+			return
+		}
+
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Topic not found")
+			return
+		}
+
 		msg := models.Message{
 			ForumID:   forumID,
+			ReplyTo:   req.ReplyTo,
+			Quote:     req.Quote,
 			Author:    req.Author,
 			Content:   req.Content,
 			CreatedAt: time.Now(),
 		}
+		if topic != nil {
+			msg.TopicID = &topic.ID
+		}
+
+		var parent *models.Message
+		if req.ReplyTo != nil {
+			parent, err = repo.GetMessageByID(*req.ReplyTo)
+			if err != nil {
+				sendError(w, http.StatusBadRequest, "Parent message not found")
+				return
+			}
+		}
+		if err := validateReply(msg, parent); err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		filtered, ok := filterMessage(w, &forumID, msg.Content)
+		if !ok {
+			return
+		}
+		msg.Content = filtered.Content
+
+		if !checkSlowMode(w, forum, user) {
+			return
+		}
+
+		attachments, err := checkUploads(files, user.Username)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := storeUploads(r.Context(), files, attachments); err != nil {
+			log.Error("Failed to store attachments", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to save attachments")
+			return
+		}
+
+		if holdSpam(w, user, msg, attachments) {
+			return
+		}
 
 		fmt.Println(msg.CreatedAt)
 
@@ -268,17 +381,76 @@ func PostMessage(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 		msg.ID = id
-
-		go broadcastToForum(forumID, WSMessage{
-			Type:    "message_created",
-			Payload: msg,
-		})
+		// The repository stores the same rendering with the message.
+		msg.ContentHTML = markdown.Render(msg.Content)
+		if len(attachments) > 0 {
+			if msg.Attachments, err = attachmentStore.AddAttachments(id, attachments); err != nil {
+				log.Error("Failed to save attachments", logger.Error(err), logger.Int("messageID", id))
+				sendError(w, http.StatusInternalServerError, "Failed to save attachments")
+				return
+			}
+			signAttachments(msg.Attachments)
+		}
+		flagMessage(&msg, filtered)
+		publishMessage(repo, &msg, parent)
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(msg)
 	}
 }
 
+// publishMessage tells everyone concerned about a message that has just
+// been posted: the author it replies to, the users it mentions, the
+// forum's subscribers and whoever is watching its topic or forum.
+func publishMessage(repo repository.ForumsRepository, msg *models.Message, parent *models.Message) {
+	notifyReply(msg, parent)
+	updateMentions(repo, msg, "")
+	notified := append([]string{}, msg.Mentions...)
+	if parent != nil {
+		notified = append(notified, parent.Author)
+	}
+	go notifySubscribers(*msg, notified)
+
+	event := WSMessage{
+		Type:    "message_created",
+		Payload: messageEvent{Message: *msg, Parent: parent},
+	}
+	if msg.TopicID != nil {
+		go broadcastToTopic(*msg.TopicID, event)
+	} else {
+		go broadcastToForum(msg.ForumID, event)
+	}
+}
+
+// messageRequest is a message as posted, in JSON or as form fields.
+type messageRequest struct {
+	Author  string `json:"author"`
+	Content string `json:"content"`
+	ReplyTo *int   `json:"reply_to"`
+	Quote   string `json:"quote"`
+}
+
+// requestUser returns the user identified by the request's Bearer token, or nil.
+func requestUser(r *http.Request, repo repository.ForumsRepository) *models.User {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil
+	}
+	return tokenUser(strings.TrimPrefix(authHeader, "Bearer "), repo)
+}
+
+func tokenUser(tokenString string, repo repository.ForumsRepository) *models.User {
+	claims, err := jwt.ParseToken(tokenString, "your-secret-key")
+	if err != nil {
+		return nil
+	}
+	user, err := repo.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
 func sendError(w http.ResponseWriter, status int, message string) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
@@ -289,8 +461,8 @@ func sendWSMessage(forumID int, message WSMessage) {
 	defer clientsMu.RUnlock()
 
 	if conns, ok := clients[forumID]; ok {
-		for conn := range conns {
-			if err := conn.WriteJSON(message); err != nil {
+		for conn, mu := range conns {
+			if err := writeJSON(conn, mu, forViewer(conn, message)); err != nil {
 				log.Error("WebSocket send error", logger.Error(err))
 				go handleFailedConnection(forumID, conn)
 			}
@@ -300,20 +472,32 @@ func sendWSMessage(forumID int, message WSMessage) {
 
 // ListForums godoc
 // @Summary Get all forums
-// @Description Get a list of all forums
+// @Description Render the forum tree: pinned forums first, then categories with their forums and subforums. With a tag, render the forums and topics carrying it instead
 // @Tags forums
-// @Produce json
-// @Success 200 {array} models.Forum
+// @Produce html
+// @Param tag query string false "Only show forums and topics with this tag"
+// @Success 200 {string} string "HTML page"
 // @Router /forums [get]
 func ListForums(repo repository.ForumsRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		forums, err := repo.GetAll()
+		if tag := r.URL.Query().Get("tag"); tag != "" {
+			renderTagPage(w, r, repo, tag)
+			return
+		}
+
+		tree, err := repo.GetForumTree()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if err := attachUnreadTree(repo, requestUser(r, repo), tree); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		renderTemplate(w, "list_forums.html", map[string]interface{}{
-			"Forums": forums,
+			"Pinned":        pinnedNodes(tree),
+			"Categories":    tree.Categories,
+			"Uncategorized": tree.Uncategorized,
 		})
 	}
 }
@@ -326,7 +510,7 @@ func NewForumForm() http.HandlerFunc {
 
 // CreateForum godoc
 // @Summary Create a new forum
-// @Description Create a new forum with title and description
+// @Description Create a new forum with title, description and an optional comma separated list of tags
 // @Tags forums
 // @Accept json
 // @Produce json
@@ -340,6 +524,12 @@ func CreateForum(repo repository.ForumsRepository) http.HandlerFunc {
 		title := r.FormValue("title")
 		description := r.FormValue("description")
 
+		tags, err := models.NormalizeTags(splitTags(r.FormValue("tags")))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		forum := models.Forum{
 			Title:       title,
 			Description: description,
@@ -353,6 +543,12 @@ func CreateForum(repo repository.ForumsRepository) http.HandlerFunc {
 		}
 		forum.ID = id
 
+		if len(tags) > 0 {
+			if err := repo.SetForumTags(id, tags); err != nil {
+				log.Error("Failed to tag new forum", logger.Error(err), logger.Int("forumID", id))
+			}
+		}
+
 		sendWSMessage(id, WSMessage{
 			Type: "forum_created",
 			Payload: map[string]interface{}{
@@ -396,27 +592,63 @@ func GetForum(repo repository.ForumsRepository) http.HandlerFunc {
 	}
 }
 
+// GetAllForums godoc
+// @Summary Get forums page
+// @Description Get a page of forums, oldest first. The first page also lists pinned forums. With a tag, only forums carrying it are listed, pinned ones included. Signed-in users also get their unread counts
+// @Tags forums
+// @Produce json
+// @Param tag query string false "Only list forums with this tag"
+// @Param before query string false "Cursor to read forums before"
+// @Param after query string false "Cursor to read forums after"
+// @Param limit query int false "Page size (max 100)"
+// @Success 200 {object} models.ForumPage
+// @Failure 400 {object} map[string]string
+// @Router /forums-list [get]
 func GetAllForums(repo repository.ForumsRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		forums, err := repo.GetAll()
+		pageReq, err := parsePageRequest(r)
 		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var page *models.ForumPage
+		if tag := r.URL.Query().Get("tag"); tag != "" {
+			name, err := models.NormalizeTag(tag)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			page, err = repo.GetTaggedForumsPage(name, pageReq)
+		} else {
+			page, err = loadForumsPage(repo, pageReq)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := attachUnread(repo, requestUser(r, repo), page.Pinned, page.Forums); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(forums)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
 	}
 }
 
 // UpdateForum godoc
 // @Summary Update forum
-// @Description Update forum details
+// @Description Update forum details. Moderators only
 // @Tags forums
 // @Accept json
 // @Produce json
 // @Param id path int true "Forum ID"
 // @Param forum body models.Forum true "Forum info"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
 // @Success 200 {object} models.Forum
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /forums/{id} [put]
 func UpdateForum(repo repository.ForumsRepository) http.HandlerFunc {
@@ -430,21 +662,45 @@ func UpdateForum(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		var before *models.Forum
+		if moderationLog != nil {
+			before, _ = repo.GetByID(id)
+		}
+
 		if err := repo.Update(id, forum); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		forum.ID = id
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationForumUpdate,
+			TargetType: models.ModerationTargetForum,
+			TargetID:   id,
+			ForumID:    &id,
+			Reason:     moderationReason(r),
+		}, before, forum)
+
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
 // DeleteForum godoc
 // @Summary Delete forum
-// @Description Delete forum by ID
+// @Description Move a forum to the trash. It can be restored until the purge job removes it. Moderators only
 // @Tags forums
 // @Param id path int true "Forum ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
 // @Success 204 "No Content"
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /forums/{id} [delete]
 func DeleteForum(repo repository.ForumsRepository) http.HandlerFunc {
@@ -456,11 +712,32 @@ func DeleteForum(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
-		if err := repo.Delete(id); err != nil {
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		deletedBy := user.Username
+
+		var before *models.Forum
+		if moderationLog != nil {
+			before, _ = repo.GetByID(id)
+		}
+
+		if err := repo.Delete(id, deletedBy); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		recordModeration(models.ModerationEntry{
+			Actor:      deletedBy,
+			Action:     models.ModerationForumDelete,
+			TargetType: models.ModerationTargetForum,
+			TargetID:   id,
+			ForumID:    &id,
+			Reason:     moderationReason(r),
+		}, before, nil)
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -489,7 +766,17 @@ func GetMessages(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
-		messages, err := repo.GetMessages(forumID)
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil {
+			http.Error(w, "Topic not found", http.StatusNotFound)
+			return
+		}
+
+		var topicID *int
+		if topic != nil {
+			topicID = &topic.ID
+		}
+		page, err := repo.GetMessagesPage(forumID, topicID, models.PageRequest{Limit: defaultPageLimit})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -507,14 +794,18 @@ func GetMessages(repo repository.ForumsRepository) http.HandlerFunc {
 			}
 		}
 
+		redactHidden(page.Messages, currentRole == "admin" || currentRole == "moderator")
+
 		data := struct {
 			Forum       *models.Forum
+			Topic       *models.Topic
 			Messages    []models.Message
 			CurrentUser string
 			CurrentRole string
 		}{
 			Forum:       forum,
-			Messages:    messages,
+			Topic:       topic,
+			Messages:    page.Messages,
 			CurrentUser: currentUser,
 			CurrentRole: currentRole,
 		}
@@ -532,9 +823,11 @@ func GetMessages(repo repository.ForumsRepository) http.HandlerFunc {
 // @Param forum_id path int true "Forum ID"
 // @Param message_id path int true "Message ID"
 // @Param message body models.Message true "Message info"
+// @Param reason query string false "Reason recorded in the moderation log"
 // @Success 200 {object} models.Message
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Router /forums/{forum_id}/messages/{message_id} [put]
 func UpdateMessage(repo repository.ForumsRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -553,6 +846,10 @@ func UpdateMessage(repo repository.ForumsRepository) http.HandlerFunc {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		if len(request.Content) > maxContentLength {
+			http.Error(w, errContentTooLong, http.StatusBadRequest)
+			return
+		}
 
 		authHeader := r.Header.Get("Authorization")
 		var user *models.User
@@ -580,12 +877,52 @@ func UpdateMessage(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
-		updatedMessage, err := repo.PutMessage(messageID, request.Content)
+		if !checkForumWritable(w, repo, msg.ForumID, user) {
+			return
+		}
+
+		filtered, ok := filterMessage(w, &msg.ForumID, request.Content)
+		if !ok {
+			return
+		}
+		request.Content = filtered.Content
+
+		// Saving identical content would only add an empty revision.
+		if request.Content == msg.Content {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(msg)
+			return
+		}
+
+		if rejectSpamEdit(w, user, msg, request.Content) {
+			return
+		}
+
+		updatedMessage, err := repo.PutMessage(messageID, request.Content, user.Username)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		updateMentions(repo, updatedMessage, msg.Content)
+		flagMessage(updatedMessage, filtered)
+
+		if user.Username != msg.Author {
+			recordModeration(models.ModerationEntry{
+				Actor:      user.Username,
+				Action:     models.ModerationMessageEdit,
+				TargetType: models.ModerationTargetMessage,
+				TargetID:   messageID,
+				ForumID:    &msg.ForumID,
+				Reason:     moderationReason(r),
+			}, msg, updatedMessage)
+		}
+
+		go broadcastMessage(updatedMessage, WSMessage{
+			Type:    "message_updated",
+			Payload: updatedMessage,
+		})
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(updatedMessage)
 	}
@@ -595,10 +932,11 @@ func UpdateMessage(repo repository.ForumsRepository) http.HandlerFunc {
 
 // DeleteMessage godoc
 // @Summary Delete message
-// @Description Delete a message from a forum
+// @Description Move a message to the trash. It can be restored until the purge job removes it
 // @Tags messages
 // @Param forum_id path int true "Forum ID"
 // @Param message_id path int true "Message ID"
+// @Param reason query string false "Reason recorded in the moderation log"
 // @Success 204 "No Content"
 // @Failure 404 {object} map[string]string
 // @Router /forums/{forum_id}/messages/{message_id} [delete]
@@ -637,12 +975,28 @@ func DeleteMessage(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
-		err = repo.DeleteMessage(messageID)
+		err = repo.DeleteMessage(messageID, user.Username)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		if user.Username != msg.Author {
+			recordModeration(models.ModerationEntry{
+				Actor:      user.Username,
+				Action:     models.ModerationMessageDelete,
+				TargetType: models.ModerationTargetMessage,
+				TargetID:   messageID,
+				ForumID:    &msg.ForumID,
+				Reason:     moderationReason(r),
+			}, msg, nil)
+		}
+
+		go broadcastMessage(msg, WSMessage{
+			Type:    "message_deleted",
+			Payload: map[string]int{"messageId": messageID},
+		})
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -701,7 +1055,33 @@ func serveGlobalChat(w http.ResponseWriter, r *http.Request, repo repository.For
 			break
 		}
 
-		_, err := repo.CreateGlobalMessage(models.GlobalMessage{
+		// Messages sent here count against the same limit as those posted
+		// to /api/global-chat.
+		if ok, wait := checkRateLimit(r, globalChatRoute); !ok {
+			if err := writeGlobalChatError(conn, wsError{Error: "Too many messages", RetryAfter: retryAfter(wait)}); err != nil {
+				break
+			}
+			continue
+		}
+
+		filtered, err := checkContent(nil, msg.Content)
+		if err != nil {
+			log.Error("Failed to load content filters", logger.Error(err))
+			if err := writeGlobalChatError(conn, wsError{Error: "Failed to check message"}); err != nil {
+				break
+			}
+			continue
+		}
+		if filtered.Rejected() {
+			log.Info("Global chat message refused", logger.String("author", msg.Author), logger.String("reason", filtered.Reason()))
+			if err := writeGlobalChatError(conn, wsError{Error: filtered.Reason()}); err != nil {
+				break
+			}
+			continue
+		}
+		msg.Content = filtered.Content
+
+		_, err = repo.CreateGlobalMessage(models.GlobalMessage{
 			Author:    msg.Author,
 			Content:   msg.Content,
 			CreatedAt: time.Now(),
@@ -714,6 +1094,14 @@ func serveGlobalChat(w http.ResponseWriter, r *http.Request, repo repository.For
 	}
 }
 
+// writeGlobalChatError answers a message sent on conn with an error frame
+// instead of posting it.
+func writeGlobalChatError(conn *websocket.Conn, payload wsError) error {
+	globalChatMu.Lock()
+	defer globalChatMu.Unlock()
+	return conn.WriteJSON(WSMessage{Type: "error", Payload: payload})
+}
+
 func cleanupExpiredMessages(repo repository.ForumsRepository) {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -792,6 +1180,14 @@ func handleGlobalChatMessage(repo repository.ForumsRepository) http.HandlerFunc
 			return
 		}
 
+		// Global chat messages are gone within minutes, so flag filters
+		// have nothing to report and only block and mask apply.
+		filtered, ok := filterMessage(w, nil, req.Content)
+		if !ok {
+			return
+		}
+		req.Content = filtered.Content
+
 		msgmodels := models.GlobalMessage{
 			Author:    req.Author,
 			Content:   req.Content,
@@ -827,10 +1223,13 @@ func handleGlobalChatMessage(repo repository.ForumsRepository) http.HandlerFunc
 
 // GetMessagesAPI godoc
 // @Summary Get forum messages with user info
-// @Description Get all messages for a forum with current user info
+// @Description Get a page of forum messages with current user info. Without a cursor the newest messages are returned, together with the pinned messages and announcements. Each message carries its reaction counts and which of them are the current user's. For a signed-in user, lastRead is the last message they have read in the forum.
 // @Tags messages
 // @Produce json
 // @Param id path int true "Forum ID"
+// @Param before query string false "Cursor to read older messages"
+// @Param after query string false "Cursor to read newer messages"
+// @Param limit query int false "Page size (max 100)"
 // @Security BearerAuth
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
@@ -846,7 +1245,28 @@ func GetMessagesAPI(repo repository.ForumsRepository) http.HandlerFunc {
 			return
 		}
 
-		messages, err := repo.GetMessages(forumID)
+		pageReq, err := parsePageRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if _, err := repo.GetByID(forumID); err != nil {
+			http.Error(w, "Forum not found", http.StatusNotFound)
+			return
+		}
+
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil {
+			http.Error(w, "Topic not found", http.StatusNotFound)
+			return
+		}
+
+		var topicID *int
+		if topic != nil {
+			topicID = &topic.ID
+		}
+		page, err := repo.GetMessagesPage(forumID, topicID, pageReq)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -866,11 +1286,61 @@ func GetMessagesAPI(repo repository.ForumsRepository) http.HandlerFunc {
 			}
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"messages":    messages,
+		if pageReq.Before == nil && pageReq.After == nil {
+			if page.Pinned, err = repo.GetPinnedMessages(forumID, topicID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := attachFiles(page.Messages, page.Pinned); err != nil {
+			log.Error("Failed to load attachments", logger.Error(err), logger.Int("forumID", forumID))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		moderator := currentRole == "admin" || currentRole == "moderator"
+		redactHidden(page.Messages, moderator)
+		redactHidden(page.Pinned, moderator)
+
+		if err := attachReactions(repo, currentUser, page.Messages, page.Pinned); err != nil {
+			log.Error("Failed to load reactions", logger.Error(err), logger.Int("forumID", forumID))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := attachMentions(repo, page.Messages, page.Pinned); err != nil {
+			log.Error("Failed to load mentions", logger.Error(err), logger.Int("forumID", forumID))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := attachPolls(currentUser, page.Messages, page.Pinned); err != nil {
+			log.Error("Failed to load polls", logger.Error(err), logger.Int("forumID", forumID))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		markIgnored(currentUser, page.Messages, page.Pinned)
+
+		result := map[string]interface{}{
+			"pinned":      page.Pinned,
+			"messages":    page.Messages,
+			"prev":        page.Prev,
+			"next":        page.Next,
 			"currentUser": currentUser,
 			"currentRole": currentRole,
-		})
+		}
+		// The client puts a "new messages" divider after the last message
+		// the user has read.
+		if currentUser != "" {
+			lastRead, err := repo.GetReadPosition(currentUser, forumID)
+			if err != nil {
+				log.Error("Failed to load read position", logger.Error(err), logger.Int("forumID", forumID))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result["lastRead"] = lastRead
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
 	}
 }
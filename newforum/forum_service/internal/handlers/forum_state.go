@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+// maxSlowMode is the longest slow mode, in seconds, a forum can be put in.
+const maxSlowMode = 24 * 60 * 60
+
+// forumStateEvent is broadcast on the forum channel and every topic channel
+// of the forum whenever it is locked, pinned, archived or put in slow mode,
+// so open pages can update the composer.
+type forumStateEvent struct {
+	ForumID  int  `json:"forum_id"`
+	Locked   bool `json:"locked"`
+	Pinned   bool `json:"pinned"`
+	Archived bool `json:"archived"`
+	ReadOnly bool `json:"read_only"`
+	SlowMode int  `json:"slow_mode"`
+}
+
+func broadcastForumState(f *models.Forum) {
+	event := WSMessage{
+		Type: "forum_state",
+		Payload: forumStateEvent{
+			ForumID:  f.ID,
+			Locked:   f.Locked,
+			Pinned:   f.Pinned,
+			Archived: f.ArchivedAt != nil,
+			ReadOnly: f.ReadOnly(),
+			SlowMode: f.SlowMode,
+		},
+	}
+	broadcastToForum(f.ID, event)
+	broadcastToForumTopics(f.ID, event)
+}
+
+// StartForumArchiver archives forums with no new messages for longer than
+// after, checking every interval.
+func StartForumArchiver(repo repository.ForumsRepository, after, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			archiveInactiveForums(repo, after)
+			<-ticker.C
+		}
+	}()
+}
+
+func archiveInactiveForums(repo repository.ForumsRepository, after time.Duration) {
+	ids, err := repo.ArchiveInactiveForums(time.Now().Add(-after))
+	if err != nil {
+		log.Error("Forum archiving failed", logger.Error(err))
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	log.Info("Inactive forums archived", logger.Int("forums", len(ids)))
+
+	for _, id := range ids {
+		f, err := repo.GetByID(id)
+		if err != nil {
+			continue
+		}
+		broadcastForumState(f)
+	}
+}
+
+// checkForumWritable makes sure new content can be added to forumID and
+// writes the error response if not. Moderators can still post in a locked
+// forum; an archived forum is read-only for everyone until it is unarchived.
+func checkForumWritable(w http.ResponseWriter, repo repository.ForumsRepository, forumID int, user *models.User) bool {
+	return writableForum(w, repo, forumID, user) != nil
+}
+
+// writableForum is checkForumWritable for callers that need the forum; it
+// returns nil when nothing can be added to it.
+func writableForum(w http.ResponseWriter, repo repository.ForumsRepository, forumID int, user *models.User) *models.Forum {
+	forum, err := repo.GetByID(forumID)
+	if err != nil {
+		sendError(w, http.StatusNotFound, "Forum not found")
+		return nil
+	}
+	if forum.ArchivedAt != nil {
+		sendError(w, http.StatusLocked, "Forum is archived")
+		return nil
+	}
+	if forum.Locked && !isModerator(user) {
+		sendError(w, http.StatusLocked, "Forum is locked")
+		return nil
+	}
+	return forum
+}
+
+// loadForumsPage reads one page of forums and, on the first page, the
+// pinned forums that are listed above it.
+func loadForumsPage(repo repository.ForumsRepository, pageReq models.PageRequest) (*models.ForumPage, error) {
+	page, err := repo.GetForumsPage(pageReq)
+	if err != nil {
+		return nil, err
+	}
+	if pageReq.Before == nil && pageReq.After == nil {
+		if page.Pinned, err = repo.GetPinnedForums(); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// SetForumState godoc
+// @Summary Lock, pin or archive a forum
+// @Description Change a forum's locked, pinned and archived flags and its slow mode, the seconds each user has to wait between messages. Omitted fields are left as they are. Moderators only
+// @Tags forums
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param state body models.ForumStateChange true "New state"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 200 {object} models.Forum
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/state [put]
+func SetForumState(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var change models.ForumStateChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if change.Locked == nil && change.Pinned == nil && change.Archived == nil && change.SlowMode == nil {
+			sendError(w, http.StatusBadRequest, "Nothing to change")
+			return
+		}
+		if change.SlowMode != nil && (*change.SlowMode < 0 || *change.SlowMode > maxSlowMode) {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("Slow mode must be between 0 and %d seconds", maxSlowMode))
+			return
+		}
+
+		var before *models.Forum
+		if moderationLog != nil {
+			before, _ = repo.GetByID(id)
+		}
+
+		forum, err := repo.SetForumState(id, change)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Forum not found")
+				return
+			}
+			log.Error("Failed to change forum state", logger.Error(err), logger.Int("forumID", id))
+			sendError(w, http.StatusInternalServerError, "Failed to change forum state")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationForumState,
+			TargetType: models.ModerationTargetForum,
+			TargetID:   id,
+			ForumID:    &id,
+			Reason:     moderationReason(r),
+		}, before, forum)
+
+		go broadcastForumState(forum)
+
+		json.NewEncoder(w).Encode(forum)
+	}
+}
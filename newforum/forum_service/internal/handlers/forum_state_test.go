@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// dialForum opens a WebSocket subscribed to forumID.
+func dialForum(t *testing.T, forumID string) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveWebSocket(w, mux.SetURLVars(r, map[string]string{"forum_id": forumID}))
+	}))
+	t.Cleanup(server.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("could not open a ws connection: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	time.Sleep(100 * time.Millisecond)
+	return ws
+}
+
+// dialTopic opens a WebSocket subscribed to topicID of forumID.
+func dialTopic(t *testing.T, repo *mocks.MockForumsRepo, forumID, topicID int) *websocket.Conn {
+	t.Helper()
+	repo.On("GetTopicByID", topicID).Return(&models.Topic{ID: topicID, ForumID: forumID}, nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/ws/{forum_id}/topics/{topic_id}", serveTopicWebSocket(repo))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws%s/ws/%d/topics/%d", strings.TrimPrefix(server.URL, "http"), forumID, topicID), nil)
+	if err != nil {
+		t.Fatalf("could not open a ws connection: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	time.Sleep(100 * time.Millisecond)
+	return ws
+}
+
+func TestSetForumState(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	useModerationLog(t, mockLog)
+
+	locked := true
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("GetByID", 41).Return(&models.Forum{ID: 41, Title: "Forum"}, nil)
+	mockRepo.On("SetForumState", 41, models.ForumStateChange{Locked: &locked}).
+		Return(&models.Forum{ID: 41, Title: "Forum", Locked: true}, nil)
+	mockLog.On("LogAction", mock.MatchedBy(func(e models.ModerationEntry) bool {
+		var before, after models.Forum
+		return e.Actor == "mod" && e.Action == models.ModerationForumState && e.TargetID == 41 &&
+			e.Reason == "flame war" &&
+			json.Unmarshal(e.Before, &before) == nil && !before.Locked &&
+			json.Unmarshal(e.After, &after) == nil && after.Locked
+	})).Return(nil)
+
+	ws := dialForum(t, "41")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/state", SetForumState(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/41/state?reason=flame+war", `{"locked":true}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var forum models.Forum
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &forum))
+	assert.True(t, forum.Locked)
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var event struct {
+		Type    string          `json:"type"`
+		Payload forumStateEvent `json:"payload"`
+	}
+	if err := ws.ReadJSON(&event); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "forum_state", event.Type)
+	assert.Equal(t, forumStateEvent{ForumID: 41, Locked: true, ReadOnly: true}, event.Payload)
+
+	mockLog.AssertExpectations(t)
+}
+
+func TestForumStateReachesTopicChannels(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	topics := []*websocket.Conn{dialTopic(t, mockRepo, 41, 5), dialTopic(t, mockRepo, 41, 6)}
+
+	broadcastForumState(&models.Forum{ID: 41, SlowMode: 30})
+
+	for _, ws := range topics {
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		var event struct {
+			Type    string          `json:"type"`
+			Payload forumStateEvent `json:"payload"`
+		}
+		if err := ws.ReadJSON(&event); err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		assert.Equal(t, "forum_state", event.Type)
+		assert.Equal(t, forumStateEvent{ForumID: 41, SlowMode: 30}, event.Payload)
+	}
+}
+
+func TestSetForumStateRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		body     string
+		notFound bool
+		wantCode int
+	}{
+		{name: "Not Moderator", role: "user", body: `{"locked":true}`, wantCode: http.StatusForbidden},
+		{name: "Empty Change", role: "moderator", body: `{}`, wantCode: http.StatusBadRequest},
+		{name: "Invalid JSON", role: "moderator", body: `locked`, wantCode: http.StatusBadRequest},
+		{name: "Negative Slow Mode", role: "moderator", body: `{"slow_mode":-5}`, wantCode: http.StatusBadRequest},
+		{name: "Slow Mode Too Long", role: "moderator", body: `{"slow_mode":86401}`, wantCode: http.StatusBadRequest},
+		{name: "Forum Not Found", role: "moderator", body: `{"pinned":true}`, notFound: true, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "user", Role: tt.role}, nil)
+			if tt.notFound {
+				mockRepo.On("SetForumState", 9, mock.Anything).Return(nil, repository.ErrNotFound)
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc("/forums/{id}/state", SetForumState(mockRepo))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/9/state", tt.body))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if !tt.notFound {
+				mockRepo.AssertNotCalled(t, "SetForumState", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestPostMessageReadOnlyForum(t *testing.T) {
+	archivedAt := time.Now()
+	tests := []struct {
+		name     string
+		role     string
+		forum    *models.Forum
+		wantCode int
+	}{
+		{name: "Locked", role: "user", forum: &models.Forum{ID: 1, Locked: true}, wantCode: http.StatusLocked},
+		{name: "Archived", role: "user", forum: &models.Forum{ID: 1, ArchivedAt: &archivedAt}, wantCode: http.StatusLocked},
+		{name: "Archived Moderator", role: "moderator", forum: &models.Forum{ID: 1, ArchivedAt: &archivedAt}, wantCode: http.StatusLocked},
+		{name: "Locked Moderator", role: "moderator", forum: &models.Forum{ID: 1, Locked: true}, wantCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: tt.role}, nil)
+			mockRepo.On("GetByID", 1).Return(tt.forum, nil)
+			mockRepo.On("CreateMessage", mock.Anything).Return(3, nil)
+
+			router := mux.NewRouter()
+			router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages", `{"author":"User1","content":"Hi"}`))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantCode == http.StatusLocked {
+				mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestCreateTopicLockedForum(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1, Locked: true}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics", CreateTopic(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/topics", `{"title":"New topic"}`))
+
+	assert.Equal(t, http.StatusLocked, rr.Code)
+	mockRepo.AssertNotCalled(t, "CreateTopic", mock.Anything)
+}
+
+func TestUpdateMessageLockedForum(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 1).Return(&models.Message{ID: 1, ForumID: 1, Author: "User1", Content: "Hi"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1, Locked: true}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages/{message_id}", UpdateMessage(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1/messages/1", `{"content":"Edited"}`))
+
+	assert.Equal(t, http.StatusLocked, rr.Code)
+	mockRepo.AssertNotCalled(t, "PutMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetAllForumsPinned(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetForumsPage", mock.Anything).Return(&models.ForumPage{
+		Forums: []models.Forum{{ID: 2, Title: "General"}},
+	}, nil)
+	mockRepo.On("GetPinnedForums").Return([]models.Forum{{ID: 1, Title: "Rules", Pinned: true}}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/forums-list", GetAllForums(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums-list", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page models.ForumPage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, []models.Forum{{ID: 1, Title: "Rules", Pinned: true}}, page.Pinned)
+	assert.Len(t, page.Forums, 1)
+
+	cursor := models.Cursor{CreatedAt: time.Now(), ID: 2}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums-list?after="+cursor.Encode(), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertNumberOfCalls(t, "GetPinnedForums", 1)
+}
+
+func TestArchiveInactiveForums(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	archivedAt := time.Now()
+	mockRepo.On("ArchiveInactiveForums", mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) > 89*24*time.Hour
+	})).Return([]int{42}, nil)
+	mockRepo.On("GetByID", 42).Return(&models.Forum{ID: 42, ArchivedAt: &archivedAt}, nil)
+
+	ws := dialForum(t, "42")
+	archiveInactiveForums(mockRepo, 90*24*time.Hour)
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var event struct {
+		Type    string          `json:"type"`
+		Payload forumStateEvent `json:"payload"`
+	}
+	if err := ws.ReadJSON(&event); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "forum_state", event.Type)
+	assert.True(t, event.Payload.Archived)
+	assert.True(t, event.Payload.ReadOnly)
+	mockRepo.AssertExpectations(t)
+}
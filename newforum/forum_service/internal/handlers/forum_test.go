@@ -22,14 +22,52 @@ import (
 
 const testSecretKey = "your-secret-key"
 
+// testRouter returns a router with the routes added by register. Some
+// Register functions install package-level stores; those are cleared when
+// the test ends so one test's mocks never serve the next. Stores the test
+// did not install are left alone, since goroutines started by earlier
+// tests may still be reading them.
+func testRouter(t *testing.T, register func(r *mux.Router)) *mux.Router {
+	t.Helper()
+	router := mux.NewRouter()
+	register(router)
+	t.Cleanup(func() {
+		if attachmentStore != nil {
+			attachmentStore, blobStorage, attachmentConfig = nil, nil, AttachmentConfig{}
+		}
+		if blockStore != nil {
+			blockStore, blockUsers = nil, nil
+		}
+		if filterStore != nil {
+			filterStore, filterReports = nil, nil
+		}
+		if notificationStore != nil {
+			notificationStore = nil
+		}
+		if pollStore != nil {
+			pollStore = nil
+		}
+		if spamStore != nil {
+			spamStore, spamClassifier, spamThreshold = nil, nil, 0
+		}
+		if subscriptionStore != nil {
+			subscriptionStore, unsubscribeSecret = nil, nil
+		}
+	})
+	return router
+}
+
 func TestListForums(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
-	forums := []models.Forum{
-		{ID: 1, Title: "Forum 1", Description: "Description 1"},
-		{ID: 2, Title: "Forum 2", Description: "Description 2"},
+	tree := &models.ForumTree{
+		Categories: []*models.CategoryNode{{
+			Category: models.Category{ID: 1, Name: "Category 1"},
+			Forums:   []*models.ForumNode{{Forum: models.Forum{ID: 1, Title: "Forum 1", Description: "Description 1"}}},
+		}},
+		Uncategorized: []*models.ForumNode{{Forum: models.Forum{ID: 2, Title: "Forum 2", Description: "Description 2"}}},
 	}
 
-	mockRepo.On("GetAll").Return(forums, nil)
+	mockRepo.On("GetForumTree").Return(tree, nil)
 
 	req, err := http.NewRequest("GET", "/forums", nil)
 	if err != nil {
@@ -76,7 +114,7 @@ func TestGetMessages(t *testing.T) {
 	}
 
 	mockRepo.On("GetByID", 1).Return(forum, nil)
-	mockRepo.On("GetMessages", 1).Return(messages, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(&models.MessagePage{Messages: messages}, nil)
 
 	req, err := http.NewRequest("GET", "/forums/1/messages", nil)
 	if err != nil {
@@ -95,15 +133,13 @@ func TestGetMessages(t *testing.T) {
 
 func TestUpdateForum(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "admin"}, nil)
 	forum := models.Forum{Title: "Updated Forum", Description: "Updated Description"}
 
 	mockRepo.On("Update", 1, forum).Return(nil)
 
 	reqBody := `{"title":"Updated Forum","description":"Updated Description"}`
-	req, err := http.NewRequest("PUT", "/forums/1", strings.NewReader(reqBody))
-	if err != nil {
-		t.Fatal(err)
-	}
+	req := authorizedRequest(t, "PUT", "/forums/1", reqBody)
 
 	rr := httptest.NewRecorder()
 	router := mux.NewRouter()
@@ -115,15 +151,26 @@ func TestUpdateForum(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestUpdateForumForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}", UpdateForum(mockRepo))
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1", `{"title":"Mine now"}`))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
 func TestDeleteForum(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "admin"}, nil)
 
-	mockRepo.On("Delete", 1).Return(nil)
+	mockRepo.On("Delete", 1, "User1").Return(nil)
 
-	req, err := http.NewRequest("DELETE", "/forums/1", nil)
-	if err != nil {
-		t.Fatal(err)
-	}
+	req := authorizedRequest(t, "DELETE", "/forums/1", "")
 
 	rr := httptest.NewRecorder()
 	router := mux.NewRouter()
@@ -135,6 +182,18 @@ func TestDeleteForum(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestDeleteForumForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}", DeleteForum(mockRepo))
+	router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/forums/1", nil))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
 func TestUpdateMessage(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
 	user := &models.User{Username: "User1", Role: "admin"}
@@ -142,7 +201,8 @@ func TestUpdateMessage(t *testing.T) {
 
 	mockRepo.On("GetUserByID", 1).Return(user, nil)
 	mockRepo.On("GetMessageByID", 1).Return(message, nil)
-	mockRepo.On("PutMessage", 1, "Updated Content").Return(message, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("PutMessage", 1, "Updated Content", "User1").Return(message, nil)
 
 	reqBody := `{"content":"Updated Content"}`
 	req, err := http.NewRequest("PUT", "/forums/1/messages/1", strings.NewReader(reqBody))
@@ -173,7 +233,7 @@ func TestDeleteMessage(t *testing.T) {
 
 	mockRepo.On("GetUserByID", 1).Return(user, nil)
 	mockRepo.On("GetMessageByID", 1).Return(message, nil)
-	mockRepo.On("DeleteMessage", 1).Return(nil)
+	mockRepo.On("DeleteMessage", 1, "User1").Return(nil)
 
 	req, err := http.NewRequest("DELETE", "/forums/1/messages/1", nil)
 	if err != nil {
@@ -203,7 +263,12 @@ func TestGetMessagesAPI(t *testing.T) {
 		{ID: 2, ForumID: 1, Author: "User2", Content: "Message 2"},
 	}
 
-	mockRepo.On("GetMessages", 1).Return(messages, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(&models.MessagePage{Messages: messages}, nil)
+
+	mockRepo.On("GetPinnedMessages", 1, (*int)(nil)).Return([]models.Message{}, nil)
+	mockRepo.On("GetReactions", mock.Anything, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", mock.Anything).Return(map[int][]string{}, nil)
 
 	req, err := http.NewRequest("GET", "/forums/1/messages-list", nil)
 	if err != nil {
@@ -220,10 +285,22 @@ func TestGetMessagesAPI(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetMessagesAPIDeletedForum(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(nil, assert.AnError)
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages-list", GetMessagesAPI(mockRepo))
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages-list", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertNotCalled(t, "GetMessagesPage", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestListForumsError(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
-	var nilForums []models.Forum
-	mockRepo.On("GetAll").Return(nilForums, assert.AnError)
+	mockRepo.On("GetForumTree").Return(nil, assert.AnError)
 
 	req, err := http.NewRequest("GET", "/forums", nil)
 	assert.NoError(t, err)
@@ -304,7 +381,7 @@ func TestGetMessagesInvalidForumID(t *testing.T) {
 	router.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	mockRepo.AssertNotCalled(t, "GetMessages")
+	mockRepo.AssertNotCalled(t, "GetMessagesPage")
 }
 
 func TestGetMessagesForumNotFound(t *testing.T) {
@@ -463,7 +540,7 @@ func TestGetMessagesAPIInvalidForumID(t *testing.T) {
 	router.ServeHTTP(rr, req)
 
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	mockRepo.AssertNotCalled(t, "GetMessages")
+	mockRepo.AssertNotCalled(t, "GetMessagesPage")
 }
 
 func TestGetMessagesAPIWithAuth(t *testing.T) {
@@ -473,7 +550,12 @@ func TestGetMessagesAPIWithAuth(t *testing.T) {
 		{ID: 2, ForumID: 1, Author: "User2", Content: "Message 2"},
 	}
 
-	mockRepo.On("GetMessages", 1).Return(messages, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(&models.MessagePage{Messages: messages}, nil)
+
+	mockRepo.On("GetPinnedMessages", 1, (*int)(nil)).Return([]models.Message{}, nil)
+	mockRepo.On("GetReactions", mock.Anything, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", mock.Anything).Return(map[int][]string{}, nil)
 
 	req, err := http.NewRequest("GET", "/forums/1/messages-list", nil)
 	assert.NoError(t, err)
@@ -559,7 +641,8 @@ func TestGetAllForums(t *testing.T) {
 		{ID: 2, Title: "Forum 2", Description: "Description 2"},
 	}
 
-	mockRepo.On("GetAll").Return(forums, nil)
+	mockRepo.On("GetForumsPage", mock.Anything).Return(&models.ForumPage{Forums: forums}, nil)
+	mockRepo.On("GetPinnedForums").Return([]models.Forum{}, nil)
 
 	req, err := http.NewRequest("GET", "/forums/all", nil)
 	assert.NoError(t, err)
@@ -616,11 +699,25 @@ func TestPostMessageInvalidContentType(t *testing.T) {
 	mockRepo.AssertNotCalled(t, "CreateMessage")
 }
 
+func TestPostMessageTooLong(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	body, _ := json.Marshal(map[string]string{"author": "User1", "content": strings.Repeat("a", maxContentLength+1)})
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages", string(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNotCalled(t, "CreateMessage")
+}
+
 func TestPostMessage(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
 	user := &models.User{Username: "User1", Role: "user"}
 
 	mockRepo.On("GetUserByID", 1).Return(user, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
 	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(1, nil)
 
 	reqBody := `{"author":"User1","content":"Test Message"}`
@@ -642,6 +739,25 @@ func TestPostMessage(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestPostMessageRendersMarkdown(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(1, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages",
+		`{"author":"User1","content":"Run `+"`make`"+` <script>"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var msg models.Message
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&msg))
+	assert.Equal(t, "Run `make` <script>", msg.Content)
+	assert.Equal(t, "<p>Run <code>make</code> &lt;script&gt;</p>", msg.ContentHTML)
+}
+
 func TestPostMessageEmptyFields(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
 
@@ -720,6 +836,7 @@ func TestPostMessageError(t *testing.T) {
 	user := &models.User{Username: "User1", Role: "user"}
 
 	mockRepo.On("GetUserByID", 1).Return(user, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
 	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(0, assert.AnError)
 
 	reqBody := `{"author":"User1","content":"Test Message"}`
@@ -783,11 +900,11 @@ func TestUpdateForumInvalidJSON(t *testing.T) {
 }
 func TestUpdateForumError(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "admin"}, nil)
 	mockRepo.On("Update", mock.AnythingOfType("int"), mock.AnythingOfType("models.Forum")).Return(assert.AnError)
 
 	reqBody := `{"title":"Updated Forum","description":"Updated Description"}`
-	req, err := http.NewRequest("PUT", "/forums/1", strings.NewReader(reqBody))
-	assert.NoError(t, err)
+	req := authorizedRequest(t, "PUT", "/forums/1", reqBody)
 
 	vars := map[string]string{
 		"id": "1",
@@ -821,10 +938,10 @@ func TestDeleteForumInvalidID(t *testing.T) {
 }
 func TestDeleteForumError(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
-	mockRepo.On("Delete", mock.AnythingOfType("int")).Return(assert.AnError)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "admin"}, nil)
+	mockRepo.On("Delete", mock.AnythingOfType("int"), mock.Anything).Return(assert.AnError)
 
-	req, err := http.NewRequest("DELETE", "/forums/1", nil)
-	assert.NoError(t, err)
+	req := authorizedRequest(t, "DELETE", "/forums/1", "")
 
 	vars := map[string]string{
 		"id": "1",
@@ -841,10 +958,9 @@ func TestDeleteForumError(t *testing.T) {
 func TestGetMessagesError(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
 	forum := &models.Forum{ID: 1, Title: "Forum 1", Description: "Description 1"}
-	var nilMessages []models.Message
 
 	mockRepo.On("GetByID", 1).Return(forum, nil)
-	mockRepo.On("GetMessages", 1).Return(nilMessages, assert.AnError)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(nil, assert.AnError)
 
 	req, err := http.NewRequest("GET", "/forums/1/messages", nil)
 	assert.NoError(t, err)
@@ -863,9 +979,9 @@ func TestGetMessagesError(t *testing.T) {
 }
 func TestGetMessagesAPIError(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
-	var nilMessages []models.Message
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
 
-	mockRepo.On("GetMessages", 1).Return(nilMessages, assert.AnError)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(nil, assert.AnError)
 
 	req, err := http.NewRequest("GET", "/forums/1/messages-list", nil)
 	assert.NoError(t, err)
@@ -884,11 +1000,16 @@ func TestGetMessagesAPIError(t *testing.T) {
 }
 func TestGetMessagesAPIWithInvalidToken(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
 	messages := []models.Message{
 		{ID: 1, ForumID: 1, Author: "User1", Content: "Message 1"},
 	}
 
-	mockRepo.On("GetMessages", mock.AnythingOfType("int")).Return(messages, nil)
+	mockRepo.On("GetMessagesPage", mock.AnythingOfType("int"), (*int)(nil), mock.Anything).Return(&models.MessagePage{Messages: messages}, nil)
+
+	mockRepo.On("GetPinnedMessages", mock.AnythingOfType("int"), (*int)(nil)).Return([]models.Message{}, nil)
+	mockRepo.On("GetReactions", mock.Anything, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", mock.Anything).Return(map[int][]string{}, nil)
 
 	req, err := http.NewRequest("GET", "/forums/1/messages-list", nil)
 	assert.NoError(t, err)
@@ -979,7 +1100,8 @@ func TestUpdateMessageDatabaseError(t *testing.T) {
 
 	mockRepo.On("GetUserByID", 1).Return(user, nil)
 	mockRepo.On("GetMessageByID", 1).Return(message, nil)
-	mockRepo.On("PutMessage", 1, "Updated Content").Return(nil, assert.AnError)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("PutMessage", 1, "Updated Content", "User1").Return(nil, assert.AnError)
 
 	reqBody := `{"content":"Updated Content"}`
 	req, err := http.NewRequest("PUT", "/forums/1/messages/1", strings.NewReader(reqBody))
@@ -1005,7 +1127,7 @@ func TestDeleteMessageDatabaseError(t *testing.T) {
 
 	mockRepo.On("GetUserByID", 1).Return(user, nil)
 	mockRepo.On("GetMessageByID", 1).Return(message, nil)
-	mockRepo.On("DeleteMessage", 1).Return(assert.AnError)
+	mockRepo.On("DeleteMessage", 1, "User1").Return(assert.AnError)
 
 	req, err := http.NewRequest("DELETE", "/forums/1/messages/1", nil)
 	assert.NoError(t, err)
@@ -1025,7 +1147,7 @@ func TestDeleteMessageDatabaseError(t *testing.T) {
 }
 func TestGetAllForumsDatabaseError(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
-	mockRepo.On("GetAll").Return(nil, assert.AnError)
+	mockRepo.On("GetForumsPage", mock.Anything).Return(nil, assert.AnError)
 
 	req, err := http.NewRequest("GET", "/forums/all", nil)
 	assert.NoError(t, err)
@@ -1085,11 +1207,11 @@ func TestPostMessageUserNotFound(t *testing.T) {
 }
 func TestUpdateForumNotFound(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "admin"}, nil)
 	mockRepo.On("Update", 1, mock.AnythingOfType("models.Forum")).Return(assert.AnError)
 
 	reqBody := `{"title":"Updated Forum","description":"Updated Description"}`
-	req, err := http.NewRequest("PUT", "/forums/1", strings.NewReader(reqBody))
-	assert.NoError(t, err)
+	req := authorizedRequest(t, "PUT", "/forums/1", reqBody)
 
 	rr := httptest.NewRecorder()
 	router := mux.NewRouter()
@@ -1102,10 +1224,10 @@ func TestUpdateForumNotFound(t *testing.T) {
 }
 func TestDeleteForumNotFound(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
-	mockRepo.On("Delete", 1).Return(assert.AnError)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "admin"}, nil)
+	mockRepo.On("Delete", 1, "User1").Return(assert.AnError)
 
-	req, err := http.NewRequest("DELETE", "/forums/1", nil)
-	assert.NoError(t, err)
+	req := authorizedRequest(t, "DELETE", "/forums/1", "")
 
 	rr := httptest.NewRecorder()
 	router := mux.NewRouter()
@@ -1118,11 +1240,16 @@ func TestDeleteForumNotFound(t *testing.T) {
 }
 func TestGetMessagesAPIUnauthorized(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
 	messages := []models.Message{
 		{ID: 1, ForumID: 1, Author: "User1", Content: "Message 1"},
 	}
 
-	mockRepo.On("GetMessages", 1).Return(messages, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(&models.MessagePage{Messages: messages}, nil)
+
+	mockRepo.On("GetPinnedMessages", 1, (*int)(nil)).Return([]models.Message{}, nil)
+	mockRepo.On("GetReactions", mock.Anything, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", mock.Anything).Return(map[int][]string{}, nil)
 
 	req, err := http.NewRequest("GET", "/forums/1/messages-list", nil)
 	assert.NoError(t, err)
@@ -1143,11 +1270,16 @@ func TestGetMessagesAPIUnauthorized(t *testing.T) {
 }
 func TestGetMessagesAPIWithExpiredToken(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
 	messages := []models.Message{
 		{ID: 1, ForumID: 1, Author: "User1", Content: "Message 1"},
 	}
 
-	mockRepo.On("GetMessages", 1).Return(messages, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(&models.MessagePage{Messages: messages}, nil)
+
+	mockRepo.On("GetPinnedMessages", 1, (*int)(nil)).Return([]models.Message{}, nil)
+	mockRepo.On("GetReactions", mock.Anything, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", mock.Anything).Return(map[int][]string{}, nil)
 
 	token, err := jwt.GenerateToken(1, testSecretKey, -1*time.Hour)
 	assert.NoError(t, err)
@@ -1305,6 +1437,7 @@ func TestPostMessageWebSocketIntegration(t *testing.T) {
 	mockRepo := new(mocks.MockForumsRepo)
 	user := &models.User{Username: "test", Role: "user"}
 	mockRepo.On("GetUserByID", mock.Anything).Return(user, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
 	mockRepo.On("CreateMessage", mock.Anything).Return(1, nil)
 
 	wsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+const (
+	defaultUserSuggestions = 10
+	maxUserSuggestions     = 50
+)
+
+func RegisterMentionHandlers(r *mux.Router, repo repository.ForumsRepository) {
+	r.HandleFunc("/api/users", SearchUsers(repo)).Methods("GET")
+}
+
+// updateMentions stores the registered users msg mentions, fills in
+// msg.Mentions and notifies the users the message did not mention before.
+// previous is the content before an edit, or empty for a new message.
+// Authors are not notified about mentioning themselves, and users who
+// blocked the author are not mentioned at all. Errors are logged only,
+// since the message itself has already been saved.
+func updateMentions(repo repository.ForumsRepository, msg *models.Message, previous string) {
+	names := models.ParseMentions(msg.Content)
+	if len(names) == 0 && len(models.ParseMentions(previous)) == 0 {
+		return
+	}
+
+	mentioned := []string{}
+	if len(names) > 0 {
+		var err error
+		if mentioned, err = repo.ResolveUsernames(names); err != nil {
+			log.Error("Failed to resolve mentions", logger.Error(err), logger.Int("messageID", msg.ID))
+			return
+		}
+		if mentioned, err = withoutBlockers(msg.Author, mentioned); err != nil {
+			log.Error("Failed to check blocked mentions", logger.Error(err), logger.Int("messageID", msg.ID))
+			return
+		}
+	}
+
+	added, err := repo.SetMessageMentions(msg.ID, mentioned)
+	if err != nil {
+		log.Error("Failed to save mentions", logger.Error(err), logger.Int("messageID", msg.ID))
+		return
+	}
+	msg.Mentions = mentioned
+
+	for _, name := range added {
+		notify(models.Notification{
+			Username:  name,
+			Type:      models.NotificationMention,
+			Actor:     msg.Author,
+			ForumID:   &msg.ForumID,
+			TopicID:   msg.TopicID,
+			MessageID: &msg.ID,
+		})
+	}
+}
+
+// attachMentions fills in the users mentioned in msgs.
+func attachMentions(repo repository.ForumsRepository, msgs ...[]models.Message) error {
+	ids := messageIDs(msgs...)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	mentions, err := repo.GetMentions(ids)
+	if err != nil {
+		return err
+	}
+	for _, list := range msgs {
+		for i := range list {
+			list[i].Mentions = mentions[list[i].ID]
+		}
+	}
+	return nil
+}
+
+// SearchUsers godoc
+// @Summary Suggest usernames
+// @Description Autocomplete usernames by prefix, ignoring case, for @mentions
+// @Tags users
+// @Produce json
+// @Param q query string true "Username prefix"
+// @Param limit query int false "Number of suggestions (max 50)"
+// @Success 200 {array} string
+// @Failure 400 {object} map[string]string
+// @Router /users [get]
+func SearchUsers(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, err := suggestionLimit(r, defaultUserSuggestions, maxUserSuggestions)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+
+		// Listing every user is not what autocompletion is for.
+		prefix := strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("q")), "@")
+		if prefix == "" {
+			json.NewEncoder(w).Encode([]string{})
+			return
+		}
+
+		names, err := repo.SearchUsernames(prefix, limit)
+		if err != nil {
+			log.Error("Failed to search users", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to search users")
+			return
+		}
+		json.NewEncoder(w).Encode(names)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{content: "no mentions here", want: nil},
+		{content: "@alice hi", want: []string{"alice"}},
+		{content: "hi @bob_1, @alice and @bob_1 again.", want: []string{"bob_1", "alice"}},
+		{content: "thanks @jane.doe.", want: []string{"jane.doe"}},
+		{content: "mail me at user@example.com", want: nil},
+		{content: "@@alice @ alone (@карл)", want: []string{"карл"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, models.ParseMentions(tt.content), tt.content)
+	}
+}
+
+func TestPostMessageMentions(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	useNotifications(t, mockNotes)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(7, nil)
+	mockRepo.On("ResolveUsernames", []string{"bob", "ghost", "alice"}).Return([]string{"alice", "bob"}, nil)
+	mockRepo.On("SetMessageMentions", 7, []string{"alice", "bob"}).Return([]string{"alice", "bob"}, nil)
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Username == "bob" && n.Type == models.NotificationMention && n.Actor == "alice" && *n.MessageID == 7
+	})).Return(&models.Notification{ID: 1, Username: "bob"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages",
+		`{"author":"alice","content":"@bob @ghost see what I wrote, @alice"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, []string{"alice", "bob"}, got.Mentions)
+	mockNotes.AssertExpectations(t)
+	mockNotes.AssertNumberOfCalls(t, "CreateNotification", 1)
+}
+
+func TestPostMessageMentionsSkipsBlockers(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	mockBlocks := new(mocks.MockBlocksRepo)
+	useNotifications(t, mockNotes)
+	blockStore = mockBlocks
+	t.Cleanup(func() { blockStore = nil })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mallory", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(8, nil)
+	mockRepo.On("ResolveUsernames", []string{"bob", "carol"}).Return([]string{"bob", "carol"}, nil)
+	mockBlocks.On("GetBlockers", "mallory", []string{"bob", "carol"}).Return([]string{"bob"}, nil)
+	mockRepo.On("SetMessageMentions", 8, []string{"carol"}).Return([]string{"carol"}, nil)
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Username == "carol" && n.Type == models.NotificationMention
+	})).Return(&models.Notification{ID: 3, Username: "carol"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages",
+		`{"author":"mallory","content":"@bob @carol"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, []string{"carol"}, got.Mentions)
+	mockNotes.AssertNumberOfCalls(t, "CreateNotification", 1)
+}
+
+func TestUpdateMessageMentions(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	useNotifications(t, mockNotes)
+
+	before := &models.Message{ID: 3, ForumID: 1, Author: "alice", Content: "ping @bob"}
+	after := &models.Message{ID: 3, ForumID: 1, Author: "alice", Content: "sorry, I meant @carol"}
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 3).Return(before, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("PutMessage", 3, after.Content, "alice").Return(after, nil)
+	mockRepo.On("ResolveUsernames", []string{"carol"}).Return([]string{"carol"}, nil)
+	mockRepo.On("SetMessageMentions", 3, []string{"carol"}).Return([]string{"carol"}, nil)
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Username == "carol" && n.Type == models.NotificationMention
+	})).Return(&models.Notification{ID: 2, Username: "carol"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{forum_id}/messages/{message_id}", UpdateMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1/messages/3", `{"content":"sorry, I meant @carol"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+	mockNotes.AssertExpectations(t)
+}
+
+func TestUpdateMessageRemovesMentions(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	before := &models.Message{ID: 3, ForumID: 1, Author: "alice", Content: "ping @bob"}
+	after := &models.Message{ID: 3, ForumID: 1, Author: "alice", Content: "never mind"}
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 3).Return(before, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("PutMessage", 3, after.Content, "alice").Return(after, nil)
+	mockRepo.On("SetMessageMentions", 3, []string{}).Return([]string{}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{forum_id}/messages/{message_id}", UpdateMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1/messages/3", `{"content":"never mind"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "ResolveUsernames", mock.Anything)
+}
+
+func TestSearchUsers(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("SearchUsernames", "al", 5).Return([]string{"alice", "Alan"}, nil)
+
+	router := mux.NewRouter()
+	RegisterMentionHandlers(router, mockRepo)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/users?q=@al&limit=5", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `["alice","Alan"]`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/users", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/users?q=al&limit=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
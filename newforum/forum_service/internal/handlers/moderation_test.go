@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// useModerationLog installs modLog for the duration of the test.
+func useModerationLog(t *testing.T, modLog *mocks.MockModerationLogRepo) {
+	t.Helper()
+	moderationLog = modLog
+	t.Cleanup(func() { moderationLog = nil })
+}
+
+func TestDeleteMessageRecordsModeration(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	useModerationLog(t, mockLog)
+
+	message := &models.Message{ID: 5, ForumID: 2, Author: "user2", Content: "abuse"}
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(message, nil)
+	mockRepo.On("DeleteMessage", 5, "admin").Return(nil)
+	mockLog.On("LogAction", mock.MatchedBy(func(e models.ModerationEntry) bool {
+		var before models.Message
+		return e.Actor == "admin" && e.Action == models.ModerationMessageDelete &&
+			e.TargetType == models.ModerationTargetMessage && e.TargetID == 5 && *e.ForumID == 2 &&
+			e.Reason == "spam" && json.Unmarshal(e.Before, &before) == nil && before.Content == "abuse" &&
+			e.After == nil
+	})).Return(nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{forum_id}/messages/{message_id}", DeleteMessage(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/forums/2/messages/5?reason=spam", ""))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockLog.AssertExpectations(t)
+}
+
+func TestDeleteOwnMessageNotRecorded(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	useModerationLog(t, mockLog)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 2, Author: "admin"}, nil)
+	mockRepo.On("DeleteMessage", 5, "admin").Return(nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{forum_id}/messages/{message_id}", DeleteMessage(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/forums/2/messages/5", ""))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockLog.AssertNotCalled(t, "LogAction", mock.Anything)
+}
+
+func TestUpdateForumRecordsModeration(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	useModerationLog(t, mockLog)
+
+	forum := models.Forum{Title: "New", Description: "Desc"}
+	mockRepo.On("GetByID", 2).Return(&models.Forum{ID: 2, Title: "Old", Description: "Desc"}, nil)
+	mockRepo.On("Update", 2, forum).Return(nil)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockLog.On("LogAction", mock.MatchedBy(func(e models.ModerationEntry) bool {
+		var before, after models.Forum
+		return e.Actor == "admin" && e.Action == models.ModerationForumUpdate && e.TargetID == 2 &&
+			json.Unmarshal(e.Before, &before) == nil && before.Title == "Old" &&
+			json.Unmarshal(e.After, &after) == nil && after.Title == "New" && after.ID == 2
+	})).Return(nil)
+
+	body, _ := json.Marshal(forum)
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}", UpdateForum(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/2", string(body)))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockLog.AssertExpectations(t)
+}
+
+func TestGetModerationLog(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockLog.On("GetModerationLog", mock.MatchedBy(func(f models.ModerationLogFilter) bool {
+		return f.Actor == "mod" && f.Action == models.ModerationMessageDelete && *f.ForumID == 2 &&
+			f.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) && f.TargetID == nil
+	}), models.PageRequest{Limit: 10}).Return(&models.ModerationLogPage{
+		Entries: []models.ModerationEntry{{ID: 1, Actor: "mod", Action: models.ModerationMessageDelete}},
+		Prev:    "older",
+	}, nil)
+
+	router := mux.NewRouter()
+	RegisterModerationHandlers(router, mockRepo, mockLog)
+	t.Cleanup(func() { moderationLog = nil })
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET",
+		"/api/moderation/log?actor=mod&action=message_delete&forum_id=2&from=2024-01-01&limit=10", ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page models.ModerationLogPage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page.Entries, 1)
+	assert.Equal(t, "older", page.Prev)
+	mockLog.AssertExpectations(t)
+}
+
+func TestGetModerationLogRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		url      string
+		wantCode int
+	}{
+		{name: "Moderator", role: "moderator", url: "/api/moderation/log", wantCode: http.StatusForbidden},
+		{name: "Invalid Target", role: "admin", url: "/api/moderation/log?target_id=abc", wantCode: http.StatusBadRequest},
+		{name: "Invalid Date", role: "admin", url: "/api/moderation/log.csv?from=yesterday", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockLog := new(mocks.MockModerationLogRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "user", Role: tt.role}, nil)
+
+			router := mux.NewRouter()
+			RegisterModerationHandlers(router, mockRepo, mockLog)
+			t.Cleanup(func() { moderationLog = nil })
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "GET", tt.url, ""))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			mockLog.AssertNotCalled(t, "GetModerationLog", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestExportModerationLog(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	forumID := 2
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockLog.On("ExportModerationLog", models.ModerationLogFilter{Actor: "mod"}, mock.Anything).Return([]models.ModerationEntry{
+		{ID: 7, Actor: "mod", Action: models.ModerationMessageDelete, TargetType: models.ModerationTargetMessage,
+			TargetID: 5, ForumID: &forumID, Reason: "=HYPERLINK(\"x\")", Before: json.RawMessage(`{"id":5}`), CreatedAt: createdAt},
+	}, nil)
+
+	router := mux.NewRouter()
+	RegisterModerationHandlers(router, mockRepo, mockLog)
+	t.Cleanup(func() { moderationLog = nil })
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/moderation/log.csv?actor=mod", ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv"))
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		moderationCSVHeader,
+		{"7", "2024-01-02T03:04:05Z", "mod", "message_delete", "message", "5", "2", "'=HYPERLINK(\"x\")", `{"id":5}`, ""},
+	}, records)
+}
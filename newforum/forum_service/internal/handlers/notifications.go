@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+var (
+	// notificationStore receives the notifications created by handlers. It
+	// is set by RegisterNotificationHandlers; without it nobody is notified.
+	notificationStore repository.NotificationsRepository
+
+	// userClients holds the notification channels of signed-in users, keyed
+	// by username. Events are sent from many goroutines and gorilla/websocket
+	// allows one writer per connection, so every connection has a mutex that
+	// is held while writing to it.
+	userClients   = make(map[string]map[*websocket.Conn]*sync.Mutex)
+	userClientsMu sync.RWMutex
+)
+
+func RegisterNotificationHandlers(r *mux.Router, repo repository.ForumsRepository, notes repository.NotificationsRepository) {
+	notificationStore = notes
+
+	r.HandleFunc("/ws/notifications", serveNotificationsWebSocket(repo))
+	r.HandleFunc("/notifications", NotificationsPage).Methods("GET")
+
+	r.HandleFunc("/api/notifications", GetNotifications(repo, notes)).Methods("GET")
+	r.HandleFunc("/api/notifications/unread-count", GetUnreadCount(repo, notes)).Methods("GET")
+	r.HandleFunc("/api/notifications/read-all", MarkAllNotificationsRead(repo, notes)).Methods("POST")
+	r.HandleFunc("/api/notifications/{id:[0-9]+}/read", MarkNotificationRead(repo, notes)).Methods("POST")
+	r.HandleFunc("/api/notifications/preferences", GetNotificationPreferences(repo, notes)).Methods("GET")
+	r.HandleFunc("/api/notifications/preferences", SetNotificationPreferences(repo, notes)).Methods("PUT")
+}
+
+// notify stores a notification and delivers it to the recipient's open
+// notification channels. Notifications users send themselves are dropped.
+// A failed write is logged but does not fail the action that caused it.
+func notify(n models.Notification) {
+	if notificationStore == nil || n.Username == "" || n.Username == n.Actor {
+		return
+	}
+	created, err := notificationStore.CreateNotification(n)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotificationMuted) {
+			log.Error("Failed to create notification",
+				logger.Error(err),
+				logger.String("type", n.Type),
+				logger.String("username", n.Username))
+		}
+		return
+	}
+	go sendToUser(created.Username, WSMessage{Type: "notification", Payload: created})
+}
+
+// notifyReply tells the author of parent that msg answers it.
+func notifyReply(msg *models.Message, parent *models.Message) {
+	if parent == nil {
+		return
+	}
+	notify(models.Notification{
+		Username:  parent.Author,
+		Type:      models.NotificationReply,
+		Actor:     msg.Author,
+		ForumID:   &msg.ForumID,
+		TopicID:   msg.TopicID,
+		MessageID: &msg.ID,
+	})
+}
+
+// notifyModeration tells the author of a message or topic that a moderator
+// acted on it. The target is taken from before, or from after for actions
+// such as restores that have no before state. Other targets, such as forums
+// and tags, have no author to notify. Report resolutions are announced by
+// ResolveReports, which knows whether anything was done to the message.
+func notifyModeration(entry models.ModerationEntry, before, after interface{}) {
+	if entry.Action == models.ModerationReportResolve {
+		return
+	}
+	n := models.Notification{
+		Type:    models.NotificationModeration,
+		Actor:   entry.Actor,
+		Detail:  entry.Action,
+		ForumID: entry.ForumID,
+	}
+	target := before
+	if target == nil {
+		target = after
+	}
+	switch t := target.(type) {
+	case *models.Message:
+		if t == nil {
+			return
+		}
+		n.Username = t.Author
+		n.TopicID = t.TopicID
+		n.MessageID = &t.ID
+	case *models.Topic:
+		if t == nil {
+			return
+		}
+		n.Username = t.Author
+		n.TopicID = &t.ID
+	default:
+		return
+	}
+	notify(n)
+}
+
+func registerUserClient(username string, conn *websocket.Conn) {
+	userClientsMu.Lock()
+	defer userClientsMu.Unlock()
+
+	if userClients[username] == nil {
+		userClients[username] = make(map[*websocket.Conn]*sync.Mutex)
+	}
+	userClients[username][conn] = &sync.Mutex{}
+}
+
+func unregisterUserClient(username string, conn *websocket.Conn) {
+	userClientsMu.Lock()
+	defer userClientsMu.Unlock()
+
+	if conns := userClients[username]; conns != nil {
+		delete(conns, conn)
+		if len(conns) == 0 {
+			delete(userClients, username)
+		}
+	}
+}
+
+// sendToUser delivers event to every notification channel username has open.
+func sendToUser(username string, event WSMessage) {
+	userClientsMu.RLock()
+	defer userClientsMu.RUnlock()
+
+	for conn, mu := range userClients[username] {
+		if err := writeJSON(conn, mu, event); err != nil {
+			log.Error("WS send error",
+				logger.Error(err),
+				logger.String("username", username))
+			go func(conn *websocket.Conn) {
+				unregisterUserClient(username, conn)
+				conn.Close()
+			}(conn)
+		}
+	}
+}
+
+// serveNotificationsWebSocket opens a user's notification channel. Browsers
+// cannot set headers on WebSocket requests, so the token comes in the query
+// string.
+func serveNotificationsWebSocket(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := tokenUser(r.URL.Query().Get("token"), repo)
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Error("WebSocket upgrade error", logger.Error(err))
+			return
+		}
+		defer func() {
+			unregisterUserClient(user.Username, conn)
+			conn.Close()
+		}()
+
+		registerUserClient(user.Username, conn)
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
+					log.Error("WebSocket error", logger.Error(err))
+				}
+				break
+			}
+		}
+	}
+}
+
+func NotificationsPage(w http.ResponseWriter, r *http.Request) {
+	renderTemplate(w, "notifications.html", nil)
+}
+
+// GetNotifications godoc
+// @Summary List notifications
+// @Description Get a page of the current user's notifications, newest first, with the number of unread notifications
+// @Tags notifications
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Param before query string false "Cursor to read older notifications"
+// @Param after query string false "Cursor to read newer notifications"
+// @Param limit query int false "Page size (max 100)"
+// @Security BearerAuth
+// @Success 200 {object} models.NotificationPage
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /notifications [get]
+func GetNotifications(repo repository.ForumsRepository, notes repository.NotificationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		unreadOnly, _ := strconv.ParseBool(r.URL.Query().Get("unread"))
+
+		result, err := notes.GetNotifications(user.Username, unreadOnly, page)
+		if err != nil {
+			log.Error("Failed to load notifications", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to load notifications")
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// GetUnreadCount godoc
+// @Summary Unread notifications
+// @Description Get the number of unread notifications of the current user
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Failure 401 {object} map[string]string
+// @Router /notifications/unread-count [get]
+func GetUnreadCount(repo repository.ForumsRepository, notes repository.NotificationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		count, err := notes.CountUnread(user.Username)
+		if err != nil {
+			log.Error("Failed to count notifications", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to count notifications")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]int{"unread": count})
+	}
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark notification read
+// @Description Mark one of the current user's notifications as read
+// @Tags notifications
+// @Param id path int true "Notification ID"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /notifications/{id}/read [post]
+func MarkNotificationRead(repo repository.ForumsRepository, notes repository.NotificationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid notification ID")
+			return
+		}
+
+		if err := notes.MarkRead(user.Username, id); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Notification not found")
+				return
+			}
+			log.Error("Failed to mark notification read", logger.Error(err), logger.Int("notificationID", id))
+			sendError(w, http.StatusInternalServerError, "Failed to mark notification read")
+			return
+		}
+
+		go sendToUser(user.Username, WSMessage{Type: "notifications_read", Payload: map[string]int{"id": id}})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark all notifications read
+// @Description Mark every unread notification of the current user as read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int64
+// @Failure 401 {object} map[string]string
+// @Router /notifications/read-all [post]
+func MarkAllNotificationsRead(repo repository.ForumsRepository, notes repository.NotificationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		updated, err := notes.MarkAllRead(user.Username)
+		if err != nil {
+			log.Error("Failed to mark notifications read", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to mark notifications read")
+			return
+		}
+
+		go sendToUser(user.Username, WSMessage{Type: "notifications_read", Payload: map[string]bool{"all": true}})
+
+		json.NewEncoder(w).Encode(map[string]int64{"updated": updated})
+	}
+}
+
+// GetNotificationPreferences godoc
+// @Summary Notification preferences
+// @Description Get which notification types the current user receives
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]bool
+// @Failure 401 {object} map[string]string
+// @Router /notifications/preferences [get]
+func GetNotificationPreferences(repo repository.ForumsRepository, notes repository.NotificationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		prefs, err := notes.GetNotificationPreferences(user.Username)
+		if err != nil {
+			log.Error("Failed to load notification preferences", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to load notification preferences")
+			return
+		}
+		json.NewEncoder(w).Encode(prefs)
+	}
+}
+
+// SetNotificationPreferences godoc
+// @Summary Change notification preferences
+// @Description Turn notification types on or off for the current user. Types left out keep their setting
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param preferences body map[string]bool true "Type to enabled"
+// @Security BearerAuth
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /notifications/preferences [put]
+func SetNotificationPreferences(repo repository.ForumsRepository, notes repository.NotificationsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var prefs map[string]bool
+		if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		for t := range prefs {
+			if !models.ValidNotificationType(t) {
+				sendError(w, http.StatusBadRequest, "Unknown notification type: "+t)
+				return
+			}
+		}
+
+		if err := notes.SetNotificationPreferences(user.Username, prefs); err != nil {
+			log.Error("Failed to save notification preferences", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to save notification preferences")
+			return
+		}
+
+		updated, err := notes.GetNotificationPreferences(user.Username)
+		if err != nil {
+			log.Error("Failed to load notification preferences", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to load notification preferences")
+			return
+		}
+		json.NewEncoder(w).Encode(updated)
+	}
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/core/pkg/jwt"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// useNotifications installs notes for the duration of the test.
+func useNotifications(t *testing.T, notes *mocks.MockNotificationsRepo) {
+	t.Helper()
+	notificationStore = notes
+	t.Cleanup(func() { notificationStore = nil })
+}
+
+// dialNotifications opens the notification channel of user 1. The
+// registered channels are dropped when the test ends so they do not leak
+// into the next one.
+func dialNotifications(t *testing.T, repo *mocks.MockForumsRepo) *websocket.Conn {
+	t.Helper()
+	t.Cleanup(func() {
+		userClientsMu.Lock()
+		userClients = make(map[string]map[*websocket.Conn]*sync.Mutex)
+		userClientsMu.Unlock()
+	})
+	server := httptest.NewServer(serveNotificationsWebSocket(repo))
+	t.Cleanup(server.Close)
+
+	token, err := jwt.GenerateToken(1, testSecretKey, time.Hour)
+	assert.NoError(t, err)
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?token="+token, nil)
+	if err != nil {
+		t.Fatalf("could not open a ws connection: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	time.Sleep(100 * time.Millisecond)
+	return ws
+}
+
+func TestNotifyDeliversToUserChannel(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	useNotifications(t, mockNotes)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	ws := dialNotifications(t, mockRepo)
+
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Type == models.NotificationReaction
+	})).Return(nil, repository.ErrNotificationMuted)
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Type == models.NotificationReply
+	})).Return(&models.Notification{ID: 4, Username: "bob", Type: models.NotificationReply, Actor: "alice"}, nil)
+
+	notify(models.Notification{Username: "bob", Type: models.NotificationReaction, Actor: "alice"})
+	notify(models.Notification{Username: "bob", Type: models.NotificationMention, Actor: "bob"})
+	notify(models.Notification{Username: "bob", Type: models.NotificationReply, Actor: "alice"})
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var event struct {
+		Type    string              `json:"type"`
+		Payload models.Notification `json:"payload"`
+	}
+	if err := ws.ReadJSON(&event); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "notification", event.Type)
+	assert.Equal(t, 4, event.Payload.ID)
+	mockNotes.AssertNumberOfCalls(t, "CreateNotification", 2)
+}
+
+func TestSendToUserConcurrent(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	ws := dialNotifications(t, mockRepo)
+
+	// Events for one user are sent from many goroutines at once and must
+	// not write to the connection concurrently.
+	const events = 20
+	for i := 0; i < events; i++ {
+		go sendToUser("bob", WSMessage{Type: "notifications_read", Payload: map[string]int{"id": i}})
+	}
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < events; i++ {
+		var event WSMessage
+		if err := ws.ReadJSON(&event); err != nil {
+			t.Fatalf("could not read message %d: %v", i, err)
+		}
+		assert.Equal(t, "notifications_read", event.Type)
+	}
+}
+
+func TestNotificationsWebSocketUnauthorized(t *testing.T) {
+	server := httptest.NewServer(serveNotificationsWebSocket(new(mocks.MockForumsRepo)))
+	defer server.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Error(t, err)
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestPostMessageNotifiesReply(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	useNotifications(t, mockNotes)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessageByID", 3).Return(&models.Message{ID: 3, ForumID: 1, Author: "bob"}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(8, nil)
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Username == "bob" && n.Type == models.NotificationReply && n.Actor == "alice" && *n.MessageID == 8
+	})).Return(&models.Notification{ID: 1, Username: "bob"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages",
+		`{"author":"alice","content":"agreed","reply_to":3}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	mockNotes.AssertExpectations(t)
+}
+
+func TestModerationNotifiesAuthor(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	useNotifications(t, mockNotes)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 2, Author: "user2"}, nil)
+	mockRepo.On("DeleteMessage", 5, "admin").Return(nil)
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Username == "user2" && n.Type == models.NotificationModeration && n.Actor == "admin" &&
+			n.Detail == models.ModerationMessageDelete && *n.MessageID == 5 && *n.ForumID == 2
+	})).Return(&models.Notification{ID: 1, Username: "user2"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{forum_id}/messages/{message_id}", DeleteMessage(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/forums/2/messages/5", ""))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockNotes.AssertExpectations(t)
+}
+
+func TestGetNotifications(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockNotes.On("GetNotifications", "bob", true, mock.MatchedBy(func(p models.PageRequest) bool {
+		return p.Limit == 5
+	})).Return(&models.NotificationPage{
+		Notifications: []models.Notification{{ID: 2, Username: "bob", Type: models.NotificationMention}},
+		Unread:        1,
+	}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterNotificationHandlers(r, mockRepo, mockNotes) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/notifications?unread=true&limit=5", ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.NotificationPage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, 1, got.Unread)
+	assert.Len(t, got.Notifications, 1)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/notifications", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestMarkNotificationsRead(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockNotes.On("MarkRead", "bob", 2).Return(nil)
+	mockNotes.On("MarkRead", "bob", 9).Return(repository.ErrNotFound)
+	mockNotes.On("MarkAllRead", "bob").Return(int64(3), nil)
+	mockNotes.On("CountUnread", "bob").Return(0, nil)
+	router := testRouter(t, func(r *mux.Router) { RegisterNotificationHandlers(r, mockRepo, mockNotes) })
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/notifications/2/read", ""))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/notifications/9/read", ""))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/notifications/read-all", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"updated":3}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/notifications/unread-count", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"unread":0}`, rr.Body.String())
+}
+
+func TestSetNotificationPreferences(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockNotes.On("SetNotificationPreferences", "bob", map[string]bool{"reaction": false}).Return(nil)
+	mockNotes.On("GetNotificationPreferences", "bob").Return(map[string]bool{
+		"reply": true, "mention": true, "reaction": false, "moderation": true, "forum_activity": true,
+	}, nil)
+	router := testRouter(t, func(r *mux.Router) { RegisterNotificationHandlers(r, mockRepo, mockNotes) })
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/notifications/preferences", `{"reaction":false}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got map[string]bool
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.False(t, got["reaction"])
+	assert.True(t, got["reply"])
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/notifications/preferences", `{"newsletter":false}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockNotes.AssertNumberOfCalls(t, "SetNotificationPreferences", 1)
+}
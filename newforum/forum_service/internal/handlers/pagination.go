@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+var errInvalidPage = errors.New("invalid pagination parameters")
+
+// parsePageRequest reads the before, after and limit query parameters.
+func parsePageRequest(r *http.Request) (models.PageRequest, error) {
+	q := r.URL.Query()
+	page := models.PageRequest{Limit: defaultPageLimit}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return page, errInvalidPage
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		page.Limit = limit
+	}
+
+	before, after := q.Get("before"), q.Get("after")
+	if before != "" && after != "" {
+		return page, errInvalidPage
+	}
+	if before != "" {
+		c, err := models.DecodeCursor(before)
+		if err != nil {
+			return page, errInvalidPage
+		}
+		page.Before = &c
+	}
+	if after != "" {
+		c, err := models.DecodeCursor(after)
+		if err != nil {
+			return page, errInvalidPage
+		}
+		page.After = &c
+	}
+	return page, nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParsePageRequest(t *testing.T) {
+	cursor := models.Cursor{CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ID: 7}
+	token := cursor.Encode()
+
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "Defaults", query: "", wantLimit: defaultPageLimit},
+		{name: "Custom Limit", query: "?limit=20", wantLimit: 20},
+		{name: "Limit Clamped", query: "?limit=1000", wantLimit: maxPageLimit},
+		{name: "Before Cursor", query: "?before=" + token, wantLimit: defaultPageLimit},
+		{name: "After Cursor", query: "?after=" + token, wantLimit: defaultPageLimit},
+		{name: "Invalid Limit", query: "?limit=abc", wantErr: true},
+		{name: "Zero Limit", query: "?limit=0", wantErr: true},
+		{name: "Invalid Cursor", query: "?before=not-a-cursor", wantErr: true},
+		{name: "Both Cursors", query: "?before=" + token + "&after=" + token, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := parsePageRequest(httptest.NewRequest("GET", "/forums/1/messages-list"+tt.query, nil))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errInvalidPage)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantLimit, page.Limit)
+		})
+	}
+}
+
+func TestGetMessagesAPIPagination(t *testing.T) {
+	cursor := models.Cursor{CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ID: 7}
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.MatchedBy(func(page models.PageRequest) bool {
+		return page.Before != nil && page.Before.ID == 7 && page.Limit == 10
+	})).Return(&models.MessagePage{
+		Messages: []models.Message{{ID: 5, ForumID: 1, Content: "older"}},
+		Prev:     "prev-cursor",
+		Next:     "next-cursor",
+	}, nil)
+	mockRepo.On("GetReactions", []int{5}, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", []int{5}).Return(map[int][]string{}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages-list", GetMessagesAPI(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages-list?limit=10&before="+cursor.Encode(), nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got struct {
+		Messages []models.Message `json:"messages"`
+		Prev     string           `json:"prev"`
+		Next     string           `json:"next"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Len(t, got.Messages, 1)
+	assert.Equal(t, "prev-cursor", got.Prev)
+	assert.Equal(t, "next-cursor", got.Next)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetMessagesAPIInvalidPage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages-list", GetMessagesAPI(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages-list?before=bogus", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNotCalled(t, "GetMessagesPage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetAllForumsInvalidPage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/forums-list", GetAllForums(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums-list?limit=-1", nil))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNotCalled(t, "GetForumsPage", mock.Anything)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+type pinRequest struct {
+	Announcement bool `json:"announcement"`
+}
+
+func RegisterPinHandlers(r *mux.Router, repo repository.ForumsRepository, limit int) {
+	r.HandleFunc("/api/forums/{id:[0-9]+}/pinned", GetPinnedMessages(repo)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}/pinned", GetPinnedMessages(repo)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/pin", PinMessage(repo, limit)).Methods("POST")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/pin", UnpinMessage(repo)).Methods("DELETE")
+}
+
+// broadcastForumWide sends an event about msg on the forum channel and, for
+// messages in a topic, on the topic channel as well.
+func broadcastForumWide(msg *models.Message, event WSMessage) {
+	broadcastToForum(msg.ForumID, event)
+	if msg.TopicID != nil {
+		broadcastToTopic(*msg.TopicID, event)
+	}
+}
+
+// broadcastPinChange sends a pin or unpin event about msg, whose state was
+// before. Announcements show in every stream of the forum, so events about a
+// message that is or was one go to all topic channels of the forum.
+func broadcastPinChange(before, msg *models.Message, event WSMessage) {
+	if !before.Announcement && !msg.Announcement {
+		broadcastForumWide(msg, event)
+		return
+	}
+	broadcastToForum(msg.ForumID, event)
+	broadcastToForumTopics(msg.ForumID, event)
+}
+
+// forumMessage loads the message named by the route and makes sure it
+// belongs to the forum in the same route.
+func forumMessage(r *http.Request, repo repository.ForumsRepository) (*models.Message, error) {
+	vars := mux.Vars(r)
+	forumID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+	messageID, err := strconv.Atoi(vars["message_id"])
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+
+	msg, err := repo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.ForumID != forumID {
+		return nil, repository.ErrNotFound
+	}
+	return msg, nil
+}
+
+// GetPinnedMessages godoc
+// @Summary Pinned messages
+// @Description List the announcements of a forum followed by the messages pinned in the forum or topic stream
+// @Tags messages
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param topic_id path int false "Topic ID"
+// @Success 200 {array} models.Message
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/pinned [get]
+func GetPinnedMessages(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Topic not found")
+			return
+		}
+		var topicID *int
+		if topic != nil {
+			topicID = &topic.ID
+		}
+
+		pinned, err := repo.GetPinnedMessages(forumID, topicID)
+		if err != nil {
+			log.Error("Failed to load pinned messages", logger.Error(err), logger.Int("forumID", forumID))
+			sendError(w, http.StatusInternalServerError, "Failed to load pinned messages")
+			return
+		}
+		redactHidden(pinned, isModerator(requestUser(r, repo)))
+		json.NewEncoder(w).Encode(pinned)
+	}
+}
+
+// PinMessage godoc
+// @Summary Pin message
+// @Description Pin a message above the message stream, optionally as a forum-wide announcement. Pinning an already pinned message changes its announcement flag. Moderators only
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Param pin body pinRequest false "Pin options"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 200 {object} models.Message
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/pin [post]
+func PinMessage(repo repository.ForumsRepository, limit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req pinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+
+		before, err := forumMessage(r, repo)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		msg, err := repo.PinMessage(before.ID, user.Username, req.Announcement, limit)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				sendError(w, http.StatusNotFound, "Message not found")
+			case errors.Is(err, repository.ErrPinLimit):
+				sendError(w, http.StatusConflict, "This forum already has "+strconv.Itoa(limit)+" pinned messages")
+			default:
+				log.Error("Failed to pin message", logger.Error(err), logger.Int("messageID", before.ID))
+				sendError(w, http.StatusInternalServerError, "Failed to pin message")
+			}
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationMessagePin,
+			TargetType: models.ModerationTargetMessage,
+			TargetID:   msg.ID,
+			ForumID:    &msg.ForumID,
+			Reason:     moderationReason(r),
+		}, before, msg)
+
+		event := []models.Message{*msg}
+		redactHidden(event, false)
+		go broadcastPinChange(before, msg, WSMessage{Type: "message_pinned", Payload: event[0]})
+
+		json.NewEncoder(w).Encode(msg)
+	}
+}
+
+// UnpinMessage godoc
+// @Summary Unpin message
+// @Description Return a pinned message or announcement to the normal stream. Moderators only
+// @Tags messages
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/pin [delete]
+func UnpinMessage(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		before, err := forumMessage(r, repo)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		msg, err := repo.UnpinMessage(before.ID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Message is not pinned")
+				return
+			}
+			log.Error("Failed to unpin message", logger.Error(err), logger.Int("messageID", before.ID))
+			sendError(w, http.StatusInternalServerError, "Failed to unpin message")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationMessageUnpin,
+			TargetType: models.ModerationTargetMessage,
+			TargetID:   msg.ID,
+			ForumID:    &msg.ForumID,
+			Reason:     moderationReason(r),
+		}, before, msg)
+
+		go broadcastPinChange(before, msg, WSMessage{
+			Type:    "message_unpinned",
+			Payload: map[string]int{"messageId": msg.ID},
+		})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
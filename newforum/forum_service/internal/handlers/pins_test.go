@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPinMessage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	useModerationLog(t, mockLog)
+
+	pinnedAt := time.Now()
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 43, Content: "Rules"}, nil)
+	mockRepo.On("PinMessage", 5, "mod", true, 3).Return(&models.Message{
+		ID: 5, ForumID: 43, Content: "Rules", PinnedAt: &pinnedAt, PinnedBy: "mod", Announcement: true,
+	}, nil)
+	mockLog.On("LogAction", mock.MatchedBy(func(e models.ModerationEntry) bool {
+		return e.Actor == "mod" && e.Action == models.ModerationMessagePin && e.TargetID == 5 && *e.ForumID == 43
+	})).Return(nil)
+
+	// The announcement is shown on the forum page and on every topic page.
+	channels := []*websocket.Conn{dialForum(t, "43"), dialTopic(t, mockRepo, 43, 6)}
+
+	router := testRouter(t, func(r *mux.Router) { RegisterPinHandlers(r, mockRepo, 3) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/forums/43/messages/5/pin", `{"announcement":true}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.True(t, got.Announcement)
+
+	for _, ws := range channels {
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		var event struct {
+			Type    string         `json:"type"`
+			Payload models.Message `json:"payload"`
+		}
+		if err := ws.ReadJSON(&event); err != nil {
+			t.Fatalf("could not read message: %v", err)
+		}
+		assert.Equal(t, "message_pinned", event.Type)
+		assert.Equal(t, 5, event.Payload.ID)
+	}
+	mockLog.AssertExpectations(t)
+}
+
+func TestPinMessageRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		url      string
+		pinErr   error
+		wantCode int
+	}{
+		{name: "Not Moderator", role: "user", url: "/api/forums/1/messages/5/pin", wantCode: http.StatusForbidden},
+		{name: "Other Forum", role: "moderator", url: "/api/forums/2/messages/5/pin", wantCode: http.StatusNotFound},
+		{name: "Limit Reached", role: "moderator", url: "/api/forums/1/messages/5/pin", pinErr: repository.ErrPinLimit, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: tt.role}, nil)
+			mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1}, nil)
+			if tt.pinErr != nil {
+				mockRepo.On("PinMessage", 5, "mod", false, 3).Return(nil, tt.pinErr)
+			}
+
+			router := testRouter(t, func(r *mux.Router) { RegisterPinHandlers(r, mockRepo, 3) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "POST", tt.url, ""))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.pinErr == nil {
+				mockRepo.AssertNotCalled(t, "PinMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUnpinMessage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1}, nil)
+	mockRepo.On("UnpinMessage", 5).Return(&models.Message{ID: 5, ForumID: 1}, nil).Once()
+	mockRepo.On("UnpinMessage", 5).Return(nil, repository.ErrNotFound)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterPinHandlers(r, mockRepo, 3) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/1/messages/5/pin", ""))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/1/messages/5/pin", ""))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestUnpinAnnouncementReachesTopicChannels(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	topicID := 5
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 44, TopicID: &topicID, Announcement: true}, nil)
+	mockRepo.On("UnpinMessage", 5).Return(&models.Message{ID: 5, ForumID: 44, TopicID: &topicID}, nil)
+
+	// Topic 6 showed the announcement from topic 5 and has to drop it.
+	ws := dialTopic(t, mockRepo, 44, 6)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterPinHandlers(r, mockRepo, 3) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/44/messages/5/pin", ""))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var event WSMessage
+	if err := ws.ReadJSON(&event); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "message_unpinned", event.Type)
+}
+
+func TestGetPinnedMessages(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	topicID := 3
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1}, nil)
+	mockRepo.On("GetPinnedMessages", 1, &topicID).Return([]models.Message{
+		{ID: 1, ForumID: 1, Content: "Announcement", Announcement: true},
+		{ID: 2, ForumID: 1, TopicID: &topicID, Content: "Spam", Hidden: true},
+	}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterPinHandlers(r, mockRepo, 3) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums/1/topics/3/pinned", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got []models.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Len(t, got, 2)
+	assert.Equal(t, "Announcement", got[0].Content)
+	assert.Empty(t, got[1].Content)
+}
+
+func TestGetMessagesAPIPinnedOnFirstPage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(&models.MessagePage{
+		Messages: []models.Message{{ID: 9, ForumID: 1, Content: "Latest"}},
+	}, nil)
+	mockRepo.On("GetPinnedMessages", 1, (*int)(nil)).Return([]models.Message{{ID: 2, ForumID: 1, Content: "Pinned"}}, nil)
+	mockRepo.On("GetReactions", mock.Anything, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", mock.Anything).Return(map[int][]string{}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages-list", GetMessagesAPI(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages-list", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got struct {
+		Pinned   []models.Message `json:"pinned"`
+		Messages []models.Message `json:"messages"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Len(t, got.Pinned, 1)
+	assert.Equal(t, "Pinned", got.Pinned[0].Content)
+	mockRepo.AssertExpectations(t)
+}
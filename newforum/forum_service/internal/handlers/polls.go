@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+type pollRequest struct {
+	Question       string     `json:"question"`
+	Options        []string   `json:"options"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	Anonymous      bool       `json:"anonymous"`
+	AllowChange    bool       `json:"allow_change"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+type voteRequest struct {
+	Options []int `json:"options"`
+}
+
+// pollStore holds the polls attached to messages. It is set by
+// RegisterPollHandlers; without it messages are listed without polls.
+var pollStore repository.PollsRepository
+
+func RegisterPollHandlers(r *mux.Router, repo repository.ForumsRepository, polls repository.PollsRepository) {
+	pollStore = polls
+
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/poll", GetPoll(repo, polls)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/poll", CreatePoll(repo, polls)).Methods("POST")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/poll/vote", Vote(repo, polls)).Methods("PUT")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/poll/vote", RetractVote(repo, polls)).Methods("DELETE")
+}
+
+// attachPolls fills in the polls of msgs as seen by username.
+func attachPolls(username string, msgs ...[]models.Message) error {
+	ids := messageIDs(msgs...)
+	if pollStore == nil || len(ids) == 0 {
+		return nil
+	}
+
+	polls, err := pollStore.GetPolls(ids, username)
+	if err != nil {
+		return err
+	}
+	for _, list := range msgs {
+		for i := range list {
+			list[i].Poll = polls[list[i].ID]
+		}
+	}
+	return nil
+}
+
+// broadcastPoll sends the current results of the poll on msg to everyone
+// watching the message.
+func broadcastPoll(msg *models.Message, poll *models.Poll) {
+	broadcastForumWide(msg, WSMessage{Type: "poll_updated", Payload: poll.Results()})
+}
+
+// GetPoll godoc
+// @Summary Get poll
+// @Description Get the poll attached to a message with its results. Signed-in users also get the options they voted for
+// @Tags polls
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} models.Poll
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/poll [get]
+func GetPoll(repo repository.ForumsRepository, polls repository.PollsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		msg, err := forumMessage(r, repo)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		var username string
+		if user := requestUser(r, repo); user != nil {
+			username = user.Username
+		}
+		poll, err := polls.GetPoll(msg.ID, username)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Poll not found")
+				return
+			}
+			log.Error("Failed to load poll", logger.Error(err), logger.Int("messageID", msg.ID))
+			sendError(w, http.StatusInternalServerError, "Failed to load poll")
+			return
+		}
+		json.NewEncoder(w).Encode(poll)
+	}
+}
+
+// CreatePoll godoc
+// @Summary Add poll to message
+// @Description Attach a poll to one of the current user's messages. Polls are single or multiple choice, public or anonymous, may let voters change their vote and may close at a given time
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Param poll body pollRequest true "Poll"
+// @Security BearerAuth
+// @Success 201 {object} models.Poll
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/poll [post]
+func CreatePoll(repo repository.ForumsRepository, polls repository.PollsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req pollRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+
+		msg, err := forumMessage(r, repo)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		if msg.Author != user.Username {
+			sendError(w, http.StatusForbidden, "Only the author can add a poll to a message")
+			return
+		}
+		if !checkForumWritable(w, repo, msg.ForumID, user) {
+			return
+		}
+
+		poll := models.Poll{
+			MessageID:      msg.ID,
+			Question:       req.Question,
+			MultipleChoice: req.MultipleChoice,
+			Anonymous:      req.Anonymous,
+			AllowChange:    req.AllowChange,
+			ClosesAt:       req.ClosesAt,
+		}
+		for _, text := range req.Options {
+			poll.Options = append(poll.Options, models.PollOption{Text: text})
+		}
+		if err := poll.Validate(time.Now()); err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		created, err := polls.CreatePoll(poll)
+		if err != nil {
+			if errors.Is(err, repository.ErrPollExists) {
+				sendError(w, http.StatusConflict, "This message already has a poll")
+				return
+			}
+			log.Error("Failed to create poll", logger.Error(err), logger.Int("messageID", msg.ID))
+			sendError(w, http.StatusInternalServerError, "Failed to create poll")
+			return
+		}
+
+		go broadcastPoll(msg, created)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(created)
+	}
+}
+
+// Vote godoc
+// @Summary Vote in poll
+// @Description Vote for one option, or several in a multiple choice poll. Each user votes once; voting again replaces the vote if the poll allows changing it
+// @Tags polls
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Param vote body voteRequest true "Chosen option IDs"
+// @Security BearerAuth
+// @Success 200 {object} models.Poll
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/poll/vote [put]
+func Vote(repo repository.ForumsRepository, polls repository.PollsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req voteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+
+		msg, poll, ok := messagePoll(w, r, repo, polls, user)
+		if !ok {
+			return
+		}
+		if err := poll.ValidateVote(req.Options); err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := polls.Vote(poll.ID, user.Username, req.Options); err != nil {
+			sendVoteError(w, err, poll.ID, "You have already voted in this poll")
+			return
+		}
+		votedPoll(w, msg, polls, user)
+	}
+}
+
+// RetractVote godoc
+// @Summary Take back vote
+// @Description Take back the current user's vote in a poll that allows changing votes
+// @Tags polls
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Security BearerAuth
+// @Success 200 {object} models.Poll
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/poll/vote [delete]
+func RetractVote(repo repository.ForumsRepository, polls repository.PollsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		msg, poll, ok := messagePoll(w, r, repo, polls, user)
+		if !ok {
+			return
+		}
+
+		if err := polls.RetractVote(poll.ID, user.Username); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "You have not voted in this poll")
+				return
+			}
+			sendVoteError(w, err, poll.ID, "Votes in this poll cannot be changed")
+			return
+		}
+		votedPoll(w, msg, polls, user)
+	}
+}
+
+// messagePoll loads the message named by the route and its poll, making
+// sure user may still vote in it.
+func messagePoll(w http.ResponseWriter, r *http.Request, repo repository.ForumsRepository, polls repository.PollsRepository, user *models.User) (*models.Message, *models.Poll, bool) {
+	msg, err := forumMessage(r, repo)
+	if err != nil {
+		sendError(w, http.StatusNotFound, "Message not found")
+		return nil, nil, false
+	}
+	if !checkForumWritable(w, repo, msg.ForumID, user) {
+		return nil, nil, false
+	}
+
+	poll, err := polls.GetPoll(msg.ID, user.Username)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			sendError(w, http.StatusNotFound, "Poll not found")
+			return nil, nil, false
+		}
+		log.Error("Failed to load poll", logger.Error(err), logger.Int("messageID", msg.ID))
+		sendError(w, http.StatusInternalServerError, "Failed to load poll")
+		return nil, nil, false
+	}
+	if poll.Closed(time.Now()) {
+		sendError(w, http.StatusConflict, "Poll is closed")
+		return nil, nil, false
+	}
+	return msg, poll, true
+}
+
+func sendVoteError(w http.ResponseWriter, err error, pollID int, final string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		sendError(w, http.StatusNotFound, "Poll not found")
+	case errors.Is(err, repository.ErrPollClosed):
+		sendError(w, http.StatusConflict, "Poll is closed")
+	case errors.Is(err, repository.ErrVoteFinal):
+		sendError(w, http.StatusConflict, final)
+	default:
+		log.Error("Failed to save vote", logger.Error(err), logger.Int("pollID", pollID))
+		sendError(w, http.StatusInternalServerError, "Failed to save vote")
+	}
+}
+
+// votedPoll answers a vote with the poll as the voter now sees it and
+// broadcasts the new results.
+func votedPoll(w http.ResponseWriter, msg *models.Message, polls repository.PollsRepository, user *models.User) {
+	poll, err := polls.GetPoll(msg.ID, user.Username)
+	if err != nil {
+		log.Error("Failed to load poll", logger.Error(err), logger.Int("messageID", msg.ID))
+		sendError(w, http.StatusInternalServerError, "Failed to load poll")
+		return
+	}
+
+	go broadcastPoll(msg, poll)
+
+	json.NewEncoder(w).Encode(poll)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testPoll(multiple, allowChange bool) *models.Poll {
+	return &models.Poll{
+		ID:             3,
+		MessageID:      5,
+		Question:       "Lunch?",
+		MultipleChoice: multiple,
+		AllowChange:    allowChange,
+		Options:        []models.PollOption{{ID: 1, Text: "Pizza"}, {ID: 2, Text: "Sushi"}},
+	}
+}
+
+func TestCreatePoll(t *testing.T) {
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	tests := []struct {
+		name     string
+		auth     bool
+		author   string
+		body     string
+		err      error
+		wantCode int
+	}{
+		{name: "Created", auth: true, author: "alice", body: `{"question":" Lunch? ","options":["Pizza"," Sushi "],"multiple_choice":true}`, wantCode: http.StatusCreated},
+		{name: "Unauthorized", author: "alice", body: `{}`, wantCode: http.StatusUnauthorized},
+		{name: "Not The Author", auth: true, author: "bob", body: `{"question":"Lunch?","options":["Pizza","Sushi"]}`, wantCode: http.StatusForbidden},
+		{name: "No Question", auth: true, author: "alice", body: `{"question":" ","options":["Pizza","Sushi"]}`, wantCode: http.StatusBadRequest},
+		{name: "One Option", auth: true, author: "alice", body: `{"question":"Lunch?","options":["Pizza"]}`, wantCode: http.StatusBadRequest},
+		{name: "Duplicate Options", auth: true, author: "alice", body: `{"question":"Lunch?","options":["Pizza","Pizza "]}`, wantCode: http.StatusBadRequest},
+		{name: "Closes In Past", auth: true, author: "alice", body: `{"question":"Lunch?","options":["Pizza","Sushi"],"closes_at":"` + past + `"}`, wantCode: http.StatusBadRequest},
+		{name: "Already Has Poll", auth: true, author: "alice", body: `{"question":"Lunch?","options":["Pizza","Sushi"]}`, err: repository.ErrPollExists, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockPolls := new(mocks.MockPollsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+			mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1, Author: tt.author}, nil)
+			mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+			var created models.Poll
+			mockPolls.On("CreatePoll", mock.AnythingOfType("models.Poll")).
+				Run(func(args mock.Arguments) { created = args.Get(0).(models.Poll) }).
+				Return(&models.Poll{ID: 3, MessageID: 5}, tt.err)
+
+			req := httptest.NewRequest("POST", "/api/forums/1/messages/5/poll", nil)
+			if tt.auth {
+				req = authorizedRequest(t, "POST", "/api/forums/1/messages/5/poll", tt.body)
+			}
+			router := testRouter(t, func(r *mux.Router) { RegisterPollHandlers(r, mockRepo, mockPolls) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantCode == http.StatusCreated {
+				assert.Equal(t, 5, created.MessageID)
+				assert.Equal(t, "Lunch?", created.Question)
+				assert.Equal(t, []models.PollOption{{Text: "Pizza"}, {Text: "Sushi"}}, created.Options)
+				assert.True(t, created.MultipleChoice)
+			}
+		})
+	}
+}
+
+func TestVote(t *testing.T) {
+	closed := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name     string
+		poll     *models.Poll
+		body     string
+		voteErr  error
+		wantCode int
+	}{
+		{name: "Single Choice", poll: testPoll(false, false), body: `{"options":[2]}`, wantCode: http.StatusOK},
+		{name: "Multiple Choice", poll: testPoll(true, false), body: `{"options":[1,2]}`, wantCode: http.StatusOK},
+		{name: "Two Options In Single Choice", poll: testPoll(false, false), body: `{"options":[1,2]}`, wantCode: http.StatusBadRequest},
+		{name: "Same Option Twice", poll: testPoll(true, false), body: `{"options":[1,1]}`, wantCode: http.StatusBadRequest},
+		{name: "Unknown Option", poll: testPoll(false, false), body: `{"options":[9]}`, wantCode: http.StatusBadRequest},
+		{name: "No Option", poll: testPoll(false, false), body: `{"options":[]}`, wantCode: http.StatusBadRequest},
+		{name: "Already Voted", poll: testPoll(false, false), body: `{"options":[1]}`, voteErr: repository.ErrVoteFinal, wantCode: http.StatusConflict},
+		{name: "Closed", poll: &models.Poll{ID: 3, MessageID: 5, ClosesAt: &closed}, body: `{"options":[1]}`, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockPolls := new(mocks.MockPollsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+			mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1, Author: "alice"}, nil)
+			mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+			mockPolls.On("GetPoll", 5, "bob").Return(tt.poll, nil)
+			mockPolls.On("Vote", 3, "bob", mock.Anything).Return(tt.voteErr)
+
+			router := testRouter(t, func(r *mux.Router) { RegisterPollHandlers(r, mockRepo, mockPolls) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/1/messages/5/poll/vote", tt.body))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantCode == http.StatusBadRequest {
+				mockPolls.AssertNotCalled(t, "Vote", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestVoteBroadcastsResults(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockPolls := new(mocks.MockPollsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 45, Author: "alice"}, nil)
+	mockRepo.On("GetByID", 45).Return(&models.Forum{ID: 45}, nil)
+
+	voted := testPoll(false, true)
+	voted.Options[1].Votes = 1
+	voted.Options[1].Voters = []string{"bob"}
+	voted.Voters = 1
+	voted.Voted = []int{2}
+	mockPolls.On("GetPoll", 5, "bob").Return(testPoll(false, true), nil).Once()
+	mockPolls.On("GetPoll", 5, "bob").Return(voted, nil).Once()
+	mockPolls.On("Vote", 3, "bob", []int{2}).Return(nil)
+
+	ws := dialForum(t, "45")
+
+	router := testRouter(t, func(r *mux.Router) { RegisterPollHandlers(r, mockRepo, mockPolls) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/45/messages/5/poll/vote", `{"options":[2]}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.Poll
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, []int{2}, got.Voted)
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var event struct {
+		Type    string      `json:"type"`
+		Payload models.Poll `json:"payload"`
+	}
+	if err := ws.ReadJSON(&event); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "poll_updated", event.Type)
+	assert.Equal(t, 1, event.Payload.Voters)
+	assert.Equal(t, []string{"bob"}, event.Payload.Options[1].Voters)
+	assert.Empty(t, event.Payload.Voted, "other viewers must not see the voter's choice")
+	assert.Equal(t, []int{2}, voted.Voted, "the voter's own poll must not change")
+}
+
+func TestRetractVote(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{name: "Retracted", wantCode: http.StatusOK},
+		{name: "Not Voted", err: repository.ErrNotFound, wantCode: http.StatusNotFound},
+		{name: "Votes Final", err: repository.ErrVoteFinal, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockPolls := new(mocks.MockPollsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+			mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1, Author: "alice"}, nil)
+			mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+			mockPolls.On("GetPoll", 5, "bob").Return(testPoll(false, true), nil)
+			mockPolls.On("RetractVote", 3, "bob").Return(tt.err)
+
+			router := testRouter(t, func(r *mux.Router) { RegisterPollHandlers(r, mockRepo, mockPolls) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/1/messages/5/poll/vote", ""))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+}
+
+func TestGetPoll(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockPolls := new(mocks.MockPollsRepo)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1}, nil)
+	mockRepo.On("GetMessageByID", 6).Return(&models.Message{ID: 6, ForumID: 1}, nil)
+	mockPolls.On("GetPoll", 5, "").Return(testPoll(false, false), nil)
+	mockPolls.On("GetPoll", 6, "").Return(nil, repository.ErrNotFound)
+	router := testRouter(t, func(r *mux.Router) { RegisterPollHandlers(r, mockRepo, mockPolls) })
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums/1/messages/5/poll", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums/1/messages/6/poll", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAttachPolls(t *testing.T) {
+	mockPolls := new(mocks.MockPollsRepo)
+	pollStore = mockPolls
+	t.Cleanup(func() { pollStore = nil })
+
+	poll := testPoll(false, false)
+	mockPolls.On("GetPolls", []int{5, 6, 5}, "bob").Return(map[int]*models.Poll{5: poll}, nil)
+
+	messages := []models.Message{{ID: 5}, {ID: 6}}
+	pinned := []models.Message{{ID: 5}}
+	assert.NoError(t, attachPolls("bob", messages, pinned))
+	assert.Equal(t, poll, messages[0].Poll)
+	assert.Nil(t, messages[1].Poll)
+	assert.Equal(t, poll, pinned[0].Poll)
+}
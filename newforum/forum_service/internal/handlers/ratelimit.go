@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/pkg/jwt"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/ratelimit"
+)
+
+// globalChatRoute is the route global chat messages are limited by, whether
+// they are posted over REST or sent on the WebSocket.
+const globalChatRoute = "POST /api/global-chat"
+
+// RateLimit is how often one signed-in user, and anyone from one address,
+// may call a route. Zero limits do not apply.
+type RateLimit struct {
+	User ratelimit.Limit
+	IP   ratelimit.Limit
+}
+
+// RateLimitConfig sets which routes RegisterRateLimits limits.
+type RateLimitConfig struct {
+	// Routes are keyed by method and path template, such as
+	// "POST /api/forums/{id}/messages", with variables written without
+	// their patterns. Routes that are not listed are not limited.
+	Routes map[string]RateLimit
+	// TrustProxy takes the client address from the X-Forwarded-For header
+	// added by a proxy in front of the service instead of the connection.
+	TrustProxy bool
+}
+
+var (
+	// rateLimits is set by RegisterRateLimits; until then nothing is
+	// limited.
+	rateLimits  RateLimitConfig
+	rateLimiter = ratelimit.NewLimiter()
+	// slowModeLimiter keeps a bucket per forum and user for forums in slow
+	// mode.
+	slowModeLimiter = ratelimit.NewLimiter()
+)
+
+// routeVarPattern matches the pattern of a route variable, which the keys
+// of RateLimitConfig.Routes leave out.
+var routeVarPattern = regexp.MustCompile(`\{([^:{}]+):[^}]*\}`)
+
+// RegisterRateLimits limits the routes in cfg. It applies to every route of
+// r, including those registered after it.
+func RegisterRateLimits(r *mux.Router, cfg RateLimitConfig) {
+	rateLimits = cfg
+	r.Use(rateLimitMiddleware)
+}
+
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				key := r.Method + " " + routeVarPattern.ReplaceAllString(tmpl, "{$1}")
+				if ok, wait := checkRateLimit(r, key); !ok {
+					w.Header().Set("Content-Type", "application/json")
+					tooManyRequests(w, wait, "Too many requests")
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkRateLimit counts a call of route by the sender of r. When the sender
+// is over one of the route's limits it reports false and how long until
+// they may try again.
+func checkRateLimit(r *http.Request, route string) (bool, time.Duration) {
+	limit, ok := rateLimits.Routes[route]
+	if !ok {
+		return true, 0
+	}
+	if id, ok := requestUserID(r); ok {
+		if ok, wait := rateLimiter.Allow(fmt.Sprintf("%s user:%d", route, id), limit.User); !ok {
+			return false, wait
+		}
+	}
+	return rateLimiter.Allow(route+" ip:"+clientIP(r), limit.IP)
+}
+
+// requestUserID reads the user from the token of r, in the Authorization
+// header or, for WebSockets, the token query parameter. Users are told
+// apart by their token alone, so limits cost no database lookup.
+func requestUserID(r *http.Request) (int, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	if token == "" {
+		return 0, false
+	}
+	claims, err := jwt.ParseToken(token, "your-secret-key")
+	if err != nil {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// clientIP is the address r came from. Behind a trusted proxy that is the
+// last address in X-Forwarded-For, the one the proxy itself added; the ones
+// before it are whatever the client sent.
+func clientIP(r *http.Request) string {
+	if rateLimits.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			addrs := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfter rounds wait up to whole seconds, as Retry-After wants them.
+func retryAfter(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter(wait)))
+	sendError(w, http.StatusTooManyRequests, message)
+}
+
+// wsError is the payload of the error frame a WebSocket gets instead of a
+// 429 response.
+type wsError struct {
+	Error      string `json:"error"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+}
+
+// checkSlowMode makes sure user has waited out the slow mode of forum since
+// they last posted there and writes a 429 response if not. Moderators are
+// not held to slow mode.
+func checkSlowMode(w http.ResponseWriter, forum *models.Forum, user *models.User) bool {
+	if forum.SlowMode <= 0 || isModerator(user) {
+		return true
+	}
+	key := fmt.Sprintf("%d:%s", forum.ID, user.Username)
+	ok, wait := slowModeLimiter.Allow(key, ratelimit.Every(time.Duration(forum.SlowMode)*time.Second))
+	if !ok {
+		tooManyRequests(w, wait, fmt.Sprintf("Slow mode is on, you can post again in %d seconds", retryAfter(wait)))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// useRateLimits installs cfg and fresh limiters for the duration of the
+// test.
+func useRateLimits(t *testing.T, r *mux.Router, cfg RateLimitConfig) {
+	t.Helper()
+	rateLimiter, slowModeLimiter = ratelimit.NewLimiter(), ratelimit.NewLimiter()
+	RegisterRateLimits(r, cfg)
+	t.Cleanup(func() {
+		rateLimits = RateLimitConfig{}
+		rateLimiter, slowModeLimiter = ratelimit.NewLimiter(), ratelimit.NewLimiter()
+	})
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	useRateLimits(t, router, RateLimitConfig{
+		Routes: map[string]RateLimit{
+			"POST /api/forums/{id}/messages": {
+				User: ratelimit.Limit{Requests: 2, Per: time.Minute},
+				IP:   ratelimit.Limit{Requests: 3, Per: time.Minute},
+			},
+		},
+		TrustProxy: true,
+	})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Handle("/api/forums/{id:[0-9]+}/messages", ok).Methods("POST")
+	router.Handle("/api/forums/{id:[0-9]+}/messages", ok).Methods("GET")
+
+	post := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Two messages from a signed-in user, in different forums, use up
+	// their limit.
+	assert.Equal(t, http.StatusOK, post(authorizedRequest(t, "POST", "/api/forums/1/messages", "")).Code)
+	assert.Equal(t, http.StatusOK, post(authorizedRequest(t, "POST", "/api/forums/2/messages", "")).Code)
+	rr := post(authorizedRequest(t, "POST", "/api/forums/1/messages", ""))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":"Too many requests"}`, rr.Body.String())
+
+	// Anonymous requests from the same address share what the address
+	// has left.
+	assert.Equal(t, http.StatusOK, post(httptest.NewRequest("POST", "/api/forums/1/messages", nil)).Code)
+	assert.Equal(t, http.StatusTooManyRequests, post(httptest.NewRequest("POST", "/api/forums/1/messages", nil)).Code)
+
+	// Behind the proxy the address it forwarded counts instead.
+	req := httptest.NewRequest("POST", "/api/forums/1/messages", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.2")
+	assert.Equal(t, http.StatusOK, post(req).Code)
+
+	// Routes without limits are left alone.
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, post(httptest.NewRequest("GET", "/api/forums/1/messages", nil)).Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Cleanup(func() { rateLimits = RateLimitConfig{} })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.10:5555"
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 203.0.113.7")
+
+	rateLimits = RateLimitConfig{}
+	assert.Equal(t, "192.0.2.10", clientIP(req), "the header is ignored without a proxy")
+
+	rateLimits = RateLimitConfig{TrustProxy: true}
+	assert.Equal(t, "203.0.113.7", clientIP(req))
+}
+
+func TestPostMessageSlowMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		wantCode int
+	}{
+		{name: "User", role: "user", wantCode: http.StatusTooManyRequests},
+		{name: "Moderator", role: "moderator", wantCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: tt.role}, nil)
+			mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1, SlowMode: 30}, nil)
+			mockRepo.On("GetByID", 2).Return(&models.Forum{ID: 2}, nil)
+			mockRepo.On("CreateMessage", mock.Anything).Return(3, nil)
+
+			router := mux.NewRouter()
+			useRateLimits(t, router, RateLimitConfig{})
+			router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+			post := func(forumID int) *httptest.ResponseRecorder {
+				rr := httptest.NewRecorder()
+				router.ServeHTTP(rr, authorizedRequest(t, "POST", fmt.Sprintf("/forums/%d/messages", forumID), `{"author":"User1","content":"Hi"}`))
+				return rr
+			}
+
+			assert.Equal(t, http.StatusCreated, post(1).Code)
+			rr := post(1)
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantCode == http.StatusTooManyRequests {
+				assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+				assert.Contains(t, rr.Body.String(), "Slow mode is on")
+				mockRepo.AssertNumberOfCalls(t, "CreateMessage", 1)
+			}
+			assert.Equal(t, http.StatusCreated, post(2).Code, "forums without slow mode are not affected")
+		})
+	}
+}
+
+func TestCreateTopicSlowMode(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1, SlowMode: 60}, nil)
+	mockRepo.On("CreateMessage", mock.Anything).Return(3, nil)
+
+	router := mux.NewRouter()
+	useRateLimits(t, router, RateLimitConfig{})
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+	router.HandleFunc("/forums/{id}/topics", CreateTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages", `{"author":"User1","content":"Hi"}`))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/topics", `{"title":"New topic"}`))
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	mockRepo.AssertNotCalled(t, "CreateTopic", mock.Anything)
+}
+
+func TestServeGlobalChatRateLimited(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetGlobalChatHistory", 100).Return([]models.GlobalMessage{}, nil)
+
+	router := mux.NewRouter()
+	useRateLimits(t, router, RateLimitConfig{
+		Routes: map[string]RateLimit{
+			globalChatRoute: {IP: ratelimit.Limit{Requests: 1, Per: time.Minute}},
+		},
+	})
+	router.HandleFunc("/ws/global", func(w http.ResponseWriter, r *http.Request) {
+		serveGlobalChat(w, r, mockRepo)
+	})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// A message posted over REST uses up the address's limit.
+	req := httptest.NewRequest("POST", "/api/global-chat", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	ok, _ := checkRateLimit(req, globalChatRoute)
+	assert.True(t, ok)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws/global", nil)
+	if err != nil {
+		t.Fatalf("could not open a ws connection: %v", err)
+	}
+	defer ws.Close()
+
+	assert.NoError(t, ws.WriteJSON(GlobalChatMessage{Author: "test", Content: "flood"}))
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var frame struct {
+		Type    string  `json:"type"`
+		Payload wsError `json:"payload"`
+	}
+	if err := ws.ReadJSON(&frame); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "error", frame.Type)
+	assert.Equal(t, wsError{Error: "Too many messages", RetryAfter: 60}, frame.Payload)
+	mockRepo.AssertNotCalled(t, "CreateGlobalMessage", mock.Anything)
+}
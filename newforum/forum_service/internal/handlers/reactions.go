@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+type reactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// reactionEvent is both the response to a reaction change and the payload of
+// the reaction_added and reaction_removed events.
+type reactionEvent struct {
+	MessageID int    `json:"messageId"`
+	Emoji     string `json:"emoji"`
+	Count     int    `json:"count"`
+	User      string `json:"user"`
+}
+
+func RegisterReactionHandlers(r *mux.Router, repo repository.ForumsRepository, limit int) {
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/reactions", AddReaction(repo, limit)).Methods("POST")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/reactions/{emoji}", RemoveReaction(repo)).Methods("DELETE")
+}
+
+// messageIDs lists the IDs of all messages in msgs.
+func messageIDs(msgs ...[]models.Message) []int {
+	var ids []int
+	for _, list := range msgs {
+		for _, m := range list {
+			ids = append(ids, m.ID)
+		}
+	}
+	return ids
+}
+
+// attachReactions fills in the reactions of msgs as seen by username.
+func attachReactions(repo repository.ForumsRepository, username string, msgs ...[]models.Message) error {
+	ids := messageIDs(msgs...)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	reactions, err := repo.GetReactions(ids, username)
+	if err != nil {
+		return err
+	}
+	for _, list := range msgs {
+		for i := range list {
+			list[i].Reactions = reactions[list[i].ID]
+		}
+	}
+	return nil
+}
+
+// AddReaction godoc
+// @Summary React to message
+// @Description React to a message with an emoji. Reacting again with the same emoji changes nothing. A message can carry a limited number of distinct emoji
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Param reaction body reactionRequest true "Emoji"
+// @Security BearerAuth
+// @Success 200 {object} reactionEvent
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/reactions [post]
+func AddReaction(repo repository.ForumsRepository, limit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req reactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if err := models.ValidateEmoji(req.Emoji); err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		msg, err := forumMessage(r, repo)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		if !checkForumWritable(w, repo, msg.ForumID, user) {
+			return
+		}
+
+		count, err := repo.AddReaction(msg.ID, user.Username, req.Emoji, limit)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				sendError(w, http.StatusNotFound, "Message not found")
+			case errors.Is(err, repository.ErrReactionLimit):
+				sendError(w, http.StatusConflict, "This message already has "+strconv.Itoa(limit)+" different reactions")
+			default:
+				log.Error("Failed to add reaction", logger.Error(err), logger.Int("messageID", msg.ID))
+				sendError(w, http.StatusInternalServerError, "Failed to add reaction")
+			}
+			return
+		}
+
+		event := reactionEvent{MessageID: msg.ID, Emoji: req.Emoji, Count: count, User: user.Username}
+		go broadcastForumWide(msg, WSMessage{Type: "reaction_added", Payload: event})
+		notify(models.Notification{
+			Username:  msg.Author,
+			Type:      models.NotificationReaction,
+			Actor:     user.Username,
+			Detail:    req.Emoji,
+			ForumID:   &msg.ForumID,
+			TopicID:   msg.TopicID,
+			MessageID: &msg.ID,
+		})
+
+		json.NewEncoder(w).Encode(event)
+	}
+}
+
+// RemoveReaction godoc
+// @Summary Remove reaction
+// @Description Take back the current user's reaction to a message
+// @Tags messages
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Param emoji path string true "Emoji, URL encoded"
+// @Security BearerAuth
+// @Success 200 {object} reactionEvent
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/reactions/{emoji} [delete]
+func RemoveReaction(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		msg, err := forumMessage(r, repo)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		emoji := mux.Vars(r)["emoji"]
+		count, err := repo.RemoveReaction(msg.ID, user.Username, emoji)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Reaction not found")
+				return
+			}
+			log.Error("Failed to remove reaction", logger.Error(err), logger.Int("messageID", msg.ID))
+			sendError(w, http.StatusInternalServerError, "Failed to remove reaction")
+			return
+		}
+
+		event := reactionEvent{MessageID: msg.ID, Emoji: emoji, Count: count, User: user.Username}
+		go broadcastForumWide(msg, WSMessage{Type: "reaction_removed", Payload: event})
+
+		json.NewEncoder(w).Encode(event)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateEmoji(t *testing.T) {
+	for _, emoji := range []string{"👍", "❤️", "👍🏽", "👨‍👩‍👧", "🇷🇺"} {
+		assert.NoError(t, models.ValidateEmoji(emoji), emoji)
+	}
+	for _, emoji := range []string{"", "a", "+1", "👍 ", ":)", "\u200d"} {
+		assert.ErrorIs(t, models.ValidateEmoji(emoji), models.ErrInvalidEmoji, emoji)
+	}
+}
+
+func TestAddReaction(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 44}, nil)
+	mockRepo.On("GetByID", 44).Return(&models.Forum{ID: 44}, nil)
+	mockRepo.On("AddReaction", 5, "alice", "👍", 2).Return(3, nil)
+
+	ws := dialForum(t, "44")
+
+	router := testRouter(t, func(r *mux.Router) { RegisterReactionHandlers(r, mockRepo, 2) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/forums/44/messages/5/reactions", `{"emoji":"👍"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got reactionEvent
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, reactionEvent{MessageID: 5, Emoji: "👍", Count: 3, User: "alice"}, got)
+
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var event struct {
+		Type    string        `json:"type"`
+		Payload reactionEvent `json:"payload"`
+	}
+	if err := ws.ReadJSON(&event); err != nil {
+		t.Fatalf("could not read message: %v", err)
+	}
+	assert.Equal(t, "reaction_added", event.Type)
+	assert.Equal(t, got, event.Payload)
+}
+
+func TestAddReactionRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     bool
+		body     string
+		forum    models.Forum
+		addErr   error
+		wantCode int
+	}{
+		{name: "Unauthorized", body: `{"emoji":"👍"}`, wantCode: http.StatusUnauthorized},
+		{name: "Not An Emoji", auth: true, body: `{"emoji":"like"}`, wantCode: http.StatusBadRequest},
+		{name: "Locked Forum", auth: true, body: `{"emoji":"👍"}`, forum: models.Forum{ID: 1, Locked: true}, wantCode: http.StatusLocked},
+		{name: "Limit Reached", auth: true, body: `{"emoji":"👍"}`, forum: models.Forum{ID: 1}, addErr: repository.ErrReactionLimit, wantCode: http.StatusConflict},
+		{name: "Deleted Message", auth: true, body: `{"emoji":"👍"}`, forum: models.Forum{ID: 1}, addErr: repository.ErrNotFound, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+			mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1}, nil)
+			mockRepo.On("GetByID", 1).Return(&tt.forum, nil)
+			if tt.addErr != nil {
+				mockRepo.On("AddReaction", 5, "alice", "👍", 2).Return(0, tt.addErr)
+			}
+
+			req := authorizedRequest(t, "POST", "/api/forums/1/messages/5/reactions", tt.body)
+			if !tt.auth {
+				req.Header.Del("Authorization")
+			}
+			router := testRouter(t, func(r *mux.Router) { RegisterReactionHandlers(r, mockRepo, 2) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.addErr == nil {
+				mockRepo.AssertNotCalled(t, "AddReaction", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestRemoveReaction(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "alice", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1}, nil)
+	mockRepo.On("RemoveReaction", 5, "alice", "👍").Return(0, nil).Once()
+	mockRepo.On("RemoveReaction", 5, "alice", "👍").Return(0, repository.ErrNotFound)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterReactionHandlers(r, mockRepo, 2) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/1/messages/5/reactions/%F0%9F%91%8D", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got reactionEvent
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, reactionEvent{MessageID: 5, Emoji: "👍", User: "alice"}, got)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/1/messages/5/reactions/%F0%9F%91%8D", ""))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetMessagesAPIReactions(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessagesPage", 1, (*int)(nil), mock.Anything).Return(&models.MessagePage{
+		Messages: []models.Message{{ID: 9, ForumID: 1}, {ID: 10, ForumID: 1}},
+	}, nil)
+	mockRepo.On("GetPinnedMessages", 1, (*int)(nil)).Return([]models.Message{{ID: 2, ForumID: 1}}, nil)
+	mockRepo.On("GetReactions", []int{9, 10, 2}, "").Return(map[int][]models.ReactionCount{
+		9: {{Emoji: "👍", Count: 2}, {Emoji: "🎉", Count: 1}},
+		2: {{Emoji: "❤️", Count: 5}},
+	}, nil)
+	mockRepo.On("GetMentions", []int{9, 10, 2}).Return(map[int][]string{}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages-list", GetMessagesAPI(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages-list", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got struct {
+		Pinned   []models.Message `json:"pinned"`
+		Messages []models.Message `json:"messages"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Len(t, got.Messages[0].Reactions, 2)
+	assert.Empty(t, got.Messages[1].Reactions)
+	assert.Equal(t, 5, got.Pinned[0].Reactions[0].Count)
+	mockRepo.AssertExpectations(t)
+}
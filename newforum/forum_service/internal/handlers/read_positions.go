@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+type readRequest struct {
+	MessageID int `json:"message_id"`
+}
+
+func RegisterReadPositionHandlers(r *mux.Router, repo repository.ForumsRepository) {
+	r.HandleFunc("/api/forums/unread", GetUnreadCounts(repo)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/read", GetReadPosition(repo)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/read", MarkForumRead(repo)).Methods("PUT")
+}
+
+// attachUnread fills in the unread counts of forums for user. Anonymous
+// users get no counts.
+func attachUnread(repo repository.ForumsRepository, user *models.User, forums ...[]models.Forum) error {
+	if user == nil {
+		return nil
+	}
+	ids := []int{}
+	for _, list := range forums {
+		for _, f := range list {
+			ids = append(ids, f.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	counts, err := repo.GetUnreadCounts(user.Username, ids)
+	if err != nil {
+		return err
+	}
+	for _, list := range forums {
+		for i := range list {
+			n := counts[list[i].ID]
+			list[i].Unread = &n
+		}
+	}
+	return nil
+}
+
+// attachUnreadTree fills in the unread counts of every forum in tree for
+// user. Anonymous users get no counts.
+func attachUnreadTree(repo repository.ForumsRepository, user *models.User, tree *models.ForumTree) error {
+	if user == nil {
+		return nil
+	}
+	counts, err := repo.GetUnreadCounts(user.Username, nil)
+	if err != nil {
+		return err
+	}
+	eachForumNode(tree, func(n *models.ForumNode) {
+		count := counts[n.ID]
+		n.Unread = &count
+	})
+	return nil
+}
+
+func readPosition(repo repository.ForumsRepository, username string, forumID, lastRead int) (*models.ReadPosition, error) {
+	counts, err := repo.GetUnreadCounts(username, []int{forumID})
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReadPosition{ForumID: forumID, LastReadMessageID: lastRead, Unread: counts[forumID]}, nil
+}
+
+// GetUnreadCounts godoc
+// @Summary Unread messages per forum
+// @Description Get how many messages the current user has not read in each forum. Forums without unread messages are left out
+// @Tags forums
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]int
+// @Failure 401 {object} map[string]string
+// @Router /forums/unread [get]
+func GetUnreadCounts(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		counts, err := repo.GetUnreadCounts(user.Username, nil)
+		if err != nil {
+			log.Error("Failed to count unread messages", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to count unread messages")
+			return
+		}
+		json.NewEncoder(w).Encode(counts)
+	}
+}
+
+// GetReadPosition godoc
+// @Summary Read position
+// @Description Get the last message the current user has read in a forum and how many newer messages there are
+// @Tags forums
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Security BearerAuth
+// @Success 200 {object} models.ReadPosition
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/read [get]
+func GetReadPosition(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+		if _, err := repo.GetByID(forumID); err != nil {
+			sendError(w, http.StatusNotFound, "Forum not found")
+			return
+		}
+
+		lastRead, err := repo.GetReadPosition(user.Username, forumID)
+		if err != nil {
+			log.Error("Failed to load read position", logger.Error(err), logger.Int("forumID", forumID))
+			sendError(w, http.StatusInternalServerError, "Failed to load read position")
+			return
+		}
+		pos, err := readPosition(repo, user.Username, forumID, lastRead)
+		if err != nil {
+			log.Error("Failed to count unread messages", logger.Error(err), logger.Int("forumID", forumID))
+			sendError(w, http.StatusInternalServerError, "Failed to count unread messages")
+			return
+		}
+		json.NewEncoder(w).Encode(pos)
+	}
+}
+
+// MarkForumRead godoc
+// @Summary Advance read position
+// @Description Mark a forum read up to a message, as the client shows it. The position never moves back
+// @Tags forums
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param position body readRequest true "Last message read"
+// @Security BearerAuth
+// @Success 200 {object} models.ReadPosition
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/read [put]
+func MarkForumRead(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		var req readRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if req.MessageID <= 0 {
+			sendError(w, http.StatusBadRequest, "message_id is required")
+			return
+		}
+
+		msg, err := repo.GetMessageByID(req.MessageID)
+		if err != nil || msg.ForumID != forumID {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		lastRead, err := repo.AdvanceReadPosition(user.Username, forumID, req.MessageID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Forum not found")
+				return
+			}
+			log.Error("Failed to advance read position", logger.Error(err), logger.Int("forumID", forumID))
+			sendError(w, http.StatusInternalServerError, "Failed to save read position")
+			return
+		}
+		pos, err := readPosition(repo, user.Username, forumID, lastRead)
+		if err != nil {
+			log.Error("Failed to count unread messages", logger.Error(err), logger.Int("forumID", forumID))
+			sendError(w, http.StatusInternalServerError, "Failed to count unread messages")
+			return
+		}
+
+		// Other tabs of the same user update their unread badges.
+		go sendToUser(user.Username, WSMessage{Type: "read_position", Payload: pos})
+
+		json.NewEncoder(w).Encode(pos)
+	}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestMarkForumRead(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	router := mux.NewRouter()
+	RegisterReadPositionHandlers(router, mockRepo)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("GetMessageByID", 10).Return(&models.Message{ID: 10, ForumID: 1}, nil)
+	mockRepo.On("GetMessageByID", 20).Return(&models.Message{ID: 20, ForumID: 2}, nil)
+	mockRepo.On("GetMessageByID", 30).Return(nil, repository.ErrNotFound)
+	mockRepo.On("AdvanceReadPosition", "bob", 1, 10).Return(12, nil)
+	mockRepo.On("GetUnreadCounts", "bob", []int{1}).Return(map[int]int{1: 3}, nil)
+
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		anonymous  bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Success", url: "/api/forums/1/read", body: `{"message_id":10}`, wantStatus: http.StatusOK,
+			wantBody: `{"forum_id":1,"last_read_message_id":12,"unread":3}`},
+		{name: "Missing Message ID", url: "/api/forums/1/read", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "Invalid JSON", url: "/api/forums/1/read", body: `{`, wantStatus: http.StatusBadRequest},
+		{name: "Message In Other Forum", url: "/api/forums/1/read", body: `{"message_id":20}`, wantStatus: http.StatusNotFound},
+		{name: "Message Not Found", url: "/api/forums/1/read", body: `{"message_id":30}`, wantStatus: http.StatusNotFound},
+		{name: "Unauthorized", url: "/api/forums/1/read", body: `{"message_id":10}`, anonymous: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := authorizedRequest(t, "PUT", tt.url, tt.body)
+			if tt.anonymous {
+				req.Header.Del("Authorization")
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+	mockRepo.AssertNumberOfCalls(t, "AdvanceReadPosition", 1)
+}
+
+func TestGetReadPosition(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	router := mux.NewRouter()
+	RegisterReadPositionHandlers(router, mockRepo)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetByID", 9).Return(nil, repository.ErrNotFound)
+	mockRepo.On("GetReadPosition", "bob", 1).Return(0, nil)
+	mockRepo.On("GetUnreadCounts", "bob", []int{1}).Return(map[int]int{1: 5}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/forums/1/read", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"forum_id":1,"last_read_message_id":0,"unread":5}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/forums/9/read", ""))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetUnreadCounts(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	router := mux.NewRouter()
+	RegisterReadPositionHandlers(router, mockRepo)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("GetUnreadCounts", "bob", []int(nil)).Return(map[int]int{1: 2, 4: 7}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/forums/unread", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"1":2,"4":7}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums/unread", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestGetAllForumsUnread(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetForumsPage", mock.Anything).Return(&models.ForumPage{
+		Forums: []models.Forum{{ID: 2, Title: "General"}},
+	}, nil)
+	mockRepo.On("GetPinnedForums").Return([]models.Forum{{ID: 1, Title: "Rules", Pinned: true}}, nil)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("GetUnreadCounts", "bob", []int{1, 2}).Return(map[int]int{2: 4}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/forums-list", GetAllForums(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/forums-list", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page models.ForumPage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, 0, *page.Pinned[0].Unread)
+	assert.Equal(t, 4, *page.Forums[0].Unread)
+}
+
+func TestGetAllForumsAnonymousUnread(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetForumsPage", mock.Anything).Return(&models.ForumPage{
+		Forums: []models.Forum{{ID: 2, Title: "General"}},
+	}, nil)
+	mockRepo.On("GetPinnedForums").Return([]models.Forum{}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/forums-list", GetAllForums(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/forums-list", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), `"unread"`)
+	mockRepo.AssertNotCalled(t, "GetUnreadCounts", mock.Anything, mock.Anything)
+}
+
+func TestGetForumTreeUnread(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetForumTree").Return(&models.ForumTree{
+		Categories: []*models.CategoryNode{{
+			Category: models.Category{ID: 1, Name: "Dev"},
+			Forums: []*models.ForumNode{{
+				Forum:     models.Forum{ID: 1, Title: "Go"},
+				Subforums: []*models.ForumNode{{Forum: models.Forum{ID: 2, Title: "Generics"}}},
+			}},
+		}},
+	}, nil)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("GetUnreadCounts", "bob", []int(nil)).Return(map[int]int{2: 3}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/forums-tree", GetForumTree(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/forums-tree", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var tree models.ForumTree
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tree))
+	forum := tree.Categories[0].Forums[0]
+	assert.Equal(t, 0, *forum.Unread)
+	assert.Equal(t, 3, *forum.Subforums[0].Unread)
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+const maxQuoteLength = 500
+
+var (
+	errQuoteWithoutReply = errors.New("quote requires reply_to")
+	errQuoteTooLong      = errors.New("quote too long")
+	errQuoteMismatch     = errors.New("quote must be an excerpt of the parent message")
+	errParentOtherStream = errors.New("parent message belongs to another forum or topic")
+)
+
+// messageEvent is the message_created payload; Parent is set for replies so
+// clients can render the "in reply to" block without another request.
+type messageEvent struct {
+	models.Message
+	Parent *models.Message `json:"parent,omitempty"`
+}
+
+// validateReply checks that msg may reply to parent: both must live in the
+// same forum and topic, and a quote must be taken from the parent's content.
+func validateReply(msg models.Message, parent *models.Message) error {
+	if parent == nil {
+		if msg.Quote != "" {
+			return errQuoteWithoutReply
+		}
+		return nil
+	}
+	if parent.ForumID != msg.ForumID || !sameTopic(parent.TopicID, msg.TopicID) {
+		return errParentOtherStream
+	}
+	if len(msg.Quote) > maxQuoteLength {
+		return errQuoteTooLong
+	}
+	if msg.Quote != "" && !strings.Contains(parent.Content, msg.Quote) {
+		return errQuoteMismatch
+	}
+	return nil
+}
+
+func sameTopic(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// buildThread arranges a flat list of messages into a reply tree rooted at
+// rootID. Messages that are not reachable from the root are ignored.
+func buildThread(rootID int, messages []models.Message) *models.MessageThread {
+	nodes := make(map[int]*models.MessageThread, len(messages))
+	for _, m := range messages {
+		nodes[m.ID] = &models.MessageThread{Message: m, Replies: []*models.MessageThread{}}
+	}
+	for _, m := range messages {
+		if m.ID == rootID || m.ReplyTo == nil {
+			continue
+		}
+		if parent, ok := nodes[*m.ReplyTo]; ok {
+			parent.Replies = append(parent.Replies, nodes[m.ID])
+		}
+	}
+	return nodes[rootID]
+}
+
+// GetMessageThread godoc
+// @Summary Get message thread
+// @Description Get a message together with its nested replies
+// @Tags messages
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} models.MessageThread
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/thread [get]
+func GetMessageThread(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		forumID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+		messageID, err := strconv.Atoi(vars["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		messages, err := repo.GetMessageThread(messageID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Message not found")
+				return
+			}
+			sendError(w, http.StatusInternalServerError, "Failed to load thread")
+			return
+		}
+
+		redactHidden(messages, isModerator(requestUser(r, repo)))
+
+		thread := buildThread(messageID, messages)
+		if thread == nil || thread.ForumID != forumID {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		json.NewEncoder(w).Encode(thread)
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestPostMessageReply(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 1, Author: "User2", Content: "Original text here"}, nil)
+	mockRepo.On("CreateMessage", mock.MatchedBy(func(msg models.Message) bool {
+		return msg.ReplyTo != nil && *msg.ReplyTo == 5 && msg.Quote == "text"
+	})).Return(6, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages",
+		`{"author":"User1","content":"Agreed","reply_to":5,"quote":"text"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPostMessageReplyIntegrity(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent *models.Message
+		body   string
+	}{
+		{
+			name:   "Parent In Other Forum",
+			parent: &models.Message{ID: 5, ForumID: 2, Content: "Original"},
+			body:   `{"author":"User1","content":"Hi","reply_to":5}`,
+		},
+		{
+			name:   "Parent In Topic",
+			parent: &models.Message{ID: 5, ForumID: 1, TopicID: intPtr(3), Content: "Original"},
+			body:   `{"author":"User1","content":"Hi","reply_to":5}`,
+		},
+		{
+			name:   "Quote Not From Parent",
+			parent: &models.Message{ID: 5, ForumID: 1, Content: "Original"},
+			body:   `{"author":"User1","content":"Hi","reply_to":5,"quote":"invented"}`,
+		},
+		{
+			name: "Quote Without Reply",
+			body: `{"author":"User1","content":"Hi","quote":"Original"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{Username: "User1", Role: "user"}, nil)
+			mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+			if tt.parent != nil {
+				mockRepo.On("GetMessageByID", tt.parent.ID).Return(tt.parent, nil)
+			}
+
+			router := mux.NewRouter()
+			router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages", tt.body))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything)
+		})
+	}
+}
+
+func TestPostMessageReplyParentNotFound(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(nil, repository.ErrNotFound)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages", PostMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/messages", `{"author":"User1","content":"Hi","reply_to":5}`))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything)
+}
+
+func TestGetMessageThread(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetMessageThread", 1).Return([]models.Message{
+		{ID: 1, ForumID: 1, Content: "root"},
+		{ID: 2, ForumID: 1, ReplyTo: intPtr(1), Content: "first"},
+		{ID: 3, ForumID: 1, ReplyTo: intPtr(2), Content: "nested"},
+		{ID: 4, ForumID: 1, ReplyTo: intPtr(1), Content: "second"},
+	}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages/{message_id}/thread", GetMessageThread(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages/1/thread", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var thread models.MessageThread
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &thread))
+	assert.Equal(t, "root", thread.Content)
+	assert.Len(t, thread.Replies, 2)
+	assert.Equal(t, "first", thread.Replies[0].Content)
+	assert.Len(t, thread.Replies[0].Replies, 1)
+	assert.Equal(t, "nested", thread.Replies[0].Replies[0].Content)
+	assert.Equal(t, "second", thread.Replies[1].Content)
+}
+
+func TestGetMessageThreadNotFound(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetMessageThread", 1).Return(nil, repository.ErrNotFound)
+	mockRepo.On("GetMessageThread", 2).Return([]models.Message{{ID: 2, ForumID: 9}}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages/{message_id}/thread", GetMessageThread(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages/1/thread", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages/2/thread", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
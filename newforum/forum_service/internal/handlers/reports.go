@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+// moderatorClients holds moderation sockets keyed by the forum they watch;
+// key 0 receives events from every forum.
+var moderatorClients = make(map[int]map[*websocket.Conn]*sync.Mutex)
+
+type reportRequest struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+// reportResolvedEvent tells other moderators a queue entry has been handled.
+type reportResolvedEvent struct {
+	MessageID  int    `json:"message_id"`
+	ForumID    int    `json:"forum_id"`
+	Status     string `json:"status"`
+	Action     string `json:"action,omitempty"`
+	ResolvedBy string `json:"resolved_by"`
+}
+
+func RegisterReportHandlers(r *mux.Router, repo repository.ForumsRepository, reports repository.ReportsRepository) {
+	r.HandleFunc("/ws/moderation", serveModerationWebSocket(repo))
+
+	r.HandleFunc("/api/forums/{id:[0-9]+}/messages/{message_id:[0-9]+}/reports", ReportMessage(repo, reports)).Methods("POST")
+	r.HandleFunc("/api/moderation/reports", ReportQueuePage()).Methods("GET")
+	r.HandleFunc("/api/reports", GetReportQueue(repo, reports)).Methods("GET")
+	r.HandleFunc("/api/reports/messages/{message_id:[0-9]+}", GetMessageReports(repo, reports)).Methods("GET")
+	r.HandleFunc("/api/reports/messages/{message_id:[0-9]+}/resolve", ResolveReports(repo, reports)).Methods("POST")
+}
+
+// redactHidden blanks the content of messages hidden by a moderator unless
+// the viewer is a moderator too.
+func redactHidden(messages []models.Message, moderator bool) {
+	if moderator {
+		return
+	}
+	for i := range messages {
+		if messages[i].Hidden {
+			messages[i].Content = ""
+			messages[i].ContentHTML = ""
+			messages[i].Quote = ""
+			messages[i].Attachments = nil
+		}
+	}
+}
+
+// serveModerationWebSocket streams report events to moderators. Browsers
+// cannot set headers on WebSocket requests, so the JWT comes in the token
+// query parameter; forum_id limits the stream to one forum.
+func serveModerationWebSocket(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isModerator(tokenUser(r.URL.Query().Get("token"), repo)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		forumID := 0
+		if v := r.URL.Query().Get("forum_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				http.Error(w, "Invalid forum ID", http.StatusBadRequest)
+				return
+			}
+			forumID = id
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Error("WebSocket upgrade error", logger.Error(err))
+			return
+		}
+		defer func() {
+			unregisterModeratorClient(forumID, conn)
+			conn.Close()
+		}()
+
+		registerModeratorClient(forumID, conn)
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
+					log.Error("WebSocket error", logger.Error(err))
+				}
+				break
+			}
+		}
+	}
+}
+
+func registerModeratorClient(forumID int, conn *websocket.Conn) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if moderatorClients[forumID] == nil {
+		moderatorClients[forumID] = make(map[*websocket.Conn]*sync.Mutex)
+	}
+	moderatorClients[forumID][conn] = &sync.Mutex{}
+}
+
+func unregisterModeratorClient(forumID int, conn *websocket.Conn) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if moderatorClients[forumID] != nil {
+		delete(moderatorClients[forumID], conn)
+	}
+}
+
+// broadcastToModerators notifies moderators watching forumID and those
+// watching every forum.
+func broadcastToModerators(forumID int, message WSMessage) {
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+
+	for _, key := range []int{forumID, 0} {
+		for conn, mu := range moderatorClients[key] {
+			if err := writeJSON(conn, mu, message); err != nil {
+				log.Error("WS send error",
+					logger.Error(err),
+					logger.Int("forumID", forumID))
+				go func(key int, conn *websocket.Conn) {
+					unregisterModeratorClient(key, conn)
+					conn.Close()
+				}(key, conn)
+			}
+		}
+	}
+}
+
+// ReportMessage godoc
+// @Summary Report message
+// @Description Flag a message for moderator review. A user can have one open report per message
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Param report body reportRequest true "Reason (spam, harassment, hate, off_topic, illegal, other) and optional details"
+// @Security BearerAuth
+// @Success 201 {object} models.Report
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/reports [post]
+func ReportMessage(repo repository.ForumsRepository, reports repository.ReportsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		forumID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+		messageID, err := strconv.Atoi(vars["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		var req reportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if !models.ReportReasons[req.Reason] {
+			sendError(w, http.StatusBadRequest, "Invalid report reason")
+			return
+		}
+
+		msg, err := repo.GetMessageByID(messageID)
+		if err != nil || msg.ForumID != forumID {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		if msg.Author == user.Username {
+			sendError(w, http.StatusBadRequest, "You cannot report your own message")
+			return
+		}
+
+		report, err := reports.CreateReport(models.Report{
+			MessageID: messageID,
+			Reporter:  user.Username,
+			Reason:    req.Reason,
+			Details:   strings.TrimSpace(req.Details),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrNotFound):
+				sendError(w, http.StatusNotFound, "Message not found")
+			case errors.Is(err, repository.ErrAlreadyReported):
+				sendError(w, http.StatusConflict, "You have already reported this message")
+			default:
+				log.Error("Failed to create report", logger.Error(err))
+				sendError(w, http.StatusInternalServerError, "Failed to report message")
+			}
+			return
+		}
+
+		go broadcastToModerators(report.ForumID, WSMessage{
+			Type:    "report_created",
+			Payload: report,
+		})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// ReportQueuePage godoc
+// @Summary Report queue page
+// @Description Render the moderation queue; the page loads the queue through the API
+// @Tags reports
+// @Produce html
+// @Success 200 {string} string "HTML page"
+// @Router /moderation/reports [get]
+func ReportQueuePage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderTemplate(w, "moderation_reports.html", nil)
+	}
+}
+
+// GetReportQueue godoc
+// @Summary Report queue
+// @Description List messages with open reports, most reported first. Moderators only
+// @Tags reports
+// @Produce json
+// @Param forum_id query int false "Restrict to a forum"
+// @Security BearerAuth
+// @Success 200 {array} models.ReportQueueItem
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /reports [get]
+func GetReportQueue(repo repository.ForumsRepository, reports repository.ReportsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isModerator(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var forumID *int
+		if v := r.URL.Query().Get("forum_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				sendError(w, http.StatusBadRequest, "Invalid forum ID")
+				return
+			}
+			forumID = &id
+		}
+
+		queue, err := reports.GetReportQueue(forumID)
+		if err != nil {
+			log.Error("Failed to load report queue", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load report queue")
+			return
+		}
+		json.NewEncoder(w).Encode(queue)
+	}
+}
+
+// GetMessageReports godoc
+// @Summary Message reports
+// @Description List every report filed against a message. Moderators only
+// @Tags reports
+// @Produce json
+// @Param message_id path int true "Message ID"
+// @Security BearerAuth
+// @Success 200 {array} models.Report
+// @Failure 403 {object} map[string]string
+// @Router /reports/messages/{message_id} [get]
+func GetMessageReports(repo repository.ForumsRepository, reports repository.ReportsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		messageID, err := strconv.Atoi(mux.Vars(r)["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		if !isModerator(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		list, err := reports.GetMessageReports(messageID)
+		if err != nil {
+			log.Error("Failed to load reports", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load reports")
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// ResolveReports godoc
+// @Summary Resolve reports
+// @Description Close every open report on a message. Resolving can delete or hide the message or warn its author in the same step. Moderators only
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param message_id path int true "Message ID"
+// @Param resolution body models.ReportResolution true "Status (resolved, dismissed) and action (delete, hide, warn)"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 200 {object} models.Message
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /reports/messages/{message_id}/resolve [post]
+func ResolveReports(repo repository.ForumsRepository, reports repository.ReportsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		messageID, err := strconv.Atoi(mux.Vars(r)["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var res models.ReportResolution
+		if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		switch {
+		case res.Status != models.ReportResolved && res.Status != models.ReportDismissed:
+			sendError(w, http.StatusBadRequest, "Status must be resolved or dismissed")
+			return
+		case res.Status == models.ReportDismissed && res.Action != models.ReportActionNone:
+			sendError(w, http.StatusBadRequest, "Dismissed reports take no action")
+			return
+		case res.Action != models.ReportActionNone && res.Action != models.ReportActionDelete &&
+			res.Action != models.ReportActionHide && res.Action != models.ReportActionWarn:
+			sendError(w, http.StatusBadRequest, "Invalid action")
+			return
+		}
+		res.ResolvedBy = user.Username
+
+		var before *models.Message
+		if moderationLog != nil {
+			before, _ = repo.GetMessageByID(messageID)
+		}
+
+		msg, err := reports.ResolveReports(messageID, res)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "No open reports for this message")
+				return
+			}
+			log.Error("Failed to resolve reports", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to resolve reports")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationReportResolve,
+			TargetType: models.ModerationTargetMessage,
+			TargetID:   messageID,
+			ForumID:    &msg.ForumID,
+			Reason:     moderationReason(r),
+		}, before, reportResolvedEvent{
+			MessageID:  msg.ID,
+			ForumID:    msg.ForumID,
+			Status:     res.Status,
+			Action:     res.Action,
+			ResolvedBy: res.ResolvedBy,
+		})
+		// Authors only hear about reports that led to an action, not who
+		// reported them.
+		if res.Action != models.ReportActionNone {
+			notify(models.Notification{
+				Username:  msg.Author,
+				Type:      models.NotificationModeration,
+				Actor:     user.Username,
+				Detail:    res.Action,
+				ForumID:   &msg.ForumID,
+				TopicID:   msg.TopicID,
+				MessageID: &msg.ID,
+			})
+		}
+
+		switch res.Action {
+		case models.ReportActionDelete:
+			go broadcastMessage(msg, WSMessage{
+				Type:    "message_deleted",
+				Payload: map[string]int{"messageId": msg.ID},
+			})
+		case models.ReportActionHide:
+			hidden := []models.Message{*msg}
+			redactHidden(hidden, false)
+			go broadcastMessage(msg, WSMessage{
+				Type:    "message_updated",
+				Payload: hidden[0],
+			})
+		}
+		go broadcastToModerators(msg.ForumID, WSMessage{
+			Type: "report_resolved",
+			Payload: reportResolvedEvent{
+				MessageID:  msg.ID,
+				ForumID:    msg.ForumID,
+				Status:     res.Status,
+				Action:     res.Action,
+				ResolvedBy: res.ResolvedBy,
+			},
+		})
+
+		json.NewEncoder(w).Encode(msg)
+	}
+}
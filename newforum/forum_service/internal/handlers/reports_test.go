@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReportMessage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockReports := new(mocks.MockReportsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "user1", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 2, Author: "user2"}, nil)
+	mockReports.On("CreateReport", models.Report{MessageID: 5, Reporter: "user1", Reason: "spam", Details: "ads"}).
+		Return(&models.Report{ID: 1, MessageID: 5, ForumID: 2, Reporter: "user1", Reason: "spam", Details: "ads", Status: models.ReportOpen}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterReportHandlers(r, mockRepo, mockReports) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr,
+		authorizedRequest(t, "POST", "/api/forums/2/messages/5/reports", `{"reason":"spam","details":" ads "}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var report models.Report
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, models.ReportOpen, report.Status)
+	mockReports.AssertExpectations(t)
+}
+
+func TestReportMessageRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		author   string
+		create   error
+		wantCode int
+	}{
+		{name: "Invalid Reason", body: `{"reason":"boring"}`, author: "user2", wantCode: http.StatusBadRequest},
+		{name: "Own Message", body: `{"reason":"spam"}`, author: "user1", wantCode: http.StatusBadRequest},
+		{name: "Already Reported", body: `{"reason":"spam"}`, author: "user2", create: repository.ErrAlreadyReported, wantCode: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockReports := new(mocks.MockReportsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "user1", Role: "user"}, nil)
+			mockRepo.On("GetMessageByID", 5).Return(&models.Message{ID: 5, ForumID: 2, Author: tt.author}, nil)
+			if tt.create != nil {
+				mockReports.On("CreateReport", mock.Anything).Return(nil, tt.create)
+			}
+
+			router := testRouter(t, func(r *mux.Router) { RegisterReportHandlers(r, mockRepo, mockReports) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr,
+				authorizedRequest(t, "POST", "/api/forums/2/messages/5/reports", tt.body))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.create == nil {
+				mockReports.AssertNotCalled(t, "CreateReport", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestReportMessageUnauthorized(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockReports := new(mocks.MockReportsRepo)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterReportHandlers(r, mockRepo, mockReports) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr,
+		httptest.NewRequest("POST", "/api/forums/2/messages/5/reports", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestGetReportQueue(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockReports := new(mocks.MockReportsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockReports.On("GetReportQueue", mock.MatchedBy(func(forumID *int) bool {
+		return forumID != nil && *forumID == 2
+	})).Return([]models.ReportQueueItem{
+		{Message: models.Message{ID: 5, ForumID: 2}, ReportCount: 3, Reasons: map[string]int{"spam": 3}},
+	}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterReportHandlers(r, mockRepo, mockReports) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/reports?forum_id=2", ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var queue []models.ReportQueueItem
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &queue))
+	assert.Len(t, queue, 1)
+	assert.Equal(t, 3, queue[0].ReportCount)
+	mockReports.AssertExpectations(t)
+}
+
+func TestGetReportQueueForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockReports := new(mocks.MockReportsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "user1", Role: "user"}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterReportHandlers(r, mockRepo, mockReports) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/reports", ""))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockReports.AssertNotCalled(t, "GetReportQueue", mock.Anything)
+}
+
+func TestResolveReports(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockReports := new(mocks.MockReportsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockReports.On("ResolveReports", 5, models.ReportResolution{
+		Status: models.ReportResolved, Action: models.ReportActionHide, ResolvedBy: "mod",
+	}).Return(&models.Message{ID: 5, ForumID: 2, Author: "user2", Content: "buy now", Hidden: true}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterReportHandlers(r, mockRepo, mockReports) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr,
+		authorizedRequest(t, "POST", "/api/reports/messages/5/resolve", `{"status":"resolved","action":"hide"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var msg models.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &msg))
+	assert.True(t, msg.Hidden)
+	assert.Equal(t, "buy now", msg.Content)
+	mockReports.AssertExpectations(t)
+}
+
+func TestResolveReportsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		resolve  error
+		wantCode int
+	}{
+		{name: "Unknown Status", body: `{"status":"open"}`, wantCode: http.StatusBadRequest},
+		{name: "Dismiss With Action", body: `{"status":"dismissed","action":"delete"}`, wantCode: http.StatusBadRequest},
+		{name: "Unknown Action", body: `{"status":"resolved","action":"ban"}`, wantCode: http.StatusBadRequest},
+		{name: "No Open Reports", body: `{"status":"dismissed"}`, resolve: repository.ErrNotFound, wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockReports := new(mocks.MockReportsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "admin"}, nil)
+			if tt.resolve != nil {
+				mockReports.On("ResolveReports", 5, mock.Anything).Return(nil, tt.resolve)
+			}
+
+			router := testRouter(t, func(r *mux.Router) { RegisterReportHandlers(r, mockRepo, mockReports) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr,
+				authorizedRequest(t, "POST", "/api/reports/messages/5/resolve", tt.body))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+}
+
+func TestRedactHidden(t *testing.T) {
+	messages := []models.Message{
+		{ID: 1, Content: "visible"},
+		{ID: 2, Content: "abuse", Quote: "quoted", Hidden: true},
+	}
+
+	redactHidden(messages, true)
+	assert.Equal(t, "abuse", messages[1].Content)
+
+	redactHidden(messages, false)
+	assert.Equal(t, "visible", messages[0].Content)
+	assert.Empty(t, messages[1].Content)
+	assert.Empty(t, messages[1].Quote)
+	assert.True(t, messages[1].Hidden)
+}
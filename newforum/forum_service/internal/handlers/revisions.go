@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+const (
+	diffEqual  = "equal"
+	diffInsert = "insert"
+	diffDelete = "delete"
+)
+
+// maxDiffCells bounds the table diffWords builds, which has a cell for
+// every pair of tokens. Longer texts are shown as replaced outright.
+const maxDiffCells = 1 << 20
+
+var diffTokens = regexp.MustCompile(`\s+|[^\s]+`)
+
+type diffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// revisionDiff describes one edit: the changes from the revision's content to
+// the version that replaced it.
+type revisionDiff struct {
+	EditedBy string    `json:"edited_by"`
+	EditedAt time.Time `json:"edited_at"`
+	Changes  []diffOp  `json:"changes"`
+}
+
+func isModerator(user *models.User) bool {
+	return user != nil && (user.Role == "admin" || user.Role == "moderator")
+}
+
+// broadcastMessage sends event to the stream the message belongs to.
+func broadcastMessage(msg *models.Message, event WSMessage) {
+	if msg.TopicID != nil {
+		broadcastToTopic(*msg.TopicID, event)
+		return
+	}
+	broadcastToForum(msg.ForumID, event)
+}
+
+// diffWords returns a word-level diff turning a into b.
+func diffWords(a, b string) []diffOp {
+	from := diffTokens.FindAllString(a, -1)
+	to := diffTokens.FindAllString(b, -1)
+	if len(from)*len(to) > maxDiffCells {
+		return replaceText(a, b)
+	}
+
+	// lcs[i][j] is the longest common subsequence of from[i:] and to[j:].
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := []diffOp{}
+	add := func(op, text string) {
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, diffOp{Op: op, Text: text})
+	}
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			add(diffEqual, from[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(diffDelete, from[i])
+			i++
+		default:
+			add(diffInsert, to[j])
+			j++
+		}
+	}
+	for ; i < len(from); i++ {
+		add(diffDelete, from[i])
+	}
+	for ; j < len(to); j++ {
+		add(diffInsert, to[j])
+	}
+	return ops
+}
+
+// replaceText is the diff that deletes all of a and inserts all of b.
+func replaceText(a, b string) []diffOp {
+	ops := []diffOp{}
+	if a != "" {
+		ops = append(ops, diffOp{Op: diffDelete, Text: a})
+	}
+	if b != "" {
+		ops = append(ops, diffOp{Op: diffInsert, Text: b})
+	}
+	return ops
+}
+
+// revisionDiffs pairs every revision with the version that replaced it, the
+// last one being the message's current content.
+func revisionDiffs(msg *models.Message, revisions []models.MessageRevision) []revisionDiff {
+	diffs := make([]revisionDiff, 0, len(revisions))
+	for i, rev := range revisions {
+		next := msg.Content
+		if i+1 < len(revisions) {
+			next = revisions[i+1].Content
+		}
+		diffs = append(diffs, revisionDiff{
+			EditedBy: rev.EditedBy,
+			EditedAt: rev.EditedAt,
+			Changes:  diffWords(rev.Content, next),
+		})
+	}
+	return diffs
+}
+
+// GetMessageRevisions godoc
+// @Summary List message revisions
+// @Description List earlier versions of a message. Available to its author and moderators; moderators also get a word diff of every edit
+// @Tags messages
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/messages/{message_id}/revisions [get]
+func GetMessageRevisions(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		vars := mux.Vars(r)
+		forumID, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+		messageID, err := strconv.Atoi(vars["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		msg, err := repo.GetMessageByID(messageID)
+		if err != nil || msg.ForumID != forumID {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		moderator := isModerator(user)
+		if !moderator && user.Username != msg.Author {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		revisions, err := repo.GetMessageRevisions(messageID)
+		if err != nil {
+			log.Error("Failed to load revisions", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load revisions")
+			return
+		}
+
+		response := map[string]interface{}{
+			"message":   msg,
+			"revisions": revisions,
+		}
+		if moderator {
+			response["diffs"] = revisionDiffs(msg, revisions)
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDiffWords(t *testing.T) {
+	got := diffWords("the quick brown fox", "the slow brown fox jumps")
+	assert.Equal(t, []diffOp{
+		{Op: diffEqual, Text: "the "},
+		{Op: diffDelete, Text: "quick"},
+		{Op: diffInsert, Text: "slow"},
+		{Op: diffEqual, Text: " brown fox"},
+		{Op: diffInsert, Text: " jumps"},
+	}, got)
+
+	assert.Equal(t, []diffOp{{Op: diffEqual, Text: "same text"}}, diffWords("same text", "same text"))
+	assert.Equal(t, []diffOp{{Op: diffInsert, Text: "new"}}, diffWords("", "new"))
+}
+
+func TestDiffWordsLongText(t *testing.T) {
+	long := strings.Repeat("word ", maxDiffCells/1000)
+	got := diffWords(long, long+"more")
+	assert.Equal(t, []diffOp{
+		{Op: diffDelete, Text: long},
+		{Op: diffInsert, Text: long + "more"},
+	}, got, "texts too long to diff are replaced outright")
+}
+
+func TestUpdateMessageTooLong(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages/{message_id}", UpdateMessage(mockRepo))
+
+	body, _ := json.Marshal(map[string]string{"content": strings.Repeat("a", maxContentLength+1)})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1/messages/1", string(body)))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNotCalled(t, "PutMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetMessageRevisions(t *testing.T) {
+	editedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	message := &models.Message{ID: 1, ForumID: 1, Author: "User1", Content: "final text", EditCount: 2}
+	revisions := []models.MessageRevision{
+		{ID: 1, MessageID: 1, Content: "first text", EditedBy: "User1", EditedAt: editedAt},
+		{ID: 2, MessageID: 1, Content: "second text", EditedBy: "Mod", EditedAt: editedAt.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name      string
+		user      *models.User
+		forumID   string
+		wantCode  int
+		wantDiffs bool
+	}{
+		{name: "Author", user: &models.User{Username: "User1", Role: "user"}, forumID: "1", wantCode: http.StatusOK},
+		{name: "Moderator", user: &models.User{Username: "Mod", Role: "moderator"}, forumID: "1", wantCode: http.StatusOK, wantDiffs: true},
+		{name: "Other User", user: &models.User{Username: "User2", Role: "user"}, forumID: "1", wantCode: http.StatusForbidden},
+		{name: "Wrong Forum", user: &models.User{Username: "Mod", Role: "admin"}, forumID: "2", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(tt.user, nil)
+			mockRepo.On("GetMessageByID", 1).Return(message, nil)
+			mockRepo.On("GetMessageRevisions", 1).Return(revisions, nil)
+
+			router := mux.NewRouter()
+			router.HandleFunc("/forums/{id}/messages/{message_id}/revisions", GetMessageRevisions(mockRepo))
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "GET", "/forums/"+tt.forumID+"/messages/1/revisions", ""))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantCode != http.StatusOK {
+				mockRepo.AssertNotCalled(t, "GetMessageRevisions", mock.Anything)
+				return
+			}
+
+			var got struct {
+				Revisions []models.MessageRevision `json:"revisions"`
+				Diffs     []revisionDiff           `json:"diffs"`
+			}
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			assert.Equal(t, revisions, got.Revisions)
+			if !tt.wantDiffs {
+				assert.Nil(t, got.Diffs)
+				return
+			}
+			assert.Len(t, got.Diffs, 2)
+			assert.Equal(t, "Mod", got.Diffs[1].EditedBy)
+			assert.Equal(t, []diffOp{
+				{Op: diffDelete, Text: "second"},
+				{Op: diffInsert, Text: "final"},
+				{Op: diffEqual, Text: " text"},
+			}, got.Diffs[1].Changes)
+		})
+	}
+}
+
+func TestGetMessageRevisionsUnauthorized(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages/{message_id}/revisions", GetMessageRevisions(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/messages/1/revisions", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockRepo.AssertNotCalled(t, "GetMessageRevisions", mock.Anything)
+}
+
+func TestUpdateMessageUnchangedContent(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 1).Return(&models.Message{ID: 1, ForumID: 1, Author: "User1", Content: "Same"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/messages/{message_id}", UpdateMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1/messages/1", `{"content":"Same"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertNotCalled(t, "PutMessage", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+const defaultSearchLimit = 20
+
+// searchLanguages lists the text search configurations a client may pick:
+// those the search vectors are built with, or the query would not match
+// the indexed lexemes.
+var searchLanguages = map[string]bool{
+	"russian": true,
+	"english": true,
+}
+
+var errInvalidSearch = errors.New("invalid search parameters")
+
+func RegisterSearchHandlers(r *mux.Router, repo repository.SearchRepository) {
+	r.HandleFunc("/api/search", Search(repo)).Methods("GET")
+}
+
+// parseSearchDate accepts RFC 3339 timestamps or plain dates. A plain date
+// used as an upper bound covers the whole day.
+func parseSearchDate(v string, endOfDay bool) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, errInvalidSearch
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+func parseSearchQuery(r *http.Request) (models.SearchQuery, error) {
+	q := r.URL.Query()
+	query := models.SearchQuery{
+		Query:    strings.TrimSpace(q.Get("q")),
+		Language: q.Get("lang"),
+		Author:   strings.TrimSpace(q.Get("author")),
+		Limit:    defaultSearchLimit,
+	}
+	if query.Query == "" {
+		return query, errInvalidSearch
+	}
+	if query.Language != "" && !searchLanguages[query.Language] {
+		return query, errInvalidSearch
+	}
+
+	if v := q.Get("forum_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return query, errInvalidSearch
+		}
+		query.ForumID = &id
+	}
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if query.From, err = parseSearchDate(v, false); err != nil {
+			return query, err
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if query.To, err = parseSearchDate(v, true); err != nil {
+			return query, err
+		}
+	}
+	if query.From != nil && query.To != nil && query.To.Before(*query.From) {
+		return query, errInvalidSearch
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return query, errInvalidSearch
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		query.Limit = limit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return query, errInvalidSearch
+		}
+		query.Offset = offset
+	}
+	return query, nil
+}
+
+// Search godoc
+// @Summary Full-text search
+// @Description Search forums and messages, ranked by relevance with highlighted snippets
+// @Tags search
+// @Produce json
+// @Param q query string true "Search query"
+// @Param lang query string false "Text search configuration (russian, english)"
+// @Param forum_id query int false "Restrict to a forum"
+// @Param author query string false "Restrict to messages by an author"
+// @Param from query string false "Earliest date (YYYY-MM-DD or RFC 3339)"
+// @Param to query string false "Latest date (YYYY-MM-DD or RFC 3339)"
+// @Param limit query int false "Page size"
+// @Param offset query int false "Results to skip"
+// @Success 200 {object} models.SearchPage
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /search [get]
+func Search(repo repository.SearchRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		query, err := parseSearchQuery(r)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		page, err := repo.Search(query)
+		if err != nil {
+			log.Error("Search failed", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Search failed")
+			return
+		}
+
+		json.NewEncoder(w).Encode(page)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSearch(t *testing.T) {
+	mockRepo := new(mocks.MockSearchRepo)
+	mockRepo.On("Search", mock.MatchedBy(func(q models.SearchQuery) bool {
+		return q.Query == "golang" && q.Language == "english" && q.ForumID != nil && *q.ForumID == 2 &&
+			q.Author == "user1" && q.From.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) &&
+			q.To.Equal(time.Date(2024, 1, 31, 23, 59, 59, 999999999, time.UTC)) &&
+			q.Limit == 5 && q.Offset == 10
+	})).Return(&models.SearchPage{
+		Results: []models.SearchResult{{Type: models.SearchResultMessage, ID: 1, ForumID: 2, Snippet: "<mark>golang</mark>"}},
+		Total:   11,
+		Limit:   5,
+		Offset:  10,
+	}, nil)
+
+	router := mux.NewRouter()
+	RegisterSearchHandlers(router, mockRepo)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET",
+		"/api/search?q=golang&lang=english&forum_id=2&author=user1&from=2024-01-01&to=2024-01-31&limit=5&offset=10", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got models.SearchPage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, 11, got.Total)
+	assert.Equal(t, "<mark>golang</mark>", got.Results[0].Snippet)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSearchInvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "Missing Query", query: ""},
+		{name: "Blank Query", query: "?q=++"},
+		{name: "Unknown Language", query: "?q=go&lang=klingon"},
+		{name: "Unindexed Language", query: "?q=go&lang=simple"},
+		{name: "Invalid Forum", query: "?q=go&forum_id=abc"},
+		{name: "Invalid Date", query: "?q=go&from=yesterday"},
+		{name: "Reversed Range", query: "?q=go&from=2024-02-01&to=2024-01-01"},
+		{name: "Invalid Limit", query: "?q=go&limit=0"},
+		{name: "Negative Offset", query: "?q=go&offset=-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockSearchRepo)
+			router := mux.NewRouter()
+			RegisterSearchHandlers(router, mockRepo)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/search"+tt.query, nil))
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			mockRepo.AssertNotCalled(t, "Search", mock.Anything)
+		})
+	}
+}
+
+func TestSearchRepositoryError(t *testing.T) {
+	mockRepo := new(mocks.MockSearchRepo)
+	mockRepo.On("Search", mock.Anything).Return(nil, errors.New("database error"))
+
+	router := mux.NewRouter()
+	RegisterSearchHandlers(router, mockRepo)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/search?q=go", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
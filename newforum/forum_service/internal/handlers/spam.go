@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/jaxxiy/newforum/forum_service/internal/spam"
+)
+
+var (
+	// spamStore and spamClassifier are set by RegisterSpamHandlers;
+	// without them messages are not scored. Messages scoring at least
+	// spamThreshold are held for review.
+	spamStore      repository.SpamRepository
+	spamClassifier *spam.Classifier
+	spamThreshold  float64
+)
+
+// heldResponse tells the author a message is waiting for review. The
+// score is left out so spammers cannot tune their messages against it.
+type heldResponse struct {
+	HeldID int    `json:"held_id"`
+	Status string `json:"status"`
+}
+
+type spamMarkRequest struct {
+	Spam bool `json:"spam"`
+}
+
+type spamScoreRequest struct {
+	Author  string `json:"author"`
+	Content string `json:"content"`
+}
+
+// heldReviewedEvent tells other moderators a held message has been
+// handled. MessageID is set when it was approved.
+type heldReviewedEvent struct {
+	HeldID     int    `json:"held_id"`
+	ForumID    int    `json:"forum_id"`
+	Approved   bool   `json:"approved"`
+	MessageID  int    `json:"message_id,omitempty"`
+	ReviewedBy string `json:"reviewed_by"`
+}
+
+func RegisterSpamHandlers(r *mux.Router, repo repository.ForumsRepository, store repository.SpamRepository, threshold float64) {
+	spamStore = store
+	spamClassifier = spam.NewClassifier()
+	spamThreshold = threshold
+
+	r.HandleFunc("/api/spam/held", GetHeldMessages(repo, store)).Methods("GET")
+	r.HandleFunc("/api/spam/held/{held_id:[0-9]+}/approve", ApproveHeldMessage(repo, store)).Methods("POST")
+	r.HandleFunc("/api/spam/held/{held_id:[0-9]+}/reject", RejectHeldMessage(repo, store)).Methods("POST")
+	r.HandleFunc("/api/spam/messages/{message_id:[0-9]+}", MarkSpam(repo)).Methods("POST")
+	r.HandleFunc("/api/spam/messages/{message_id:[0-9]+}/score", GetMessageSpamScore(repo)).Methods("GET")
+	r.HandleFunc("/api/spam/score", ScoreSpam(repo)).Methods("POST")
+	r.HandleFunc("/api/spam/model", GetSpamModel(repo)).Methods("GET")
+	r.HandleFunc("/api/spam/retrain", RetrainSpamClassifier(repo, store)).Methods("POST")
+}
+
+// TrainSpamClassifier trains the classifier on every message marked as
+// spam or not so far, replacing what it had learnt.
+func TrainSpamClassifier(store repository.SpamRepository) (models.SpamModel, error) {
+	samples, err := store.GetSpamSamples()
+	if err != nil {
+		return models.SpamModel{}, err
+	}
+	spamClassifier.Train(samples)
+	return spamClassifier.Model(), nil
+}
+
+// scoreMessage rates content as if author posted it now.
+func scoreMessage(author, content string) (models.SpamScore, error) {
+	now := time.Now()
+	activity, err := spamStore.GetAuthorActivity(author, content,
+		now.Add(-spam.VelocityWindow), now.Add(-spam.DuplicateWindow))
+	if err != nil {
+		return models.SpamScore{}, err
+	}
+	in := spam.Input{Content: content, Activity: *activity}
+	if activity.RegisteredAt != nil {
+		in.AccountAge = now.Sub(*activity.RegisteredAt)
+	}
+	return spam.Score(in, spamClassifier), nil
+}
+
+// holdSpam scores a message about to be posted and, if it looks like
+// spam, holds it for review instead and responds 202 Accepted. It reports
+// whether it has responded. Moderators are not scored, and a message that
+// cannot be scored is let through rather than lost.
+func holdSpam(w http.ResponseWriter, user *models.User, msg models.Message, attachments []models.Attachment) bool {
+	if spamStore == nil || isModerator(user) {
+		return false
+	}
+	score, err := scoreMessage(msg.Author, msg.Content)
+	if err != nil {
+		log.Error("Failed to score message", logger.Error(err), logger.String("author", msg.Author))
+		return false
+	}
+	if score.Score < spamThreshold {
+		return false
+	}
+
+	msg.Attachments = attachments
+	held, err := spamStore.HoldMessage(models.HeldMessage{Message: msg, Spam: score})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			sendError(w, http.StatusNotFound, "Forum not found")
+			return true
+		}
+		log.Error("Failed to hold message", logger.Error(err))
+		sendError(w, http.StatusInternalServerError, "Failed to save message")
+		return true
+	}
+	log.Info("Message held as spam",
+		logger.Int("heldID", held.ID),
+		logger.Int("forumID", msg.ForumID),
+		logger.String("author", msg.Author))
+
+	go broadcastToModerators(msg.ForumID, WSMessage{
+		Type:    "message_held",
+		Payload: held,
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(heldResponse{HeldID: held.ID, Status: "pending_review"})
+	return true
+}
+
+// rejectSpamEdit scores the content msg is being edited to and, if it
+// looks like spam, refuses the edit with 422 Unprocessable Entity. It
+// reports whether it has responded. An edit cannot be held like a new
+// message without hiding what is already posted, so it is turned down
+// instead. Moderators are not scored, and content that cannot be scored
+// is let through.
+func rejectSpamEdit(w http.ResponseWriter, user *models.User, msg *models.Message, content string) bool {
+	if spamStore == nil || isModerator(user) {
+		return false
+	}
+	score, err := scoreMessage(msg.Author, content)
+	if err != nil {
+		log.Error("Failed to score edit", logger.Error(err), logger.Int("messageID", msg.ID))
+		return false
+	}
+	if score.Score < spamThreshold {
+		return false
+	}
+	log.Info("Edit rejected as spam",
+		logger.Int("messageID", msg.ID),
+		logger.Int("forumID", msg.ForumID),
+		logger.String("author", msg.Author))
+	sendError(w, http.StatusUnprocessableEntity, "Edit looks like spam")
+	return true
+}
+
+// learnSpam records a training sample and teaches it to the classifier,
+// taking back the sample it replaces, which it returns.
+func learnSpam(sample models.SpamSample) (*models.SpamSample, error) {
+	previous, err := spamStore.AddSpamSample(sample)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		spamClassifier.Forget(previous.Content, previous.Spam)
+	}
+	spamClassifier.Learn(sample.Content, sample.Spam)
+	return previous, nil
+}
+
+func heldID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["held_id"])
+	if err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid held message ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// GetHeldMessages godoc
+// @Summary Held messages
+// @Description List the messages held as likely spam, oldest first, with their scores. Moderators only
+// @Tags spam
+// @Produce json
+// @Param forum_id query int false "Restrict to a forum"
+// @Security BearerAuth
+// @Success 200 {array} models.HeldMessage
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /spam/held [get]
+func GetHeldMessages(repo repository.ForumsRepository, store repository.SpamRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isModerator(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var forumID *int
+		if v := r.URL.Query().Get("forum_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				sendError(w, http.StatusBadRequest, "Invalid forum ID")
+				return
+			}
+			forumID = &id
+		}
+
+		held, err := store.GetHeldMessages(forumID)
+		if err != nil {
+			log.Error("Failed to load held messages", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load held messages")
+			return
+		}
+		json.NewEncoder(w).Encode(held)
+	}
+}
+
+// ApproveHeldMessage godoc
+// @Summary Approve held message
+// @Description Post a message held as likely spam, as of now, and teach the classifier it is not spam. Moderators only
+// @Tags spam
+// @Produce json
+// @Param held_id path int true "Held message ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 200 {object} models.Message
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /spam/held/{held_id}/approve [post]
+func ApproveHeldMessage(repo repository.ForumsRepository, store repository.SpamRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := heldID(w, r)
+		if !ok {
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		msg, err := store.ApproveHeldMessage(id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Held message not found")
+				return
+			}
+			log.Error("Failed to approve held message", logger.Error(err), logger.Int("heldID", id))
+			sendError(w, http.StatusInternalServerError, "Failed to approve message")
+			return
+		}
+		signAttachments(msg.Attachments)
+
+		// The message is posted either way, so failing to learn from it
+		// is only logged.
+		if _, err := learnSpam(models.SpamSample{MessageID: &msg.ID, Content: msg.Content, MarkedBy: user.Username}); err != nil {
+			log.Error("Failed to save spam sample", logger.Error(err), logger.Int("messageID", msg.ID))
+		}
+
+		// The message it replied to may have been deleted while it was
+		// held; it is then posted as a plain message.
+		var parent *models.Message
+		if msg.ReplyTo != nil {
+			if parent, err = repo.GetMessageByID(*msg.ReplyTo); err != nil {
+				parent = nil
+			}
+		}
+		publishMessage(repo, msg, parent)
+
+		event := heldReviewedEvent{
+			HeldID:     id,
+			ForumID:    msg.ForumID,
+			Approved:   true,
+			MessageID:  msg.ID,
+			ReviewedBy: user.Username,
+		}
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationSpamApprove,
+			TargetType: models.ModerationTargetMessage,
+			TargetID:   msg.ID,
+			ForumID:    &msg.ForumID,
+			Reason:     moderationReason(r),
+		}, nil, msg)
+		go broadcastToModerators(msg.ForumID, WSMessage{Type: "held_reviewed", Payload: event})
+
+		json.NewEncoder(w).Encode(msg)
+	}
+}
+
+// RejectHeldMessage godoc
+// @Summary Reject held message
+// @Description Delete a message held as likely spam and teach the classifier it is spam. Moderators only
+// @Tags spam
+// @Param held_id path int true "Held message ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /spam/held/{held_id}/reject [post]
+func RejectHeldMessage(repo repository.ForumsRepository, store repository.SpamRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, ok := heldID(w, r)
+		if !ok {
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		held, err := store.RejectHeldMessage(id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Held message not found")
+				return
+			}
+			log.Error("Failed to reject held message", logger.Error(err), logger.Int("heldID", id))
+			sendError(w, http.StatusInternalServerError, "Failed to reject message")
+			return
+		}
+
+		if _, err := learnSpam(models.SpamSample{Content: held.Message.Content, Spam: true, MarkedBy: user.Username}); err != nil {
+			log.Error("Failed to save spam sample", logger.Error(err), logger.Int("heldID", id))
+		}
+
+		forumID := held.Message.ForumID
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationSpamReject,
+			TargetType: models.ModerationTargetHeld,
+			TargetID:   held.ID,
+			ForumID:    &forumID,
+			Reason:     moderationReason(r),
+		}, held, nil)
+		go broadcastToModerators(forumID, WSMessage{Type: "held_reviewed", Payload: heldReviewedEvent{
+			HeldID:     id,
+			ForumID:    forumID,
+			ReviewedBy: user.Username,
+		}})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MarkSpam godoc
+// @Summary Mark message as spam
+// @Description Teach the classifier whether a posted message is spam. Marking a message again replaces the earlier mark. This only trains the classifier: remove spam through the report queue or by deleting it. Moderators only
+// @Tags spam
+// @Accept json
+// @Param message_id path int true "Message ID"
+// @Param mark body spamMarkRequest true "Whether the message is spam"
+// @Security BearerAuth
+// @Success 204
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /spam/messages/{message_id} [post]
+func MarkSpam(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		messageID, err := strconv.Atoi(mux.Vars(r)["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req spamMarkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+
+		msg, err := repo.GetMessageByID(messageID)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		sample := models.SpamSample{MessageID: &msg.ID, Content: msg.Content, Spam: req.Spam, MarkedBy: user.Username}
+		previous, err := learnSpam(sample)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Message not found")
+				return
+			}
+			log.Error("Failed to save spam sample", logger.Error(err), logger.Int("messageID", messageID))
+			sendError(w, http.StatusInternalServerError, "Failed to mark message")
+			return
+		}
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationSpamMark,
+			TargetType: models.ModerationTargetMessage,
+			TargetID:   msg.ID,
+			ForumID:    &msg.ForumID,
+			Reason:     moderationReason(r),
+		}, previous, sample)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetMessageSpamScore godoc
+// @Summary Message spam score
+// @Description Score a posted message as if its author posted it again now, with the signals the score is made of. Admins only
+// @Tags spam
+// @Produce json
+// @Param message_id path int true "Message ID"
+// @Security BearerAuth
+// @Success 200 {object} models.SpamScore
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /spam/messages/{message_id}/score [get]
+func GetMessageSpamScore(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		messageID, err := strconv.Atoi(mux.Vars(r)["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		msg, err := repo.GetMessageByID(messageID)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Message not found")
+			return
+		}
+
+		score, err := scoreMessage(msg.Author, msg.Content)
+		if err != nil {
+			log.Error("Failed to score message", logger.Error(err), logger.Int("messageID", messageID))
+			sendError(w, http.StatusInternalServerError, "Failed to score message")
+			return
+		}
+		json.NewEncoder(w).Encode(score)
+	}
+}
+
+// ScoreSpam godoc
+// @Summary Score text
+// @Description Score any text as if the given user posted it now, to try out the classifier and the hold threshold. Admins only
+// @Tags spam
+// @Accept json
+// @Produce json
+// @Param text body spamScoreRequest true "Author and content"
+// @Security BearerAuth
+// @Success 200 {object} models.SpamScore
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /spam/score [post]
+func ScoreSpam(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req spamScoreRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if strings.TrimSpace(req.Content) == "" {
+			sendError(w, http.StatusBadRequest, "Content is required")
+			return
+		}
+
+		score, err := scoreMessage(strings.TrimSpace(req.Author), req.Content)
+		if err != nil {
+			log.Error("Failed to score text", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to score text")
+			return
+		}
+		json.NewEncoder(w).Encode(score)
+	}
+}
+
+// GetSpamModel godoc
+// @Summary Spam classifier
+// @Description Show how many samples the classifier learnt from, whether it has enough to count yet and the words and domains that most suggest spam or not. Admins only
+// @Tags spam
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SpamModel
+// @Failure 403 {object} map[string]string
+// @Router /spam/model [get]
+func GetSpamModel(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isAdmin(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		json.NewEncoder(w).Encode(spamClassifier.Model())
+	}
+}
+
+// RetrainSpamClassifier godoc
+// @Summary Retrain spam classifier
+// @Description Train the classifier again from every stored sample. It learns from each review as it happens, so this is only needed after samples were changed in the database. Admins only
+// @Tags spam
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.SpamModel
+// @Failure 403 {object} map[string]string
+// @Router /spam/retrain [post]
+func RetrainSpamClassifier(repo repository.ForumsRepository, store repository.SpamRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if !isAdmin(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		model, err := TrainSpamClassifier(store)
+		if err != nil {
+			log.Error("Failed to retrain spam classifier", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to retrain classifier")
+			return
+		}
+		log.Info("Spam classifier retrained",
+			logger.String("by", user.Username),
+			logger.Int("spamSamples", model.SpamSamples),
+			logger.Int("hamSamples", model.HamSamples))
+		json.NewEncoder(w).Encode(model)
+	}
+}
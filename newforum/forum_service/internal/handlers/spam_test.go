@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/jaxxiy/newforum/forum_service/internal/spam"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// spamRoutes registers the spam handlers, which also install the spam
+// store and a fresh classifier, and the route that scores new messages.
+func spamRoutes(repo *mocks.MockForumsRepo, store *mocks.MockSpamRepo) func(*mux.Router) {
+	return func(r *mux.Router) {
+		RegisterSpamHandlers(r, repo, store, 0.7)
+		r.HandleFunc("/api/forums/{id}/messages", PostMessage(repo)).Methods("POST")
+	}
+}
+
+func TestPostMessageSpamHold(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSpam := new(mocks.MockSpamRepo)
+	router := testRouter(t, spamRoutes(mockRepo, mockSpam))
+
+	registered := time.Now().Add(-time.Hour)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(12, nil)
+	mockSpam.On("GetAuthorActivity", "bob", "buy now https://pills.example", mock.Anything, mock.Anything).
+		Return(&models.AuthorActivity{Recent: 6, Duplicates: 3, RegisteredAt: &registered}, nil)
+	mockSpam.On("GetAuthorActivity", "bob", "hello there", mock.Anything, mock.Anything).
+		Return(&models.AuthorActivity{RegisteredAt: &registered}, nil)
+	mockSpam.On("GetAuthorActivity", "bob", "db down", mock.Anything, mock.Anything).
+		Return(nil, errors.New("db down"))
+
+	var held models.HeldMessage
+	mockSpam.On("HoldMessage", mock.AnythingOfType("models.HeldMessage")).
+		Run(func(args mock.Arguments) { held = args.Get(0).(models.HeldMessage) }).
+		Return(&models.HeldMessage{ID: 4, Message: models.Message{ForumID: 1, Author: "bob"}}, nil)
+
+	post := func(content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"author": "bob", "content": content})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/forums/1/messages", string(body)))
+		return rr
+	}
+
+	rr := post("buy now https://pills.example")
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.JSONEq(t, `{"held_id":4,"status":"pending_review"}`, rr.Body.String())
+	assert.Equal(t, "buy now https://pills.example", held.Message.Content)
+	assert.GreaterOrEqual(t, held.Spam.Score, 0.7)
+	assert.Equal(t, 1.0, held.Spam.Signals[models.SpamSignalRepeated])
+	mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything)
+
+	rr = post("hello there")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	// A message that cannot be scored is posted rather than lost.
+	rr = post("db down")
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	mockRepo.AssertNumberOfCalls(t, "CreateMessage", 2)
+	mockSpam.AssertNumberOfCalls(t, "HoldMessage", 1)
+}
+
+func TestUpdateMessageSpam(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSpam := new(mocks.MockSpamRepo)
+	router := testRouter(t, spamRoutes(mockRepo, mockSpam))
+	router.HandleFunc("/api/forums/{id}/messages/{message_id}", UpdateMessage(mockRepo)).Methods("PUT")
+
+	registered := time.Now().Add(-time.Hour)
+	msg := &models.Message{ID: 3, ForumID: 1, Author: "bob", Content: "hello there"}
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+	mockRepo.On("GetMessageByID", 3).Return(msg, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("PutMessage", 3, "hello again", "bob").Return(&models.Message{ID: 3, ForumID: 1, Author: "bob", Content: "hello again"}, nil)
+	mockSpam.On("GetAuthorActivity", "bob", "buy now https://pills.example", mock.Anything, mock.Anything).
+		Return(&models.AuthorActivity{Recent: 6, Duplicates: 3, RegisteredAt: &registered}, nil)
+	mockSpam.On("GetAuthorActivity", "bob", "hello again", mock.Anything, mock.Anything).
+		Return(&models.AuthorActivity{RegisteredAt: &registered}, nil)
+
+	edit := func(content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"content": content})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/1/messages/3", string(body)))
+		return rr
+	}
+
+	rr := edit("buy now https://pills.example")
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	mockRepo.AssertNotCalled(t, "PutMessage", mock.Anything, mock.Anything, mock.Anything)
+
+	rr = edit("hello again")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockSpam.AssertNotCalled(t, "HoldMessage", mock.Anything)
+}
+
+func TestPostMessageSpamModeratorNotScored(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSpam := new(mocks.MockSpamRepo)
+	router := testRouter(t, spamRoutes(mockRepo, mockSpam))
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.AnythingOfType("models.Message")).Return(12, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/forums/1/messages", `{"author":"mod","content":"https://a.example https://b.example"}`))
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	mockSpam.AssertNotCalled(t, "GetAuthorActivity", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReviewHeldMessages(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSpam := new(mocks.MockSpamRepo)
+	router := testRouter(t, spamRoutes(mockRepo, mockSpam))
+
+	forumID := 1
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockSpam.On("GetHeldMessages", &forumID).Return([]models.HeldMessage{{ID: 4}}, nil)
+	mockSpam.On("ApproveHeldMessage", 4).Return(&models.Message{ID: 30, ForumID: 1, Author: "bob", Content: "hello"}, nil)
+	mockSpam.On("ApproveHeldMessage", 5).Return(nil, repository.ErrNotFound)
+	mockSpam.On("RejectHeldMessage", 6).Return(&models.HeldMessage{ID: 6, Message: models.Message{ForumID: 1, Content: "buy pills"}}, nil)
+	messageID := 30
+	mockSpam.On("AddSpamSample", models.SpamSample{MessageID: &messageID, Content: "hello", MarkedBy: "mod"}).Return(nil, nil)
+	mockSpam.On("AddSpamSample", models.SpamSample{Content: "buy pills", Spam: true, MarkedBy: "mod"}).Return(nil, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+	}{
+		{"List", "GET", "/api/spam/held?forum_id=1", http.StatusOK},
+		{"List Bad Forum", "GET", "/api/spam/held?forum_id=x", http.StatusBadRequest},
+		{"Approve", "POST", "/api/spam/held/4/approve", http.StatusOK},
+		{"Approve Missing", "POST", "/api/spam/held/5/approve", http.StatusNotFound},
+		{"Reject", "POST", "/api/spam/held/6/reject", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, tt.method, tt.url, ""))
+			assert.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+		})
+	}
+	mockSpam.AssertExpectations(t)
+
+	model := spamClassifier.Model()
+	assert.Equal(t, 1, model.SpamSamples)
+	assert.Equal(t, 1, model.HamSamples)
+}
+
+func TestMarkSpam(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSpam := new(mocks.MockSpamRepo)
+	router := testRouter(t, spamRoutes(mockRepo, mockSpam))
+
+	messageID := 7
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("GetMessageByID", 7).Return(&models.Message{ID: 7, ForumID: 1, Content: "cheap pills"}, nil)
+	mockRepo.On("GetMessageByID", 8).Return(nil, repository.ErrNotFound)
+	mockSpam.On("AddSpamSample", models.SpamSample{MessageID: &messageID, Content: "cheap pills", Spam: true, MarkedBy: "mod"}).
+		Return(nil, nil).Once()
+	// Marking it again replaces the first mark.
+	mockSpam.On("AddSpamSample", models.SpamSample{MessageID: &messageID, Content: "cheap pills", MarkedBy: "mod"}).
+		Return(&models.SpamSample{MessageID: &messageID, Content: "cheap pills", Spam: true}, nil).Once()
+
+	for _, tt := range []struct {
+		url, body  string
+		wantStatus int
+	}{
+		{"/api/spam/messages/7", `{"spam":true}`, http.StatusNoContent},
+		{"/api/spam/messages/7", `{"spam":false}`, http.StatusNoContent},
+		{"/api/spam/messages/8", `{"spam":true}`, http.StatusNotFound},
+		{"/api/spam/messages/7", `spam`, http.StatusBadRequest},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "POST", tt.url, tt.body))
+		assert.Equal(t, tt.wantStatus, rr.Code, tt.body)
+	}
+	mockSpam.AssertExpectations(t)
+
+	model := spamClassifier.Model()
+	assert.Equal(t, 0, model.SpamSamples)
+	assert.Equal(t, 1, model.HamSamples)
+}
+
+func TestSpamReviewForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSpam := new(mocks.MockSpamRepo)
+	router := testRouter(t, spamRoutes(mockRepo, mockSpam))
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob", Role: "user"}, nil)
+
+	for _, tt := range []struct{ method, url string }{
+		{"GET", "/api/spam/held"},
+		{"POST", "/api/spam/held/4/approve"},
+		{"POST", "/api/spam/held/4/reject"},
+		{"POST", "/api/spam/messages/7"},
+		{"GET", "/api/spam/model"},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, tt.method, tt.url, `{"spam":true}`))
+		assert.Equal(t, http.StatusForbidden, rr.Code, tt.url)
+	}
+	mockSpam.AssertNotCalled(t, "ApproveHeldMessage", mock.Anything)
+	mockSpam.AssertNotCalled(t, "AddSpamSample", mock.Anything)
+}
+
+func TestSpamAdmin(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSpam := new(mocks.MockSpamRepo)
+	router := testRouter(t, spamRoutes(mockRepo, mockSpam))
+
+	var samples []models.SpamSample
+	for i := 0; i < spam.MinTrainingSamples; i++ {
+		samples = append(samples,
+			models.SpamSample{Content: fmt.Sprintf("cheap pills offer %d", i), Spam: true},
+			models.SpamSample{Content: fmt.Sprintf("meeting notes %d", i)})
+	}
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "root", Role: "admin"}, nil)
+	mockRepo.On("GetMessageByID", 7).Return(&models.Message{ID: 7, Author: "bob", Content: "cheap pills"}, nil)
+	mockSpam.On("GetSpamSamples").Return(samples, nil)
+	mockSpam.On("GetAuthorActivity", "bob", "cheap pills", mock.Anything, mock.Anything).
+		Return(&models.AuthorActivity{}, nil)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/spam/retrain", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var model models.SpamModel
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&model))
+	assert.True(t, model.Ready)
+	assert.Equal(t, spam.MinTrainingSamples, model.SpamSamples)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/api/spam/messages/7/score", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var score models.SpamScore
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&score))
+	assert.Greater(t, score.Signals[models.SpamSignalClassifier], 0.9)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/spam/score", `{"author":" bob ","content":"cheap pills"}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/spam/score", `{"author":"bob","content":"  "}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
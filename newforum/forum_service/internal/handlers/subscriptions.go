@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/mail"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+const (
+	// digestMessageLimit caps the messages listed per forum in one digest;
+	// the rest are only counted.
+	digestMessageLimit  = 20
+	digestExcerptLength = 300
+)
+
+var (
+	// subscriptionStore is set by RegisterSubscriptionHandlers; without it
+	// subscribers are not told about new messages.
+	subscriptionStore repository.SubscriptionsRepository
+
+	// unsubscribeSecret signs the unsubscribe links sent in digests.
+	unsubscribeSecret []byte
+)
+
+type subscriptionRequest struct {
+	Frequency string `json:"frequency"`
+}
+
+// unsubscribedPage is the data of unsubscribed.html. Confirm asks the user
+// to confirm before anything is changed.
+type unsubscribedPage struct {
+	Forum   *models.Forum
+	Invalid bool
+	Confirm bool
+}
+
+func RegisterSubscriptionHandlers(r *mux.Router, repo repository.ForumsRepository, subs repository.SubscriptionsRepository, secret string) {
+	subscriptionStore = subs
+	unsubscribeSecret = []byte(secret)
+
+	r.HandleFunc("/api/subscriptions", GetSubscriptions(repo, subs)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/subscription", Subscribe(repo, subs)).Methods("PUT")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/subscription", Unsubscribe(repo, subs)).Methods("DELETE")
+	r.HandleFunc("/unsubscribe", OneClickUnsubscribe(repo, subs)).Methods("GET", "POST")
+}
+
+// StartDigestMailer mails subscribers the messages posted in the forums
+// they follow, checking every interval which subscriptions are due. Links
+// in the mails point to baseURL.
+func StartDigestMailer(subs repository.SubscriptionsRepository, sender mail.Sender, baseURL string, interval time.Duration) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sendDigests(subs, sender, baseURL, time.Now())
+			<-ticker.C
+		}
+	}()
+}
+
+// sendDigests sends one mail to every subscriber with due subscriptions,
+// covering the messages posted up to now.
+func sendDigests(subs repository.SubscriptionsRepository, sender mail.Sender, baseURL string, now time.Time) {
+	due, err := subs.GetDueSubscriptions(now)
+	if err != nil {
+		log.Error("Digest run failed", logger.Error(err))
+		return
+	}
+
+	// Due subscriptions come grouped by subscriber.
+	for start := 0; start < len(due); {
+		end := start + 1
+		for end < len(due) && due[end].Username == due[start].Username {
+			end++
+		}
+		sendDigest(subs, sender, baseURL, due[start:end], now)
+		start = end
+	}
+}
+
+// sendDigest mails one subscriber the new messages of their due forums.
+// Forums without new messages are marked as sent as well, so a daily digest
+// waits for the next day. If anything fails nothing is marked and the
+// subscriber is tried again on the next run.
+func sendDigest(subs repository.SubscriptionsRepository, sender mail.Sender, baseURL string, due []models.Subscription, now time.Time) {
+	username := due[0].Username
+	digest := models.Digest{Username: username, BaseURL: baseURL}
+	forumIDs := make([]int, 0, len(due))
+
+	for _, s := range due {
+		messages, total, err := subs.GetDigestMessages(s.ForumID, username, s.LastSentAt, now, digestMessageLimit)
+		if err != nil {
+			log.Error("Failed to load digest messages",
+				logger.Error(err),
+				logger.String("username", username),
+				logger.Int("forumID", s.ForumID))
+			return
+		}
+		forumIDs = append(forumIDs, s.ForumID)
+		if total == 0 {
+			continue
+		}
+		for i := range messages {
+			messages[i].Content = excerpt(messages[i].Content, digestExcerptLength)
+		}
+		digest.Forums = append(digest.Forums, models.DigestForum{
+			ForumID:        s.ForumID,
+			ForumTitle:     s.ForumTitle,
+			Messages:       messages,
+			More:           total - len(messages),
+			UnsubscribeURL: unsubscribeURL(baseURL, username, s.ForumID),
+		})
+	}
+
+	if len(digest.Forums) > 0 {
+		msg, err := digestMail(due[0].Email, digest)
+		if err != nil {
+			log.Error("Failed to render digest", logger.Error(err), logger.String("username", username))
+			return
+		}
+		if err := sender.Send(msg); err != nil {
+			log.Error("Failed to send digest", logger.Error(err), logger.String("username", username))
+			return
+		}
+	}
+
+	if err := subs.MarkDigestSent(username, forumIDs, now); err != nil {
+		log.Error("Failed to mark digest sent", logger.Error(err), logger.String("username", username))
+	}
+}
+
+// digestMail renders digest for the subscriber at address. A digest about a
+// single forum also carries List-Unsubscribe headers so mail clients can
+// offer their own unsubscribe button.
+func digestMail(address string, digest models.Digest) (mail.Message, error) {
+	var body bytes.Buffer
+	if err := templates.ExecuteTemplate(&body, "digest_email.html", digest); err != nil {
+		return mail.Message{}, err
+	}
+
+	msg := mail.Message{
+		To:      address,
+		Subject: "Новые сообщения в ваших подписках",
+		HTML:    body.String(),
+	}
+	if len(digest.Forums) == 1 {
+		f := digest.Forums[0]
+		msg.Subject = fmt.Sprintf("Новые сообщения в форуме «%s»", f.ForumTitle)
+		msg.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + f.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+	return msg, nil
+}
+
+// excerpt shortens s to at most n runes, marking the cut with an ellipsis.
+func excerpt(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
+
+func unsubscribeSignature(username string, forumID int) string {
+	mac := hmac.New(sha256.New, unsubscribeSecret)
+	fmt.Fprintf(mac, "%s:%d", username, forumID)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// unsubscribeURL returns a link that unsubscribes username from a forum
+// without signing in. The signature stops anyone else from forging it.
+func unsubscribeURL(baseURL, username string, forumID int) string {
+	q := url.Values{}
+	q.Set("user", username)
+	q.Set("forum", strconv.Itoa(forumID))
+	q.Set("sig", unsubscribeSignature(username, forumID))
+	return baseURL + "/unsubscribe?" + q.Encode()
+}
+
+// notifySubscribers tells the users following a forum about a new message.
+// Users in skip were already notified about it, as the author of the
+// message it answers or by mention.
+func notifySubscribers(msg models.Message, skip []string) {
+	if subscriptionStore == nil {
+		return
+	}
+	names, err := subscriptionStore.GetSubscribers(msg.ForumID)
+	if err != nil {
+		log.Error("Failed to load subscribers", logger.Error(err), logger.Int("forumID", msg.ForumID))
+		return
+	}
+	for _, name := range names {
+		if slices.Contains(skip, name) {
+			continue
+		}
+		notify(models.Notification{
+			Username:  name,
+			Type:      models.NotificationActivity,
+			Actor:     msg.Author,
+			ForumID:   &msg.ForumID,
+			TopicID:   msg.TopicID,
+			MessageID: &msg.ID,
+		})
+	}
+}
+
+// GetSubscriptions godoc
+// @Summary List subscriptions
+// @Description List the forums the current user follows
+// @Tags subscriptions
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Subscription
+// @Failure 401 {object} map[string]string
+// @Router /subscriptions [get]
+func GetSubscriptions(repo repository.ForumsRepository, subs repository.SubscriptionsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		list, err := subs.GetSubscriptions(user.Username)
+		if err != nil {
+			log.Error("Failed to load subscriptions", logger.Error(err), logger.String("username", user.Username))
+			sendError(w, http.StatusInternalServerError, "Failed to load subscriptions")
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// Subscribe godoc
+// @Summary Subscribe to forum
+// @Description Follow a forum and get its new messages by email, immediately, daily or weekly (default daily). Subscribing again changes the frequency
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param subscription body subscriptionRequest false "Digest frequency"
+// @Security BearerAuth
+// @Success 200 {object} models.Subscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/subscription [put]
+func Subscribe(repo repository.ForumsRepository, subs repository.SubscriptionsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		var req subscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if req.Frequency == "" {
+			req.Frequency = models.DigestDaily
+		}
+		if !models.ValidDigestFrequency(req.Frequency) {
+			sendError(w, http.StatusBadRequest, "Unknown digest frequency: "+req.Frequency)
+			return
+		}
+
+		forum, err := repo.GetByID(forumID)
+		if err != nil {
+			sendError(w, http.StatusNotFound, "Forum not found")
+			return
+		}
+
+		sub, err := subs.Subscribe(user.Username, forumID, req.Frequency)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Forum not found")
+				return
+			}
+			log.Error("Failed to subscribe", logger.Error(err), logger.Int("forumID", forumID))
+			sendError(w, http.StatusInternalServerError, "Failed to subscribe")
+			return
+		}
+		sub.ForumTitle = forum.Title
+
+		json.NewEncoder(w).Encode(sub)
+	}
+}
+
+// Unsubscribe godoc
+// @Summary Unsubscribe from forum
+// @Description Stop following a forum
+// @Tags subscriptions
+// @Param id path int true "Forum ID"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/subscription [delete]
+func Unsubscribe(repo repository.ForumsRepository, subs repository.SubscriptionsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		if err := subs.Unsubscribe(user.Username, forumID); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Subscription not found")
+				return
+			}
+			log.Error("Failed to unsubscribe", logger.Error(err), logger.Int("forumID", forumID))
+			sendError(w, http.StatusInternalServerError, "Failed to unsubscribe")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// OneClickUnsubscribe godoc
+// @Summary Unsubscribe by link
+// @Description Follow the signed link from a digest to stop following a forum without signing in. Opening the link only asks for confirmation, since mail scanners and link previews fetch links too; the user confirms with a POST to the same link, as mail clients do (RFC 8058)
+// @Tags subscriptions
+// @Produce html
+// @Param user query string true "Username"
+// @Param forum query int true "Forum ID"
+// @Param sig query string true "Link signature"
+// @Success 200 "Confirmation page, or unsubscribed after a POST"
+// @Failure 400 "Invalid link"
+// @Router /unsubscribe [get]
+// @Router /unsubscribe [post]
+func OneClickUnsubscribe(repo repository.ForumsRepository, subs repository.SubscriptionsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		username := q.Get("user")
+		forumID, err := strconv.Atoi(q.Get("forum"))
+		valid := err == nil && username != "" &&
+			hmac.Equal([]byte(q.Get("sig")), []byte(unsubscribeSignature(username, forumID)))
+
+		if !valid {
+			w.WriteHeader(http.StatusBadRequest)
+			renderTemplate(w, "unsubscribed.html", unsubscribedPage{Invalid: true})
+			return
+		}
+
+		page := unsubscribedPage{Confirm: r.Method != http.MethodPost}
+		if !page.Confirm {
+			// Following an old link again is not an error.
+			if err := subs.Unsubscribe(username, forumID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				log.Error("Failed to unsubscribe", logger.Error(err), logger.Int("forumID", forumID))
+				http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+				return
+			}
+		}
+		page.Forum, _ = repo.GetByID(forumID)
+		renderTemplate(w, "unsubscribed.html", page)
+	}
+}
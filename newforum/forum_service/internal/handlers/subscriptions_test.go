@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mail"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSubscribe(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSubs := new(mocks.MockSubscriptionsRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterSubscriptionHandlers(r, mockRepo, mockSubs, "test-secret") })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1, Title: "Go"}, nil)
+	mockRepo.On("GetByID", 9).Return(nil, repository.ErrNotFound)
+	mockSubs.On("Subscribe", "bob", 1, models.DigestDaily).Return(&models.Subscription{
+		Username: "bob", ForumID: 1, Frequency: models.DigestDaily,
+	}, nil)
+	mockSubs.On("Subscribe", "bob", 1, models.DigestWeekly).Return(&models.Subscription{
+		Username: "bob", ForumID: 1, Frequency: models.DigestWeekly,
+	}, nil)
+
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		anonymous  bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Default Frequency", url: "/api/forums/1/subscription", wantStatus: http.StatusOK, wantBody: `"frequency":"daily"`},
+		{name: "Weekly", url: "/api/forums/1/subscription", body: `{"frequency":"weekly"}`, wantStatus: http.StatusOK, wantBody: `"forum_title":"Go"`},
+		{name: "Unknown Frequency", url: "/api/forums/1/subscription", body: `{"frequency":"hourly"}`, wantStatus: http.StatusBadRequest},
+		{name: "Forum Not Found", url: "/api/forums/9/subscription", wantStatus: http.StatusNotFound},
+		{name: "Unauthorized", url: "/api/forums/1/subscription", anonymous: true, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := authorizedRequest(t, "PUT", tt.url, tt.body)
+			if tt.anonymous {
+				req.Header.Del("Authorization")
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			if tt.wantBody != "" {
+				assert.Contains(t, rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+	mockSubs.AssertNumberOfCalls(t, "Subscribe", 2)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSubs := new(mocks.MockSubscriptionsRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterSubscriptionHandlers(r, mockRepo, mockSubs, "test-secret") })
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "bob"}, nil)
+	mockSubs.On("Unsubscribe", "bob", 1).Return(nil)
+	mockSubs.On("Unsubscribe", "bob", 2).Return(repository.ErrNotFound)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/1/subscription", ""))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/api/forums/2/subscription", ""))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestOneClickUnsubscribe(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockSubs := new(mocks.MockSubscriptionsRepo)
+	router := testRouter(t, func(r *mux.Router) { RegisterSubscriptionHandlers(r, mockRepo, mockSubs, "test-secret") })
+
+	mockRepo.On("GetByID", 3).Return(&models.Forum{ID: 3, Title: "Golang"}, nil)
+	mockSubs.On("Unsubscribe", "bob", 3).Return(nil).Once()
+	mockSubs.On("Unsubscribe", "bob", 3).Return(repository.ErrNotFound)
+
+	link, err := url.Parse(unsubscribeURL("http://forum.test", "bob", 3))
+	assert.NoError(t, err)
+
+	// Opening the link, as link scanners do too, only asks to confirm.
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", link.RequestURI(), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Golang")
+	assert.Contains(t, rr.Body.String(), `<form method="post">`)
+	mockSubs.AssertNotCalled(t, "Unsubscribe", mock.Anything, mock.Anything)
+
+	// The confirmation form and mail clients POST to the same link.
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", link.RequestURI(), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "<form")
+
+	// Unsubscribing again is not an error.
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", link.RequestURI(), strings.NewReader("List-Unsubscribe=One-Click")))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// A link signed for one user must not unsubscribe another.
+	forged := link.Query()
+	forged.Set("user", "alice")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/unsubscribe?"+forged.Encode(), nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	mockSubs.AssertNumberOfCalls(t, "Unsubscribe", 2)
+	mockSubs.AssertNotCalled(t, "Unsubscribe", "alice", 3)
+}
+
+func TestSendDigests(t *testing.T) {
+	mockSubs := new(mocks.MockSubscriptionsRepo)
+	mockSender := new(mocks.MockMailSender)
+	unsubscribeSecret = []byte("test-secret")
+	t.Cleanup(func() { unsubscribeSecret = nil })
+
+	now := time.Now()
+	lastWeek := now.Add(-7 * 24 * time.Hour)
+	topicID := 4
+	mockSubs.On("GetDueSubscriptions", now).Return([]models.Subscription{
+		{Username: "alice", Email: "alice@example.com", ForumID: 1, ForumTitle: "Go", Frequency: models.DigestImmediate, LastSentAt: now.Add(-time.Minute)},
+		{Username: "bob", Email: "bob@example.com", ForumID: 1, ForumTitle: "Go", Frequency: models.DigestDaily, LastSentAt: now.Add(-24 * time.Hour)},
+		{Username: "bob", Email: "bob@example.com", ForumID: 2, ForumTitle: "Rust", Frequency: models.DigestWeekly, LastSentAt: lastWeek},
+	}, nil)
+
+	mockSubs.On("GetDigestMessages", 1, "alice", mock.Anything, now, digestMessageLimit).
+		Return([]models.Message{{ID: 9, ForumID: 1, Author: "carol", Content: "hi", CreatedAt: now}}, 1, nil)
+	mockSubs.On("GetDigestMessages", 1, "bob", mock.Anything, now, digestMessageLimit).
+		Return([]models.Message{
+			{ID: 7, ForumID: 1, Author: "alice", Content: strings.Repeat("я", 400), CreatedAt: now},
+			{ID: 8, ForumID: 1, TopicID: &topicID, Author: "carol", Content: "<b>bold</b>", CreatedAt: now},
+		}, 25, nil)
+	mockSubs.On("GetDigestMessages", 2, "bob", lastWeek, now, digestMessageLimit).
+		Return([]models.Message{}, 0, nil)
+
+	mockSender.On("Send", mock.MatchedBy(func(m mail.Message) bool { return m.To == "alice@example.com" })).
+		Return(errors.New("connection refused"))
+	var sent mail.Message
+	mockSender.On("Send", mock.MatchedBy(func(m mail.Message) bool { return m.To == "bob@example.com" })).
+		Run(func(args mock.Arguments) { sent = args.Get(0).(mail.Message) }).
+		Return(nil)
+	mockSubs.On("MarkDigestSent", "bob", []int{1, 2}, now).Return(nil)
+
+	sendDigests(mockSubs, mockSender, "http://forum.test", now)
+
+	// Alice's mail failed, so her subscription stays due.
+	mockSubs.AssertNotCalled(t, "MarkDigestSent", "alice", mock.Anything, mock.Anything)
+	mockSubs.AssertExpectations(t)
+
+	assert.Equal(t, "Новые сообщения в форуме «Go»", sent.Subject)
+	assert.Equal(t, "<"+unsubscribeURL("http://forum.test", "bob", 1)+">", sent.Headers["List-Unsubscribe"])
+	assert.Contains(t, sent.HTML, strings.Repeat("я", digestExcerptLength)+"…")
+	assert.NotContains(t, sent.HTML, strings.Repeat("я", digestExcerptLength+1))
+	assert.Contains(t, sent.HTML, "&lt;b&gt;bold&lt;/b&gt;")
+	assert.Contains(t, sent.HTML, "http://forum.test/api/forums/1/topics/4/messages")
+	assert.Contains(t, sent.HTML, "И ещё 23")
+	assert.NotContains(t, sent.HTML, "Rust")
+}
+
+func TestNotifySubscribers(t *testing.T) {
+	mockSubs := new(mocks.MockSubscriptionsRepo)
+	mockNotes := new(mocks.MockNotificationsRepo)
+	subscriptionStore = mockSubs
+	t.Cleanup(func() { subscriptionStore = nil })
+	useNotifications(t, mockNotes)
+
+	mockSubs.On("GetSubscribers", 1).Return([]string{"alice", "bob", "carol"}, nil)
+	mockNotes.On("CreateNotification", mock.MatchedBy(func(n models.Notification) bool {
+		return n.Username == "carol" && n.Type == models.NotificationActivity && n.Actor == "alice" && *n.MessageID == 5
+	})).Return(&models.Notification{ID: 1, Username: "carol"}, nil)
+
+	// Alice wrote the message and bob was mentioned in it.
+	notifySubscribers(models.Message{ID: 5, ForumID: 1, Author: "alice"}, []string{"bob"})
+
+	mockNotes.AssertExpectations(t)
+	mockNotes.AssertNumberOfCalls(t, "CreateNotification", 1)
+}
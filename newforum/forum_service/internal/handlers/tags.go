@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+const (
+	defaultTagSuggestions = 10
+	maxTagSuggestions     = 50
+)
+
+type tagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type renameTagRequest struct {
+	Name string `json:"name"`
+}
+
+type mergeTagRequest struct {
+	Into string `json:"into"`
+}
+
+func RegisterTagHandlers(r *mux.Router, repo repository.ForumsRepository) {
+	r.HandleFunc("/api/tags", SearchTags(repo)).Methods("GET")
+	r.HandleFunc("/api/tags/{name}", TagPage(repo)).Methods("GET")
+	r.HandleFunc("/api/tags/{name}/list", GetTagPage(repo)).Methods("GET")
+	r.HandleFunc("/api/tags/{name}", RenameTag(repo)).Methods("PUT")
+	r.HandleFunc("/api/tags/{name}/merge", MergeTags(repo)).Methods("POST")
+
+	r.HandleFunc("/api/forums/{id:[0-9]+}/tags", GetForumTags(repo)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/tags", SetForumTags(repo)).Methods("PUT")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}/tags", GetTopicTags(repo)).Methods("GET")
+	r.HandleFunc("/api/forums/{id:[0-9]+}/topics/{topic_id:[0-9]+}/tags", SetTopicTags(repo)).Methods("PUT")
+}
+
+// suggestionLimit reads the limit query parameter of an autocomplete
+// endpoint, capping it at max.
+func suggestionLimit(r *http.Request, def, max int) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, errors.New("invalid limit")
+	}
+	if n > max {
+		n = max
+	}
+	return n, nil
+}
+
+// splitTags splits the comma separated tag list sent by HTML forms.
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// loadTagPage reads a tag together with one page of its forums and all of
+// its topics. The tag name in the request is normalised first, so links
+// written by hand still find the tag.
+func loadTagPage(repo repository.ForumsRepository, name string, pageReq models.PageRequest) (*models.TagPage, *models.ForumPage, error) {
+	name, err := models.NormalizeTag(name)
+	if err != nil {
+		return nil, nil, repository.ErrNotFound
+	}
+
+	tag, err := repo.GetTag(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	forums, err := repo.GetTaggedForumsPage(tag.Name, pageReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	topics, err := repo.GetTaggedTopics(tag.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &models.TagPage{Tag: *tag, Forums: forums.Forums, Topics: topics}, forums, nil
+}
+
+// renderTagPage writes the HTML page listing everything carrying a tag.
+func renderTagPage(w http.ResponseWriter, r *http.Request, repo repository.ForumsRepository, name string) {
+	pageReq, err := parsePageRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, forums, err := loadTagPage(repo, name, pageReq)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "Tag not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	renderTemplate(w, "tag.html", map[string]interface{}{
+		"Tag":    page.Tag,
+		"Forums": page.Forums,
+		"Topics": page.Topics,
+		"Prev":   forums.Prev,
+		"Next":   forums.Next,
+	})
+}
+
+// TagPage godoc
+// @Summary Tag page
+// @Description Render the forums and topics carrying a tag
+// @Tags tags
+// @Produce html
+// @Param name path string true "Tag name"
+// @Param before query string false "Cursor to read forums before"
+// @Param after query string false "Cursor to read forums after"
+// @Success 200 {string} string "HTML page"
+// @Failure 404 {string} string "Tag not found"
+// @Router /tags/{name} [get]
+func TagPage(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		renderTagPage(w, r, repo, mux.Vars(r)["name"])
+	}
+}
+
+// GetTagPage godoc
+// @Summary Tagged forums and topics
+// @Description Get a tag with one page of its forums and all of its topics
+// @Tags tags
+// @Produce json
+// @Param name path string true "Tag name"
+// @Param before query string false "Cursor to read forums before"
+// @Param after query string false "Cursor to read forums after"
+// @Param limit query int false "Page size (max 100)"
+// @Success 200 {object} models.TagPage
+// @Failure 404 {object} map[string]string
+// @Router /tags/{name}/list [get]
+func GetTagPage(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		pageReq, err := parsePageRequest(r)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		page, _, err := loadTagPage(repo, mux.Vars(r)["name"], pageReq)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Tag not found")
+				return
+			}
+			log.Error("Failed to load tag page", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load tag")
+			return
+		}
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// SearchTags godoc
+// @Summary Suggest tags
+// @Description Autocomplete tags by prefix, most used first. Without a prefix the most used tags are returned
+// @Tags tags
+// @Produce json
+// @Param q query string false "Tag prefix"
+// @Param limit query int false "Number of suggestions (max 50)"
+// @Success 200 {array} models.Tag
+// @Failure 400 {object} map[string]string
+// @Router /tags [get]
+func SearchTags(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit, err := suggestionLimit(r, defaultTagSuggestions, maxTagSuggestions)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+
+		// A prefix that normalises to nothing, such as "-", matches every tag.
+		prefix, _ := models.NormalizeTag(r.URL.Query().Get("q"))
+
+		tags, err := repo.SearchTags(prefix, limit)
+		if err != nil {
+			log.Error("Failed to search tags", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to search tags")
+			return
+		}
+		json.NewEncoder(w).Encode(tags)
+	}
+}
+
+// GetForumTags godoc
+// @Summary Forum tags
+// @Description Get the tags of a forum
+// @Tags tags
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Success 200 {array} string
+// @Router /forums/{id}/tags [get]
+func GetForumTags(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		tags, err := repo.GetForumTags(forumID)
+		if err != nil {
+			log.Error("Failed to load forum tags", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load tags")
+			return
+		}
+		json.NewEncoder(w).Encode(tags)
+	}
+}
+
+// SetForumTags godoc
+// @Summary Set forum tags
+// @Description Replace the tags of a forum. Tags are normalised and created when new. Moderators only
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param tags body tagsRequest true "New tags"
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/tags [put]
+func SetForumTags(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		if !isModerator(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		tags, ok := decodeTags(w, r)
+		if !ok {
+			return
+		}
+
+		if err := repo.SetForumTags(forumID, tags); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Forum not found")
+				return
+			}
+			log.Error("Failed to set forum tags", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to set tags")
+			return
+		}
+		json.NewEncoder(w).Encode(tags)
+	}
+}
+
+// GetTopicTags godoc
+// @Summary Topic tags
+// @Description Get the tags of a topic
+// @Tags tags
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param topic_id path int true "Topic ID"
+// @Success 200 {array} string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/topics/{topic_id}/tags [get]
+func GetTopicTags(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil || topic == nil {
+			sendError(w, http.StatusNotFound, "Topic not found")
+			return
+		}
+
+		tags, err := repo.GetTopicTags(topic.ID)
+		if err != nil {
+			log.Error("Failed to load topic tags", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load tags")
+			return
+		}
+		json.NewEncoder(w).Encode(tags)
+	}
+}
+
+// SetTopicTags godoc
+// @Summary Set topic tags
+// @Description Replace the tags of a topic. Tags are normalised and created when new. Topic author or moderators only
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param topic_id path int true "Topic ID"
+// @Param tags body tagsRequest true "New tags"
+// @Security BearerAuth
+// @Success 200 {array} string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/topics/{topic_id}/tags [put]
+func SetTopicTags(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil || topic == nil {
+			sendError(w, http.StatusNotFound, "Topic not found")
+			return
+		}
+
+		if user.Username != topic.Author && !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		tags, ok := decodeTags(w, r)
+		if !ok {
+			return
+		}
+
+		if err := repo.SetTopicTags(topic.ID, tags); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Topic not found")
+				return
+			}
+			log.Error("Failed to set topic tags", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to set tags")
+			return
+		}
+		json.NewEncoder(w).Encode(tags)
+	}
+}
+
+// decodeTags reads and normalises the tag list in the request body. It
+// writes the error response itself and reports whether decoding succeeded.
+func decodeTags(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var req tagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		return nil, false
+	}
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return tags, true
+}
+
+// RenameTag godoc
+// @Summary Rename tag
+// @Description Give a tag a new name. Renaming onto an existing tag is refused; merge the tags instead. Admins only
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param name path string true "Tag name"
+// @Param tag body renameTagRequest true "New name"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /tags/{name} [put]
+func RenameTag(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if !isAdmin(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req renameTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		newName, err := models.NormalizeTag(req.Name)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		name, _ := models.NormalizeTag(mux.Vars(r)["name"])
+		tag, err := repo.GetTag(name)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Tag not found")
+				return
+			}
+			sendError(w, http.StatusInternalServerError, "Failed to load tag")
+			return
+		}
+
+		if err := repo.RenameTag(tag.Name, newName); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrTagExists):
+				sendError(w, http.StatusConflict, err.Error())
+			case errors.Is(err, repository.ErrNotFound):
+				sendError(w, http.StatusNotFound, "Tag not found")
+			default:
+				log.Error("Failed to rename tag", logger.Error(err))
+				sendError(w, http.StatusInternalServerError, "Failed to rename tag")
+			}
+			return
+		}
+		renamed := *tag
+		renamed.Name = newName
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationTagRename,
+			TargetType: models.ModerationTargetTag,
+			TargetID:   tag.ID,
+			Reason:     moderationReason(r),
+		}, tag, renamed)
+
+		json.NewEncoder(w).Encode(renamed)
+	}
+}
+
+// MergeTags godoc
+// @Summary Merge tags
+// @Description Move everything carrying a tag over to another tag and delete the first one. Admins only
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Param name path string true "Tag to merge away"
+// @Param merge body mergeTagRequest true "Tag to merge into"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /tags/{name}/merge [post]
+func MergeTags(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		user := requestUser(r, repo)
+		if !isAdmin(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		var req mergeTagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+
+		sourceName, _ := models.NormalizeTag(mux.Vars(r)["name"])
+		source, err := repo.GetTag(sourceName)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Tag not found")
+				return
+			}
+			sendError(w, http.StatusInternalServerError, "Failed to load tag")
+			return
+		}
+		targetName, _ := models.NormalizeTag(req.Into)
+		target, err := repo.GetTag(targetName)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Target tag not found")
+				return
+			}
+			sendError(w, http.StatusInternalServerError, "Failed to load tag")
+			return
+		}
+		if source.ID == target.ID {
+			sendError(w, http.StatusBadRequest, "Cannot merge a tag into itself")
+			return
+		}
+
+		if err := repo.MergeTags(source.Name, target.Name); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Tag not found")
+				return
+			}
+			log.Error("Failed to merge tags", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to merge tags")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationTagMerge,
+			TargetType: models.ModerationTargetTag,
+			TargetID:   source.ID,
+			Reason:     moderationReason(r),
+		}, source, target)
+
+		merged, err := repo.GetTag(target.Name)
+		if err != nil {
+			merged = target
+		}
+		json.NewEncoder(w).Encode(merged)
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := models.NormalizeTags([]string{"  Web Dev ", "web_dev", "C++", "Го!", "golang"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-dev", "c++", "го", "golang"}, tags)
+
+	_, err = models.NormalizeTags([]string{"--"})
+	assert.ErrorIs(t, err, models.ErrInvalidTag)
+
+	_, err = models.NormalizeTags([]string{strings.Repeat("a", models.MaxTagLength+1)})
+	assert.ErrorIs(t, err, models.ErrInvalidTag)
+
+	many := []string{}
+	for i := 0; i <= models.MaxTags; i++ {
+		many = append(many, string(rune('a'+i)))
+	}
+	_, err = models.NormalizeTags(many)
+	assert.ErrorIs(t, err, models.ErrTooManyTags)
+}
+
+func TestSearchTags(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("SearchTags", "web-d", 10).Return([]models.Tag{{ID: 1, Name: "web-dev", ForumCount: 2}}, nil)
+	mockRepo.On("SearchTags", "", 50).Return([]models.Tag{}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/tags?q="+url.QueryEscape("Web D"), nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got []models.Tag
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "web-dev", got[0].Name)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/tags?limit=500", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/tags?limit=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestTagPage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetTag", "c++").Return(&models.Tag{ID: 2, Name: "c++", ForumCount: 1, TopicCount: 1}, nil)
+	mockRepo.On("GetTaggedForumsPage", "c++", mock.Anything).Return(&models.ForumPage{
+		Forums: []models.Forum{{ID: 1, Title: "Systems programming", CreatedAt: time.Now()}},
+	}, nil)
+	mockRepo.On("GetTaggedTopics", "c++").Return([]models.Topic{
+		{ID: 4, ForumID: 1, Title: "Move semantics", Author: "bjarne", CreatedAt: time.Now()},
+	}, nil)
+	mockRepo.On("GetTag", "rust").Return(nil, repository.ErrNotFound)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+	router.HandleFunc("/api/forums", ListForums(mockRepo))
+
+	for _, target := range []string{"/api/tags/" + url.PathEscape("C++"), "/api/forums?tag=" + url.QueryEscape("c++")} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code, target)
+		assert.Contains(t, rr.Body.String(), "Systems programming")
+		assert.Contains(t, rr.Body.String(), "Move semantics")
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/tags/rust", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/tags/c++/list", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page models.TagPage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Equal(t, 1, page.Tag.TopicCount)
+	assert.Len(t, page.Forums, 1)
+}
+
+func TestGetAllForumsByTag(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetTaggedForumsPage", "web-dev", mock.Anything).Return(&models.ForumPage{
+		Forums: []models.Forum{{ID: 3, Title: "Frontend"}},
+	}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums-list", GetAllForums(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums-list?tag=Web+Dev", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "GetPinnedForums")
+}
+
+func TestSetForumTags(t *testing.T) {
+	t.Run("Moderator", func(t *testing.T) {
+		mockRepo := new(mocks.MockForumsRepo)
+		mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+		mockRepo.On("SetForumTags", 3, []string{"golang", "web-dev"}).Return(nil)
+
+		router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/3/tags", `{"tags":["Golang","web dev","golang"]}`))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Tag", func(t *testing.T) {
+		mockRepo := new(mocks.MockForumsRepo)
+		mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+
+		router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/3/tags", `{"tags":["!!"]}`))
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		mockRepo.AssertNotCalled(t, "SetForumTags", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Not Moderator", func(t *testing.T) {
+		mockRepo := new(mocks.MockForumsRepo)
+		mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "user", Role: "user"}, nil)
+
+		router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/3/tags", `{"tags":["golang"]}`))
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestSetTopicTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantCode int
+	}{
+		{name: "Author", username: "User1", wantCode: http.StatusOK},
+		{name: "Other User", username: "User2", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockForumsRepo)
+			mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: tt.username, Role: "user"}, nil)
+			mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1, Author: "User1"}, nil)
+			mockRepo.On("SetTopicTags", 3, []string{"modules"}).Return(nil)
+
+			router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/forums/1/topics/3/tags", `{"tags":["Modules"]}`))
+
+			assert.Equal(t, tt.wantCode, rr.Code)
+		})
+	}
+}
+
+func TestCreateTopicWithTags(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateTopic", mock.Anything).Return(7, nil)
+	mockRepo.On("SetTopicTags", 7, []string{"golang", "generics"}).Return(nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics", CreateTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/topics", `{"title":"Generics","tags":["Golang","generics"]}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.Topic
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, []string{"golang", "generics"}, got.Tags)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRenameTag(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	useModerationLog(t, mockLog)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("GetTag", "golang").Return(&models.Tag{ID: 2, Name: "golang"}, nil)
+	mockRepo.On("RenameTag", "golang", "go").Return(nil).Once()
+	mockRepo.On("RenameTag", "golang", "go").Return(repository.ErrTagExists)
+	mockLog.On("LogAction", mock.MatchedBy(func(e models.ModerationEntry) bool {
+		return e.Action == models.ModerationTagRename && e.TargetType == models.ModerationTargetTag && e.TargetID == 2 &&
+			strings.Contains(string(e.Before), `"golang"`) && strings.Contains(string(e.After), `"go"`)
+	})).Return(nil).Once()
+
+	router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/tags/golang", `{"name":"Go"}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/tags/golang", `{"name":"Go"}`))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	mockLog.AssertExpectations(t)
+}
+
+func TestMergeTags(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockLog := new(mocks.MockModerationLogRepo)
+	useModerationLog(t, mockLog)
+
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("GetTag", "golang").Return(&models.Tag{ID: 2, Name: "golang", ForumCount: 1}, nil)
+	mockRepo.On("GetTag", "go").Return(&models.Tag{ID: 1, Name: "go", ForumCount: 3}, nil)
+	mockRepo.On("MergeTags", "golang", "go").Return(nil)
+	mockLog.On("LogAction", mock.MatchedBy(func(e models.ModerationEntry) bool {
+		return e.Action == models.ModerationTagMerge && e.TargetID == 2
+	})).Return(nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/tags/golang/merge", `{"into":"go"}`))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertCalled(t, "MergeTags", "golang", "go")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/tags/go/merge", `{"into":"Go"}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockRepo.AssertNumberOfCalls(t, "MergeTags", 1)
+	mockLog.AssertExpectations(t)
+}
+
+func TestTagAdminOnly(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+
+	router := testRouter(t, func(r *mux.Router) { RegisterTagHandlers(r, mockRepo) })
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/api/tags/golang", `{"name":"go"}`))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/api/tags/golang/merge", `{"into":"go"}`))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	mockRepo.AssertNotCalled(t, "RenameTag", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "MergeTags", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+var (
+	topicClients = make(map[int]map[*websocket.Conn]*sync.Mutex)
+	// topicForums maps every topic with open channels to its forum, so
+	// forum-wide events can reach the topic pages of a forum.
+	topicForums = make(map[int]int)
+
+	errInvalidTopicID = errors.New("invalid topic ID")
+)
+
+type topicRequest struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// requestTopic resolves the optional {topic_id} route variable and makes sure
+// the topic belongs to forumID. It returns nil, nil for forum-level routes.
+func requestTopic(r *http.Request, repo repository.ForumsRepository, forumID int) (*models.Topic, error) {
+	idStr, ok := mux.Vars(r)["topic_id"]
+	if !ok {
+		return nil, nil
+	}
+	topicID, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, errInvalidTopicID
+	}
+
+	topic, err := repo.GetTopicByID(topicID)
+	if err != nil {
+		return nil, err
+	}
+	if topic.ForumID != forumID {
+		return nil, repository.ErrNotFound
+	}
+	return topic, nil
+}
+
+// serveTopicWebSocket opens the channel of a topic. Topics are only served
+// under the forum they belong to.
+func serveTopicWebSocket(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		forumID, err := strconv.Atoi(mux.Vars(r)["forum_id"])
+		if err != nil {
+			http.Error(w, "Invalid forum ID", http.StatusBadRequest)
+			return
+		}
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil {
+			if errors.Is(err, errInvalidTopicID) {
+				http.Error(w, "Invalid topic ID", http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, repository.ErrNotFound) {
+				http.Error(w, "Topic not found", http.StatusNotFound)
+				return
+			}
+			log.Error("Failed to load topic", logger.Error(err))
+			http.Error(w, "Failed to load topic", http.StatusInternalServerError)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Error("WebSocket upgrade error", logger.Error(err))
+			return
+		}
+		defer func() {
+			unregisterTopicClient(topic.ID, conn)
+			forgetViewer(conn)
+			conn.Close()
+		}()
+
+		registerTopicClient(topic.ForumID, topic.ID, conn)
+		watchViewer(conn, r)
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway) {
+					log.Error("WebSocket error", logger.Error(err))
+				}
+				break
+			}
+		}
+	}
+}
+
+func registerTopicClient(forumID, topicID int, conn *websocket.Conn) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if topicClients[topicID] == nil {
+		topicClients[topicID] = make(map[*websocket.Conn]*sync.Mutex)
+	}
+	topicClients[topicID][conn] = &sync.Mutex{}
+	topicForums[topicID] = forumID
+}
+
+func unregisterTopicClient(topicID int, conn *websocket.Conn) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	if topicClients[topicID] != nil {
+		delete(topicClients[topicID], conn)
+		if len(topicClients[topicID]) == 0 {
+			delete(topicClients, topicID)
+			delete(topicForums, topicID)
+		}
+	}
+}
+
+func broadcastToTopic(topicID int, message WSMessage) {
+	clientsMu.RLock()
+	defer clientsMu.RUnlock()
+
+	for conn, mu := range topicClients[topicID] {
+		if err := writeJSON(conn, mu, forViewer(conn, message)); err != nil {
+			log.Error("WS send error",
+				logger.Error(err),
+				logger.Int("topicID", topicID))
+			go func(conn *websocket.Conn) {
+				unregisterTopicClient(topicID, conn)
+				conn.Close()
+			}(conn)
+		}
+	}
+}
+
+// broadcastToForumTopics sends message on every open topic channel of
+// forumID.
+func broadcastToForumTopics(forumID int, message WSMessage) {
+	clientsMu.RLock()
+	topicIDs := []int{}
+	for topicID, f := range topicForums {
+		if f == forumID {
+			topicIDs = append(topicIDs, topicID)
+		}
+	}
+	clientsMu.RUnlock()
+
+	for _, topicID := range topicIDs {
+		broadcastToTopic(topicID, message)
+	}
+}
+
+// ListTopics godoc
+// @Summary Forum topics page
+// @Description Render the topics of a forum with reply counts and last activity
+// @Tags topics
+// @Produce html
+// @Param id path int true "Forum ID"
+// @Success 200 {string} string "HTML page"
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/topics [get]
+func ListTopics(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid forum ID", http.StatusBadRequest)
+			return
+		}
+
+		forum, err := repo.GetByID(forumID)
+		if err != nil {
+			http.Error(w, "Forum not found", http.StatusNotFound)
+			return
+		}
+
+		topics, err := repo.GetTopics(forumID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		renderTemplate(w, "topic_list.html", map[string]interface{}{
+			"Forum":  forum,
+			"Topics": topics,
+		})
+	}
+}
+
+// GetTopicsAPI godoc
+// @Summary Get forum topics
+// @Description Get the topics of a forum as JSON
+// @Tags topics
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Success 200 {array} models.Topic
+// @Failure 400 {object} map[string]string
+// @Router /forums/{id}/topics-list [get]
+func GetTopicsAPI(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		topics, err := repo.GetTopics(forumID)
+		if err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to load topics")
+			return
+		}
+
+		json.NewEncoder(w).Encode(topics)
+	}
+}
+
+// CreateTopic godoc
+// @Summary Create topic
+// @Description Create a topic inside a forum
+// @Tags topics
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param topic body topicRequest true "Topic info"
+// @Security BearerAuth
+// @Success 201 {object} models.Topic
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /forums/{id}/topics [post]
+func CreateTopic(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		var req topicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if strings.TrimSpace(req.Title) == "" {
+			sendError(w, http.StatusBadRequest, "Title is required")
+			return
+		}
+
+		tags, err := models.NormalizeTags(req.Tags)
+		if err != nil {
+			sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		forum := writableForum(w, repo, forumID, user)
+		if forum == nil || !checkSlowMode(w, forum, user) {
+			return
+		}
+
+		topic := models.Topic{
+			ForumID:   forumID,
+			Title:     req.Title,
+			Desc:      req.Description,
+			Author:    user.Username,
+			CreatedAt: time.Now(),
+		}
+
+		id, err := repo.CreateTopic(topic)
+		if err != nil {
+			log.Error("DB error", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to create topic")
+			return
+		}
+		topic.ID = id
+
+		if len(tags) > 0 {
+			if err := repo.SetTopicTags(id, tags); err != nil {
+				log.Error("Failed to tag new topic", logger.Error(err), logger.Int("topicID", id))
+			} else {
+				topic.Tags = tags
+			}
+		}
+
+		go broadcastToForum(forumID, WSMessage{
+			Type:    "topic_created",
+			Payload: topic,
+		})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(topic)
+	}
+}
+
+// UpdateTopic godoc
+// @Summary Update topic
+// @Description Update the title and description of a topic
+// @Tags topics
+// @Accept json
+// @Produce json
+// @Param id path int true "Forum ID"
+// @Param topic_id path int true "Topic ID"
+// @Param topic body topicRequest true "Topic info"
+// @Security BearerAuth
+// @Success 200 {object} models.Topic
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/topics/{topic_id} [put]
+func UpdateTopic(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		var req topicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+		if strings.TrimSpace(req.Title) == "" {
+			sendError(w, http.StatusBadRequest, "Title is required")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if user == nil {
+			sendError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil || topic == nil {
+			sendError(w, http.StatusNotFound, "Topic not found")
+			return
+		}
+
+		if user.Username != topic.Author && user.Role != "admin" {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		topic.Title = req.Title
+		topic.Desc = req.Description
+		if err := repo.UpdateTopic(topic.ID, *topic); err != nil {
+			sendError(w, http.StatusInternalServerError, "Failed to update topic")
+			return
+		}
+
+		go broadcastToForum(forumID, WSMessage{
+			Type:    "topic_updated",
+			Payload: topic,
+		})
+
+		json.NewEncoder(w).Encode(topic)
+	}
+}
+
+// DeleteTopic godoc
+// @Summary Delete topic
+// @Description Move a topic and its messages to the trash. They can be restored until the purge job removes them. Moderators only
+// @Tags topics
+// @Param id path int true "Forum ID"
+// @Param topic_id path int true "Topic ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Security BearerAuth
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /forums/{id}/topics/{topic_id} [delete]
+func DeleteTopic(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		forumID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid forum ID", http.StatusBadRequest)
+			return
+		}
+
+		user := requestUser(r, repo)
+		if user == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		topic, err := requestTopic(r, repo, forumID)
+		if err != nil || topic == nil {
+			http.Error(w, "Topic not found", http.StatusNotFound)
+			return
+		}
+
+		// Deleting a topic takes everyone's replies with it, so it is
+		// not left to the topic's author.
+		if !isModerator(user) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if err := repo.DeleteTopic(topic.ID, user.Username); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationTopicDelete,
+			TargetType: models.ModerationTargetTopic,
+			TargetID:   topic.ID,
+			ForumID:    &forumID,
+			Reason:     moderationReason(r),
+		}, topic, nil)
+
+		go broadcastToForum(forumID, WSMessage{
+			Type:    "topic_deleted",
+			Payload: map[string]int{"topicId": topic.ID},
+		})
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/pkg/jwt"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func authorizedRequest(t *testing.T, method, url, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	token, err := jwt.GenerateToken(1, testSecretKey, 24*time.Hour)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+func TestListTopics(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1, Title: "Forum"}, nil)
+	mockRepo.On("GetTopics", 1).Return([]models.Topic{
+		{ID: 1, ForumID: 1, Title: "Topic 1", ReplyCount: 2},
+	}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics", ListTopics(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/topics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Topic 1")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListTopicsForumNotFound(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(nil, repository.ErrNotFound)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics", ListTopics(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/topics", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertNotCalled(t, "GetTopics", mock.Anything)
+}
+
+func TestGetTopicsAPI(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	topics := []models.Topic{{ID: 1, ForumID: 1, Title: "Topic 1"}}
+	mockRepo.On("GetTopics", 1).Return(topics, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics-list", GetTopicsAPI(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/topics-list", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var got []models.Topic
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, topics, got)
+}
+
+func TestCreateTopic(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("CreateTopic", mock.MatchedBy(func(topic models.Topic) bool {
+		return topic.ForumID == 1 && topic.Title == "New topic" && topic.Author == "User1"
+	})).Return(7, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics", CreateTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/topics", `{"title":"New topic","description":"About"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	var got models.Topic
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, 7, got.ID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTopicValidation(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics", CreateTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/topics", `{"title":"  "}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/forums/1/topics", strings.NewReader(`{"title":"Topic"}`))
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	mockRepo.AssertNotCalled(t, "CreateTopic", mock.Anything)
+}
+
+func TestUpdateTopicForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User2", Role: "user"}, nil)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1, Author: "User1"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}", UpdateTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1/topics/3", `{"title":"Renamed"}`))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "UpdateTopic", mock.Anything, mock.Anything)
+}
+
+func TestUpdateTopic(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1, Author: "User1"}, nil)
+	mockRepo.On("UpdateTopic", 3, mock.MatchedBy(func(topic models.Topic) bool {
+		return topic.Title == "Renamed"
+	})).Return(nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}", UpdateTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "PUT", "/forums/1/topics/3", `{"title":"Renamed"}`))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteTopicWrongForum(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "admin"}, nil)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 2, Author: "User1"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}", DeleteTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/forums/1/topics/3", ""))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertNotCalled(t, "DeleteTopic", mock.Anything, mock.Anything)
+}
+
+func TestTopicWebSocketWrongForum(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 2}, nil)
+	mockRepo.On("GetTopicByID", 4).Return(nil, repository.ErrNotFound)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/ws/{forum_id}/topics/{topic_id}", serveTopicWebSocket(mockRepo))
+
+	for _, url := range []string{"/ws/1/topics/3", "/ws/2/topics/4"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code, url)
+	}
+}
+
+func TestDeleteTopic(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "Admin", Role: "admin"}, nil)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1, Author: "User1"}, nil)
+	mockRepo.On("DeleteTopic", 3, "Admin").Return(nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}", DeleteTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/forums/1/topics/3", ""))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteTopicByAuthor(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1, Author: "User1"}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}", DeleteTopic(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "DELETE", "/forums/1/topics/3", ""))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code, "other users' replies are not the author's to delete")
+	mockRepo.AssertNotCalled(t, "DeleteTopic", mock.Anything, mock.Anything)
+}
+
+func TestPostMessageToTopic(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1}, nil)
+	mockRepo.On("CreateMessage", mock.MatchedBy(func(msg models.Message) bool {
+		return msg.TopicID != nil && *msg.TopicID == 3
+	})).Return(10, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}/messages", PostMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/topics/3/messages", `{"author":"User1","content":"Hi"}`))
+
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPostMessageToTopicOfOtherForum(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{Username: "User1", Role: "user"}, nil)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 2}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}/messages", PostMessage(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/forums/1/topics/3/messages", `{"author":"User1","content":"Hi"}`))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything)
+}
+
+func TestGetMessagesAPIForTopic(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetByID", 1).Return(&models.Forum{ID: 1}, nil)
+	topicID := 3
+	messages := []models.Message{{ID: 1, ForumID: 1, TopicID: &topicID, Author: "User1", Content: "Hi"}}
+	mockRepo.On("GetTopicByID", 3).Return(&models.Topic{ID: 3, ForumID: 1}, nil)
+	mockRepo.On("GetMessagesPage", 1, &topicID, mock.Anything).Return(&models.MessagePage{Messages: messages}, nil)
+	mockRepo.On("GetPinnedMessages", 1, &topicID).Return([]models.Message{}, nil)
+	mockRepo.On("GetReactions", mock.Anything, "").Return(map[int][]models.ReactionCount{}, nil)
+	mockRepo.On("GetMentions", mock.Anything).Return(map[int][]string{}, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/forums/{id}/topics/{topic_id}/messages-list", GetMessagesAPI(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/forums/1/topics/3/messages-list", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+)
+
+// StartTrashPurge permanently removes forums, topics and messages that have
+// been in the trash for longer than retention, checking every interval.
+func StartTrashPurge(repo repository.ForumsRepository, retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := repo.PurgeDeleted(time.Now().Add(-retention))
+			if err != nil {
+				log.Error("Trash purge failed", logger.Error(err))
+			} else if purged > 0 {
+				log.Info("Trash purged", logger.Int("rows", int(purged)))
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// GetDeletedForums godoc
+// @Summary List deleted forums
+// @Description List forums in the trash. Moderators only
+// @Tags trash
+// @Produce json
+// @Success 200 {array} models.Forum
+// @Failure 403 {object} map[string]string
+// @Router /trash/forums [get]
+func GetDeletedForums(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isModerator(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		forums, err := repo.GetDeletedForums()
+		if err != nil {
+			log.Error("Failed to load deleted forums", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load trash")
+			return
+		}
+		json.NewEncoder(w).Encode(forums)
+	}
+}
+
+// GetDeletedTopics godoc
+// @Summary List deleted topics
+// @Description List topics in the trash. Moderators only
+// @Tags trash
+// @Produce json
+// @Success 200 {array} models.Topic
+// @Failure 403 {object} map[string]string
+// @Router /trash/topics [get]
+func GetDeletedTopics(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isModerator(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		topics, err := repo.GetDeletedTopics()
+		if err != nil {
+			log.Error("Failed to load deleted topics", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load trash")
+			return
+		}
+		json.NewEncoder(w).Encode(topics)
+	}
+}
+
+// GetDeletedMessages godoc
+// @Summary List deleted messages
+// @Description List messages in the trash. Moderators only
+// @Tags trash
+// @Produce json
+// @Success 200 {array} models.Message
+// @Failure 403 {object} map[string]string
+// @Router /trash/messages [get]
+func GetDeletedMessages(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if !isModerator(requestUser(r, repo)) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		messages, err := repo.GetDeletedMessages()
+		if err != nil {
+			log.Error("Failed to load deleted messages", logger.Error(err))
+			sendError(w, http.StatusInternalServerError, "Failed to load trash")
+			return
+		}
+		json.NewEncoder(w).Encode(messages)
+	}
+}
+
+// RestoreForum godoc
+// @Summary Restore forum
+// @Description Move a forum out of the trash. Moderators only
+// @Tags trash
+// @Param id path int true "Forum ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Success 204 "No Content"
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /trash/forums/{id}/restore [post]
+func RestoreForum(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid forum ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		if err := repo.Restore(id); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Forum not found in trash")
+				return
+			}
+			sendError(w, http.StatusInternalServerError, "Failed to restore forum")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationForumRestore,
+			TargetType: models.ModerationTargetForum,
+			TargetID:   id,
+			ForumID:    &id,
+			Reason:     moderationReason(r),
+		}, nil, nil)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RestoreTopic godoc
+// @Summary Restore topic
+// @Description Move a topic out of the trash together with the messages deleted with it. Moderators only
+// @Tags trash
+// @Produce json
+// @Param topic_id path int true "Topic ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Success 200 {object} models.Topic
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /trash/topics/{topic_id}/restore [post]
+func RestoreTopic(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		topicID, err := strconv.Atoi(mux.Vars(r)["topic_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid topic ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		topic, err := repo.RestoreTopic(topicID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Topic not found in trash")
+				return
+			}
+			log.Error("Failed to restore topic", logger.Error(err), logger.Int("topicID", topicID))
+			sendError(w, http.StatusInternalServerError, "Failed to restore topic")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationTopicRestore,
+			TargetType: models.ModerationTargetTopic,
+			TargetID:   topicID,
+			ForumID:    &topic.ForumID,
+			Reason:     moderationReason(r),
+		}, nil, topic)
+
+		go broadcastToForum(topic.ForumID, WSMessage{
+			Type:    "topic_created",
+			Payload: topic,
+		})
+
+		json.NewEncoder(w).Encode(topic)
+	}
+}
+
+// RestoreMessage godoc
+// @Summary Restore message
+// @Description Move a message out of the trash. A message of a deleted topic restores the topic too, with the messages deleted with it. Moderators only
+// @Tags trash
+// @Produce json
+// @Param message_id path int true "Message ID"
+// @Param reason query string false "Reason recorded in the moderation log"
+// @Success 200 {object} models.Message
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /trash/messages/{message_id}/restore [post]
+func RestoreMessage(repo repository.ForumsRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		messageID, err := strconv.Atoi(mux.Vars(r)["message_id"])
+		if err != nil {
+			sendError(w, http.StatusBadRequest, "Invalid message ID")
+			return
+		}
+
+		user := requestUser(r, repo)
+		if !isModerator(user) {
+			sendError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+
+		msg, err := repo.RestoreMessage(messageID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				sendError(w, http.StatusNotFound, "Message not found in trash")
+				return
+			}
+			sendError(w, http.StatusInternalServerError, "Failed to restore message")
+			return
+		}
+
+		recordModeration(models.ModerationEntry{
+			Actor:      user.Username,
+			Action:     models.ModerationMessageRestore,
+			TargetType: models.ModerationTargetMessage,
+			TargetID:   messageID,
+			ForumID:    &msg.ForumID,
+			Reason:     moderationReason(r),
+		}, nil, msg)
+
+		go broadcastMessage(msg, WSMessage{
+			Type:    "message_created",
+			Payload: messageEvent{Message: *msg},
+		})
+
+		json.NewEncoder(w).Encode(msg)
+	}
+}
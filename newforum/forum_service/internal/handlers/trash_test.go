@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jaxxiy/newforum/forum_service/internal/mocks"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/jaxxiy/newforum/forum_service/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+// trashRoutes registers the trash routes, which RegisterForumHandlers adds
+// in production.
+func trashRoutes(repo *mocks.MockForumsRepo) func(*mux.Router) {
+	return func(r *mux.Router) {
+		r.HandleFunc("/trash/forums", GetDeletedForums(repo)).Methods("GET")
+		r.HandleFunc("/trash/forums/{id}/restore", RestoreForum(repo)).Methods("POST")
+		r.HandleFunc("/trash/topics", GetDeletedTopics(repo)).Methods("GET")
+		r.HandleFunc("/trash/topics/{topic_id}/restore", RestoreTopic(repo)).Methods("POST")
+		r.HandleFunc("/trash/messages", GetDeletedMessages(repo)).Methods("GET")
+		r.HandleFunc("/trash/messages/{message_id}/restore", RestoreMessage(repo)).Methods("POST")
+	}
+}
+
+func TestGetDeletedForums(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	deletedAt := time.Now()
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("GetDeletedForums").Return([]models.Forum{
+		{ID: 1, Title: "Forum", DeletedAt: &deletedAt, DeletedBy: "mod"},
+	}, nil)
+
+	router := testRouter(t, trashRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/trash/forums", ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var forums []models.Forum
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &forums))
+	assert.Len(t, forums, 1)
+	assert.Equal(t, "mod", forums[0].DeletedBy)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetDeletedMessagesForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "user", Role: "user"}, nil)
+
+	router := testRouter(t, trashRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/trash/messages", ""))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "GetDeletedMessages")
+}
+
+func TestRestoreForum(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("Restore", 1).Return(nil)
+
+	router := testRouter(t, trashRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/trash/forums/1/restore", ""))
+
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreForumNotInTrash(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "admin", Role: "admin"}, nil)
+	mockRepo.On("Restore", 2).Return(repository.ErrNotFound)
+
+	router := testRouter(t, trashRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/trash/forums/2/restore", ""))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreTopic(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("GetDeletedTopics").Return([]models.Topic{{ID: 4, ForumID: 1, Title: "Gone", DeletedBy: "mod"}}, nil)
+	mockRepo.On("RestoreTopic", 4).Return(&models.Topic{ID: 4, ForumID: 1, Title: "Gone"}, nil)
+	mockRepo.On("RestoreTopic", 5).Return(nil, repository.ErrNotFound)
+	router := testRouter(t, trashRoutes(mockRepo))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "GET", "/trash/topics", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var topics []models.Topic
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &topics))
+	assert.Len(t, topics, 1)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/trash/topics/4/restore", ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var topic models.Topic
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &topic))
+	assert.Equal(t, "Gone", topic.Title)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/trash/topics/5/restore", ""))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/trash/topics/4/restore", nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNumberOfCalls(t, "RestoreTopic", 2)
+}
+
+func TestRestoreMessage(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+	mockRepo.On("GetUserByID", 1).Return(&models.User{ID: 1, Username: "mod", Role: "moderator"}, nil)
+	mockRepo.On("RestoreMessage", 5).Return(&models.Message{ID: 5, ForumID: 1, Author: "user", Content: "back"}, nil)
+
+	router := testRouter(t, trashRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, authorizedRequest(t, "POST", "/trash/messages/5/restore", ""))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var msg models.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &msg))
+	assert.Equal(t, "back", msg.Content)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreMessageForbidden(t *testing.T) {
+	mockRepo := new(mocks.MockForumsRepo)
+
+	router := testRouter(t, trashRoutes(mockRepo))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/trash/messages/5/restore", nil))
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	mockRepo.AssertNotCalled(t, "RestoreMessage")
+}
@@ -0,0 +1,108 @@
+// Package mail sends the emails of the forum service. Senders are
+// pluggable: SMTPSender talks to any SMTP server, such as a local MailHog
+// during development, and LogSender only logs what would have been sent.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"sort"
+	"time"
+
+	"github.com/jaxxiy/newforum/core/logger"
+)
+
+var log = logger.GetLogger()
+
+// Message is an HTML email to a single recipient. Headers are added to the
+// standard ones, for example List-Unsubscribe.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Headers map[string]string
+}
+
+type Sender interface {
+	Send(msg Message) error
+}
+
+// SMTPSender delivers mail through an SMTP server. Auth may be nil for
+// servers that accept mail without logging in.
+type SMTPSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// NewSMTPSender returns a sender for the server at addr ("host:port"). PLAIN
+// authentication is used when username is set.
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	s := &SMTPSender{Addr: addr, From: from}
+	if username != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		s.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	data, err := build(s.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.Addr, s.Auth, s.From, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// LogSender logs mail instead of sending it. It is used when no SMTP server
+// is configured.
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Info("Mail not sent, no SMTP server configured",
+		logger.String("to", msg.To),
+		logger.String("subject", msg.Subject))
+	return nil
+}
+
+// build formats msg as an RFC 5322 message with a quoted-printable HTML
+// body.
+func build(from string, msg Message, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+
+	keys := make([]string, 0, len(msg.Headers))
+	for k := range msg.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, msg.Headers[k])
+	}
+
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(msg.HTML)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuild(t *testing.T) {
+	date := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	data, err := build("forum@example.com", Message{
+		To:      "bob@example.com",
+		Subject: "Новые сообщения",
+		HTML:    "<p>" + strings.Repeat("привет ", 30) + "</p>",
+		Headers: map[string]string{
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+			"List-Unsubscribe":      "<https://forum.example.com/unsubscribe?sig=abc>",
+		},
+	}, date)
+	assert.NoError(t, err)
+
+	head, body, found := strings.Cut(string(data), "\r\n\r\n")
+	assert.True(t, found)
+	lines := strings.Split(head, "\r\n")
+	subject, err := new(mime.WordDecoder).DecodeHeader(strings.TrimPrefix(lines[2], "Subject: "))
+	assert.NoError(t, err)
+	assert.Equal(t, "Новые сообщения", subject)
+	assert.Equal(t, []string{
+		"From: forum@example.com",
+		"To: bob@example.com",
+		lines[2],
+		"Date: Fri, 01 Mar 2024 09:30:00 +0000",
+		"List-Unsubscribe: <https://forum.example.com/unsubscribe?sig=abc>",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		"MIME-Version: 1.0",
+		"Content-Type: text/html; charset=UTF-8",
+		"Content-Transfer-Encoding: quoted-printable",
+	}, lines)
+
+	for _, line := range strings.Split(body, "\r\n") {
+		assert.LessOrEqual(t, len(line), 76)
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>"+strings.Repeat("привет ", 30)+"</p>", string(decoded))
+}
+
+func TestNewSMTPSender(t *testing.T) {
+	assert.Nil(t, NewSMTPSender("localhost:1025", "forum@example.com", "", "").Auth)
+	assert.NotNil(t, NewSMTPSender("smtp.example.com:587", "forum@example.com", "forum", "secret").Auth)
+}
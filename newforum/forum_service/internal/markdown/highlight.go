@@ -0,0 +1,255 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+)
+
+// language describes enough of a programming language's syntax to colour
+// its keywords, strings, comments and numbers.
+type language struct {
+	keywords     []string
+	lineComments []string
+	blockComment [2]string
+	// quotes start strings; rawQuotes start strings that may span lines
+	// and have no escapes.
+	quotes    string
+	rawQuotes string
+	// ignoreCase is for languages such as SQL whose keywords are written
+	// in any case.
+	ignoreCase bool
+
+	keywordSet map[string]bool
+}
+
+var languages = map[string]*language{
+	"go": {
+		keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough",
+			"for", "func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select",
+			"struct", "switch", "type", "var", "nil", "true", "false", "iota"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		rawQuotes:    "`",
+	},
+	"javascript": {
+		keywords: []string{"async", "await", "break", "case", "catch", "class", "const", "continue", "default",
+			"delete", "do", "else", "export", "extends", "finally", "for", "from", "function", "if", "import", "in",
+			"instanceof", "interface", "let", "new", "of", "return", "static", "super", "switch", "this", "throw",
+			"try", "type", "typeof", "var", "void", "while", "yield", "null", "undefined", "true", "false"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+		rawQuotes:    "`",
+	},
+	"python": {
+		keywords: []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del",
+			"elif", "else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda",
+			"nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield", "None", "True",
+			"False", "self"},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"sql": {
+		keywords: []string{"select", "from", "where", "and", "or", "not", "insert", "into", "values", "update",
+			"set", "delete", "create", "table", "index", "alter", "drop", "add", "column", "join", "left", "right",
+			"inner", "outer", "on", "as", "group", "by", "order", "having", "limit", "offset", "distinct", "null",
+			"is", "in", "exists", "primary", "key", "references", "default", "unique", "returning", "begin",
+			"commit", "rollback", "case", "when", "then", "else", "end", "union", "all", "with", "conflict", "do",
+			"nothing", "true", "false", "asc", "desc", "like", "between"},
+		lineComments: []string{"--"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `'"`,
+		ignoreCase:   true,
+	},
+	"bash": {
+		keywords: []string{"if", "then", "else", "elif", "fi", "for", "while", "until", "do", "done", "case",
+			"esac", "in", "function", "return", "local", "export", "echo", "exit", "cd", "source"},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"json": {
+		keywords: []string{"true", "false", "null"},
+		quotes:   `"`,
+	},
+	"yaml": {
+		keywords:     []string{"true", "false", "null", "yes", "no"},
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	},
+	"java": {
+		keywords: []string{"abstract", "boolean", "break", "byte", "case", "catch", "char", "class", "continue",
+			"default", "do", "double", "else", "enum", "extends", "final", "finally", "float", "for", "if",
+			"implements", "import", "instanceof", "int", "interface", "long", "new", "package", "private",
+			"protected", "public", "return", "short", "static", "super", "switch", "this", "throw", "throws",
+			"try", "var", "void", "while", "null", "true", "false"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	},
+	"c": {
+		keywords: []string{"auto", "bool", "break", "case", "char", "class", "const", "continue", "default",
+			"delete", "do", "double", "else", "enum", "extern", "float", "for", "if", "include", "define", "inline",
+			"int", "long", "namespace", "new", "nullptr", "private", "public", "return", "short", "signed",
+			"sizeof", "static", "struct", "switch", "template", "this", "typedef", "union", "unsigned", "using",
+			"virtual", "void", "while", "NULL", "true", "false"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	},
+	"rust": {
+		keywords: []string{"as", "async", "await", "break", "const", "continue", "crate", "else", "enum", "fn",
+			"for", "if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref", "return", "self",
+			"Self", "static", "struct", "trait", "type", "unsafe", "use", "where", "while", "true", "false"},
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"`,
+	},
+}
+
+// aliases are the other names code blocks are commonly tagged with.
+var aliases = map[string]string{
+	"golang":     "go",
+	"js":         "javascript",
+	"jsx":        "javascript",
+	"ts":         "javascript",
+	"tsx":        "javascript",
+	"typescript": "javascript",
+	"py":         "python",
+	"postgresql": "sql",
+	"psql":       "sql",
+	"sh":         "bash",
+	"shell":      "bash",
+	"zsh":        "bash",
+	"yml":        "yaml",
+	"cpp":        "c",
+	"c++":        "c",
+	"h":          "c",
+	"rs":         "rust",
+}
+
+func init() {
+	for _, l := range languages {
+		l.keywordSet = make(map[string]bool, len(l.keywords))
+		for _, k := range l.keywords {
+			l.keywordSet[k] = true
+		}
+	}
+}
+
+func lookupLanguage(name string) *language {
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	return languages[name]
+}
+
+// highlight returns code as HTML, with spans around the tokens of lang.
+// Code in languages it does not know is only escaped.
+func highlight(lang, code string) string {
+	l := lookupLanguage(lang)
+	if l == nil {
+		return html.EscapeString(code)
+	}
+
+	var b strings.Builder
+	plain := 0
+	flush := func(i int) {
+		b.WriteString(html.EscapeString(code[plain:i]))
+	}
+	span := func(class string, start, end int) {
+		flush(start)
+		b.WriteString(`<span class="hl-` + class + `">` + html.EscapeString(code[start:end]) + `</span>`)
+		plain = end
+	}
+
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case l.blockComment[0] != "" && strings.HasPrefix(code[i:], l.blockComment[0]):
+			end := strings.Index(code[i+len(l.blockComment[0]):], l.blockComment[1])
+			if end < 0 {
+				end = len(code)
+			} else {
+				end += i + len(l.blockComment[0]) + len(l.blockComment[1])
+			}
+			span("comment", i, end)
+			i = end
+		case l.isLineComment(code[i:]):
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				end = len(code)
+			} else {
+				end += i
+			}
+			span("comment", i, end)
+			i = end
+		case strings.IndexByte(l.quotes, c) >= 0:
+			end := stringEnd(code, i, false)
+			span("string", i, end)
+			i = end
+		case strings.IndexByte(l.rawQuotes, c) >= 0:
+			end := stringEnd(code, i, true)
+			span("string", i, end)
+			i = end
+		case isDigit(c) && (i == 0 || !isWordByte(code[i-1])):
+			end := i + 1
+			for end < len(code) && (isWordByte(code[end]) || code[end] == '.') {
+				end++
+			}
+			span("number", i, end)
+			i = end
+		case isWordByte(c) || c == '$':
+			end := i + 1
+			for end < len(code) && isWordByte(code[end]) {
+				end++
+			}
+			word := code[i:end]
+			if l.ignoreCase {
+				word = strings.ToLower(word)
+			}
+			if l.keywordSet[word] && (i == 0 || !isWordByte(code[i-1])) {
+				span("keyword", i, end)
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	flush(len(code))
+	return b.String()
+}
+
+func (l *language) isLineComment(s string) bool {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd returns where the string starting with the quote at code[i]
+// ends. Ordinary strings also end at the end of the line.
+func stringEnd(code string, i int, raw bool) int {
+	quote := code[i]
+	for j := i + 1; j < len(code); j++ {
+		switch code[j] {
+		case '\\':
+			if !raw {
+				j++
+			}
+		case '\n':
+			if !raw {
+				return j
+			}
+		case quote:
+			return j + 1
+		}
+	}
+	return len(code)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
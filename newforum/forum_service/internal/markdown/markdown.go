@@ -0,0 +1,417 @@
+// Package markdown renders the Markdown subset messages are written in:
+// paragraphs, fenced code blocks with syntax highlighting, links, lists,
+// block quotes and inline code. Anything else is shown as it was typed.
+//
+// The renderer escapes everything it does not produce itself, and its
+// output then goes through Sanitize, which keeps only the tags and
+// attributes on an allow-list, so what users write never reaches the page
+// as markup.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds how deep quotes and lists nest; deeper markers are kept
+// as text.
+const maxDepth = 8
+
+var (
+	fenceRe   = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([A-Za-z0-9_+-]*)")
+	quoteRe   = regexp.MustCompile(`^ {0,3}> ?`)
+	bulletRe  = regexp.MustCompile(`^ {0,3}([-*+])([ \t]+|$)`)
+	orderedRe = regexp.MustCompile(`^ {0,3}([0-9]{1,9})([.)])([ \t]+|$)`)
+)
+
+// Render turns message source into HTML safe to put on a page.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0, false)
+	return Sanitize(b.String())
+}
+
+// renderBlocks renders lines as a sequence of blocks. In tight list items
+// paragraphs are not wrapped in <p>.
+func renderBlocks(b *strings.Builder, lines []string, depth int, tight bool) {
+	wrote := false
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+			continue
+		case isFence(line):
+			i = renderFence(b, lines, i)
+		case depth < maxDepth && quoteRe.MatchString(line):
+			var inner []string
+			for ; i < len(lines) && quoteRe.MatchString(lines[i]); i++ {
+				inner = append(inner, lines[i][len(quoteRe.FindString(lines[i])):])
+			}
+			b.WriteString("<blockquote>")
+			renderBlocks(b, inner, depth+1, false)
+			b.WriteString("</blockquote>")
+		case depth < maxDepth && isListItem(line):
+			i = renderList(b, lines, i, depth)
+		default:
+			start := i
+			for i++; i < len(lines) && !isBlank(lines[i]) && !startsBlock(lines[i], depth); i++ {
+			}
+			if tight && wrote {
+				b.WriteString("<br>")
+			}
+			if !tight {
+				b.WriteString("<p>")
+			}
+			for j, l := range lines[start:i] {
+				if j > 0 {
+					b.WriteString("<br>\n")
+				}
+				renderInline(b, strings.TrimSpace(l), true)
+			}
+			if !tight {
+				b.WriteString("</p>")
+			}
+		}
+		wrote = true
+	}
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// startsBlock reports whether line ends the paragraph before it.
+func startsBlock(line string, depth int) bool {
+	return isFence(line) || depth < maxDepth && (quoteRe.MatchString(line) || isListItem(line))
+}
+
+func isFence(line string) bool {
+	m := fenceRe.FindStringSubmatch(line)
+	// A backtick in the info string makes the line inline code instead.
+	return m != nil && !(m[1][0] == '`' && strings.Contains(line[len(m[0]):], "`"))
+}
+
+func renderFence(b *strings.Builder, lines []string, i int) int {
+	m := fenceRe.FindStringSubmatch(lines[i])
+	fence, lang := m[1], strings.ToLower(m[2])
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		closing := strings.TrimSpace(lines[i])
+		if len(closing) >= len(fence) && strings.Trim(closing, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(` class="language-` + lang + `"`)
+	}
+	b.WriteString(">")
+	b.WriteString(highlight(lang, strings.Join(code, "\n")))
+	b.WriteString("</code></pre>")
+	return i
+}
+
+// listMarker is the bullet or number that starts a list item.
+type listMarker struct {
+	ordered bool
+	start   int
+	delim   string
+	// length is the length of the marker with the spaces around it.
+	length int
+	// width is how far continuation lines of the item are indented.
+	width int
+}
+
+func isListItem(line string) bool {
+	_, ok := parseMarker(line)
+	return ok
+}
+
+func parseMarker(line string) (listMarker, bool) {
+	if m := bulletRe.FindStringSubmatch(line); m != nil {
+		return listMarker{delim: m[1], length: len(m[0]), width: markerWidth(m[0])}, true
+	}
+	if m := orderedRe.FindStringSubmatch(line); m != nil {
+		start, _ := strconv.Atoi(m[1])
+		return listMarker{ordered: true, start: start, delim: m[2], length: len(m[0]), width: markerWidth(m[0])}, true
+	}
+	return listMarker{}, false
+}
+
+// markerWidth is the indentation of the content after a marker, which
+// continuation lines of the item must match.
+func markerWidth(marker string) int {
+	if !strings.HasSuffix(marker, " ") && !strings.HasSuffix(marker, "\t") {
+		return len(marker) + 1
+	}
+	return len(strings.TrimRight(marker, " \t")) + 1
+}
+
+func (m listMarker) sameList(other listMarker) bool {
+	return m.ordered == other.ordered && m.delim == other.delim
+}
+
+func renderList(b *strings.Builder, lines []string, i, depth int) int {
+	first, _ := parseMarker(lines[i])
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+		if first.start != 1 {
+			b.WriteString(`<ol start="` + strconv.Itoa(first.start) + `">`)
+		} else {
+			b.WriteString("<ol>")
+		}
+	} else {
+		b.WriteString("<ul>")
+	}
+
+	for i < len(lines) {
+		// Blank lines between items do not end the list.
+		if isBlank(lines[i]) {
+			j := i
+			for j < len(lines) && isBlank(lines[j]) {
+				j++
+			}
+			if m, ok := parseMarker(safeLine(lines, j)); !ok || !m.sameList(first) {
+				break
+			}
+			i = j
+		}
+		m, ok := parseMarker(lines[i])
+		if !ok || !m.sameList(first) {
+			break
+		}
+
+		item := []string{lines[i][m.length:]}
+		for i++; i < len(lines); i++ {
+			l := lines[i]
+			if isBlank(l) {
+				if indent(safeLine(lines, i+1)) >= m.width {
+					item = append(item, "")
+					continue
+				}
+				break
+			}
+			if indent(l) >= m.width {
+				item = append(item, dedent(l, m.width))
+				continue
+			}
+			if startsBlock(l, depth) {
+				break
+			}
+			// A lazy continuation of the item's paragraph.
+			item = append(item, l)
+		}
+
+		b.WriteString("<li>")
+		renderBlocks(b, item, depth+1, true)
+		b.WriteString("</li>")
+	}
+
+	b.WriteString("</" + tag + ">")
+	return i
+}
+
+func safeLine(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+// indent counts the leading spaces of line, a tab counting as four.
+func indent(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return 0
+}
+
+// dedent removes up to n columns of leading whitespace.
+func dedent(line string, n int) string {
+	col := 0
+	for i, c := range line {
+		if col >= n || (c != ' ' && c != '\t') {
+			return line[i:]
+		}
+		if c == '\t' {
+			col += 4
+		} else {
+			col++
+		}
+	}
+	return ""
+}
+
+// renderInline renders the text of a paragraph: code spans, links and
+// escaped text. Links are not rendered inside link text.
+func renderInline(b *strings.Builder, s string, links bool) {
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == '`':
+			i = renderCodeSpan(b, s, i)
+		case links && c == '[':
+			if text, href, n, ok := parseLink(s[i:]); ok {
+				writeLink(b, href, text)
+				i += n
+			} else {
+				b.WriteByte('[')
+				i++
+			}
+		case links && c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 && safeURL(s[i+1:i+end]) {
+				href := s[i+1 : i+end]
+				writeLink(b, href, html.EscapeString(href))
+				i += end + 1
+			} else {
+				b.WriteString("&lt;")
+				i++
+			}
+		case links && c == 'h' && (i == 0 || !isWordByte(s[i-1])) && hasURLPrefix(s[i:]):
+			n := bareURLLength(s[i:])
+			href := s[i : i+n]
+			if safeURL(href) {
+				writeLink(b, href, html.EscapeString(href))
+			} else {
+				b.WriteString(html.EscapeString(href))
+			}
+			i += n
+		default:
+			j := i + 1
+			for j < len(s) && !strings.ContainsRune("\\`[<h", rune(s[j])) {
+				j++
+			}
+			b.WriteString(html.EscapeString(s[i:j]))
+			i = j
+		}
+	}
+}
+
+// renderCodeSpan renders the code span starting at the run of backticks
+// at s[i], or the backticks as text when the run is not closed.
+func renderCodeSpan(b *strings.Builder, s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '`' {
+		n++
+	}
+	for j := i + n; j < len(s); {
+		k := strings.IndexByte(s[j:], '`')
+		if k < 0 {
+			break
+		}
+		j += k
+		m := 0
+		for j+m < len(s) && s[j+m] == '`' {
+			m++
+		}
+		if m == n {
+			code := s[i+n : j]
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			return j + m
+		}
+		j += m
+	}
+	b.WriteString(s[i : i+n])
+	return i + n
+}
+
+// parseLink parses a [text](url) link at the start of s and returns its
+// rendered text, its URL and its length. Links to anything but web pages
+// and mail addresses are not links.
+func parseLink(s string) (text, href string, n int, ok bool) {
+	depth := 0
+	end := -1
+	for i := 1; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				end = i
+			}
+			depth--
+		}
+	}
+	if end < 0 || end+1 >= len(s) || s[end+1] != '(' {
+		return "", "", 0, false
+	}
+
+	depth = 0
+	for i := end + 2; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\t':
+			return "", "", 0, false
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			href = s[end+2 : i]
+			if !safeURL(href) || end == 1 {
+				return "", "", 0, false
+			}
+			var t strings.Builder
+			renderInline(&t, s[1:end], false)
+			return t.String(), href, i + 1, true
+		}
+	}
+	return "", "", 0, false
+}
+
+func writeLink(b *strings.Builder, href, text string) {
+	b.WriteString(`<a href="` + html.EscapeString(href) + `">` + text + `</a>`)
+}
+
+func hasURLPrefix(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// bareURLLength is the length of the URL at the start of s, leaving out
+// punctuation that more likely ends the sentence than the URL.
+func bareURLLength(s string) int {
+	n := strings.IndexAny(s, " \t<>\"")
+	if n < 0 {
+		n = len(s)
+	}
+	for n > 0 && strings.IndexByte(".,:;!?'", s[n-1]) >= 0 {
+		n--
+	}
+	if n > 0 && s[n-1] == ')' && strings.Count(s[:n], "(") < strings.Count(s[:n], ")") {
+		n--
+	}
+	return n
+}
+
+func isPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
@@ -0,0 +1,94 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/html"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{name: "Paragraphs", src: "Hello\nworld\n\nBye", want: "<p>Hello<br>\nworld</p><p>Bye</p>"},
+		{name: "Escaped", src: "1 < 2 & \"3\"", want: "<p>1 &lt; 2 &amp; &#34;3&#34;</p>"},
+		{name: "Inline Code", src: "Run `go test ./...` now, `a<b`", want: "<p>Run <code>go test ./...</code> now, <code>a&lt;b</code></p>"},
+		{name: "Unclosed Code Span", src: "a `b", want: "<p>a `b</p>"},
+		{name: "Double Backticks", src: "`` a`b ``", want: "<p><code>a`b</code></p>"},
+		{name: "Link", src: "See [the docs](https://example.com/a_(b)).",
+			want: `<p>See <a href="https://example.com/a_(b)" rel="nofollow noopener noreferrer" target="_blank">the docs</a>.</p>`},
+		{name: "Bare URL", src: "Go to https://example.com/x?y=1.",
+			want: `<p>Go to <a href="https://example.com/x?y=1" rel="nofollow noopener noreferrer" target="_blank">https://example.com/x?y=1</a>.</p>`},
+		{name: "Autolink", src: "<mailto:bob@example.com>",
+			want: `<p><a href="mailto:bob@example.com" rel="nofollow noopener noreferrer" target="_blank">mailto:bob@example.com</a></p>`},
+		{name: "Escaped Bracket", src: `\[not](https://example.com)`, want: "<p>[not](<a href=\"https://example.com\" rel=\"nofollow noopener noreferrer\" target=\"_blank\">https://example.com</a>)</p>"},
+		{name: "Bullet List", src: "- one\n- two\n  more\n\n- three", want: "<ul><li>one</li><li>two<br>\nmore</li><li>three</li></ul>"},
+		{name: "Ordered List", src: "3. three\n4. four", want: `<ol start="3"><li>three</li><li>four</li></ol>`},
+		{name: "Nested List", src: "- a\n  - b\n- c", want: "<ul><li>a<ul><li>b</li></ul></li><li>c</li></ul>"},
+		{name: "Quote", src: "> quoted\n> - item\n\nafter", want: "<blockquote><p>quoted</p><ul><li>item</li></ul></blockquote><p>after</p>"},
+		{name: "Fenced Code", src: "```\n<b>\n  x\n```", want: "<pre><code>&lt;b&gt;\n  x</code></pre>"},
+		{name: "Unclosed Fence", src: "~~~\ncode", want: "<pre><code>code</code></pre>"},
+		{name: "Highlighted Code", src: "```go\nfunc f() { return \"x\" } // done\n```",
+			want: `<pre><code class="language-go"><span class="hl-keyword">func</span> f() { <span class="hl-keyword">return</span> <span class="hl-string">&#34;x&#34;</span> } <span class="hl-comment">// done</span></code></pre>`},
+		{name: "Not A Fence", src: "```a``` b", want: "<p><code>a</code> b</p>"},
+		{name: "Emphasis Kept As Text", src: "*not* __styled__", want: "<p>*not* __styled__</p>"},
+		{name: "Empty", src: " \n\n", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Render(tt.src))
+		})
+	}
+}
+
+func TestRenderXSS(t *testing.T) {
+	for _, src := range []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[x](javascript:alert(1))`,
+		`[x](JaVaScRiPt:alert(1))`,
+		`[x](data:text/html;base64,PHNjcmlwdD4=)`,
+		`<javascript:alert(1)>`,
+		"[x](https://a.com\"onmouseover=\"alert(1))",
+		"```\"><script>alert(1)</script>\n```",
+		"```go\" onclick=\"alert(1)\n```",
+		`<a href="javascript:alert(1)">x</a>`,
+		"> <iframe src=//evil>",
+		"- <svg onload=alert(1)>",
+	} {
+		out := Render(src)
+		z := html.NewTokenizer(strings.NewReader(out))
+		for tt := z.Next(); tt != html.ErrorToken; tt = z.Next() {
+			if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+				continue
+			}
+			tok := z.Token()
+			assert.True(t, allowedTags[tok.Data], "%q rendered as %q", src, out)
+			for _, a := range tok.Attr {
+				assert.Contains(t, []string{"href", "rel", "target", "class", "start"}, a.Key, "%q rendered as %q", src, out)
+				if a.Key == "href" {
+					assert.True(t, safeURL(a.Val), "%q rendered as %q", src, out)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderDeepNesting(t *testing.T) {
+	out := Render(strings.Repeat(">", 100) + " deep")
+	assert.Equal(t, maxDepth, strings.Count(out, "<blockquote>"))
+	assert.Contains(t, out, "deep")
+}
+
+func TestHighlight(t *testing.T) {
+	assert.Equal(t, `<span class="hl-keyword">SELECT</span> * <span class="hl-keyword">FROM</span> t <span class="hl-comment">-- all</span>`,
+		highlight("postgresql", "SELECT * FROM t -- all"))
+	assert.Equal(t, `x = <span class="hl-number">42</span> <span class="hl-comment"># &lt;b&gt;</span>`, highlight("py", "x = 42 # <b>"))
+	assert.Equal(t, `<span class="hl-string">&#39;it\&#39;s&#39;</span>`, highlight("js", `'it\'s'`))
+	assert.Equal(t, "foo &lt;bar&gt;", highlight("brainfuck", "foo <bar>"))
+}
@@ -0,0 +1,140 @@
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags are the elements Render produces. Other elements are
+// dropped and their text kept, except for those in droppedWithContent.
+var allowedTags = map[string]bool{
+	"p": true, "br": true, "pre": true, "code": true, "span": true, "a": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true,
+}
+
+var droppedWithContent = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"noscript": true, "template": true, "textarea": true, "title": true, "svg": true, "math": true,
+}
+
+var (
+	languageClass  = regexp.MustCompile(`^language-[a-z0-9_+-]+$`)
+	highlightClass = regexp.MustCompile(`^hl-(keyword|string|comment|number)$`)
+	listStart      = regexp.MustCompile(`^[0-9]{1,9}$`)
+)
+
+// Sanitize keeps only the elements and attributes on the allow-list,
+// re-escaping all text. Links get rel="nofollow noopener noreferrer" and
+// open in a new tab; ones to anything but web pages and mail addresses
+// lose their href.
+func Sanitize(s string) string {
+	z := html.NewTokenizer(strings.NewReader(s))
+	var b strings.Builder
+	var open []string
+	dropping, dropDepth := "", 0
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				b.WriteString("</" + open[i] + ">")
+			}
+			return b.String()
+
+		case html.TextToken:
+			if dropDepth == 0 {
+				b.WriteString(html.EscapeString(string(z.Text())))
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if dropDepth > 0 {
+				if tok.Data == dropping && tt == html.StartTagToken {
+					dropDepth++
+				}
+				continue
+			}
+			if droppedWithContent[tok.Data] {
+				if tt == html.StartTagToken {
+					dropping, dropDepth = tok.Data, 1
+				}
+				continue
+			}
+			if !allowedTags[tok.Data] {
+				continue
+			}
+			b.WriteString("<" + tok.Data + allowedAttrs(tok) + ">")
+			if tok.Data != "br" {
+				open = append(open, tok.Data)
+			}
+
+		case html.EndTagToken:
+			tok := z.Token()
+			if dropDepth > 0 {
+				if tok.Data == dropping {
+					dropDepth--
+				}
+				continue
+			}
+			// Close everything opened since the matching start tag; end
+			// tags that match nothing are dropped.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == tok.Data {
+					for j := len(open) - 1; j >= i; j-- {
+						b.WriteString("</" + open[j] + ">")
+					}
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+}
+
+func allowedAttrs(tok html.Token) string {
+	var b strings.Builder
+	attr := func(name, value string) {
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	for _, a := range tok.Attr {
+		if a.Namespace != "" {
+			continue
+		}
+		switch {
+		case tok.Data == "a" && a.Key == "href" && safeURL(a.Val):
+			attr("href", a.Val)
+		case tok.Data == "code" && a.Key == "class" && languageClass.MatchString(a.Val),
+			tok.Data == "span" && a.Key == "class" && highlightClass.MatchString(a.Val),
+			tok.Data == "ol" && a.Key == "start" && listStart.MatchString(a.Val):
+			attr(a.Key, a.Val)
+		}
+	}
+	if tok.Data == "a" {
+		attr("rel", "nofollow noopener noreferrer")
+		attr("target", "_blank")
+	}
+	return b.String()
+}
+
+// safeURL reports whether a link may point at raw: web pages and mail
+// addresses only, so links cannot run scripts.
+func safeURL(raw string) bool {
+	if raw == "" || strings.ContainsAny(raw, " \t\r\n") {
+		return false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return u.Opaque != ""
+	}
+	return false
+}
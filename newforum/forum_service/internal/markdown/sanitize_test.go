@@ -0,0 +1,45 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "Allowed", in: `<p>a<br>b</p>`, want: `<p>a<br>b</p>`},
+		{name: "Unknown Tag Keeps Text", in: `<b onclick="x">bold</b>`, want: `bold`},
+		{name: "Script Dropped", in: `a<script>alert("<p>")</script>b`, want: `ab`},
+		{name: "Nested Dropped", in: `<svg><svg></svg><p>x</p></svg>y`, want: `y`},
+		{name: "Attributes Filtered", in: `<code class="language-go" style="x">c</code><span class="evil">s</span>`,
+			want: `<code class="language-go">c</code><span>s</span>`},
+		{name: "Link Attributes", in: `<a href="https://x.org" target="_self" rel="opener">x</a>`,
+			want: `<a href="https://x.org" rel="nofollow noopener noreferrer" target="_blank">x</a>`},
+		{name: "Unsafe Link", in: `<a href="javascript:alert(1)">x</a>`, want: `<a rel="nofollow noopener noreferrer" target="_blank">x</a>`},
+		{name: "Unclosed Closed", in: `<ul><li>a`, want: `<ul><li>a</li></ul>`},
+		{name: "Stray End Tag", in: `</p>a</li>`, want: `a`},
+		{name: "Misnested", in: `<blockquote><p>a</blockquote>b`, want: `<blockquote><p>a</p></blockquote>b`},
+		{name: "Comment", in: `a<!-- <script> -->b`, want: `ab`},
+		{name: "Text Escaped", in: `&lt;script&gt;`, want: `&lt;script&gt;`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Sanitize(tt.in))
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	for _, u := range []string{"https://example.com", "http://example.com/a?b#c", "mailto:a@b.c", "HTTPS://EXAMPLE.COM"} {
+		assert.True(t, safeURL(u), u)
+	}
+	for _, u := range []string{"", "javascript:alert(1)", "vbscript:x", "data:text/html,x", "//evil.com", "/relative", "https://", "http://a b", "java\tscript:x"} {
+		assert.False(t, safeURL(u), u)
+	}
+}
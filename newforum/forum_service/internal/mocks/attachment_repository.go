@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockAttachmentsRepo реализует интерфейс repository.AttachmentsRepository
+type MockAttachmentsRepo struct {
+	mock.Mock
+}
+
+func (m *MockAttachmentsRepo) TouchBlob(hash string) (*models.Blob, error) {
+	args := m.Called(hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Blob), args.Error(1)
+}
+
+func (m *MockAttachmentsRepo) SaveBlob(b models.Blob) error {
+	args := m.Called(b)
+	return args.Error(0)
+}
+
+func (m *MockAttachmentsRepo) AddAttachments(messageID int, attachments []models.Attachment) ([]models.Attachment, error) {
+	args := m.Called(messageID, attachments)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentsRepo) GetAttachments(messageIDs []int) (map[int][]models.Attachment, error) {
+	args := m.Called(messageIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]models.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentsRepo) GetAttachment(id int) (*models.Attachment, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Attachment), args.Error(1)
+}
+
+func (m *MockAttachmentsRepo) DeleteOrphanedBlobs(unusedSince time.Time) ([]models.Blob, error) {
+	args := m.Called(unusedSince)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Blob), args.Error(1)
+}
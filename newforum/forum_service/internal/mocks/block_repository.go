@@ -0,0 +1,54 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+)
+
+// MockBlocksRepo реализует интерфейс repository.BlocksRepository
+type MockBlocksRepo struct {
+	mock.Mock
+}
+
+func (m *MockBlocksRepo) BlockUser(blocker, blocked string) error {
+	args := m.Called(blocker, blocked)
+	return args.Error(0)
+}
+
+func (m *MockBlocksRepo) UnblockUser(blocker, blocked string) error {
+	args := m.Called(blocker, blocked)
+	return args.Error(0)
+}
+
+func (m *MockBlocksRepo) GetBlockedUsers(username string) ([]string, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockBlocksRepo) GetBlockers(username string, among []string) ([]string, error) {
+	args := m.Called(username, among)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockBlocksRepo) IgnoreUser(ignorer, ignored string) error {
+	args := m.Called(ignorer, ignored)
+	return args.Error(0)
+}
+
+func (m *MockBlocksRepo) UnignoreUser(ignorer, ignored string) error {
+	args := m.Called(ignorer, ignored)
+	return args.Error(0)
+}
+
+func (m *MockBlocksRepo) GetIgnoredUsers(username string) ([]string, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
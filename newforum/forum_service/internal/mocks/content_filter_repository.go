@@ -0,0 +1,51 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockContentFiltersRepo реализует интерфейс repository.ContentFiltersRepository
+type MockContentFiltersRepo struct {
+	mock.Mock
+}
+
+func (m *MockContentFiltersRepo) GetContentFilters(forumID *int) (*models.ContentFilters, error) {
+	args := m.Called(forumID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ContentFilters), args.Error(1)
+}
+
+func (m *MockContentFiltersRepo) AddWordFilter(f models.WordFilter) (*models.WordFilter, error) {
+	args := m.Called(f)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WordFilter), args.Error(1)
+}
+
+func (m *MockContentFiltersRepo) DeleteWordFilter(forumID *int, id int) (*models.WordFilter, error) {
+	args := m.Called(forumID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.WordFilter), args.Error(1)
+}
+
+func (m *MockContentFiltersRepo) AddLinkRule(rule models.LinkRule) (*models.LinkRule, error) {
+	args := m.Called(rule)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LinkRule), args.Error(1)
+}
+
+func (m *MockContentFiltersRepo) DeleteLinkRule(forumID *int, id int) (*models.LinkRule, error) {
+	args := m.Called(forumID, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.LinkRule), args.Error(1)
+}
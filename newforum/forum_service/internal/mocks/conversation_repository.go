@@ -0,0 +1,69 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockConversationsRepo реализует интерфейс repository.ConversationsRepository
+type MockConversationsRepo struct {
+	mock.Mock
+}
+
+func (m *MockConversationsRepo) CreateConversation(creator, title string, participants []string) (*models.Conversation, error) {
+	args := m.Called(creator, title, participants)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationsRepo) FindDirectConversation(username, other string) (*models.Conversation, error) {
+	args := m.Called(username, other)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationsRepo) GetConversations(username string) ([]models.Conversation, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationsRepo) GetConversation(id int, username string) (*models.Conversation, error) {
+	args := m.Called(id, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Conversation), args.Error(1)
+}
+
+func (m *MockConversationsRepo) GetConversationMessages(id int, page models.PageRequest) (*models.DirectMessagePage, error) {
+	args := m.Called(id, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DirectMessagePage), args.Error(1)
+}
+
+func (m *MockConversationsRepo) AddConversationMessage(msg models.DirectMessage) (*models.DirectMessage, error) {
+	args := m.Called(msg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.DirectMessage), args.Error(1)
+}
+
+func (m *MockConversationsRepo) MarkConversationRead(id int, username string, messageID int) (int, error) {
+	args := m.Called(id, username, messageID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockConversationsRepo) CountUnreadDirectMessages(username string) (int, error) {
+	args := m.Called(username)
+	return args.Int(0), args.Error(1)
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"time"
+
 	"github.com/jaxxiy/newforum/forum_service/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -36,8 +38,8 @@ func (m *MockForumsRepo) Update(id int, forum models.Forum) error {
 	return args.Error(0)
 }
 
-func (m *MockForumsRepo) Delete(id int) error {
-	args := m.Called(id)
+func (m *MockForumsRepo) Delete(id int, deletedBy string) error {
+	args := m.Called(id, deletedBy)
 	return args.Error(0)
 }
 
@@ -62,16 +64,16 @@ func (m *MockForumsRepo) GetMessageByID(id int) (*models.Message, error) {
 	return args.Get(0).(*models.Message), args.Error(1)
 }
 
-func (m *MockForumsRepo) PutMessage(id int, content string) (*models.Message, error) {
-	args := m.Called(id, content)
+func (m *MockForumsRepo) PutMessage(id int, content, editedBy string) (*models.Message, error) {
+	args := m.Called(id, content, editedBy)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Message), args.Error(1)
 }
 
-func (m *MockForumsRepo) DeleteMessage(id int) error {
-	args := m.Called(id)
+func (m *MockForumsRepo) DeleteMessage(id int, deletedBy string) error {
+	args := m.Called(id, deletedBy)
 	return args.Error(0)
 }
 
@@ -92,3 +94,341 @@ func (m *MockForumsRepo) GetUserByID(id int) (*models.User, error) {
 	args := m.Called(id)
 	return args.Get(0).(*models.User), args.Error(1)
 }
+
+func (m *MockForumsRepo) GetTopics(forumID int) ([]models.Topic, error) {
+	args := m.Called(forumID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Topic), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetTopicByID(id int) (*models.Topic, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Topic), args.Error(1)
+}
+
+func (m *MockForumsRepo) CreateTopic(topic models.Topic) (int, error) {
+	args := m.Called(topic)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumsRepo) UpdateTopic(id int, topic models.Topic) error {
+	args := m.Called(id, topic)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) DeleteTopic(id int, deletedBy string) error {
+	args := m.Called(id, deletedBy)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) GetMessageThread(messageID int) ([]models.Message, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Message), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetMessagesPage(forumID int, topicID *int, page models.PageRequest) (*models.MessagePage, error) {
+	args := m.Called(forumID, topicID, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MessagePage), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetForumsPage(page models.PageRequest) (*models.ForumPage, error) {
+	args := m.Called(page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ForumPage), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetMessageRevisions(messageID int) ([]models.MessageRevision, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.MessageRevision), args.Error(1)
+}
+
+func (m *MockForumsRepo) Restore(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) RestoreMessage(id int) (*models.Message, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetDeletedForums() ([]models.Forum, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Forum), args.Error(1)
+}
+
+func (m *MockForumsRepo) RestoreTopic(id int) (*models.Topic, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Topic), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetDeletedTopics() ([]models.Topic, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Topic), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetDeletedMessages() ([]models.Message, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Message), args.Error(1)
+}
+
+func (m *MockForumsRepo) PurgeDeleted(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetPinnedForums() ([]models.Forum, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Forum), args.Error(1)
+}
+
+func (m *MockForumsRepo) SetForumState(id int, change models.ForumStateChange) (*models.Forum, error) {
+	args := m.Called(id, change)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Forum), args.Error(1)
+}
+
+func (m *MockForumsRepo) ArchiveInactiveForums(before time.Time) ([]int, error) {
+	args := m.Called(before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockForumsRepo) PinMessage(messageID int, pinnedBy string, announcement bool, limit int) (*models.Message, error) {
+	args := m.Called(messageID, pinnedBy, announcement, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockForumsRepo) UnpinMessage(messageID int) (*models.Message, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetPinnedMessages(forumID int, topicID *int) ([]models.Message, error) {
+	args := m.Called(forumID, topicID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Message), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetCategories() ([]models.Category, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockForumsRepo) CreateCategory(c models.Category) (int, error) {
+	args := m.Called(c)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumsRepo) UpdateCategory(id int, c models.Category) error {
+	args := m.Called(id, c)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) DeleteCategory(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) ReorderCategories(ids []int) error {
+	args := m.Called(ids)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) MoveForums(p models.ForumPlacement) error {
+	args := m.Called(p)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) GetForumTree() (*models.ForumTree, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ForumTree), args.Error(1)
+}
+
+func (m *MockForumsRepo) SearchTags(prefix string, limit int) ([]models.Tag, error) {
+	args := m.Called(prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Tag), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetTag(name string) (*models.Tag, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetForumTags(forumID int) ([]string, error) {
+	args := m.Called(forumID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetTopicTags(topicID int) ([]string, error) {
+	args := m.Called(topicID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumsRepo) SetForumTags(forumID int, tags []string) error {
+	args := m.Called(forumID, tags)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) SetTopicTags(topicID int, tags []string) error {
+	args := m.Called(topicID, tags)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) GetTaggedForumsPage(tag string, page models.PageRequest) (*models.ForumPage, error) {
+	args := m.Called(tag, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ForumPage), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetTaggedTopics(tag string) ([]models.Topic, error) {
+	args := m.Called(tag)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Topic), args.Error(1)
+}
+
+func (m *MockForumsRepo) RenameTag(name, newName string) error {
+	args := m.Called(name, newName)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) MergeTags(source, target string) error {
+	args := m.Called(source, target)
+	return args.Error(0)
+}
+
+func (m *MockForumsRepo) AddReaction(messageID int, username, emoji string, limit int) (int, error) {
+	args := m.Called(messageID, username, emoji, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumsRepo) RemoveReaction(messageID int, username, emoji string) (int, error) {
+	args := m.Called(messageID, username, emoji)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetReactions(messageIDs []int, username string) (map[int][]models.ReactionCount, error) {
+	args := m.Called(messageIDs, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]models.ReactionCount), args.Error(1)
+}
+
+func (m *MockForumsRepo) ResolveUsernames(names []string) ([]string, error) {
+	args := m.Called(names)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumsRepo) SearchUsernames(prefix string, limit int) ([]string, error) {
+	args := m.Called(prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumsRepo) SetMessageMentions(messageID int, usernames []string) ([]string, error) {
+	args := m.Called(messageID, usernames)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetMentions(messageIDs []int) (map[int][]string, error) {
+	args := m.Called(messageIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]string), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetUnreadCounts(username string, forumIDs []int) (map[int]int, error) {
+	args := m.Called(username, forumIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+func (m *MockForumsRepo) GetReadPosition(username string, forumID int) (int, error) {
+	args := m.Called(username, forumID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumsRepo) AdvanceReadPosition(username string, forumID, messageID int) (int, error) {
+	args := m.Called(username, forumID, messageID)
+	return args.Int(0), args.Error(1)
+}
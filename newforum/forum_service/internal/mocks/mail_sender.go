@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/mail"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMailSender реализует интерфейс mail.Sender
+type MockMailSender struct {
+	mock.Mock
+}
+
+func (m *MockMailSender) Send(msg mail.Message) error {
+	args := m.Called(msg)
+	return args.Error(0)
+}
@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockModerationLogRepo реализует интерфейс repository.ModerationLogRepository
+type MockModerationLogRepo struct {
+	mock.Mock
+}
+
+func (m *MockModerationLogRepo) LogAction(e models.ModerationEntry) error {
+	args := m.Called(e)
+	return args.Error(0)
+}
+
+func (m *MockModerationLogRepo) GetModerationLog(f models.ModerationLogFilter, page models.PageRequest) (*models.ModerationLogPage, error) {
+	args := m.Called(f, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ModerationLogPage), args.Error(1)
+}
+
+func (m *MockModerationLogRepo) ExportModerationLog(f models.ModerationLogFilter, fn func(models.ModerationEntry) error) error {
+	args := m.Called(f, fn)
+	if entries, ok := args.Get(0).([]models.ModerationEntry); ok {
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
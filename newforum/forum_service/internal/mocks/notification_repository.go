@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockNotificationsRepo реализует интерфейс repository.NotificationsRepository
+type MockNotificationsRepo struct {
+	mock.Mock
+}
+
+func (m *MockNotificationsRepo) CreateNotification(n models.Notification) (*models.Notification, error) {
+	args := m.Called(n)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Notification), args.Error(1)
+}
+
+func (m *MockNotificationsRepo) GetNotifications(username string, unreadOnly bool, page models.PageRequest) (*models.NotificationPage, error) {
+	args := m.Called(username, unreadOnly, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.NotificationPage), args.Error(1)
+}
+
+func (m *MockNotificationsRepo) CountUnread(username string) (int, error) {
+	args := m.Called(username)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockNotificationsRepo) MarkRead(username string, id int) error {
+	args := m.Called(username, id)
+	return args.Error(0)
+}
+
+func (m *MockNotificationsRepo) MarkAllRead(username string) (int64, error) {
+	args := m.Called(username)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockNotificationsRepo) GetNotificationPreferences(username string) (map[string]bool, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]bool), args.Error(1)
+}
+
+func (m *MockNotificationsRepo) SetNotificationPreferences(username string, prefs map[string]bool) error {
+	args := m.Called(username, prefs)
+	return args.Error(0)
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPollsRepo реализует интерфейс repository.PollsRepository
+type MockPollsRepo struct {
+	mock.Mock
+}
+
+func (m *MockPollsRepo) CreatePoll(p models.Poll) (*models.Poll, error) {
+	args := m.Called(p)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Poll), args.Error(1)
+}
+
+func (m *MockPollsRepo) GetPoll(messageID int, username string) (*models.Poll, error) {
+	args := m.Called(messageID, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Poll), args.Error(1)
+}
+
+func (m *MockPollsRepo) GetPolls(messageIDs []int, username string) (map[int]*models.Poll, error) {
+	args := m.Called(messageIDs, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]*models.Poll), args.Error(1)
+}
+
+func (m *MockPollsRepo) Vote(pollID int, username string, optionIDs []int) error {
+	args := m.Called(pollID, username, optionIDs)
+	return args.Error(0)
+}
+
+func (m *MockPollsRepo) RetractVote(pollID int, username string) error {
+	args := m.Called(pollID, username)
+	return args.Error(0)
+}
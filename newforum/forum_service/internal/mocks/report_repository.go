@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockReportsRepo реализует интерфейс repository.ReportsRepository
+type MockReportsRepo struct {
+	mock.Mock
+}
+
+func (m *MockReportsRepo) CreateReport(rep models.Report) (*models.Report, error) {
+	args := m.Called(rep)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Report), args.Error(1)
+}
+
+func (m *MockReportsRepo) GetReportQueue(forumID *int) ([]models.ReportQueueItem, error) {
+	args := m.Called(forumID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.ReportQueueItem), args.Error(1)
+}
+
+func (m *MockReportsRepo) GetMessageReports(messageID int) ([]models.Report, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Report), args.Error(1)
+}
+
+func (m *MockReportsRepo) ResolveReports(messageID int, res models.ReportResolution) (*models.Message, error) {
+	args := m.Called(messageID, res)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSearchRepo реализует интерфейс repository.SearchRepository
+type MockSearchRepo struct {
+	mock.Mock
+}
+
+func (m *MockSearchRepo) Search(q models.SearchQuery) (*models.SearchPage, error) {
+	args := m.Called(q)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SearchPage), args.Error(1)
+}
@@ -0,0 +1,69 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSpamRepo реализует интерфейс repository.SpamRepository
+type MockSpamRepo struct {
+	mock.Mock
+}
+
+func (m *MockSpamRepo) GetAuthorActivity(author, content string, recentSince, duplicatesSince time.Time) (*models.AuthorActivity, error) {
+	args := m.Called(author, content, recentSince, duplicatesSince)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuthorActivity), args.Error(1)
+}
+
+func (m *MockSpamRepo) HoldMessage(held models.HeldMessage) (*models.HeldMessage, error) {
+	args := m.Called(held)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.HeldMessage), args.Error(1)
+}
+
+func (m *MockSpamRepo) GetHeldMessages(forumID *int) ([]models.HeldMessage, error) {
+	args := m.Called(forumID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.HeldMessage), args.Error(1)
+}
+
+func (m *MockSpamRepo) ApproveHeldMessage(id int) (*models.Message, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockSpamRepo) RejectHeldMessage(id int) (*models.HeldMessage, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.HeldMessage), args.Error(1)
+}
+
+func (m *MockSpamRepo) AddSpamSample(sample models.SpamSample) (*models.SpamSample, error) {
+	args := m.Called(sample)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SpamSample), args.Error(1)
+}
+
+func (m *MockSpamRepo) GetSpamSamples() ([]models.SpamSample, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.SpamSample), args.Error(1)
+}
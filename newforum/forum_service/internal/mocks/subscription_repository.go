@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockSubscriptionsRepo реализует интерфейс repository.SubscriptionsRepository
+type MockSubscriptionsRepo struct {
+	mock.Mock
+}
+
+func (m *MockSubscriptionsRepo) Subscribe(username string, forumID int, frequency string) (*models.Subscription, error) {
+	args := m.Called(username, forumID, frequency)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionsRepo) Unsubscribe(username string, forumID int) error {
+	args := m.Called(username, forumID)
+	return args.Error(0)
+}
+
+func (m *MockSubscriptionsRepo) GetSubscriptions(username string) ([]models.Subscription, error) {
+	args := m.Called(username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionsRepo) GetSubscribers(forumID int) ([]string, error) {
+	args := m.Called(forumID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockSubscriptionsRepo) GetDueSubscriptions(now time.Time) ([]models.Subscription, error) {
+	args := m.Called(now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Subscription), args.Error(1)
+}
+
+func (m *MockSubscriptionsRepo) GetDigestMessages(forumID int, username string, since, until time.Time, limit int) ([]models.Message, int, error) {
+	args := m.Called(forumID, username, since, until, limit)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]models.Message), args.Int(1), args.Error(2)
+}
+
+func (m *MockSubscriptionsRepo) MarkDigestSent(username string, forumIDs []int, sentAt time.Time) error {
+	args := m.Called(username, forumIDs, sentAt)
+	return args.Error(0)
+}
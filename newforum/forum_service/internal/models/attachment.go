@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Attachment is a file attached to a message. URL and ThumbnailURL are
+// signed download links that expire; ThumbnailURL is only set for images
+// a thumbnail could be made of.
+type Attachment struct {
+	ID            int       `json:"id"`
+	MessageID     int       `json:"message_id"`
+	Uploader      string    `json:"uploader"`
+	Filename      string    `json:"filename"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	Hash          string    `json:"-"`
+	ThumbnailType string    `json:"-"`
+	URL           string    `json:"url,omitempty"`
+	ThumbnailURL  string    `json:"thumbnail_url,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Blob is a stored file. Files are stored once per SHA-256 of their
+// content, so attachments with the same content share a blob.
+type Blob struct {
+	Hash          string
+	Size          int64
+	ContentType   string
+	ThumbnailType string
+}
+
+// Key is where the blob's content is stored.
+func (b Blob) Key() string {
+	return "blobs/" + b.Hash[:2] + "/" + b.Hash
+}
+
+// ThumbnailKey is where the blob's thumbnail is stored, if it has one.
+func (b Blob) ThumbnailKey() string {
+	return "thumbnails/" + b.Hash[:2] + "/" + b.Hash
+}
+
+// Blob returns the blob holding the attachment's content.
+func (a *Attachment) Blob() Blob {
+	return Blob{Hash: a.Hash, Size: a.Size, ContentType: a.ContentType, ThumbnailType: a.ThumbnailType}
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+type Category struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Position    int       `json:"position"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// LastPost describes the newest message in a forum.
+type LastPost struct {
+	MessageID int       `json:"message_id"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ForumNode is a forum in the forum tree together with its activity and
+// subforums.
+type ForumNode struct {
+	Forum
+	Tags         []string     `json:"tags"`
+	MessageCount int          `json:"message_count"`
+	LastPost     *LastPost    `json:"last_post,omitempty"`
+	Subforums    []*ForumNode `json:"subforums"`
+}
+
+type CategoryNode struct {
+	Category
+	Forums []*ForumNode `json:"forums"`
+}
+
+// ForumTree is every forum arranged by category and parent. Top-level
+// forums without a category are listed in Uncategorized.
+type ForumTree struct {
+	Categories    []*CategoryNode `json:"categories"`
+	Uncategorized []*ForumNode    `json:"uncategorized"`
+}
+
+// ForumPlacement moves forums under a category or a parent forum and orders
+// them as listed. A subforum always shares its parent's category, so
+// CategoryID is ignored when ParentID is set.
+type ForumPlacement struct {
+	CategoryID *int  `json:"category_id"`
+	ParentID   *int  `json:"parent_id"`
+	ForumIDs   []int `json:"forum_ids"`
+}
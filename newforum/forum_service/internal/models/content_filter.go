@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// What happens to a message containing a filtered word.
+const (
+	FilterBlock = "block"
+	FilterMask  = "mask"
+	FilterFlag  = "flag"
+)
+
+// Link rule policies. Once a forum has an allow rule, links to domains not
+// on its allow-list are refused too.
+const (
+	LinkAllow = "allow"
+	LinkDeny  = "deny"
+)
+
+// Reports filed by the content filter carry these instead of a user and
+// one of ReportReasons.
+const (
+	FilterReporter     = "content-filter"
+	ReportReasonFilter = "content_filter"
+)
+
+// WordFilter is a banned word or phrase. A * at either end also matches
+// longer words. ForumID is nil for filters that apply to every forum and
+// the global chat.
+type WordFilter struct {
+	ID        int       `json:"id"`
+	ForumID   *int      `json:"forum_id"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LinkRule allows or denies links to a domain and its subdomains.
+type LinkRule struct {
+	ID        int       `json:"id"`
+	ForumID   *int      `json:"forum_id"`
+	Domain    string    `json:"domain"`
+	Policy    string    `json:"policy"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContentFilters are the rules that apply to a forum: its own and the
+// site-wide ones.
+type ContentFilters struct {
+	Words []WordFilter `json:"words"`
+	Links []LinkRule   `json:"links"`
+}
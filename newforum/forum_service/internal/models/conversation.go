@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// MaxConversationParticipants limits private conversations to small groups,
+// counting the user who starts them.
+const MaxConversationParticipants = 10
+
+// Conversation is a private conversation as seen by one of its
+// participants: Unread and LastReadMessageID are that participant's.
+type Conversation struct {
+	ID                int            `json:"id"`
+	Title             string         `json:"title,omitempty"`
+	CreatedBy         string         `json:"created_by"`
+	Participants      []string       `json:"participants"`
+	LastMessage       *DirectMessage `json:"last_message,omitempty"`
+	LastReadMessageID int            `json:"last_read_message_id"`
+	Unread            int            `json:"unread"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+// HasParticipant reports whether username takes part in the conversation.
+func (c *Conversation) HasParticipant(username string) bool {
+	for _, p := range c.Participants {
+		if p == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Direct reports whether the conversation is a one-to-one conversation
+// rather than a group.
+func (c *Conversation) Direct() bool {
+	return len(c.Participants) == 2 && c.Title == ""
+}
+
+type DirectMessage struct {
+	ID             int       `json:"id"`
+	ConversationID int       `json:"conversation_id"`
+	Author         string    `json:"author"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// DirectMessagePage is one page of a conversation, oldest message first.
+type DirectMessagePage struct {
+	Messages []DirectMessage `json:"messages"`
+	Prev     string          `json:"prev,omitempty"`
+	Next     string          `json:"next,omitempty"`
+}
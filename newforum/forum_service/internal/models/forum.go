@@ -3,8 +3,40 @@ package models
 import "time"
 
 type Forum struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Locked      bool       `json:"locked"`
+	Pinned      bool       `json:"pinned"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	CategoryID  *int       `json:"category_id,omitempty"`
+	ParentID    *int       `json:"parent_id,omitempty"`
+	Position    int        `json:"position"`
+	SlowMode    int        `json:"slow_mode"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy   string     `json:"deleted_by,omitempty"`
+	// Unread is the number of messages the requesting user has not read
+	// yet. It is only set for signed-in users.
+	Unread *int `json:"unread,omitempty"`
+}
+
+// ReadOnly reports whether new messages and topics are refused.
+func (f *Forum) ReadOnly() bool {
+	return f.Locked || f.ArchivedAt != nil
+}
+
+// HasUnread reports whether the requesting user has unread messages here.
+func (f Forum) HasUnread() bool {
+	return f.Unread != nil && *f.Unread > 0
+}
+
+// ForumStateChange updates a forum's moderation flags and slow mode, the
+// number of seconds each user has to wait between messages there (0 turns
+// it off); nil fields are left as they are.
+type ForumStateChange struct {
+	Locked   *bool `json:"locked,omitempty"`
+	Pinned   *bool `json:"pinned,omitempty"`
+	Archived *bool `json:"archived,omitempty"`
+	SlowMode *int  `json:"slow_mode,omitempty"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxMentions is the most users a single message can notify.
+const MaxMentions = 20
+
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+// ParseMentions returns the usernames written as @username in content, in
+// order of first appearance and without duplicates. An @ only starts a
+// mention at the beginning of the text or after a character that cannot be
+// part of a username, so e-mail addresses are not mentions. Trailing dots
+// and dashes are treated as punctuation.
+func ParseMentions(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	prev := ' '
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if r == '@' && prev != '@' && !isMentionRune(prev) {
+			end := i + size
+			for end < len(content) {
+				next, n := utf8.DecodeRuneInString(content[end:])
+				if !isMentionRune(next) {
+					break
+				}
+				end += n
+			}
+			name := strings.TrimRight(content[i+size:end], ".-")
+			if name != "" && !seen[name] && len(names) < MaxMentions {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		prev = r
+		i += size
+	}
+	return names
+}
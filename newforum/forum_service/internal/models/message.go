@@ -3,9 +3,43 @@ package models
 import "time"
 
 type Message struct {
+	ID           int             `json:"id"`
+	ForumID      int             `json:"forum_id"`
+	TopicID      *int            `json:"topic_id,omitempty"`
+	ReplyTo      *int            `json:"reply_to,omitempty"`
+	Quote        string          `json:"quote,omitempty"`
+	Author       string          `json:"author"`
+	Content      string          `json:"content"`
+	ContentHTML  string          `json:"content_html"`
+	CreatedAt    time.Time       `json:"created_at"`
+	EditedAt     *time.Time      `json:"edited_at,omitempty"`
+	EditCount    int             `json:"edit_count"`
+	DeletedAt    *time.Time      `json:"deleted_at,omitempty"`
+	DeletedBy    string          `json:"deleted_by,omitempty"`
+	Hidden       bool            `json:"hidden,omitempty"`
+	PinnedAt     *time.Time      `json:"pinned_at,omitempty"`
+	PinnedBy     string          `json:"pinned_by,omitempty"`
+	Announcement bool            `json:"announcement,omitempty"`
+	Reactions    []ReactionCount `json:"reactions,omitempty"`
+	Mentions     []string        `json:"mentions,omitempty"`
+	Poll         *Poll           `json:"poll,omitempty"`
+	Attachments  []Attachment    `json:"attachments,omitempty"`
+	// Ignored is set when the viewer ignores the author; clients collapse
+	// such messages.
+	Ignored bool `json:"ignored,omitempty"`
+}
+
+// MessageRevision keeps the content a message had before one of its edits.
+type MessageRevision struct {
 	ID        int       `json:"id"`
-	ForumID   int       `json:"forum_id"`
-	Author    string    `json:"author"`
+	MessageID int       `json:"message_id"`
 	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	EditedBy  string    `json:"edited_by"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// MessageThread is a message together with the replies posted to it.
+type MessageThread struct {
+	Message
+	Replies []*MessageThread `json:"replies"`
 }
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ModerationTargetMessage = "message"
+	ModerationTargetForum   = "forum"
+	ModerationTargetTopic   = "topic"
+	ModerationTargetTag     = "tag"
+	ModerationTargetFilter  = "word_filter"
+	ModerationTargetLink    = "link_rule"
+	ModerationTargetHeld    = "held_message"
+)
+
+// Actions recorded in the moderation log. Users cannot be banned, so there
+// is no action for bans; warnings are issued by resolving reports and are
+// logged as report_resolve.
+const (
+	ModerationMessageEdit    = "message_edit"
+	ModerationMessageDelete  = "message_delete"
+	ModerationMessageRestore = "message_restore"
+	ModerationMessagePin     = "message_pin"
+	ModerationMessageUnpin   = "message_unpin"
+	ModerationForumUpdate    = "forum_update"
+	ModerationForumDelete    = "forum_delete"
+	ModerationForumRestore   = "forum_restore"
+	ModerationForumState     = "forum_state"
+	ModerationTopicDelete    = "topic_delete"
+	ModerationTopicRestore   = "topic_restore"
+	ModerationReportResolve  = "report_resolve"
+	ModerationTagRename      = "tag_rename"
+	ModerationTagMerge       = "tag_merge"
+	ModerationFilterAdd      = "filter_add"
+	ModerationFilterDelete   = "filter_delete"
+	ModerationSpamApprove    = "spam_approve"
+	ModerationSpamReject     = "spam_reject"
+	ModerationSpamMark       = "spam_mark"
+)
+
+// ModerationEntry records one moderation action. Before and After hold JSON
+// snapshots of the target; either is empty when there is nothing to show.
+type ModerationEntry struct {
+	ID         int             `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int             `json:"target_id"`
+	ForumID    *int            `json:"forum_id,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// ModerationLogFilter narrows the moderation log; zero fields match everything.
+type ModerationLogFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   *int
+	ForumID    *int
+	From       *time.Time
+	To         *time.Time
+}
+
+type ModerationLogPage struct {
+	Entries []ModerationEntry `json:"entries"`
+	Prev    string            `json:"prev,omitempty"`
+	Next    string            `json:"next,omitempty"`
+}
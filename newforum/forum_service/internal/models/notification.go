@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+const (
+	NotificationReply      = "reply"
+	NotificationMention    = "mention"
+	NotificationReaction   = "reaction"
+	NotificationModeration = "moderation"
+	NotificationActivity   = "forum_activity"
+)
+
+// NotificationTypes lists every notification type in the order the
+// preferences page shows them.
+var NotificationTypes = []string{
+	NotificationReply,
+	NotificationMention,
+	NotificationReaction,
+	NotificationModeration,
+	NotificationActivity,
+}
+
+func ValidNotificationType(t string) bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification tells a user about something that happened to them or their
+// content. Actor is the user who caused it; Detail adds context such as the
+// emoji of a reaction or the moderation action taken.
+type Notification struct {
+	ID        int        `json:"id"`
+	Username  string     `json:"username"`
+	Type      string     `json:"type"`
+	Actor     string     `json:"actor,omitempty"`
+	Detail    string     `json:"detail,omitempty"`
+	ForumID   *int       `json:"forum_id,omitempty"`
+	TopicID   *int       `json:"topic_id,omitempty"`
+	MessageID *int       `json:"message_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// NotificationPage is one page of a user's notifications, newest first,
+// together with the number of unread notifications overall.
+type NotificationPage struct {
+	Notifications []Notification `json:"notifications"`
+	Unread        int            `json:"unread"`
+	Prev          string         `json:"prev,omitempty"`
+	Next          string         `json:"next,omitempty"`
+}
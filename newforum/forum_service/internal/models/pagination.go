@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a row position for keyset pagination on (created_at, id).
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Encode returns the opaque string form handed out to API clients.
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var nanos int64
+	var id int
+	if _, err := fmt.Sscanf(string(raw), "%d:%d", &nanos, &id); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// PageRequest selects at most Limit rows strictly before or after a cursor.
+// With neither cursor set the listing's default end is used.
+type PageRequest struct {
+	Before *Cursor
+	After  *Cursor
+	Limit  int
+}
+
+// MessagePage is one page of a message stream. Pinned holds the pinned
+// messages and announcements shown above the stream and is only filled on
+// the first page.
+type MessagePage struct {
+	Pinned   []Message `json:"pinned,omitempty"`
+	Messages []Message `json:"messages"`
+	Prev     string    `json:"prev,omitempty"`
+	Next     string    `json:"next,omitempty"`
+}
+
+// ForumPage is one page of unpinned forums. Pinned is only filled on the
+// first page, which has no cursor.
+type ForumPage struct {
+	Pinned []Forum `json:"pinned,omitempty"`
+	Forums []Forum `json:"forums"`
+	Prev   string  `json:"prev,omitempty"`
+	Next   string  `json:"next,omitempty"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MinPollOptions        = 2
+	MaxPollOptions        = 10
+	MaxPollQuestionLength = 300
+	MaxPollOptionLength   = 200
+)
+
+var (
+	ErrPollQuestion     = fmt.Errorf("a poll needs a question of at most %d characters", MaxPollQuestionLength)
+	ErrPollOptionCount  = fmt.Errorf("a poll needs %d to %d options", MinPollOptions, MaxPollOptions)
+	ErrPollOption       = fmt.Errorf("poll options must be unique and at most %d characters", MaxPollOptionLength)
+	ErrPollClosesInPast = errors.New("a poll cannot close in the past")
+	ErrInvalidVote      = errors.New("vote for options of this poll")
+	ErrSingleChoice     = errors.New("this poll allows only one choice")
+)
+
+// Poll is attached to a message. Voters counts the users who voted, and
+// Voted lists the options the requesting user chose. The option voters
+// are only given out for public polls.
+type Poll struct {
+	ID             int          `json:"id"`
+	MessageID      int          `json:"message_id"`
+	Question       string       `json:"question"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	Anonymous      bool         `json:"anonymous"`
+	AllowChange    bool         `json:"allow_change"`
+	ClosesAt       *time.Time   `json:"closes_at,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Options        []PollOption `json:"options"`
+	Voters         int          `json:"voters"`
+	Voted          []int        `json:"voted,omitempty"`
+}
+
+type PollOption struct {
+	ID     int      `json:"id"`
+	Text   string   `json:"text"`
+	Votes  int      `json:"votes"`
+	Voters []string `json:"voters,omitempty"`
+}
+
+// Closed reports whether voting in the poll had ended by now.
+func (p *Poll) Closed(now time.Time) bool {
+	return p.ClosesAt != nil && !now.Before(*p.ClosesAt)
+}
+
+// Validate checks a new poll, trimming its question and options.
+func (p *Poll) Validate(now time.Time) error {
+	p.Question = strings.TrimSpace(p.Question)
+	if p.Question == "" || utf8.RuneCountInString(p.Question) > MaxPollQuestionLength {
+		return ErrPollQuestion
+	}
+	if len(p.Options) < MinPollOptions || len(p.Options) > MaxPollOptions {
+		return ErrPollOptionCount
+	}
+	seen := make(map[string]bool, len(p.Options))
+	for i := range p.Options {
+		text := strings.TrimSpace(p.Options[i].Text)
+		if text == "" || utf8.RuneCountInString(text) > MaxPollOptionLength || seen[text] {
+			return ErrPollOption
+		}
+		seen[text] = true
+		p.Options[i].Text = text
+	}
+	if p.Closed(now) {
+		return ErrPollClosesInPast
+	}
+	return nil
+}
+
+// ValidateVote checks that optionIDs name distinct options of the poll, and
+// only one of them unless the poll is multiple choice.
+func (p *Poll) ValidateVote(optionIDs []int) error {
+	if len(optionIDs) == 0 {
+		return ErrInvalidVote
+	}
+	if len(optionIDs) > 1 && !p.MultipleChoice {
+		return ErrSingleChoice
+	}
+	chosen := make(map[int]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		if chosen[id] || !p.hasOption(id) {
+			return ErrInvalidVote
+		}
+		chosen[id] = true
+	}
+	return nil
+}
+
+func (p *Poll) hasOption(id int) bool {
+	for _, o := range p.Options {
+		if o.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Results returns the poll without what is personal to one user, as it is
+// broadcast to everyone watching the message.
+func (p *Poll) Results() *Poll {
+	results := *p
+	results.Voted = nil
+	return &results
+}
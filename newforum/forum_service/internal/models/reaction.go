@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxEmojiLength is the longest reaction accepted, in bytes. It leaves room
+// for emoji built from several code points such as flags and families.
+const MaxEmojiLength = 32
+
+var ErrInvalidEmoji = errors.New("a reaction must be a single emoji")
+
+const zeroWidthJoiner = '\u200d'
+
+// ReactionCount is the number of users who reacted to a message with one
+// emoji. Reacted tells whether the requesting user is one of them.
+type ReactionCount struct {
+	Emoji   string `json:"emoji"`
+	Count   int    `json:"count"`
+	Reacted bool   `json:"reacted"`
+}
+
+// ValidateEmoji accepts a string made of symbols and the modifiers, variation
+// selectors and joiners emoji sequences are built from. It does not check the
+// sequence against the Unicode emoji list, so any pictographic symbol passes.
+func ValidateEmoji(emoji string) error {
+	if emoji == "" || len(emoji) > MaxEmojiLength || !utf8.ValidString(emoji) {
+		return ErrInvalidEmoji
+	}
+	symbol := false
+	for _, r := range emoji {
+		switch {
+		case unicode.Is(unicode.So, r):
+			symbol = true
+		case unicode.Is(unicode.Sk, r), unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r), r == zeroWidthJoiner:
+		default:
+			return ErrInvalidEmoji
+		}
+	}
+	if !symbol {
+		return ErrInvalidEmoji
+	}
+	return nil
+}
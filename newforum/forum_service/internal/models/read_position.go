@@ -0,0 +1,9 @@
+package models
+
+// ReadPosition is how far a user has read a forum: every message up to
+// LastReadMessageID, and Unread newer messages by other users.
+type ReadPosition struct {
+	ForumID           int `json:"forum_id"`
+	LastReadMessageID int `json:"last_read_message_id"`
+	Unread            int `json:"unread"`
+}
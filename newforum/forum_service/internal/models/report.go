@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// Report reasons a user can pick when flagging a message.
+var ReportReasons = map[string]bool{
+	"spam":       true,
+	"harassment": true,
+	"hate":       true,
+	"off_topic":  true,
+	"illegal":    true,
+	"other":      true,
+}
+
+// Actions a moderator can take on the reported message while resolving.
+const (
+	ReportActionNone   = ""
+	ReportActionDelete = "delete"
+	ReportActionHide   = "hide"
+	ReportActionWarn   = "warn"
+)
+
+type Report struct {
+	ID         int        `json:"id"`
+	MessageID  int        `json:"message_id"`
+	ForumID    int        `json:"forum_id"`
+	Reporter   string     `json:"reporter"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details,omitempty"`
+	Status     string     `json:"status"`
+	Action     string     `json:"action,omitempty"`
+	ResolvedBy string     `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ReportQueueItem groups the open reports filed against one message.
+type ReportQueueItem struct {
+	Message         Message        `json:"message"`
+	ReportCount     int            `json:"report_count"`
+	Reasons         map[string]int `json:"reasons"`
+	FirstReportedAt time.Time      `json:"first_reported_at"`
+	LastReportedAt  time.Time      `json:"last_reported_at"`
+}
+
+// ReportResolution closes every open report on a message. Status is either
+// ReportResolved or ReportDismissed; Action only applies to resolved reports.
+type ReportResolution struct {
+	Status     string `json:"status"`
+	Action     string `json:"action"`
+	ResolvedBy string `json:"-"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	SearchResultForum   = "forum"
+	SearchResultMessage = "message"
+)
+
+// SearchQuery describes a full-text search. Language names a text search
+// configuration; when empty every supported configuration is tried.
+type SearchQuery struct {
+	Query    string
+	Language string
+	ForumID  *int
+	Author   string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
+
+// SearchResult is a single ranked hit. Snippet is HTML-escaped with the
+// matched terms wrapped in <mark> tags.
+type SearchResult struct {
+	Type      string    `json:"type"`
+	ID        int       `json:"id"`
+	ForumID   int       `json:"forum_id"`
+	TopicID   *int      `json:"topic_id,omitempty"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author,omitempty"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+}
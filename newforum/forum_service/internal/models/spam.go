@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Signals a spam score is made of, each between 0 and 1.
+const (
+	SpamSignalClassifier = "classifier"
+	SpamSignalLinks      = "links"
+	SpamSignalRepeated   = "repeated"
+	SpamSignalNewAccount = "new_account"
+	SpamSignalVelocity   = "velocity"
+)
+
+// SpamScore is how likely a message is to be spam, from 0 to 1, and the
+// signals it was worked out from.
+type SpamScore struct {
+	Score   float64            `json:"score"`
+	Signals map[string]float64 `json:"signals"`
+}
+
+// AuthorActivity is what is known about the author of a message being
+// scored. Recent counts their messages in the velocity window and
+// Duplicates the messages anyone posted with the same content in the
+// duplicate window, held messages included. RegisteredAt is nil for
+// authors without an account here.
+type AuthorActivity struct {
+	Recent       int        `json:"recent"`
+	Duplicates   int        `json:"duplicates"`
+	RegisteredAt *time.Time `json:"registered_at,omitempty"`
+}
+
+// HeldMessage is a message that scored as spam and waits for a moderator
+// to approve it before anyone else sees it. Its attachments are listed
+// without download links.
+type HeldMessage struct {
+	ID        int       `json:"id"`
+	Message   Message   `json:"message"`
+	Spam      SpamScore `json:"spam"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SpamSample is a message a moderator marked as spam or not, which the
+// classifier is trained on. MessageID is nil for rejected held messages,
+// which were never posted.
+type SpamSample struct {
+	ID        int       `json:"id"`
+	MessageID *int      `json:"message_id,omitempty"`
+	Content   string    `json:"content"`
+	Spam      bool      `json:"spam"`
+	MarkedBy  string    `json:"marked_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SpamModel describes what the classifier has learnt. Until it is Ready
+// it does not contribute to scores.
+type SpamModel struct {
+	SpamSamples int       `json:"spam_samples"`
+	HamSamples  int       `json:"ham_samples"`
+	Vocabulary  int       `json:"vocabulary"`
+	Ready       bool      `json:"ready"`
+	SpamTokens  []string  `json:"spam_tokens"`
+	HamTokens   []string  `json:"ham_tokens"`
+	TrainedAt   time.Time `json:"trained_at"`
+}
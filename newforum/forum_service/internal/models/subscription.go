@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+const (
+	DigestImmediate = "immediate"
+	DigestDaily     = "daily"
+	DigestWeekly    = "weekly"
+)
+
+// DigestFrequencies lists how often a subscriber can be mailed.
+var DigestFrequencies = []string{DigestImmediate, DigestDaily, DigestWeekly}
+
+func ValidDigestFrequency(f string) bool {
+	for _, known := range DigestFrequencies {
+		if f == known {
+			return true
+		}
+	}
+	return false
+}
+
+// DigestInterval is how long the digest job waits after mailing a
+// subscriber before mailing them again. Immediate subscribers are mailed on
+// every run of the job that finds new messages.
+func DigestInterval(frequency string) time.Duration {
+	switch frequency {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// Subscription is a user following a forum. Messages posted after
+// LastSentAt go into the next digest. Email is only read for the digest job.
+type Subscription struct {
+	Username   string    `json:"username"`
+	Email      string    `json:"-"`
+	ForumID    int       `json:"forum_id"`
+	ForumTitle string    `json:"forum_title"`
+	Frequency  string    `json:"frequency"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSentAt time.Time `json:"last_sent_at"`
+}
+
+// DigestForum is the part of a digest about one forum: its oldest new
+// messages and how many more there are.
+type DigestForum struct {
+	ForumID        int
+	ForumTitle     string
+	Messages       []Message
+	More           int
+	UnsubscribeURL string
+}
+
+// Digest is one mail to a subscriber covering every subscribed forum that
+// is due.
+type Digest struct {
+	Username string
+	BaseURL  string
+	Forums   []DigestForum
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxTagLength is the longest allowed tag name, in characters.
+	MaxTagLength = 32
+	// MaxTags is the most tags a single forum or topic can carry.
+	MaxTags = 10
+)
+
+var (
+	ErrInvalidTag  = errors.New("tags must contain letters or digits and be at most 32 characters long")
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+type Tag struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	ForumCount int    `json:"forum_count"`
+	TopicCount int    `json:"topic_count"`
+}
+
+// TagPage lists everything carrying one tag.
+type TagPage struct {
+	Tag    Tag     `json:"tag"`
+	Forums []Forum `json:"forums"`
+	Topics []Topic `json:"topics"`
+}
+
+// NormalizeTag lower-cases name and joins its words with dashes, so that
+// "Web Dev", "web_dev" and "web-dev" all become the same tag. Characters
+// other than letters, digits and the "+#." used in names like c++ and .net
+// are dropped.
+func NormalizeTag(name string) (string, error) {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("+#.", r):
+			if dash && b.Len() > 0 {
+				b.WriteRune('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			dash = true
+		}
+	}
+
+	tag := b.String()
+	if tag == "" || len([]rune(tag)) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalises names and drops duplicates, keeping the order in
+// which the tags were first given.
+func NormalizeTags(names []string) ([]string, error) {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxTags {
+		return nil, ErrTooManyTags
+	}
+	return tags, nil
+}
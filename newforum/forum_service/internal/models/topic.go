@@ -1,8 +1,17 @@
 package models
 
+import "time"
+
 type Topic struct {
-	ID      int    `json:"id"`
-	ForumID int    `json:"forum_id"`
-	Title   string `json:"title"`
-	Desc    string `json:"description"`
+	ID           int        `json:"id"`
+	ForumID      int        `json:"forum_id"`
+	Title        string     `json:"title"`
+	Desc         string     `json:"description"`
+	Author       string     `json:"author"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReplyCount   int        `json:"reply_count"`
+	LastActivity *time.Time `json:"last_activity,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy    string     `json:"deleted_by,omitempty"`
 }
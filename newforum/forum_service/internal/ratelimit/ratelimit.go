@@ -0,0 +1,141 @@
+// Package ratelimit limits how often something may happen with token
+// buckets kept in memory.
+//
+// Every key, such as a user or an address, has a bucket holding up to
+// Limit.Requests tokens. Each request takes one and the bucket fills up
+// again at an even rate, so a full bucket allows a short burst while the
+// long-run rate stays at Requests per Per.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often a Limiter drops buckets that have filled up
+// again, which behave just like the fresh bucket a later request gets.
+const sweepInterval = time.Minute
+
+// Limit allows Requests requests every Per. The zero Limit allows
+// everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Every is a Limit of one request per d.
+func Every(d time.Duration) Limit {
+	return Limit{Requests: 1, Per: d}
+}
+
+// ParseLimit parses a limit written as requests per duration, such as
+// "5/10s" or "30/1m". An empty string or "0" is the zero Limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	count, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not requests/duration", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: invalid number of requests", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: invalid duration", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// Enabled reports whether l limits anything.
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// perSecond is how many tokens a bucket under l gains every second.
+func (l Limit) perSecond() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// Limiter keeps a token bucket per key. The limit is given with every
+// request rather than fixed up front, so one Limiter can serve keys with
+// different limits, and a limit that changes applies from the next
+// request. It is safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key under l. When the bucket is
+// empty it reports false and how long until the next token.
+func (lim *Limiter) Allow(key string, l Limit) (bool, time.Duration) {
+	if !l.Enabled() {
+		return true, 0
+	}
+
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	now := lim.now()
+	lim.sweep(now)
+
+	b, ok := lim.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Requests)}
+		lim.buckets[key] = b
+	} else {
+		elapsed := now.Sub(b.updated).Seconds()
+		b.tokens = math.Min(float64(l.Requests), b.tokens+elapsed*l.perSecond())
+	}
+	b.updated = now
+	b.limit = l
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.perSecond() * float64(time.Second))
+}
+
+// sweep drops the buckets that have had time to fill up since they were
+// last used, at most once every sweepInterval.
+func (lim *Limiter) sweep(now time.Time) {
+	if now.Sub(lim.swept) < sweepInterval {
+		return
+	}
+	lim.swept = now
+	for key, b := range lim.buckets {
+		if now.Sub(b.updated) >= b.limit.Per {
+			delete(lim.buckets, key)
+		}
+	}
+}
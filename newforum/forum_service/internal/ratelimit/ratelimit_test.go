@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testLimiter returns a Limiter whose clock only moves when the returned
+// function is called.
+func testLimiter() (*Limiter, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lim := NewLimiter()
+	lim.now = func() time.Time { return now }
+	return lim, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "5/10s", want: Limit{Requests: 5, Per: 10 * time.Second}},
+		{in: " 30/1m ", want: Limit{Requests: 30, Per: time.Minute}},
+		{in: "", want: Limit{}},
+		{in: "0", want: Limit{}},
+		{in: "5", wantErr: true},
+		{in: "x/1s", wantErr: true},
+		{in: "-1/1s", wantErr: true},
+		{in: "5/soon", wantErr: true},
+		{in: "5/0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseLimit(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, "5/10s", Limit{Requests: 5, Per: 10 * time.Second}.String())
+	assert.Equal(t, "0", Limit{}.String())
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	lim, advance := testLimiter()
+	l := Limit{Requests: 3, Per: 3 * time.Second}
+
+	for i := 0; i < 3; i++ {
+		ok, _ := lim.Allow("alice", l)
+		assert.True(t, ok, "request %d fits the burst", i+1)
+	}
+	ok, wait := lim.Allow("alice", l)
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	ok, _ = lim.Allow("bob", l)
+	assert.True(t, ok, "every key has its own bucket")
+
+	advance(500 * time.Millisecond)
+	ok, wait = lim.Allow("alice", l)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	advance(500 * time.Millisecond)
+	ok, _ = lim.Allow("alice", l)
+	assert.True(t, ok, "a token is back after a second")
+
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		ok, _ = lim.Allow("alice", l)
+		assert.True(t, ok)
+	}
+	ok, _ = lim.Allow("alice", l)
+	assert.False(t, ok, "a bucket never holds more than a burst")
+}
+
+func TestLimiter_ZeroLimit(t *testing.T) {
+	lim, _ := testLimiter()
+	for i := 0; i < 100; i++ {
+		ok, _ := lim.Allow("alice", Limit{})
+		assert.True(t, ok)
+	}
+	assert.Empty(t, lim.buckets)
+}
+
+func TestLimiter_ChangedLimit(t *testing.T) {
+	lim, advance := testLimiter()
+
+	ok, _ := lim.Allow("forum", Every(time.Minute))
+	assert.True(t, ok)
+	advance(10 * time.Second)
+	ok, wait := lim.Allow("forum", Every(time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, 50*time.Second, wait)
+
+	advance(5 * time.Second)
+	ok, _ = lim.Allow("forum", Every(5*time.Second))
+	assert.True(t, ok, "a shorter limit fills the bucket faster")
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	lim, advance := testLimiter()
+
+	lim.Allow("short", Every(time.Second))
+	lim.Allow("long", Every(time.Hour))
+	assert.Len(t, lim.buckets, 2)
+
+	advance(sweepInterval)
+	lim.Allow("other", Every(time.Second))
+	assert.Contains(t, lim.buckets, "long", "a bucket still filling up is kept")
+	assert.NotContains(t, lim.buckets, "short")
+
+	advance(time.Hour)
+	ok, _ := lim.Allow("long", Every(time.Hour))
+	assert.True(t, ok)
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+// AttachmentsRepository stores the files attached to messages and the
+// blobs holding their content.
+type AttachmentsRepository interface {
+	TouchBlob(hash string) (*models.Blob, error)
+	SaveBlob(b models.Blob) error
+	AddAttachments(messageID int, attachments []models.Attachment) ([]models.Attachment, error)
+	GetAttachments(messageIDs []int) (map[int][]models.Attachment, error)
+	GetAttachment(id int) (*models.Attachment, error)
+	DeleteOrphanedBlobs(unusedSince time.Time) ([]models.Blob, error)
+}
+
+const attachmentColumns = `a.id, a.message_id, a.uploader, a.filename, a.content_type, a.size, a.hash, b.thumbnail_type, a.created_at`
+
+type AttachmentsRepo struct {
+	DB *sql.DB
+}
+
+func NewAttachmentsRepo(db *sql.DB) *AttachmentsRepo {
+	return &AttachmentsRepo{
+		DB: db,
+	}
+}
+
+func scanAttachment(row rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	err := row.Scan(&a.ID, &a.MessageID, &a.Uploader, &a.Filename, &a.ContentType, &a.Size,
+		&a.Hash, &a.ThumbnailType, &a.CreatedAt)
+	return a, err
+}
+
+// TouchBlob marks the blob with the given hash as just used and returns
+// it, so content that is already stored need not be stored again.
+func (r *AttachmentsRepo) TouchBlob(hash string) (*models.Blob, error) {
+	b := models.Blob{Hash: hash}
+	err := r.DB.QueryRow(`
+		UPDATE blobs SET last_used_at = $2
+		WHERE hash = $1
+		RETURNING size, content_type, thumbnail_type`, hash, time.Now()).
+		Scan(&b.Size, &b.ContentType, &b.ThumbnailType)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SaveBlob records a blob whose content has been stored.
+func (r *AttachmentsRepo) SaveBlob(b models.Blob) error {
+	now := time.Now()
+	_, err := r.DB.Exec(`
+		INSERT INTO blobs (hash, size, content_type, thumbnail_type, created_at, last_used_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (hash) DO UPDATE SET last_used_at = EXCLUDED.last_used_at`,
+		b.Hash, b.Size, b.ContentType, b.ThumbnailType, now)
+	return err
+}
+
+// AddAttachments attaches files whose blobs are saved to a message.
+func (r *AttachmentsRepo) AddAttachments(messageID int, attachments []models.Attachment) ([]models.Attachment, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	added := make([]models.Attachment, len(attachments))
+	now := time.Now()
+	for i, a := range attachments {
+		a.MessageID = messageID
+		a.CreatedAt = now
+		if err := tx.QueryRow(`
+			INSERT INTO attachments (message_id, uploader, filename, content_type, size, hash, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+			messageID, a.Uploader, a.Filename, a.ContentType, a.Size, a.Hash, now).Scan(&a.ID); err != nil {
+			return nil, fmt.Errorf("failed to add attachment: %w", err)
+		}
+		added[i] = a
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return added, nil
+}
+
+// GetAttachments returns the attachments of the given messages, keyed by
+// message ID, in the order they were attached.
+func (r *AttachmentsRepo) GetAttachments(messageIDs []int) (map[int][]models.Attachment, error) {
+	rows, err := r.DB.Query(`
+		SELECT `+attachmentColumns+`
+		FROM attachments a
+		JOIN blobs b ON b.hash = a.hash
+		WHERE a.message_id = ANY($1)
+		ORDER BY a.message_id, a.id`, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make(map[int][]models.Attachment)
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments[a.MessageID] = append(attachments[a.MessageID], a)
+	}
+	return attachments, rows.Err()
+}
+
+func (r *AttachmentsRepo) GetAttachment(id int) (*models.Attachment, error) {
+	a, err := scanAttachment(r.DB.QueryRow(`
+		SELECT `+attachmentColumns+`
+		FROM attachments a
+		JOIN blobs b ON b.hash = a.hash
+		WHERE a.id = $1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DeleteOrphanedBlobs forgets the blobs no attachment refers to any more,
+// once their messages have been purged or rejected as spam, and returns
+// them so their content can be deleted. Blobs used since unusedSince are
+// kept: an upload may be about to attach them.
+func (r *AttachmentsRepo) DeleteOrphanedBlobs(unusedSince time.Time) ([]models.Blob, error) {
+	rows, err := r.DB.Query(`
+		DELETE FROM blobs b
+		WHERE b.last_used_at < $1
+			AND NOT EXISTS (SELECT 1 FROM attachments a WHERE a.hash = b.hash)
+			AND NOT EXISTS (SELECT 1 FROM held_attachments h WHERE h.hash = b.hash)
+		RETURNING b.hash, b.size, b.content_type, b.thumbnail_type`, unusedSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blobs []models.Blob
+	for rows.Next() {
+		var b models.Blob
+		if err := rows.Scan(&b.Hash, &b.Size, &b.ContentType, &b.ThumbnailType); err != nil {
+			return nil, err
+		}
+		blobs = append(blobs, b)
+	}
+	return blobs, rows.Err()
+}
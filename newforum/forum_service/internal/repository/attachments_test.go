@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var attachmentCols = []string{"id", "message_id", "uploader", "filename", "content_type", "size", "hash", "thumbnail_type", "created_at"}
+
+func TestAttachmentsRepo_Blobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAttachmentsRepo(db)
+
+	mock.ExpectQuery(`UPDATE blobs SET last_used_at = \$2\s+WHERE hash = \$1\s+RETURNING size, content_type, thumbnail_type`).
+		WithArgs("aa11", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"size", "content_type", "thumbnail_type"}).AddRow(5, "image/png", "image/png"))
+	mock.ExpectQuery(`UPDATE blobs SET last_used_at`).
+		WithArgs("bb22", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"size", "content_type", "thumbnail_type"}))
+	mock.ExpectExec(`INSERT INTO blobs .* ON CONFLICT \(hash\) DO UPDATE SET last_used_at`).
+		WithArgs("bb22", int64(5), "image/png", "image/png", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	blob, err := repo.TouchBlob("aa11")
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Blob{Hash: "aa11", Size: 5, ContentType: "image/png", ThumbnailType: "image/png"}, blob)
+	_, err = repo.TouchBlob("bb22")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, repo.SaveBlob(models.Blob{Hash: "bb22", Size: 5, ContentType: "image/png", ThumbnailType: "image/png"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentsRepo_AddAttachments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAttachmentsRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO attachments \(message_id, uploader, filename, content_type, size, hash, created_at\)`).
+		WithArgs(5, "bob", "a.txt", "text/plain", int64(3), "aa11", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(`INSERT INTO attachments`).
+		WithArgs(5, "bob", "b.png", "image/png", int64(9), "bb22", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectCommit()
+
+	added, err := repo.AddAttachments(5, []models.Attachment{
+		{Uploader: "bob", Filename: "a.txt", ContentType: "text/plain", Size: 3, Hash: "aa11"},
+		{Uploader: "bob", Filename: "b.png", ContentType: "image/png", Size: 9, Hash: "bb22"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, added, 2)
+	assert.Equal(t, 8, added[1].ID)
+	assert.Equal(t, 5, added[1].MessageID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentsRepo_GetAttachments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAttachmentsRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM attachments a\s+JOIN blobs b ON b.hash = a.hash\s+WHERE a.message_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int{5, 6})).
+		WillReturnRows(sqlmock.NewRows(attachmentCols).
+			AddRow(7, 5, "bob", "a.txt", "text/plain", 3, "aa11", "", now).
+			AddRow(8, 5, "bob", "b.png", "image/png", 9, "bb22", "image/png", now))
+	mock.ExpectQuery(`WHERE a.id = \$1`).
+		WithArgs(9).
+		WillReturnRows(sqlmock.NewRows(attachmentCols))
+
+	attachments, err := repo.GetAttachments([]int{5, 6})
+	assert.NoError(t, err)
+	assert.Len(t, attachments[5], 2)
+	assert.Equal(t, "image/png", attachments[5][1].ThumbnailType)
+	assert.Empty(t, attachments[6])
+
+	_, err = repo.GetAttachment(9)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentsRepo_DeleteOrphanedBlobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewAttachmentsRepo(db)
+
+	before := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`DELETE FROM blobs b\s+WHERE b.last_used_at < \$1\s+AND NOT EXISTS \(SELECT 1 FROM attachments a WHERE a.hash = b.hash\)\s+AND NOT EXISTS \(SELECT 1 FROM held_attachments h WHERE h.hash = b.hash\)`).
+		WithArgs(before).
+		WillReturnRows(sqlmock.NewRows([]string{"hash", "size", "content_type", "thumbnail_type"}).
+			AddRow("aa11", 3, "text/plain", ""))
+
+	blobs, err := repo.DeleteOrphanedBlobs(before)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Blob{{Hash: "aa11", Size: 3, ContentType: "text/plain"}}, blobs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
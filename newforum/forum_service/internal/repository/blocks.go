@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// BlocksRepository stores which users each user has blocked or ignores.
+// Blocked users cannot message or mention the blocker; messages by ignored
+// users are collapsed for the ignorer.
+type BlocksRepository interface {
+	BlockUser(blocker, blocked string) error
+	UnblockUser(blocker, blocked string) error
+	GetBlockedUsers(username string) ([]string, error)
+	GetBlockers(username string, among []string) ([]string, error)
+	IgnoreUser(ignorer, ignored string) error
+	UnignoreUser(ignorer, ignored string) error
+	GetIgnoredUsers(username string) ([]string, error)
+}
+
+type BlocksRepo struct {
+	DB *sql.DB
+}
+
+func NewBlocksRepo(db *sql.DB) *BlocksRepo {
+	return &BlocksRepo{
+		DB: db,
+	}
+}
+
+// BlockUser blocks a user. Blocking a user twice is not an error.
+func (r *BlocksRepo) BlockUser(blocker, blocked string) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO user_blocks (blocker, blocked, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (blocker, blocked) DO NOTHING`, blocker, blocked, time.Now())
+	return err
+}
+
+func (r *BlocksRepo) UnblockUser(blocker, blocked string) error {
+	result, err := r.DB.Exec(`DELETE FROM user_blocks WHERE blocker = $1 AND blocked = $2`, blocker, blocked)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetBlockedUsers lists the users username has blocked.
+func (r *BlocksRepo) GetBlockedUsers(username string) ([]string, error) {
+	return r.usernames(`SELECT blocked FROM user_blocks WHERE blocker = $1 ORDER BY blocked`, username)
+}
+
+// GetBlockers returns those of among who have blocked username.
+func (r *BlocksRepo) GetBlockers(username string, among []string) ([]string, error) {
+	if len(among) == 0 {
+		return []string{}, nil
+	}
+	return r.usernames(`
+		SELECT blocker FROM user_blocks
+		WHERE blocked = $1 AND blocker = ANY($2)
+		ORDER BY blocker`, username, pq.Array(among))
+}
+
+// IgnoreUser ignores a user. Ignoring a user twice is not an error.
+func (r *BlocksRepo) IgnoreUser(ignorer, ignored string) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO user_ignores (ignorer, ignored, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (ignorer, ignored) DO NOTHING`, ignorer, ignored, time.Now())
+	return err
+}
+
+func (r *BlocksRepo) UnignoreUser(ignorer, ignored string) error {
+	result, err := r.DB.Exec(`DELETE FROM user_ignores WHERE ignorer = $1 AND ignored = $2`, ignorer, ignored)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetIgnoredUsers lists the users username ignores.
+func (r *BlocksRepo) GetIgnoredUsers(username string) ([]string, error) {
+	return r.usernames(`SELECT ignored FROM user_ignores WHERE ignorer = $1 ORDER BY ignored`, username)
+}
+
+func (r *BlocksRepo) usernames(query string, args ...interface{}) ([]string, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
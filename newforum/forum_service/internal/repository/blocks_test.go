@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocksRepo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewBlocksRepo(db)
+
+	mock.ExpectExec(`INSERT INTO user_blocks \(blocker, blocked, created_at\)\s+VALUES \(\$1, \$2, \$3\)\s+ON CONFLICT \(blocker, blocked\) DO NOTHING`).
+		WithArgs("bob", "mallory", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.BlockUser("bob", "mallory"))
+
+	mock.ExpectQuery(`SELECT blocker FROM user_blocks\s+WHERE blocked = \$1 AND blocker = ANY\(\$2\)`).
+		WithArgs("mallory", pq.Array([]string{"alice", "bob"})).
+		WillReturnRows(sqlmock.NewRows([]string{"blocker"}).AddRow("bob"))
+	blockers, err := repo.GetBlockers("mallory", []string{"alice", "bob"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, blockers)
+
+	blockers, err = repo.GetBlockers("mallory", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, blockers)
+
+	mock.ExpectExec(`DELETE FROM user_blocks WHERE blocker = \$1 AND blocked = \$2`).
+		WithArgs("bob", "alice").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.UnblockUser("bob", "alice"), ErrNotFound)
+
+	mock.ExpectExec(`INSERT INTO user_ignores \(ignorer, ignored, created_at\)\s+VALUES \(\$1, \$2, \$3\)\s+ON CONFLICT \(ignorer, ignored\) DO NOTHING`).
+		WithArgs("bob", "mallory", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.IgnoreUser("bob", "mallory"))
+
+	mock.ExpectQuery(`SELECT ignored FROM user_ignores WHERE ignorer = \$1 ORDER BY ignored`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"ignored"}).AddRow("mallory"))
+	ignored, err := repo.GetIgnoredUsers("bob")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mallory"}, ignored)
+
+	mock.ExpectExec(`DELETE FROM user_ignores WHERE ignorer = \$1 AND ignored = \$2`).
+		WithArgs("bob", "alice").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.UnignoreUser("bob", "alice"), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
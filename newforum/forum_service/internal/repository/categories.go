@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrForumCycle = errors.New("a forum cannot be placed inside itself")
+
+const categoryColumns = `id, name, description, position, created_at`
+
+func scanCategory(row rowScanner) (models.Category, error) {
+	var c models.Category
+	err := row.Scan(&c.ID, &c.Name, &c.Description, &c.Position, &c.CreatedAt)
+	return c, err
+}
+
+func (r *ForumsRepo) GetCategories() ([]models.Category, error) {
+	rows, err := r.DB.Query(`SELECT ` + categoryColumns + ` FROM categories ORDER BY position, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// CreateCategory adds a category after the existing ones.
+func (r *ForumsRepo) CreateCategory(c models.Category) (int, error) {
+	var id int
+	err := r.DB.QueryRow(`
+		INSERT INTO categories (name, description, position)
+		SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM categories
+		RETURNING id`,
+		c.Name, c.Description).Scan(&id)
+	return id, err
+}
+
+func (r *ForumsRepo) UpdateCategory(id int, c models.Category) error {
+	result, err := r.DB.Exec(`UPDATE categories SET name = $2, description = $3 WHERE id = $1`,
+		id, c.Name, c.Description)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteCategory removes a category. Its forums stay and become
+// uncategorised.
+func (r *ForumsRepo) DeleteCategory(id int) error {
+	result, err := r.DB.Exec(`DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReorderCategories gives the listed categories positions in list order.
+func (r *ForumsRepo) ReorderCategories(ids []int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for pos, id := range ids {
+		result, err := tx.Exec(`UPDATE categories SET position = $2 WHERE id = $1`, id, pos)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+	}
+	return tx.Commit()
+}
+
+// MoveForums places the listed forums under p's category or parent forum,
+// in list order. Forums that are not listed keep their place.
+func (r *ForumsRepo) MoveForums(p models.ForumPlacement) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	categoryID := p.CategoryID
+	if p.ParentID != nil {
+		// Walk up from the new parent; meeting one of the moved forums on
+		// the way means the move would create a loop.
+		var cycles int
+		err := tx.QueryRow(`
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM forums WHERE id = $1
+				UNION
+				SELECT f.id, f.parent_id FROM forums f JOIN ancestors a ON f.id = a.parent_id
+			)
+			SELECT COUNT(*) FROM ancestors WHERE id = ANY($2)`,
+			*p.ParentID, pq.Array(p.ForumIDs)).Scan(&cycles)
+		if err != nil {
+			return err
+		}
+		if cycles > 0 {
+			return ErrForumCycle
+		}
+
+		err = tx.QueryRow(`SELECT category_id FROM forums WHERE id = $1 AND deleted_at IS NULL`, *p.ParentID).Scan(&categoryID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return err
+		}
+	}
+
+	for pos, id := range p.ForumIDs {
+		result, err := tx.Exec(`
+			UPDATE forums SET category_id = $2, parent_id = $3, position = $4
+			WHERE id = $1 AND deleted_at IS NULL`,
+			id, categoryID, p.ParentID, pos)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+	}
+	return tx.Commit()
+}
+
+// GetForumTree returns every forum arranged by category and parent, with
+// tags, message counts and the latest post of each forum. Subforums of a deleted
+// forum are shown at the top level of their category.
+func (r *ForumsRepo) GetForumTree() (*models.ForumTree, error) {
+	categories, err := r.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT f.` + strings.ReplaceAll(forumColumns, ", ", ", f.") + `,
+			COALESCE(s.message_count, 0), l.id, l.author, l.created_at,
+			ARRAY(SELECT t.name FROM forum_tags ft JOIN tags t ON t.id = ft.tag_id
+			      WHERE ft.forum_id = f.id ORDER BY t.name)
+		FROM forums f
+		LEFT JOIN (
+			SELECT forum_id, COUNT(*) AS message_count
+			FROM messages
+			WHERE deleted_at IS NULL
+			GROUP BY forum_id
+		) s ON s.forum_id = f.id
+		LEFT JOIN LATERAL (
+			SELECT id, author, created_at
+			FROM messages
+			WHERE forum_id = f.id AND deleted_at IS NULL
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		) l ON TRUE
+		WHERE f.deleted_at IS NULL
+		ORDER BY f.position, f.created_at, f.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := []*models.ForumNode{}
+	byID := map[int]*models.ForumNode{}
+	for rows.Next() {
+		var (
+			node       models.ForumNode
+			lastID     sql.NullInt64
+			lastAuthor sql.NullString
+			lastAt     sql.NullTime
+		)
+		node.Forum, err = scanForum(withColumns{rows, []interface{}{&node.MessageCount, &lastID, &lastAuthor, &lastAt, pq.Array(&node.Tags)}})
+		if err != nil {
+			return nil, err
+		}
+		if lastID.Valid {
+			node.LastPost = &models.LastPost{MessageID: int(lastID.Int64), Author: lastAuthor.String, CreatedAt: lastAt.Time}
+		}
+		node.Subforums = []*models.ForumNode{}
+		nodes = append(nodes, &node)
+		byID[node.ID] = &node
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tree := &models.ForumTree{Categories: []*models.CategoryNode{}, Uncategorized: []*models.ForumNode{}}
+	byCategory := map[int]*models.CategoryNode{}
+	for _, c := range categories {
+		cn := &models.CategoryNode{Category: c, Forums: []*models.ForumNode{}}
+		tree.Categories = append(tree.Categories, cn)
+		byCategory[c.ID] = cn
+	}
+
+	for _, node := range nodes {
+		if node.ParentID != nil {
+			if parent, ok := byID[*node.ParentID]; ok {
+				parent.Subforums = append(parent.Subforums, node)
+				continue
+			}
+		}
+		if node.CategoryID != nil {
+			if cn, ok := byCategory[*node.CategoryID]; ok {
+				cn.Forums = append(cn.Forums, node)
+				continue
+			}
+		}
+		tree.Uncategorized = append(tree.Uncategorized, node)
+	}
+	return tree, nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var categoryCols = []string{"id", "name", "description", "position", "created_at"}
+
+func TestForumsRepo_Categories(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+
+	mock.ExpectQuery(`SELECT id, name, description, position, created_at FROM categories ORDER BY position, id`).
+		WillReturnRows(sqlmock.NewRows(categoryCols).
+			AddRow(1, "Languages", "Go, Rust and friends", 0, testTime).
+			AddRow(2, "Off topic", "", 1, testTime))
+	categories, err := repo.GetCategories()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Category{
+		{ID: 1, Name: "Languages", Description: "Go, Rust and friends", Position: 0, CreatedAt: testTime},
+		{ID: 2, Name: "Off topic", Position: 1, CreatedAt: testTime},
+	}, categories)
+
+	mock.ExpectQuery(`INSERT INTO categories \(name, description, position\)\s+SELECT \$1, \$2, COALESCE\(MAX\(position\) \+ 1, 0\) FROM categories`).
+		WithArgs("Jobs", "Vacancies").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	id, err := repo.CreateCategory(models.Category{Name: "Jobs", Description: "Vacancies"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+
+	mock.ExpectExec(`UPDATE categories SET name = \$2, description = \$3 WHERE id = \$1`).
+		WithArgs(9, "Jobs", "").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.UpdateCategory(9, models.Category{Name: "Jobs"}), ErrNotFound)
+
+	mock.ExpectExec(`DELETE FROM categories WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.DeleteCategory(3))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE categories SET position = \$2 WHERE id = \$1`).
+		WithArgs(2, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE categories SET position = \$2 WHERE id = \$1`).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.ReorderCategories([]int{2, 1}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE categories SET position`).
+		WithArgs(7, 0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.ReorderCategories([]int{7}), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_MoveForums(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	categoryID, parentID := 1, 4
+
+	t.Run("Into Category", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE forums SET category_id = \$2, parent_id = \$3, position = \$4\s+WHERE id = \$1 AND deleted_at IS NULL`).
+			WithArgs(5, &categoryID, nil, 0).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE forums SET category_id`).
+			WithArgs(3, &categoryID, nil, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.MoveForums(models.ForumPlacement{CategoryID: &categoryID, ForumIDs: []int{5, 3}}))
+	})
+
+	t.Run("Into Parent", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`WITH RECURSIVE ancestors`).
+			WithArgs(4, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(`SELECT category_id FROM forums WHERE id = \$1`).
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"category_id"}).AddRow(2))
+		mock.ExpectExec(`UPDATE forums SET category_id`).
+			WithArgs(5, 2, &parentID, 0).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.MoveForums(models.ForumPlacement{ParentID: &parentID, ForumIDs: []int{5}}))
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`WITH RECURSIVE ancestors`).
+			WithArgs(4, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.MoveForums(models.ForumPlacement{ParentID: &parentID, ForumIDs: []int{1}}), ErrForumCycle)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_GetForumTree(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	treeCols := append(append([]string{}, forumCols...), "message_count", "last_id", "last_author", "last_created_at", "tags")
+
+	mock.ExpectQuery(`FROM categories`).
+		WillReturnRows(sqlmock.NewRows(categoryCols).AddRow(1, "Languages", "", 0, testTime))
+	mock.ExpectQuery(`SELECT f.id, f.name(.|\n)*LEFT JOIN LATERAL(.|\n)*WHERE f.deleted_at IS NULL\s+ORDER BY f.position, f.created_at, f.id`).
+		WillReturnRows(sqlmock.NewRows(treeCols).
+			AddRow(1, "Go", "", testTime, false, false, nil, 1, nil, 0, 0, 12, 40, "gopher", testTime, "{golang,backend}").
+			AddRow(2, "Generics", "", testTime, false, false, nil, 1, 1, 0, 0, 3, 41, "rob", testTime, "{}").
+			AddRow(3, "Misc", "", testTime, false, false, nil, nil, nil, 0, 0, 0, nil, nil, nil, "{}").
+			AddRow(4, "Orphan", "", testTime, false, false, nil, 9, nil, 1, 0, 0, nil, nil, nil, "{}"))
+
+	tree, err := repo.GetForumTree()
+	assert.NoError(t, err)
+	if assert.Len(t, tree.Categories, 1) && assert.Len(t, tree.Categories[0].Forums, 1) {
+		golang := tree.Categories[0].Forums[0]
+		assert.Equal(t, "Go", golang.Title)
+		assert.Equal(t, 12, golang.MessageCount)
+		assert.Equal(t, []string{"golang", "backend"}, golang.Tags)
+		assert.Equal(t, &models.LastPost{MessageID: 40, Author: "gopher", CreatedAt: testTime}, golang.LastPost)
+		if assert.Len(t, golang.Subforums, 1) {
+			assert.Equal(t, "Generics", golang.Subforums[0].Title)
+		}
+	}
+	if assert.Len(t, tree.Uncategorized, 2) {
+		assert.Equal(t, "Misc", tree.Uncategorized[0].Title)
+		assert.Nil(t, tree.Uncategorized[0].LastPost)
+		assert.Equal(t, "Orphan", tree.Uncategorized[1].Title)
+	}
+
+	mock.ExpectQuery(`FROM categories`).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.GetForumTree()
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
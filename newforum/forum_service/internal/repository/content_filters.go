@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrFilterExists = errors.New("filter already exists")
+
+// ContentFiltersRepository stores the banned words and link rules of each
+// forum and the site-wide ones, which have no forum.
+type ContentFiltersRepository interface {
+	GetContentFilters(forumID *int) (*models.ContentFilters, error)
+	AddWordFilter(f models.WordFilter) (*models.WordFilter, error)
+	DeleteWordFilter(forumID *int, id int) (*models.WordFilter, error)
+	AddLinkRule(rule models.LinkRule) (*models.LinkRule, error)
+	DeleteLinkRule(forumID *int, id int) (*models.LinkRule, error)
+}
+
+const (
+	wordFilterColumns = `id, forum_id, pattern, action, created_by, created_at`
+	linkRuleColumns   = `id, forum_id, domain, policy, created_by, created_at`
+)
+
+type ContentFiltersRepo struct {
+	DB *sql.DB
+}
+
+func NewContentFiltersRepo(db *sql.DB) *ContentFiltersRepo {
+	return &ContentFiltersRepo{
+		DB: db,
+	}
+}
+
+func scanWordFilter(row rowScanner) (models.WordFilter, error) {
+	var f models.WordFilter
+	err := row.Scan(&f.ID, &f.ForumID, &f.Pattern, &f.Action, &f.CreatedBy, &f.CreatedAt)
+	return f, err
+}
+
+func scanLinkRule(row rowScanner) (models.LinkRule, error) {
+	var rule models.LinkRule
+	err := row.Scan(&rule.ID, &rule.ForumID, &rule.Domain, &rule.Policy, &rule.CreatedBy, &rule.CreatedAt)
+	return rule, err
+}
+
+// GetContentFilters returns the site-wide rules and, unless forumID is
+// nil, the rules of that forum.
+func (r *ContentFiltersRepo) GetContentFilters(forumID *int) (*models.ContentFilters, error) {
+	filters := &models.ContentFilters{Words: []models.WordFilter{}, Links: []models.LinkRule{}}
+
+	rows, err := r.DB.Query(`
+		SELECT `+wordFilterColumns+`
+		FROM word_filters
+		WHERE forum_id IS NULL OR forum_id = $1
+		ORDER BY forum_id NULLS FIRST, pattern`, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		f, err := scanWordFilter(rows)
+		if err != nil {
+			return nil, err
+		}
+		filters.Words = append(filters.Words, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.DB.Query(`
+		SELECT `+linkRuleColumns+`
+		FROM link_rules
+		WHERE forum_id IS NULL OR forum_id = $1
+		ORDER BY forum_id NULLS FIRST, domain`, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		rule, err := scanLinkRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		filters.Links = append(filters.Links, rule)
+	}
+	return filters, rows.Err()
+}
+
+func (r *ContentFiltersRepo) AddWordFilter(f models.WordFilter) (*models.WordFilter, error) {
+	created, err := scanWordFilter(r.DB.QueryRow(`
+		INSERT INTO word_filters (forum_id, pattern, action, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+wordFilterColumns,
+		f.ForumID, f.Pattern, f.Action, f.CreatedBy, time.Now()))
+	if err != nil {
+		return nil, filterError(err)
+	}
+	return &created, nil
+}
+
+// DeleteWordFilter deletes a filter of the forum, or a site-wide one when
+// forumID is nil, and returns it.
+func (r *ContentFiltersRepo) DeleteWordFilter(forumID *int, id int) (*models.WordFilter, error) {
+	deleted, err := scanWordFilter(r.DB.QueryRow(`
+		DELETE FROM word_filters
+		WHERE id = $1 AND forum_id IS NOT DISTINCT FROM $2
+		RETURNING `+wordFilterColumns, id, forumID))
+	if err != nil {
+		return nil, filterError(err)
+	}
+	return &deleted, nil
+}
+
+func (r *ContentFiltersRepo) AddLinkRule(rule models.LinkRule) (*models.LinkRule, error) {
+	created, err := scanLinkRule(r.DB.QueryRow(`
+		INSERT INTO link_rules (forum_id, domain, policy, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+linkRuleColumns,
+		rule.ForumID, rule.Domain, rule.Policy, rule.CreatedBy, time.Now()))
+	if err != nil {
+		return nil, filterError(err)
+	}
+	return &created, nil
+}
+
+// DeleteLinkRule deletes a rule of the forum, or a site-wide one when
+// forumID is nil, and returns it.
+func (r *ContentFiltersRepo) DeleteLinkRule(forumID *int, id int) (*models.LinkRule, error) {
+	deleted, err := scanLinkRule(r.DB.QueryRow(`
+		DELETE FROM link_rules
+		WHERE id = $1 AND forum_id IS NOT DISTINCT FROM $2
+		RETURNING `+linkRuleColumns, id, forumID))
+	if err != nil {
+		return nil, filterError(err)
+	}
+	return &deleted, nil
+}
+
+func filterError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrFilterExists
+	case errors.As(err, &pqErr) && pqErr.Code == "23503":
+		// The forum does not exist.
+		return ErrNotFound
+	}
+	return err
+}
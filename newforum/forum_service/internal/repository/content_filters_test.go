@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	wordFilterCols = []string{"id", "forum_id", "pattern", "action", "created_by", "created_at"}
+	linkRuleCols   = []string{"id", "forum_id", "domain", "policy", "created_by", "created_at"}
+)
+
+func TestContentFiltersRepo_GetContentFilters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewContentFiltersRepo(db)
+
+	now := time.Now()
+	forumID := 5
+	mock.ExpectQuery(`FROM word_filters\s+WHERE forum_id IS NULL OR forum_id = \$1\s+ORDER BY forum_id NULLS FIRST, pattern`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(wordFilterCols).
+			AddRow(1, nil, "spam*", "block", "admin", now).
+			AddRow(2, 5, "darn", "mask", "mod", now))
+	mock.ExpectQuery(`FROM link_rules\s+WHERE forum_id IS NULL OR forum_id = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(linkRuleCols).
+			AddRow(3, 5, "example.com", "allow", "mod", now))
+	mock.ExpectQuery(`FROM word_filters`).
+		WithArgs(nil).
+		WillReturnRows(sqlmock.NewRows(wordFilterCols))
+	mock.ExpectQuery(`FROM link_rules`).
+		WithArgs(nil).
+		WillReturnRows(sqlmock.NewRows(linkRuleCols))
+
+	filters, err := repo.GetContentFilters(&forumID)
+	assert.NoError(t, err)
+	assert.Len(t, filters.Words, 2)
+	assert.Nil(t, filters.Words[0].ForumID)
+	assert.Equal(t, &forumID, filters.Words[1].ForumID)
+	assert.Equal(t, models.FilterMask, filters.Words[1].Action)
+	assert.Equal(t, "example.com", filters.Links[0].Domain)
+
+	filters, err = repo.GetContentFilters(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.ContentFilters{Words: []models.WordFilter{}, Links: []models.LinkRule{}}, filters)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestContentFiltersRepo_AddAndDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewContentFiltersRepo(db)
+
+	now := time.Now()
+	forumID := 5
+	mock.ExpectQuery(`INSERT INTO word_filters \(forum_id, pattern, action, created_by, created_at\)`).
+		WithArgs(5, "darn", "mask", "mod", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(wordFilterCols).AddRow(2, 5, "darn", "mask", "mod", now))
+	mock.ExpectQuery(`INSERT INTO word_filters`).
+		WithArgs(5, "darn", "block", "mod", sqlmock.AnyArg()).
+		WillReturnError(&pq.Error{Code: "23505"})
+	mock.ExpectQuery(`DELETE FROM word_filters\s+WHERE id = \$1 AND forum_id IS NOT DISTINCT FROM \$2`).
+		WithArgs(2, nil).
+		WillReturnRows(sqlmock.NewRows(wordFilterCols))
+	mock.ExpectQuery(`INSERT INTO link_rules \(forum_id, domain, policy, created_by, created_at\)`).
+		WithArgs(99, "evil.com", "deny", "mod", sqlmock.AnyArg()).
+		WillReturnError(&pq.Error{Code: "23503"})
+	mock.ExpectQuery(`DELETE FROM link_rules\s+WHERE id = \$1 AND forum_id IS NOT DISTINCT FROM \$2`).
+		WithArgs(3, 5).
+		WillReturnRows(sqlmock.NewRows(linkRuleCols).AddRow(3, 5, "example.com", "allow", "mod", now))
+
+	f, err := repo.AddWordFilter(models.WordFilter{ForumID: &forumID, Pattern: "darn", Action: "mask", CreatedBy: "mod"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, f.ID)
+	_, err = repo.AddWordFilter(models.WordFilter{ForumID: &forumID, Pattern: "darn", Action: "block", CreatedBy: "mod"})
+	assert.ErrorIs(t, err, ErrFilterExists)
+	// A site-wide filter cannot be deleted through a forum and vice versa.
+	_, err = repo.DeleteWordFilter(nil, 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	missing := 99
+	_, err = repo.AddLinkRule(models.LinkRule{ForumID: &missing, Domain: "evil.com", Policy: "deny", CreatedBy: "mod"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	rule, err := repo.DeleteLinkRule(&forumID, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", rule.Domain)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+// ConversationsRepository stores private conversations, who takes part in
+// them and how far each participant has read.
+type ConversationsRepository interface {
+	CreateConversation(creator, title string, participants []string) (*models.Conversation, error)
+	FindDirectConversation(username, other string) (*models.Conversation, error)
+	GetConversations(username string) ([]models.Conversation, error)
+	GetConversation(id int, username string) (*models.Conversation, error)
+	GetConversationMessages(id int, page models.PageRequest) (*models.DirectMessagePage, error)
+	AddConversationMessage(m models.DirectMessage) (*models.DirectMessage, error)
+	MarkConversationRead(id int, username string, messageID int) (int, error)
+	CountUnreadDirectMessages(username string) (int, error)
+}
+
+// conversationQuery selects the conversations of the participant given as
+// $1, with how many messages by others they have not read yet.
+const conversationQuery = `
+	SELECT c.id, c.title, c.created_by, c.created_at, c.updated_at, p.last_read_message_id,
+		(SELECT COUNT(*) FROM conversation_messages m
+			WHERE m.conversation_id = c.id AND m.id > p.last_read_message_id AND m.author <> p.username)
+	FROM conversations c
+	JOIN conversation_participants p ON p.conversation_id = c.id AND p.username = $1`
+
+const directMessageColumns = `id, conversation_id, author, content, created_at`
+
+type ConversationsRepo struct {
+	DB *sql.DB
+}
+
+func NewConversationsRepo(db *sql.DB) *ConversationsRepo {
+	return &ConversationsRepo{
+		DB: db,
+	}
+}
+
+func scanDirectMessage(row rowScanner) (models.DirectMessage, error) {
+	var m models.DirectMessage
+	err := row.Scan(&m.ID, &m.ConversationID, &m.Author, &m.Content, &m.CreatedAt)
+	return m, err
+}
+
+// CreateConversation starts a conversation between participants, which
+// must include creator, and returns it as creator sees it.
+func (r *ConversationsRepo) CreateConversation(creator, title string, participants []string) (*models.Conversation, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	c := models.Conversation{Title: title, CreatedBy: creator, CreatedAt: now, UpdatedAt: now}
+	if err := tx.QueryRow(`
+		INSERT INTO conversations (title, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $3)
+		RETURNING id`, title, creator, now).Scan(&c.ID); err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO conversation_participants (conversation_id, username, joined_at)
+		SELECT $1, unnest($2::varchar[]), $3`, c.ID, pq.Array(participants), now); err != nil {
+		return nil, fmt.Errorf("failed to add participants: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	c.Participants = participants
+	return &c, nil
+}
+
+// FindDirectConversation returns the one-to-one conversation between two
+// users, or ErrNotFound if they have none yet.
+func (r *ConversationsRepo) FindDirectConversation(username, other string) (*models.Conversation, error) {
+	var id int
+	err := r.DB.QueryRow(`
+		SELECT c.id
+		FROM conversations c
+		JOIN conversation_participants a ON a.conversation_id = c.id AND a.username = $1
+		JOIN conversation_participants b ON b.conversation_id = c.id AND b.username = $2
+		WHERE c.title = ''
+			AND (SELECT COUNT(*) FROM conversation_participants p WHERE p.conversation_id = c.id) = 2
+		ORDER BY c.id
+		LIMIT 1`, username, other).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return r.GetConversation(id, username)
+}
+
+// GetConversations lists the conversations username takes part in, the
+// most recently active first.
+func (r *ConversationsRepo) GetConversations(username string) ([]models.Conversation, error) {
+	return r.conversations(conversationQuery+`
+		ORDER BY c.updated_at DESC, c.id DESC`, username)
+}
+
+// GetConversation returns a conversation as username sees it. It returns
+// ErrNotFound both when there is no such conversation and when username
+// does not take part in it, so outsiders cannot tell the two apart.
+func (r *ConversationsRepo) GetConversation(id int, username string) (*models.Conversation, error) {
+	conversations, err := r.conversations(conversationQuery+`
+		WHERE c.id = $2`, username, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return nil, ErrNotFound
+	}
+	return &conversations[0], nil
+}
+
+// conversations runs a conversationQuery and fills in the participants and
+// last message of each conversation found.
+func (r *ConversationsRepo) conversations(query string, args ...interface{}) ([]models.Conversation, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversations: %w", err)
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		var c models.Conversation
+		if err := rows.Scan(&c.ID, &c.Title, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt,
+			&c.LastReadMessageID, &c.Unread); err != nil {
+			return nil, err
+		}
+		c.Participants = []string{}
+		conversations = append(conversations, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return conversations, nil
+	}
+
+	ids := make([]int, len(conversations))
+	byID := make(map[int]*models.Conversation, len(conversations))
+	for i := range conversations {
+		ids[i] = conversations[i].ID
+		byID[conversations[i].ID] = &conversations[i]
+	}
+
+	participants, err := r.DB.Query(`
+		SELECT conversation_id, username
+		FROM conversation_participants
+		WHERE conversation_id = ANY($1)
+		ORDER BY username`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read participants: %w", err)
+	}
+	defer participants.Close()
+	for participants.Next() {
+		var id int
+		var username string
+		if err := participants.Scan(&id, &username); err != nil {
+			return nil, err
+		}
+		byID[id].Participants = append(byID[id].Participants, username)
+	}
+	if err := participants.Err(); err != nil {
+		return nil, err
+	}
+
+	last, err := r.DB.Query(`
+		SELECT DISTINCT ON (conversation_id) `+directMessageColumns+`
+		FROM conversation_messages
+		WHERE conversation_id = ANY($1)
+		ORDER BY conversation_id, id DESC`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last messages: %w", err)
+	}
+	defer last.Close()
+	for last.Next() {
+		m, err := scanDirectMessage(last)
+		if err != nil {
+			return nil, err
+		}
+		byID[m.ConversationID].LastMessage = &m
+	}
+	return conversations, last.Err()
+}
+
+// GetConversationMessages returns one page of a conversation. Without a
+// cursor the newest messages are returned. Messages are always ordered
+// oldest first. Callers check that the reader takes part in it.
+func (r *ConversationsRepo) GetConversationMessages(id int, page models.PageRequest) (*models.DirectMessagePage, error) {
+	conds := []string{"conversation_id = $1"}
+	args := []interface{}{id}
+	cond, condArgs, desc := keyset(page, len(args)+1, true)
+	if cond != "" {
+		conds = append(conds, cond)
+	}
+	args = append(args, condArgs...)
+	args = append(args, page.Limit+1)
+
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM conversation_messages
+		%s
+		%s
+		LIMIT $%d`, directMessageColumns, whereClause(conds), orderBy(desc), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversation messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []models.DirectMessage{}
+	for rows.Next() {
+		m, err := scanDirectMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := len(messages) > page.Limit
+	if hasMore {
+		messages = messages[:page.Limit]
+	}
+	if desc {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	result := &models.DirectMessagePage{Messages: messages}
+	if n := len(messages); n > 0 {
+		first := models.Cursor{CreatedAt: messages[0].CreatedAt, ID: messages[0].ID}
+		last := models.Cursor{CreatedAt: messages[n-1].CreatedAt, ID: messages[n-1].ID}
+		result.Prev, result.Next = pageCursors(page, desc, hasMore, first, last)
+	}
+	return result, nil
+}
+
+// AddConversationMessage stores a message, moves the conversation to the
+// top of its participants' lists and marks it read for the author.
+func (r *ConversationsRepo) AddConversationMessage(m models.DirectMessage) (*models.DirectMessage, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	created, err := scanDirectMessage(tx.QueryRow(`
+		INSERT INTO conversation_messages (conversation_id, author, content, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+directMessageColumns, m.ConversationID, m.Author, m.Content, m.CreatedAt))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to create message: %w", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE conversations SET updated_at = $2 WHERE id = $1`,
+		created.ConversationID, created.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %w", err)
+	}
+	if _, err := tx.Exec(`
+		UPDATE conversation_participants SET last_read_message_id = $3
+		WHERE conversation_id = $1 AND username = $2`,
+		created.ConversationID, created.Author, created.ID); err != nil {
+		return nil, fmt.Errorf("failed to update read position: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &created, nil
+}
+
+// MarkConversationRead marks a conversation read up to messageID for one of
+// its participants and returns the resulting position, which never moves
+// back.
+func (r *ConversationsRepo) MarkConversationRead(id int, username string, messageID int) (int, error) {
+	var lastRead int
+	err := r.DB.QueryRow(`
+		UPDATE conversation_participants
+		SET last_read_message_id = GREATEST(last_read_message_id, $3)
+		WHERE conversation_id = $1 AND username = $2
+		RETURNING last_read_message_id`, id, username, messageID).Scan(&lastRead)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return lastRead, err
+}
+
+// CountUnreadDirectMessages returns how many messages by others username
+// has not read across all of their conversations.
+func (r *ConversationsRepo) CountUnreadDirectMessages(username string) (int, error) {
+	var count int
+	err := r.DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM conversation_messages m
+		JOIN conversation_participants p ON p.conversation_id = m.conversation_id AND p.username = $1
+		WHERE m.id > p.last_read_message_id AND m.author <> $1`, username).Scan(&count)
+	return count, err
+}
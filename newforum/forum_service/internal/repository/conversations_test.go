@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var conversationCols = []string{"id", "title", "created_by", "created_at", "updated_at", "last_read_message_id", "unread"}
+
+func TestConversationsRepo_CreateConversation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewConversationsRepo(db)
+
+	participants := []string{"alice", "bob"}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO conversations \(title, created_by, created_at, updated_at\)`).
+		WithArgs("", "alice", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`INSERT INTO conversation_participants \(conversation_id, username, joined_at\)\s+SELECT \$1, unnest\(\$2::varchar\[\]\), \$3`).
+		WithArgs(3, pq.Array(participants), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	c, err := repo.CreateConversation("alice", "", participants)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, c.ID)
+	assert.Equal(t, participants, c.Participants)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationsRepo_GetConversation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewConversationsRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM conversations c\s+JOIN conversation_participants p ON p.conversation_id = c.id AND p.username = \$1\s+WHERE c.id = \$2`).
+		WithArgs("bob", 3).
+		WillReturnRows(sqlmock.NewRows(conversationCols).AddRow(3, "", "alice", now, now, 4, 1))
+	mock.ExpectQuery(`SELECT conversation_id, username\s+FROM conversation_participants\s+WHERE conversation_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int{3})).
+		WillReturnRows(sqlmock.NewRows([]string{"conversation_id", "username"}).AddRow(3, "alice").AddRow(3, "bob"))
+	mock.ExpectQuery(`SELECT DISTINCT ON \(conversation_id\) id, conversation_id, author, content, created_at\s+FROM conversation_messages`).
+		WithArgs(pq.Array([]int{3})).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "conversation_id", "author", "content", "created_at"}).
+			AddRow(5, 3, "alice", "hello", now))
+
+	c, err := repo.GetConversation(3, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, c.Participants)
+	assert.Equal(t, 1, c.Unread)
+	assert.Equal(t, 5, c.LastMessage.ID)
+
+	// Users outside the conversation are not told it exists.
+	mock.ExpectQuery(`FROM conversations c\s+JOIN conversation_participants p`).
+		WithArgs("mallory", 3).
+		WillReturnRows(sqlmock.NewRows(conversationCols))
+	_, err = repo.GetConversation(3, "mallory")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationsRepo_AddConversationMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewConversationsRepo(db)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO conversation_messages \(conversation_id, author, content, created_at\)`).
+		WithArgs(3, "alice", "hi", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "conversation_id", "author", "content", "created_at"}).
+			AddRow(6, 3, "alice", "hi", now))
+	mock.ExpectExec(`UPDATE conversations SET updated_at = \$2 WHERE id = \$1`).
+		WithArgs(3, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE conversation_participants SET last_read_message_id = \$3\s+WHERE conversation_id = \$1 AND username = \$2`).
+		WithArgs(3, "alice", 6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	msg, err := repo.AddConversationMessage(models.DirectMessage{ConversationID: 3, Author: "alice", Content: "hi", CreatedAt: now})
+	assert.NoError(t, err)
+	assert.Equal(t, 6, msg.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConversationsRepo_MarkConversationRead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewConversationsRepo(db)
+
+	mock.ExpectQuery(`UPDATE conversation_participants\s+SET last_read_message_id = GREATEST\(last_read_message_id, \$3\)`).
+		WithArgs(3, "bob", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id"}).AddRow(7))
+	lastRead, err := repo.MarkConversationRead(3, "bob", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, lastRead)
+
+	mock.ExpectQuery(`UPDATE conversation_participants`).
+		WithArgs(3, "mallory", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id"}))
+	_, err = repo.MarkConversationRead(3, "mallory", 5)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\)\s+FROM conversation_messages m\s+JOIN conversation_participants p`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	count, err := repo.CountUnreadDirectMessages("bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
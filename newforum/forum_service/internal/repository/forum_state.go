@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+// GetPinnedForums returns the pinned forums, oldest first.
+func (r *ForumsRepo) GetPinnedForums() ([]models.Forum, error) {
+	rows, err := r.DB.Query(`
+		SELECT ` + forumColumns + `
+		FROM forums
+		WHERE pinned AND deleted_at IS NULL
+		ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := []models.Forum{}
+	for rows.Next() {
+		f, err := scanForum(rows)
+		if err != nil {
+			return nil, err
+		}
+		forums = append(forums, f)
+	}
+	return forums, rows.Err()
+}
+
+// SetForumState applies the non-nil fields of change and returns the updated
+// forum. Archiving an already archived forum keeps its original archive time;
+// unarchiving records when the forum was brought back so the archiver gives
+// it a full inactivity period again.
+func (r *ForumsRepo) SetForumState(id int, change models.ForumStateChange) (*models.Forum, error) {
+	sets := []string{}
+	args := []interface{}{id}
+	if change.Locked != nil {
+		args = append(args, *change.Locked)
+		sets = append(sets, fmt.Sprintf("locked = $%d", len(args)))
+	}
+	if change.Pinned != nil {
+		args = append(args, *change.Pinned)
+		sets = append(sets, fmt.Sprintf("pinned = $%d", len(args)))
+	}
+	if change.Archived != nil {
+		if *change.Archived {
+			args = append(args, time.Now())
+			sets = append(sets, fmt.Sprintf("archived_at = COALESCE(archived_at, $%d)", len(args)))
+		} else {
+			args = append(args, time.Now())
+			sets = append(sets, "archived_at = NULL",
+				fmt.Sprintf("unarchived_at = CASE WHEN archived_at IS NULL THEN unarchived_at ELSE $%d END", len(args)))
+		}
+	}
+	if change.SlowMode != nil {
+		args = append(args, *change.SlowMode)
+		sets = append(sets, fmt.Sprintf("slow_mode = $%d", len(args)))
+	}
+	if len(sets) == 0 {
+		return nil, errors.New("no forum state changes given")
+	}
+
+	f, err := scanForum(r.DB.QueryRow(fmt.Sprintf(`
+		UPDATE forums SET %s
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING %s`, strings.Join(sets, ", "), forumColumns), args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ArchiveInactiveForums archives every forum whose latest message, creation
+// and last unarchiving are all older than before. Pinned forums are never
+// archived. It returns the IDs of the forums it archived.
+func (r *ForumsRepo) ArchiveInactiveForums(before time.Time) ([]int, error) {
+	rows, err := r.DB.Query(`
+		UPDATE forums f SET archived_at = $2
+		WHERE f.archived_at IS NULL AND f.deleted_at IS NULL AND NOT f.pinned
+			AND GREATEST(
+				(SELECT MAX(m.created_at) FROM messages m WHERE m.forum_id = f.id AND m.deleted_at IS NULL),
+				f.unarchived_at, f.created_at) < $1
+		RETURNING f.id`, before, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
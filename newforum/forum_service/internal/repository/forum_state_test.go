@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_GetPinnedForums(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+
+	mock.ExpectQuery(`FROM forums\s+WHERE pinned AND deleted_at IS NULL\s+ORDER BY created_at, id`).
+		WillReturnRows(sqlmock.NewRows(forumCols).AddRow(1, "Rules", "Read first", testTime, true, true, nil, nil, nil, 0, 0))
+	got, err := repo.GetPinnedForums()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Forum{{ID: 1, Title: "Rules", Description: "Read first", CreatedAt: testTime, Locked: true, Pinned: true}}, got)
+
+	mock.ExpectQuery(`FROM forums`).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.GetPinnedForums()
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_SetForumState(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	yes, no := true, false
+
+	t.Run("Lock And Archive", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE forums SET locked = \$2, archived_at = COALESCE\(archived_at, \$3\)\s+WHERE id = \$1 AND deleted_at IS NULL\s+RETURNING id, name`).
+			WithArgs(1, true, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows(forumCols).AddRow(1, "Forum", "Desc", testTime, true, false, testTime, nil, nil, 0, 0))
+
+		got, err := repo.SetForumState(1, models.ForumStateChange{Locked: &yes, Archived: &yes})
+		assert.NoError(t, err)
+		assert.True(t, got.Locked)
+		assert.True(t, got.ReadOnly())
+	})
+
+	t.Run("Unpin And Unarchive", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE forums SET pinned = \$2, archived_at = NULL, unarchived_at = CASE WHEN archived_at IS NULL THEN unarchived_at ELSE \$3 END\s+WHERE id = \$1`).
+			WithArgs(1, false, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows(forumCols).AddRow(1, "Forum", "Desc", testTime, false, false, nil, nil, nil, 0, 0))
+
+		got, err := repo.SetForumState(1, models.ForumStateChange{Pinned: &no, Archived: &no})
+		assert.NoError(t, err)
+		assert.False(t, got.ReadOnly())
+	})
+
+	t.Run("Slow Mode", func(t *testing.T) {
+		seconds := 30
+		mock.ExpectQuery(`UPDATE forums SET slow_mode = \$2\s+WHERE id = \$1`).
+			WithArgs(1, 30).
+			WillReturnRows(sqlmock.NewRows(forumCols).AddRow(1, "Forum", "Desc", testTime, false, false, nil, nil, nil, 0, 30))
+
+		got, err := repo.SetForumState(1, models.ForumStateChange{SlowMode: &seconds})
+		assert.NoError(t, err)
+		assert.Equal(t, 30, got.SlowMode)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mock.ExpectQuery(`UPDATE forums SET locked = \$2`).
+			WithArgs(999, true).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.SetForumState(999, models.ForumStateChange{Locked: &yes})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("No Changes", func(t *testing.T) {
+		_, err := repo.SetForumState(1, models.ForumStateChange{})
+		assert.Error(t, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_ArchiveInactiveForums(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	before := time.Now().Add(-90 * 24 * time.Hour)
+
+	mock.ExpectQuery(`UPDATE forums f SET archived_at = \$2\s+WHERE f.archived_at IS NULL AND f.deleted_at IS NULL AND NOT f.pinned\s+AND GREATEST\(.*f.unarchived_at, f.created_at\) < \$1`).
+		WithArgs(before, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(7))
+	ids, err := repo.ArchiveInactiveForums(before)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3, 7}, ids)
+
+	mock.ExpectQuery(`UPDATE forums f`).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.ArchiveInactiveForums(before)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
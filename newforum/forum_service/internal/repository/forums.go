@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jaxxiy/newforum/core/logger"
+	"github.com/jaxxiy/newforum/forum_service/internal/markdown"
 	"github.com/jaxxiy/newforum/forum_service/internal/models"
 )
 
@@ -17,15 +19,92 @@ type ForumsRepository interface {
 	GetByID(id int) (*models.Forum, error)
 	Create(forum models.Forum) (int, error)
 	Update(id int, forum models.Forum) error
-	Delete(id int) error
+	Delete(id int, deletedBy string) error
 	GetMessages(forumID int) ([]models.Message, error)
 	CreateMessage(msg models.Message) (int, error)
 	GetMessageByID(id int) (*models.Message, error)
-	PutMessage(id int, content string) (*models.Message, error)
-	DeleteMessage(id int) error
+	PutMessage(id int, content, editedBy string) (*models.Message, error)
+	DeleteMessage(id int, deletedBy string) error
 	CreateGlobalMessage(msg models.GlobalMessage) (int, error)
 	GetGlobalChatHistory(limit int) ([]models.GlobalMessage, error)
 	GetUserByID(id int) (*models.User, error)
+	GetTopics(forumID int) ([]models.Topic, error)
+	GetTopicByID(id int) (*models.Topic, error)
+	CreateTopic(topic models.Topic) (int, error)
+	UpdateTopic(id int, topic models.Topic) error
+	DeleteTopic(id int, deletedBy string) error
+	GetMessageThread(messageID int) ([]models.Message, error)
+	GetMessagesPage(forumID int, topicID *int, page models.PageRequest) (*models.MessagePage, error)
+	GetForumsPage(page models.PageRequest) (*models.ForumPage, error)
+	GetMessageRevisions(messageID int) ([]models.MessageRevision, error)
+	Restore(id int) error
+	RestoreMessage(id int) (*models.Message, error)
+	RestoreTopic(id int) (*models.Topic, error)
+	GetDeletedForums() ([]models.Forum, error)
+	GetDeletedTopics() ([]models.Topic, error)
+	GetDeletedMessages() ([]models.Message, error)
+	PurgeDeleted(before time.Time) (int64, error)
+	GetPinnedForums() ([]models.Forum, error)
+	SetForumState(id int, change models.ForumStateChange) (*models.Forum, error)
+	ArchiveInactiveForums(before time.Time) ([]int, error)
+	PinMessage(messageID int, pinnedBy string, announcement bool, limit int) (*models.Message, error)
+	UnpinMessage(messageID int) (*models.Message, error)
+	GetPinnedMessages(forumID int, topicID *int) ([]models.Message, error)
+	GetCategories() ([]models.Category, error)
+	CreateCategory(c models.Category) (int, error)
+	UpdateCategory(id int, c models.Category) error
+	DeleteCategory(id int) error
+	ReorderCategories(ids []int) error
+	MoveForums(p models.ForumPlacement) error
+	GetForumTree() (*models.ForumTree, error)
+	SearchTags(prefix string, limit int) ([]models.Tag, error)
+	GetTag(name string) (*models.Tag, error)
+	GetForumTags(forumID int) ([]string, error)
+	GetTopicTags(topicID int) ([]string, error)
+	SetForumTags(forumID int, tags []string) error
+	SetTopicTags(topicID int, tags []string) error
+	GetTaggedForumsPage(tag string, page models.PageRequest) (*models.ForumPage, error)
+	GetTaggedTopics(tag string) ([]models.Topic, error)
+	RenameTag(name, newName string) error
+	MergeTags(source, target string) error
+	AddReaction(messageID int, username, emoji string, limit int) (int, error)
+	RemoveReaction(messageID int, username, emoji string) (int, error)
+	GetReactions(messageIDs []int, username string) (map[int][]models.ReactionCount, error)
+	ResolveUsernames(names []string) ([]string, error)
+	SearchUsernames(prefix string, limit int) ([]string, error)
+	SetMessageMentions(messageID int, usernames []string) ([]string, error)
+	GetMentions(messageIDs []int) (map[int][]string, error)
+	GetUnreadCounts(username string, forumIDs []int) (map[int]int, error)
+	GetReadPosition(username string, forumID int) (int, error)
+	AdvanceReadPosition(username string, forumID, messageID int) (int, error)
+}
+
+// forumColumns is the column list read by scanForum.
+const forumColumns = `id, name, description, created_at, locked, pinned, archived_at, category_id, parent_id, position, slow_mode`
+
+// messageColumns is the column list read by scanMessage.
+const messageColumns = `id, forum_id, author, content, created_at, topic_id, reply_to, quote, edited_at, edit_count, deleted_at, deleted_by, hidden, pinned_at, pinned_by, announcement, content_html`
+
+// qualifiedMessageColumns is messageColumns prefixed with the "m" table alias
+// for queries that join messages to other tables.
+var qualifiedMessageColumns = "m." + strings.ReplaceAll(messageColumns, ", ", ", m.")
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row rowScanner) (models.Message, error) {
+	var m models.Message
+	err := row.Scan(&m.ID, &m.ForumID, &m.Author, &m.Content, &m.CreatedAt, &m.TopicID, &m.ReplyTo, &m.Quote,
+		&m.EditedAt, &m.EditCount, &m.DeletedAt, &m.DeletedBy, &m.Hidden, &m.PinnedAt, &m.PinnedBy, &m.Announcement, &m.ContentHTML)
+	return m, err
+}
+
+func scanForum(row rowScanner) (models.Forum, error) {
+	var f models.Forum
+	err := row.Scan(&f.ID, &f.Title, &f.Description, &f.CreatedAt, &f.Locked, &f.Pinned, &f.ArchivedAt,
+		&f.CategoryID, &f.ParentID, &f.Position, &f.SlowMode)
+	return f, err
 }
 
 type ForumsRepo struct {
@@ -49,7 +128,7 @@ func (r *ForumsRepo) Create(f models.Forum) (int, error) {
 }
 
 func (r *ForumsRepo) GetAll() ([]models.Forum, error) {
-	rows, err := r.DB.Query(`SELECT id, name, description, created_at FROM forums`)
+	rows, err := r.DB.Query(`SELECT ` + forumColumns + ` FROM forums WHERE deleted_at IS NULL ORDER BY pinned DESC, position, created_at, id`)
 	if err != nil {
 		return nil, err
 	}
@@ -57,8 +136,8 @@ func (r *ForumsRepo) GetAll() ([]models.Forum, error) {
 
 	forums := []models.Forum{}
 	for rows.Next() {
-		var f models.Forum
-		if err := rows.Scan(&f.ID, &f.Title, &f.Description, &f.CreatedAt); err != nil {
+		f, err := scanForum(rows)
+		if err != nil {
 			return nil, err
 		}
 		forums = append(forums, f)
@@ -67,11 +146,8 @@ func (r *ForumsRepo) GetAll() ([]models.Forum, error) {
 }
 
 func (r *ForumsRepo) GetByID(id int) (*models.Forum, error) {
-	query := `SELECT id, name, description FROM forums WHERE id = $1`
-	row := r.DB.QueryRow(query, id)
-
-	var forum models.Forum
-	err := row.Scan(&forum.ID, &forum.Title, &forum.Description)
+	query := `SELECT ` + forumColumns + ` FROM forums WHERE id = $1 AND deleted_at IS NULL`
+	forum, err := scanForum(r.DB.QueryRow(query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, errors.New("forum not found")
@@ -84,7 +160,7 @@ func (r *ForumsRepo) GetByID(id int) (*models.Forum, error) {
 
 func (r *ForumsRepo) Update(id int, f models.Forum) error {
 	result, err := r.DB.Exec(
-		`UPDATE forums SET name = $1, description = $2 WHERE id = $3`,
+		`UPDATE forums SET name = $1, description = $2 WHERE id = $3 AND deleted_at IS NULL`,
 		f.Title, f.Description, id,
 	)
 	if err != nil {
@@ -98,10 +174,12 @@ func (r *ForumsRepo) Update(id int, f models.Forum) error {
 	return nil
 }
 
-func (r *ForumsRepo) Delete(id int) error {
+// Delete moves a forum to the trash. Its messages are hidden along with it
+// and come back if the forum is restored.
+func (r *ForumsRepo) Delete(id int, deletedBy string) error {
 	result, err := r.DB.Exec(
-		`DELETE FROM forums WHERE id = $1`,
-		id,
+		`UPDATE forums SET deleted_at = $2, deleted_by = $3 WHERE id = $1 AND deleted_at IS NULL`,
+		id, time.Now(), deletedBy,
 	)
 	if err != nil {
 		return err
@@ -117,7 +195,7 @@ func (r *ForumsRepo) Delete(id int) error {
 func (r *ForumsRepo) CreateMessage(msg models.Message) (int, error) {
 	var exists bool
 	fmt.Println(msg.ForumID)
-	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM forums WHERE id = $1)", msg.ForumID).Scan(&exists)
+	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM forums WHERE id = $1 AND deleted_at IS NULL)", msg.ForumID).Scan(&exists)
 	if err != nil {
 		return 0, fmt.Errorf("forum check failed: %v", err)
 	}
@@ -127,8 +205,8 @@ func (r *ForumsRepo) CreateMessage(msg models.Message) (int, error) {
 
 	var id int
 	err = r.DB.QueryRow(
-		"INSERT INTO messages (forum_id, author, content, created_at) VALUES ($1, $2, $3, $4) RETURNING id",
-		msg.ForumID, msg.Author, msg.Content, msg.CreatedAt,
+		"INSERT INTO messages (forum_id, author, content, content_html, created_at, topic_id, reply_to, quote) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		msg.ForumID, msg.Author, msg.Content, markdown.Render(msg.Content), msg.CreatedAt, msg.TopicID, msg.ReplyTo, msg.Quote,
 	).Scan(&id)
 
 	if err != nil {
@@ -140,9 +218,9 @@ func (r *ForumsRepo) CreateMessage(msg models.Message) (int, error) {
 
 func (r *ForumsRepo) GetMessages(forumID int) ([]models.Message, error) {
 	rows, err := r.DB.Query(`
-		SELECT id, forum_id, author, content, created_at 
+		SELECT `+messageColumns+`
 		FROM messages 
-		WHERE forum_id = $1 
+		WHERE forum_id = $1 AND topic_id IS NULL AND deleted_at IS NULL
 		ORDER BY created_at`, forumID)
 	if err != nil {
 		return nil, err
@@ -151,8 +229,8 @@ func (r *ForumsRepo) GetMessages(forumID int) ([]models.Message, error) {
 
 	messages := []models.Message{}
 	for rows.Next() {
-		var m models.Message
-		if err := rows.Scan(&m.ID, &m.ForumID, &m.Author, &m.Content, &m.CreatedAt); err != nil {
+		m, err := scanMessage(rows)
+		if err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
@@ -160,36 +238,105 @@ func (r *ForumsRepo) GetMessages(forumID int) ([]models.Message, error) {
 	return messages, nil
 }
 
-func (r *ForumsRepo) DeleteMessage(id int) error {
-	_, err := r.DB.Exec("DELETE FROM messages WHERE id = $1", id)
+// DeleteMessage moves a message to the trash.
+func (r *ForumsRepo) DeleteMessage(id int, deletedBy string) error {
+	_, err := r.DB.Exec(
+		"UPDATE messages SET deleted_at = $2, deleted_by = $3 WHERE id = $1 AND deleted_at IS NULL",
+		id, time.Now(), deletedBy,
+	)
 	return err
 }
 
-func (r *ForumsRepo) PutMessage(messageID int, updatedContent string) (*models.Message, error) {
-	var updatedMessage models.Message
+// PutMessage replaces a message's content and its rendered HTML, saving the
+// previous content as a revision attributed to editedBy.
+func (r *ForumsRepo) PutMessage(messageID int, updatedContent, editedBy string) (*models.Message, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message: %w", err)
+	}
+	defer tx.Rollback()
+
+	editedAt := time.Now()
+	result, err := tx.Exec(`
+		INSERT INTO message_revisions (message_id, content, edited_by, edited_at)
+		SELECT id, content, $2, $3
+		FROM messages
+		WHERE id = $1
+		FOR UPDATE`,
+		messageID, editedBy, editedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save message revision: %w", err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil, ErrNotFound
+	}
 
-	err := r.DB.QueryRow(`
+	updatedMessage, err := scanMessage(tx.QueryRow(`
         UPDATE messages 
-        SET content = $1
-        WHERE id = $2
-        RETURNING id, forum_id, author, content, created_at`,
+        SET content = $1, content_html = $4, edited_at = $2, edit_count = edit_count + 1
+        WHERE id = $3
+        RETURNING `+messageColumns,
 		updatedContent,
+		editedAt,
 		messageID,
-	).Scan(
-		&updatedMessage.ID,
-		&updatedMessage.ForumID,
-		&updatedMessage.Author,
-		&updatedMessage.Content,
-		&updatedMessage.CreatedAt,
-	)
-
+		markdown.Render(updatedContent),
+	))
 	if err != nil {
 		return nil, fmt.Errorf("failed to update message: %w", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update message: %w", err)
+	}
+
 	return &updatedMessage, nil
 }
 
+// RenderMissingHTML renders the content of messages written before the
+// rendered HTML was stored, batch messages at a time, and returns how many
+// it rendered.
+func (r *ForumsRepo) RenderMissingHTML(batch int) (int, error) {
+	rendered, lastID := 0, 0
+	for {
+		rows, err := r.DB.Query(`
+			SELECT id, content FROM messages
+			WHERE content_html = '' AND content <> '' AND id > $1
+			ORDER BY id
+			LIMIT $2`, lastID, batch)
+		if err != nil {
+			return rendered, err
+		}
+		var ids []int
+		var contents []string
+		for rows.Next() {
+			var id int
+			var content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return rendered, err
+			}
+			ids = append(ids, id)
+			contents = append(contents, content)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return rendered, err
+		}
+		if len(ids) == 0 {
+			return rendered, nil
+		}
+
+		for i, id := range ids {
+			if _, err := r.DB.Exec(`UPDATE messages SET content_html = $2 WHERE id = $1`, id, markdown.Render(contents[i])); err != nil {
+				return rendered, err
+			}
+			rendered++
+		}
+		lastID = ids[len(ids)-1]
+	}
+}
+
 func (r *ForumsRepo) CreateGlobalMessage(msg models.GlobalMessage) (int, error) {
 	var id int
 	err := r.DB.QueryRow(`
@@ -303,13 +450,53 @@ func (r *ForumsRepo) GetUserByID(userID int) (*models.User, error) {
 }
 
 func (r *ForumsRepo) GetMessageByID(messageID int) (*models.Message, error) {
-	var m models.Message
-	err := r.DB.QueryRow(
-		"SELECT id, forum_id, author, content, created_at FROM messages WHERE id = $1",
+	// Messages of a forum in the trash are gone along with it.
+	m, err := scanMessage(r.DB.QueryRow(`
+		SELECT `+qualifiedMessageColumns+`
+		FROM messages m
+		JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL
+		WHERE m.id = $1 AND m.deleted_at IS NULL`,
 		messageID,
-	).Scan(&m.ID, &m.ForumID, &m.Author, &m.Content, &m.CreatedAt)
+	))
 	if err != nil {
 		return nil, err
 	}
 	return &m, nil
 }
+
+// GetMessageThread returns the message with the given ID followed by all of
+// its direct and indirect replies, oldest first. Threads of deleted forums
+// are not found; replies always live in the forum of the message they answer.
+func (r *ForumsRepo) GetMessageThread(messageID int) ([]models.Message, error) {
+	rows, err := r.DB.Query(`
+		WITH RECURSIVE thread AS (
+			SELECT `+qualifiedMessageColumns+`
+			FROM messages m
+			JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL
+			WHERE m.id = $1 AND m.deleted_at IS NULL
+			UNION ALL
+			SELECT `+qualifiedMessageColumns+`
+			FROM messages m
+			JOIN thread t ON m.reply_to = t.id
+			WHERE m.deleted_at IS NULL
+		)
+		SELECT `+messageColumns+` FROM thread
+		ORDER BY created_at`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if len(messages) == 0 {
+		return nil, ErrNotFound
+	}
+	return messages, nil
+}
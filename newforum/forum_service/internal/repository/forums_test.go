@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"testing"
 	"time"
@@ -74,6 +75,8 @@ func TestForumsRepo_Create(t *testing.T) {
 	}
 }
 
+var forumCols = []string{"id", "name", "description", "created_at", "locked", "pinned", "archived_at", "category_id", "parent_id", "position", "slow_mode"}
+
 func TestForumsRepo_GetAll(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -94,22 +97,22 @@ func TestForumsRepo_GetAll(t *testing.T) {
 		{
 			name: "Success",
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "name", "description", "created_at"}).
-					AddRow(1, "Forum 1", "Desc 1", testTime).
-					AddRow(2, "Forum 2", "Desc 2", testTime)
-				mock.ExpectQuery(`SELECT id, name, description, created_at FROM forums`).
+				rows := sqlmock.NewRows(forumCols).
+					AddRow(1, "Forum 1", "Desc 1", testTime, false, true, nil, nil, nil, 0, 0).
+					AddRow(2, "Forum 2", "Desc 2", testTime, true, false, testTime, nil, nil, 0, 0)
+				mock.ExpectQuery(`SELECT id, name, description, created_at, locked, pinned, archived_at, category_id, parent_id, position, slow_mode FROM forums WHERE deleted_at IS NULL ORDER BY pinned DESC`).
 					WillReturnRows(rows)
 			},
 			want: []models.Forum{
-				{ID: 1, Title: "Forum 1", Description: "Desc 1", CreatedAt: testTime},
-				{ID: 2, Title: "Forum 2", Description: "Desc 2", CreatedAt: testTime},
+				{ID: 1, Title: "Forum 1", Description: "Desc 1", CreatedAt: testTime, Pinned: true},
+				{ID: 2, Title: "Forum 2", Description: "Desc 2", CreatedAt: testTime, Locked: true, ArchivedAt: &testTime},
 			},
 		},
 		{
 			name: "Empty Result",
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "name", "description", "created_at"})
-				mock.ExpectQuery(`SELECT id, name, description, created_at FROM forums`).
+				rows := sqlmock.NewRows(forumCols)
+				mock.ExpectQuery(`SELECT id, name, description, created_at, locked, pinned, archived_at, category_id, parent_id, position, slow_mode FROM forums`).
 					WillReturnRows(rows)
 			},
 			want:    []models.Forum{},
@@ -118,7 +121,7 @@ func TestForumsRepo_GetAll(t *testing.T) {
 		{
 			name: "Database Error",
 			mock: func() {
-				mock.ExpectQuery(`SELECT id, name, description, created_at FROM forums`).
+				mock.ExpectQuery(`SELECT id, name, description, created_at, locked, pinned, archived_at, category_id, parent_id, position, slow_mode FROM forums`).
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -152,6 +155,8 @@ func TestForumsRepo_GetByID(t *testing.T) {
 
 	repo := NewForumsRepo(db)
 
+	testTime := time.Now()
+
 	tests := []struct {
 		name    string
 		id      int
@@ -163,9 +168,9 @@ func TestForumsRepo_GetByID(t *testing.T) {
 			name: "Success",
 			id:   1,
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "name", "description"}).
-					AddRow(1, "Test Forum", "Test Description")
-				mock.ExpectQuery(`SELECT id, name, description FROM forums WHERE id = \$1`).
+				rows := sqlmock.NewRows(forumCols).
+					AddRow(1, "Test Forum", "Test Description", testTime, true, false, nil, nil, nil, 0, 0)
+				mock.ExpectQuery(`SELECT id, name, description, created_at, locked, pinned, archived_at, category_id, parent_id, position, slow_mode FROM forums WHERE id = \$1`).
 					WithArgs(1).
 					WillReturnRows(rows)
 			},
@@ -173,13 +178,15 @@ func TestForumsRepo_GetByID(t *testing.T) {
 				ID:          1,
 				Title:       "Test Forum",
 				Description: "Test Description",
+				CreatedAt:   testTime,
+				Locked:      true,
 			},
 		},
 		{
 			name: "Not Found",
 			id:   999,
 			mock: func() {
-				mock.ExpectQuery(`SELECT id, name, description FROM forums WHERE id = \$1`).
+				mock.ExpectQuery(`SELECT id, name, description, created_at, locked, pinned, archived_at, category_id, parent_id, position, slow_mode FROM forums WHERE id = \$1`).
 					WithArgs(999).
 					WillReturnError(sql.ErrNoRows)
 			},
@@ -189,7 +196,7 @@ func TestForumsRepo_GetByID(t *testing.T) {
 			name: "Database Error",
 			id:   1,
 			mock: func() {
-				mock.ExpectQuery(`SELECT id, name, description FROM forums WHERE id = \$1`).
+				mock.ExpectQuery(`SELECT id, name, description, created_at, locked, pinned, archived_at, category_id, parent_id, position, slow_mode FROM forums WHERE id = \$1`).
 					WithArgs(1).
 					WillReturnError(errors.New("database error"))
 			},
@@ -307,8 +314,8 @@ func TestForumsRepo_Delete(t *testing.T) {
 			name: "Success",
 			id:   1,
 			mock: func() {
-				mock.ExpectExec(`DELETE FROM forums`).
-					WithArgs(1).
+				mock.ExpectExec(`UPDATE forums SET deleted_at`).
+					WithArgs(1, sqlmock.AnyArg(), "admin").
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 		},
@@ -316,8 +323,8 @@ func TestForumsRepo_Delete(t *testing.T) {
 			name: "Not Found",
 			id:   999,
 			mock: func() {
-				mock.ExpectExec(`DELETE FROM forums`).
-					WithArgs(999).
+				mock.ExpectExec(`UPDATE forums SET deleted_at`).
+					WithArgs(999, sqlmock.AnyArg(), "admin").
 					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			wantErr: true,
@@ -326,8 +333,8 @@ func TestForumsRepo_Delete(t *testing.T) {
 			name: "Database Error",
 			id:   1,
 			mock: func() {
-				mock.ExpectExec(`DELETE FROM forums`).
-					WithArgs(1).
+				mock.ExpectExec(`UPDATE forums SET deleted_at`).
+					WithArgs(1, sqlmock.AnyArg(), "admin").
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -338,7 +345,7 @@ func TestForumsRepo_Delete(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock()
 
-			err := repo.Delete(tt.id)
+			err := repo.Delete(tt.id, "admin")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -380,7 +387,7 @@ func TestForumsRepo_CreateMessage(t *testing.T) {
 					WithArgs(1).
 					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 				mock.ExpectQuery(`INSERT INTO messages`).
-					WithArgs(1, "testuser", "Test message", testTime).
+					WithArgs(1, "testuser", "Test message", "<p>Test message</p>", testTime, nil, nil, "").
 					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 			},
 			want: 1,
@@ -411,7 +418,7 @@ func TestForumsRepo_CreateMessage(t *testing.T) {
 					WithArgs(1).
 					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 				mock.ExpectQuery(`INSERT INTO messages`).
-					WithArgs(1, "testuser", "Test message", testTime).
+					WithArgs(1, "testuser", "Test message", "<p>Test message</p>", testTime, nil, nil, "").
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -461,9 +468,9 @@ func TestForumsRepo_GetMessages(t *testing.T) {
 			name:    "Success",
 			forumID: 1,
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "forum_id", "author", "content", "created_at"}).
-					AddRow(1, 1, "user1", "message 1", testTime).
-					AddRow(2, 1, "user2", "message 2", testTime)
+				rows := sqlmock.NewRows(messageCols).
+					AddRow(messageRow(models.Message{ID: 1, ForumID: 1, Author: "user1", Content: "message 1", CreatedAt: testTime})...).
+					AddRow(messageRow(models.Message{ID: 2, ForumID: 1, Author: "user2", Content: "message 2", CreatedAt: testTime})...)
 				mock.ExpectQuery(`SELECT id, forum_id, author, content, created_at`).
 					WithArgs(1).
 					WillReturnRows(rows)
@@ -477,7 +484,7 @@ func TestForumsRepo_GetMessages(t *testing.T) {
 			name:    "Empty Result",
 			forumID: 2,
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "forum_id", "author", "content", "created_at"})
+				rows := sqlmock.NewRows(messageCols)
 				mock.ExpectQuery(`SELECT id, forum_id, author, content, created_at`).
 					WithArgs(2).
 					WillReturnRows(rows)
@@ -534,8 +541,8 @@ func TestForumsRepo_DeleteMessage(t *testing.T) {
 			name: "Success",
 			id:   1,
 			mock: func() {
-				mock.ExpectExec(`DELETE FROM messages`).
-					WithArgs(1).
+				mock.ExpectExec(`UPDATE messages SET deleted_at`).
+					WithArgs(1, sqlmock.AnyArg(), "testuser").
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
 		},
@@ -543,8 +550,8 @@ func TestForumsRepo_DeleteMessage(t *testing.T) {
 			name: "Database Error",
 			id:   1,
 			mock: func() {
-				mock.ExpectExec(`DELETE FROM messages`).
-					WithArgs(1).
+				mock.ExpectExec(`UPDATE messages SET deleted_at`).
+					WithArgs(1, sqlmock.AnyArg(), "testuser").
 					WillReturnError(errors.New("database error"))
 			},
 			wantErr: true,
@@ -555,7 +562,7 @@ func TestForumsRepo_DeleteMessage(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock()
 
-			err := repo.DeleteMessage(tt.id)
+			err := repo.DeleteMessage(tt.id, "testuser")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("DeleteMessage() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -575,6 +582,7 @@ func TestForumsRepo_PutMessage(t *testing.T) {
 	repo := NewForumsRepo(db)
 
 	testTime := time.Now()
+	editedAt := testTime.Add(time.Minute)
 
 	tests := []struct {
 		name           string
@@ -589,18 +597,26 @@ func TestForumsRepo_PutMessage(t *testing.T) {
 			messageID:      1,
 			updatedContent: "updated content",
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "forum_id", "author", "content", "created_at"}).
-					AddRow(1, 1, "testuser", "updated content", testTime)
-				mock.ExpectQuery(`UPDATE messages`).
-					WithArgs("updated content", 1).
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO message_revisions`).
+					WithArgs(1, "editor", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				rows := sqlmock.NewRows(messageCols).
+					AddRow(messageRow(models.Message{ID: 1, ForumID: 1, Author: "testuser", Content: "updated content", ContentHTML: "<p>updated content</p>", CreatedAt: testTime, EditedAt: &editedAt, EditCount: 1})...)
+				mock.ExpectQuery(`UPDATE messages(.|\n)*content_html = \$4(.|\n)*edit_count = edit_count \+ 1`).
+					WithArgs("updated content", sqlmock.AnyArg(), 1, "<p>updated content</p>").
 					WillReturnRows(rows)
+				mock.ExpectCommit()
 			},
 			want: &models.Message{
-				ID:        1,
-				ForumID:   1,
-				Author:    "testuser",
-				Content:   "updated content",
-				CreatedAt: testTime,
+				ID:          1,
+				ForumID:     1,
+				Author:      "testuser",
+				Content:     "updated content",
+				ContentHTML: "<p>updated content</p>",
+				CreatedAt:   testTime,
+				EditedAt:    &editedAt,
+				EditCount:   1,
 			},
 		},
 		{
@@ -608,9 +624,11 @@ func TestForumsRepo_PutMessage(t *testing.T) {
 			messageID:      999,
 			updatedContent: "updated content",
 			mock: func() {
-				mock.ExpectQuery(`UPDATE messages`).
-					WithArgs("updated content", 999).
-					WillReturnError(sql.ErrNoRows)
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO message_revisions`).
+					WithArgs(999, "editor", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -619,9 +637,14 @@ func TestForumsRepo_PutMessage(t *testing.T) {
 			messageID:      1,
 			updatedContent: "updated content",
 			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO message_revisions`).
+					WithArgs(1, "editor", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
 				mock.ExpectQuery(`UPDATE messages`).
-					WithArgs("updated content", 1).
+					WithArgs("updated content", sqlmock.AnyArg(), 1, "<p>updated content</p>").
 					WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
@@ -631,7 +654,7 @@ func TestForumsRepo_PutMessage(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.mock()
 
-			got, err := repo.PutMessage(tt.messageID, tt.updatedContent)
+			got, err := repo.PutMessage(tt.messageID, tt.updatedContent, "editor")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("PutMessage() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -645,6 +668,35 @@ func TestForumsRepo_PutMessage(t *testing.T) {
 	}
 }
 
+func TestForumsRepo_RenderMissingHTML(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectQuery(`SELECT id, content FROM messages\s+WHERE content_html = '' AND content <> '' AND id > \$1`).
+		WithArgs(0, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "content"}).AddRow(3, "`code`").AddRow(7, " "))
+	mock.ExpectExec(`UPDATE messages SET content_html = \$2 WHERE id = \$1`).
+		WithArgs(3, "<p><code>code</code></p>").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Content that renders to nothing stays empty; the cursor moves past it.
+	mock.ExpectExec(`UPDATE messages SET content_html`).
+		WithArgs(7, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT id, content FROM messages`).
+		WithArgs(7, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "content"}))
+
+	rendered, err := repo.RenderMissingHTML(2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rendered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestForumsRepo_CreateGlobalMessage(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -1016,9 +1068,9 @@ func TestForumsRepo_GetMessageByID(t *testing.T) {
 			name:      "Success",
 			messageID: 1,
 			mock: func() {
-				rows := sqlmock.NewRows([]string{"id", "forum_id", "author", "content", "created_at"}).
-					AddRow(1, 1, "testuser", "test message", testTime)
-				mock.ExpectQuery(`SELECT id, forum_id, author, content, created_at`).
+				rows := sqlmock.NewRows(messageCols).
+					AddRow(messageRow(models.Message{ID: 1, ForumID: 1, Author: "testuser", Content: "test message", CreatedAt: testTime})...)
+				mock.ExpectQuery(`SELECT m.id, m.forum_id, m.author, m.content, m.created_at.* JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL`).
 					WithArgs(1).
 					WillReturnRows(rows)
 			},
@@ -1034,7 +1086,7 @@ func TestForumsRepo_GetMessageByID(t *testing.T) {
 			name:      "Not Found",
 			messageID: 999,
 			mock: func() {
-				mock.ExpectQuery(`SELECT id, forum_id, author, content, created_at`).
+				mock.ExpectQuery(`SELECT m.id, m.forum_id, m.author, m.content, m.created_at.* JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL`).
 					WithArgs(999).
 					WillReturnError(sql.ErrNoRows)
 			},
@@ -1044,7 +1096,7 @@ func TestForumsRepo_GetMessageByID(t *testing.T) {
 			name:      "Database Error",
 			messageID: 1,
 			mock: func() {
-				mock.ExpectQuery(`SELECT id, forum_id, author, content, created_at`).
+				mock.ExpectQuery(`SELECT m.id, m.forum_id, m.author, m.content, m.created_at.* JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL`).
 					WithArgs(1).
 					WillReturnError(errors.New("database error"))
 			},
@@ -1069,3 +1121,67 @@ func TestForumsRepo_GetMessageByID(t *testing.T) {
 		})
 	}
 }
+
+var messageCols = []string{"id", "forum_id", "author", "content", "created_at", "topic_id", "reply_to", "quote", "edited_at", "edit_count", "deleted_at", "deleted_by", "hidden",
+	"pinned_at", "pinned_by", "announcement", "content_html"}
+
+func messageRow(m models.Message) []driver.Value {
+	var topicID, replyTo, editedAt, deletedAt, pinnedAt driver.Value
+	if m.TopicID != nil {
+		topicID = *m.TopicID
+	}
+	if m.ReplyTo != nil {
+		replyTo = *m.ReplyTo
+	}
+	if m.EditedAt != nil {
+		editedAt = *m.EditedAt
+	}
+	if m.DeletedAt != nil {
+		deletedAt = *m.DeletedAt
+	}
+	if m.PinnedAt != nil {
+		pinnedAt = *m.PinnedAt
+	}
+	return []driver.Value{m.ID, m.ForumID, m.Author, m.Content, m.CreatedAt, topicID, replyTo, m.Quote, editedAt, m.EditCount, deletedAt, m.DeletedBy, m.Hidden,
+		pinnedAt, m.PinnedBy, m.Announcement, m.ContentHTML}
+}
+
+func TestForumsRepo_GetMessageThread(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	rootID := 1
+
+	rows := sqlmock.NewRows(messageCols).
+		AddRow(messageRow(models.Message{ID: 1, ForumID: 1, Author: "user1", Content: "root", CreatedAt: testTime})...).
+		AddRow(messageRow(models.Message{ID: 2, ForumID: 1, ReplyTo: &rootID, Quote: "root", Author: "user2", Content: "reply", CreatedAt: testTime})...)
+	mock.ExpectQuery(`WITH RECURSIVE thread AS \(\s+SELECT m.id(.|\n)*FROM messages m\s+JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL\s+WHERE m.id = \$1 AND m.deleted_at IS NULL`).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	got, err := repo.GetMessageThread(1)
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, &rootID, got[1].ReplyTo)
+	assert.Equal(t, "root", got[1].Quote)
+
+	mock.ExpectQuery(`WITH RECURSIVE thread`).
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows(messageCols))
+	_, err = repo.GetMessageThread(999)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	mock.ExpectQuery(`WITH RECURSIVE thread`).
+		WithArgs(1).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.GetMessageThread(1)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
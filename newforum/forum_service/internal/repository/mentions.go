@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// likePrefix escapes the LIKE wildcards in prefix, which are common in
+// usernames, and appends the trailing %.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// ResolveUsernames returns those of names that belong to registered users.
+func (r *ForumsRepo) ResolveUsernames(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return []string{}, nil
+	}
+	return r.usernames(`SELECT username FROM users WHERE username = ANY($1) ORDER BY username`, pq.Array(names))
+}
+
+// SearchUsernames returns up to limit usernames starting with prefix,
+// ignoring case, for mention autocompletion.
+func (r *ForumsRepo) SearchUsernames(prefix string, limit int) ([]string, error) {
+	return r.usernames(`SELECT username FROM users WHERE username ILIKE $1 ORDER BY username LIMIT $2`,
+		likePrefix(prefix), limit)
+}
+
+func (r *ForumsRepo) usernames(query string, args ...interface{}) ([]string, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SetMessageMentions replaces the users mentioned in a message and returns
+// the ones that were not mentioned before, so an edit only notifies users it
+// adds.
+func (r *ForumsRepo) SetMessageMentions(messageID int, usernames []string) ([]string, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM message_mentions WHERE message_id = $1 AND NOT (username = ANY($2))`,
+		messageID, pq.Array(usernames)); err != nil {
+		return nil, err
+	}
+
+	// Rows that already exist are skipped by ON CONFLICT and so are not
+	// returned.
+	rows, err := tx.Query(`
+		INSERT INTO message_mentions (message_id, username)
+		SELECT $1, UNNEST($2::text[])
+		ON CONFLICT DO NOTHING
+		RETURNING username`, messageID, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	added := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		added = append(added, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return added, nil
+}
+
+// GetMentions returns the users mentioned in each of the given messages,
+// keyed by message ID.
+func (r *ForumsRepo) GetMentions(messageIDs []int) (map[int][]string, error) {
+	rows, err := r.DB.Query(`
+		SELECT message_id, username FROM message_mentions
+		WHERE message_id = ANY($1)
+		ORDER BY message_id, username`, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := make(map[int][]string)
+	for rows.Next() {
+		var messageID int
+		var name string
+		if err := rows.Scan(&messageID, &name); err != nil {
+			return nil, err
+		}
+		mentions[messageID] = append(mentions[messageID], name)
+	}
+	return mentions, rows.Err()
+}
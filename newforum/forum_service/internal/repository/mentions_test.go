@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_ResolveUsernames(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectQuery(`SELECT username FROM users WHERE username = ANY\(\$1\) ORDER BY username`).
+		WithArgs(pq.Array([]string{"bob", "ghost"})).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("bob"))
+	names, err := repo.ResolveUsernames([]string{"bob", "ghost"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, names)
+
+	mock.ExpectQuery(`SELECT username FROM users WHERE username ILIKE \$1 ORDER BY username LIMIT \$2`).
+		WithArgs(`a\_b%`, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("a_bc"))
+	names, err = repo.SearchUsernames("a_b", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a_bc"}, names)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_SetMessageMentions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM message_mentions WHERE message_id = \$1 AND NOT \(username = ANY\(\$2\)\)`).
+		WithArgs(3, pq.Array([]string{"bob", "carol"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO message_mentions \(message_id, username\)\s+SELECT \$1, UNNEST\(\$2::text\[\]\)\s+ON CONFLICT DO NOTHING\s+RETURNING username`).
+		WithArgs(3, pq.Array([]string{"bob", "carol"})).
+		WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("carol"))
+	mock.ExpectCommit()
+
+	added, err := repo.SetMessageMentions(3, []string{"bob", "carol"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"carol"}, added)
+
+	mock.ExpectQuery(`SELECT message_id, username FROM message_mentions\s+WHERE message_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int{3, 4})).
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "username"}).
+			AddRow(3, "bob").
+			AddRow(3, "carol"))
+	mentions, err := repo.GetMentions([]int{3, 4})
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]string{3: {"bob", "carol"}}, mentions)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+// ModerationLogRepository appends to and reads the moderation log. There is
+// deliberately no way to change or remove an entry.
+type ModerationLogRepository interface {
+	LogAction(e models.ModerationEntry) error
+	GetModerationLog(f models.ModerationLogFilter, page models.PageRequest) (*models.ModerationLogPage, error)
+	ExportModerationLog(f models.ModerationLogFilter, fn func(models.ModerationEntry) error) error
+}
+
+const moderationColumns = `id, actor, action, target_type, target_id, forum_id, reason, before, after, created_at`
+
+type ModerationLogRepo struct {
+	DB *sql.DB
+}
+
+func NewModerationLogRepo(db *sql.DB) *ModerationLogRepo {
+	return &ModerationLogRepo{
+		DB: db,
+	}
+}
+
+func scanModerationEntry(row rowScanner) (models.ModerationEntry, error) {
+	var e models.ModerationEntry
+	var before, after []byte
+	err := row.Scan(&e.ID, &e.Actor, &e.Action, &e.TargetType, &e.TargetID, &e.ForumID, &e.Reason,
+		&before, &after, &e.CreatedAt)
+	if len(before) > 0 {
+		e.Before = json.RawMessage(before)
+	}
+	if len(after) > 0 {
+		e.After = json.RawMessage(after)
+	}
+	return e, err
+}
+
+// jsonArg passes a snapshot as text so it is not sent as bytea.
+func jsonArg(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+func (r *ModerationLogRepo) LogAction(e models.ModerationEntry) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO moderation_log (actor, action, target_type, target_id, forum_id, reason, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		e.Actor, e.Action, e.TargetType, e.TargetID, e.ForumID, e.Reason, jsonArg(e.Before), jsonArg(e.After))
+	if err != nil {
+		return fmt.Errorf("failed to write moderation log: %w", err)
+	}
+	return nil
+}
+
+// moderationWhere builds the WHERE conditions for f, numbering arguments
+// from 1.
+func moderationWhere(f models.ModerationLogFilter) ([]string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Actor != "" {
+		add("actor = $%d", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != nil {
+		add("target_id = $%d", *f.TargetID)
+	}
+	if f.ForumID != nil {
+		add("forum_id = $%d", *f.ForumID)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("created_at <= $%d", *f.To)
+	}
+	return conds, args
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conds, " AND ")
+}
+
+// GetModerationLog returns one page of matching entries, newest first.
+// Prev pages towards older entries and Next towards newer ones.
+func (r *ModerationLogRepo) GetModerationLog(f models.ModerationLogFilter, page models.PageRequest) (*models.ModerationLogPage, error) {
+	conds, args := moderationWhere(f)
+	cond, condArgs, desc := keyset(page, len(args)+1, true)
+	if cond != "" {
+		conds = append(conds, cond)
+	}
+	args = append(args, condArgs...)
+	args = append(args, page.Limit+1)
+
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM moderation_log
+		%s
+		%s
+		LIMIT $%d`, moderationColumns, whereClause(conds), orderBy(desc), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moderation log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.ModerationEntry{}
+	for rows.Next() {
+		e, err := scanModerationEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := len(entries) > page.Limit
+	if hasMore {
+		entries = entries[:page.Limit]
+	}
+	if !desc {
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	}
+
+	result := &models.ModerationLogPage{Entries: entries}
+	if n := len(entries); n > 0 {
+		oldest := models.Cursor{CreatedAt: entries[n-1].CreatedAt, ID: entries[n-1].ID}
+		newest := models.Cursor{CreatedAt: entries[0].CreatedAt, ID: entries[0].ID}
+		result.Prev, result.Next = pageCursors(page, desc, hasMore, oldest, newest)
+	}
+	return result, nil
+}
+
+// ExportModerationLog streams every matching entry, newest first, to fn.
+func (r *ModerationLogRepo) ExportModerationLog(f models.ModerationLogFilter, fn func(models.ModerationEntry) error) error {
+	conds, args := moderationWhere(f)
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM moderation_log
+		%s
+		ORDER BY created_at DESC, id DESC`, moderationColumns, whereClause(conds)), args...)
+	if err != nil {
+		return fmt.Errorf("failed to read moderation log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanModerationEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
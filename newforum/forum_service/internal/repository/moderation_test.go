@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var moderationCols = []string{"id", "actor", "action", "target_type", "target_id", "forum_id", "reason", "before", "after", "created_at"}
+
+func TestModerationLogRepo_LogAction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewModerationLogRepo(db)
+	forumID := 2
+
+	mock.ExpectExec(`INSERT INTO moderation_log`).
+		WithArgs("admin", models.ModerationMessageDelete, models.ModerationTargetMessage, 5, &forumID, "spam",
+			`{"content":"buy now"}`, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = repo.LogAction(models.ModerationEntry{
+		Actor:      "admin",
+		Action:     models.ModerationMessageDelete,
+		TargetType: models.ModerationTargetMessage,
+		TargetID:   5,
+		ForumID:    &forumID,
+		Reason:     "spam",
+		Before:     json.RawMessage(`{"content":"buy now"}`),
+	})
+	assert.NoError(t, err)
+
+	mock.ExpectExec(`INSERT INTO moderation_log`).
+		WillReturnError(errors.New("database error"))
+	assert.Error(t, repo.LogAction(models.ModerationEntry{Actor: "admin"}))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationLogRepo_GetModerationLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewModerationLogRepo(db)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	row := func(id int) []driver.Value {
+		return []driver.Value{id, "admin", models.ModerationMessageDelete, models.ModerationTargetMessage, id * 10, nil, "",
+			[]byte(`{"id":1}`), nil, base.Add(time.Duration(id) * time.Minute)}
+	}
+	cursor := func(id int) *models.Cursor {
+		return &models.Cursor{CreatedAt: base.Add(time.Duration(id) * time.Minute), ID: id}
+	}
+
+	t.Run("Latest Page", func(t *testing.T) {
+		mock.ExpectQuery(`FROM moderation_log\s+WHERE actor = \$1 AND target_type = \$2\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$3`).
+			WithArgs("admin", "message", 3).
+			WillReturnRows(sqlmock.NewRows(moderationCols).
+				AddRow(row(5)...).AddRow(row(4)...).AddRow(row(3)...))
+
+		got, err := repo.GetModerationLog(models.ModerationLogFilter{Actor: "admin", TargetType: "message"}, models.PageRequest{Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, got.Entries, 2)
+		assert.Equal(t, 5, got.Entries[0].ID)
+		assert.JSONEq(t, `{"id":1}`, string(got.Entries[0].Before))
+		assert.Nil(t, got.Entries[0].After)
+		assertCursor(t, cursor(4), got.Prev)
+		assert.Empty(t, got.Next)
+	})
+
+	t.Run("After Cursor", func(t *testing.T) {
+		mock.ExpectQuery(`WHERE \(created_at, id\) > \(\$1, \$2\)\s+ORDER BY created_at, id\s+LIMIT \$3`).
+			WithArgs(cursor(1).CreatedAt, 1, 3).
+			WillReturnRows(sqlmock.NewRows(moderationCols).AddRow(row(2)...).AddRow(row(3)...))
+
+		got, err := repo.GetModerationLog(models.ModerationLogFilter{}, models.PageRequest{After: cursor(1), Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, got.Entries[0].ID)
+		assert.Equal(t, 2, got.Entries[1].ID)
+		assertCursor(t, cursor(2), got.Prev)
+		assert.Empty(t, got.Next)
+	})
+
+	t.Run("Database Error", func(t *testing.T) {
+		mock.ExpectQuery(`FROM moderation_log`).
+			WillReturnError(errors.New("database error"))
+
+		_, err := repo.GetModerationLog(models.ModerationLogFilter{}, models.PageRequest{Limit: 2})
+		assert.Error(t, err)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationLogRepo_ExportModerationLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewModerationLogRepo(db)
+	testTime := time.Now()
+	forumID := 2
+
+	mock.ExpectQuery(`FROM moderation_log\s+WHERE forum_id = \$1\s+ORDER BY created_at DESC, id DESC`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(moderationCols).
+			AddRow(2, "mod", models.ModerationForumUpdate, models.ModerationTargetForum, 2, 2, "", nil, []byte(`{}`), testTime).
+			AddRow(1, "mod", models.ModerationForumDelete, models.ModerationTargetForum, 2, 2, "", nil, nil, testTime))
+
+	var ids []int
+	err = repo.ExportModerationLog(models.ModerationLogFilter{ForumID: &forumID}, func(e models.ModerationEntry) error {
+		ids = append(ids, e.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, ids)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
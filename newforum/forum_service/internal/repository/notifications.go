@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+// ErrNotificationMuted is returned when the recipient turned off
+// notifications of the given type.
+var ErrNotificationMuted = errors.New("notification type is turned off")
+
+// NotificationsRepository stores the notifications shown to users and the
+// types each user wants to receive.
+type NotificationsRepository interface {
+	CreateNotification(n models.Notification) (*models.Notification, error)
+	GetNotifications(username string, unreadOnly bool, page models.PageRequest) (*models.NotificationPage, error)
+	CountUnread(username string) (int, error)
+	MarkRead(username string, id int) error
+	MarkAllRead(username string) (int64, error)
+	GetNotificationPreferences(username string) (map[string]bool, error)
+	SetNotificationPreferences(username string, prefs map[string]bool) error
+}
+
+const notificationColumns = `id, username, type, actor, detail, forum_id, topic_id, message_id, created_at, read_at`
+
+type NotificationsRepo struct {
+	DB *sql.DB
+}
+
+func NewNotificationsRepo(db *sql.DB) *NotificationsRepo {
+	return &NotificationsRepo{
+		DB: db,
+	}
+}
+
+func scanNotification(row rowScanner) (models.Notification, error) {
+	var n models.Notification
+	err := row.Scan(&n.ID, &n.Username, &n.Type, &n.Actor, &n.Detail, &n.ForumID, &n.TopicID, &n.MessageID,
+		&n.CreatedAt, &n.ReadAt)
+	return n, err
+}
+
+// CreateNotification stores n unless its recipient turned its type off, in
+// which case ErrNotificationMuted is returned.
+func (r *NotificationsRepo) CreateNotification(n models.Notification) (*models.Notification, error) {
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	created, err := scanNotification(r.DB.QueryRow(`
+		INSERT INTO notifications (username, type, actor, detail, forum_id, topic_id, message_id, created_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8
+		WHERE NOT EXISTS (
+			SELECT 1 FROM notification_preferences WHERE username = $1 AND type = $2 AND NOT enabled)
+		RETURNING `+notificationColumns,
+		n.Username, n.Type, n.Actor, n.Detail, n.ForumID, n.TopicID, n.MessageID, n.CreatedAt))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotificationMuted
+		}
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetNotifications returns one page of a user's notifications, newest
+// first, optionally leaving out the ones already read.
+func (r *NotificationsRepo) GetNotifications(username string, unreadOnly bool, page models.PageRequest) (*models.NotificationPage, error) {
+	conds := []string{"username = $1"}
+	args := []interface{}{username}
+	if unreadOnly {
+		conds = append(conds, "read_at IS NULL")
+	}
+	cond, condArgs, desc := keyset(page, len(args)+1, true)
+	if cond != "" {
+		conds = append(conds, cond)
+	}
+	args = append(args, condArgs...)
+	args = append(args, page.Limit+1)
+
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM notifications
+		%s
+		%s
+		LIMIT $%d`, notificationColumns, whereClause(conds), orderBy(desc), len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := len(notifications) > page.Limit
+	if hasMore {
+		notifications = notifications[:page.Limit]
+	}
+	if !desc {
+		for i, j := 0, len(notifications)-1; i < j; i, j = i+1, j-1 {
+			notifications[i], notifications[j] = notifications[j], notifications[i]
+		}
+	}
+
+	result := &models.NotificationPage{Notifications: notifications}
+	if n := len(notifications); n > 0 {
+		oldest := models.Cursor{CreatedAt: notifications[n-1].CreatedAt, ID: notifications[n-1].ID}
+		newest := models.Cursor{CreatedAt: notifications[0].CreatedAt, ID: notifications[0].ID}
+		result.Prev, result.Next = pageCursors(page, desc, hasMore, oldest, newest)
+	}
+
+	if result.Unread, err = r.CountUnread(username); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *NotificationsRepo) CountUnread(username string) (int, error) {
+	var count int
+	err := r.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE username = $1 AND read_at IS NULL`,
+		username).Scan(&count)
+	return count, err
+}
+
+// MarkRead marks one of the user's notifications as read. Marking a
+// notification that is already read is not an error.
+func (r *NotificationsRepo) MarkRead(username string, id int) error {
+	result, err := r.DB.Exec(`
+		UPDATE notifications SET read_at = COALESCE(read_at, $3)
+		WHERE id = $1 AND username = $2`, id, username, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of the user as read and
+// returns how many there were.
+func (r *NotificationsRepo) MarkAllRead(username string) (int64, error) {
+	result, err := r.DB.Exec(`
+		UPDATE notifications SET read_at = $2
+		WHERE username = $1 AND read_at IS NULL`, username, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetNotificationPreferences returns whether the user receives each
+// notification type. Types the user never changed are on.
+func (r *NotificationsRepo) GetNotificationPreferences(username string) (map[string]bool, error) {
+	prefs := make(map[string]bool, len(models.NotificationTypes))
+	for _, t := range models.NotificationTypes {
+		prefs[t] = true
+	}
+
+	rows, err := r.DB.Query(`SELECT type, enabled FROM notification_preferences WHERE username = $1`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t string
+		var enabled bool
+		if err := rows.Scan(&t, &enabled); err != nil {
+			return nil, err
+		}
+		if _, known := prefs[t]; known {
+			prefs[t] = enabled
+		}
+	}
+	return prefs, rows.Err()
+}
+
+// SetNotificationPreferences turns the given notification types on or off.
+// Types missing from prefs keep their setting.
+func (r *NotificationsRepo) SetNotificationPreferences(username string, prefs map[string]bool) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range models.NotificationTypes {
+		enabled, ok := prefs[t]
+		if !ok {
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO notification_preferences (username, type, enabled) VALUES ($1, $2, $3)
+			ON CONFLICT (username, type) DO UPDATE SET enabled = EXCLUDED.enabled`,
+			username, t, enabled); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var notificationCols = []string{"id", "username", "type", "actor", "detail", "forum_id", "topic_id", "message_id", "created_at", "read_at"}
+
+func TestNotificationsRepo_CreateNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewNotificationsRepo(db)
+
+	forumID, messageID := 1, 7
+	createdAt := time.Now()
+	n := models.Notification{
+		Username:  "bob",
+		Type:      models.NotificationMention,
+		Actor:     "alice",
+		ForumID:   &forumID,
+		MessageID: &messageID,
+		CreatedAt: createdAt,
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO notifications \(username, type, actor, detail, forum_id, topic_id, message_id, created_at\)\s+SELECT(.|\n)*WHERE NOT EXISTS`).
+			WithArgs("bob", models.NotificationMention, "alice", "", &forumID, (*int)(nil), &messageID, createdAt).
+			WillReturnRows(sqlmock.NewRows(notificationCols).
+				AddRow(12, "bob", models.NotificationMention, "alice", "", 1, nil, 7, createdAt, nil))
+
+		created, err := repo.CreateNotification(n)
+		assert.NoError(t, err)
+		if assert.NotNil(t, created) {
+			assert.Equal(t, 12, created.ID)
+			assert.Equal(t, 7, *created.MessageID)
+			assert.Nil(t, created.TopicID)
+			assert.Nil(t, created.ReadAt)
+		}
+	})
+
+	t.Run("Muted", func(t *testing.T) {
+		mock.ExpectQuery(`INSERT INTO notifications`).
+			WillReturnError(sql.ErrNoRows)
+
+		_, err := repo.CreateNotification(n)
+		assert.ErrorIs(t, err, ErrNotificationMuted)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationsRepo_GetNotifications(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewNotificationsRepo(db)
+
+	testTime := time.Now()
+	mock.ExpectQuery(`FROM notifications\s+WHERE username = \$1 AND read_at IS NULL\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$2`).
+		WithArgs("bob", 3).
+		WillReturnRows(sqlmock.NewRows(notificationCols).
+			AddRow(5, "bob", models.NotificationReply, "alice", "", 1, nil, 9, testTime, nil).
+			AddRow(4, "bob", models.NotificationReaction, "carol", "👍", 1, nil, 8, testTime.Add(-time.Minute), nil).
+			AddRow(3, "bob", models.NotificationMention, "dave", "", 1, nil, 7, testTime.Add(-time.Hour), nil))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM notifications WHERE username = \$1 AND read_at IS NULL`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	page, err := repo.GetNotifications("bob", true, models.PageRequest{Limit: 2})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Notifications, 2) {
+		assert.Equal(t, 5, page.Notifications[0].ID)
+		assert.Equal(t, "👍", page.Notifications[1].Detail)
+	}
+	assert.Equal(t, 3, page.Unread)
+	assert.NotEmpty(t, page.Prev)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationsRepo_MarkRead(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewNotificationsRepo(db)
+
+	mock.ExpectExec(`UPDATE notifications SET read_at = COALESCE\(read_at, \$3\)\s+WHERE id = \$1 AND username = \$2`).
+		WithArgs(4, "bob", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.MarkRead("bob", 4))
+
+	mock.ExpectExec(`UPDATE notifications SET read_at`).
+		WithArgs(4, "eve", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.MarkRead("eve", 4), ErrNotFound)
+
+	mock.ExpectExec(`UPDATE notifications SET read_at = \$2\s+WHERE username = \$1 AND read_at IS NULL`).
+		WithArgs("bob", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 6))
+	n, err := repo.MarkAllRead("bob")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), n)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotificationsRepo_Preferences(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewNotificationsRepo(db)
+
+	mock.ExpectQuery(`SELECT type, enabled FROM notification_preferences WHERE username = \$1`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"type", "enabled"}).
+			AddRow(models.NotificationReaction, false).
+			AddRow("retired_type", false))
+	prefs, err := repo.GetNotificationPreferences("bob")
+	assert.NoError(t, err)
+	assert.Len(t, prefs, len(models.NotificationTypes))
+	assert.False(t, prefs[models.NotificationReaction])
+	assert.True(t, prefs[models.NotificationReply])
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO notification_preferences \(username, type, enabled\) VALUES \(\$1, \$2, \$3\)\s+ON CONFLICT \(username, type\) DO UPDATE`).
+		WithArgs("bob", models.NotificationMention, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO notification_preferences`).
+		WithArgs("bob", models.NotificationReaction, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.SetNotificationPreferences("bob", map[string]bool{
+		models.NotificationReaction: true,
+		models.NotificationMention:  true,
+	}))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+// keyset builds the cursor condition and sort direction for page, numbering
+// its arguments from argPos. Without a cursor, latestFirst selects whether
+// the page starts at the newest or the oldest row.
+func keyset(page models.PageRequest, argPos int, latestFirst bool) (cond string, args []interface{}, desc bool) {
+	switch {
+	case page.After != nil:
+		return fmt.Sprintf("(created_at, id) > ($%d, $%d)", argPos, argPos+1),
+			[]interface{}{page.After.CreatedAt, page.After.ID}, false
+	case page.Before != nil:
+		return fmt.Sprintf("(created_at, id) < ($%d, $%d)", argPos, argPos+1),
+			[]interface{}{page.Before.CreatedAt, page.Before.ID}, true
+	default:
+		return "", nil, latestFirst
+	}
+}
+
+func orderBy(desc bool) string {
+	if desc {
+		return "ORDER BY created_at DESC, id DESC"
+	}
+	return "ORDER BY created_at, id"
+}
+
+// pageCursors returns the cursors around a non-empty page whose first and
+// last rows are given. hasMore reports whether the query found rows beyond
+// the page in the direction it was reading.
+func pageCursors(page models.PageRequest, desc, hasMore bool, first, last models.Cursor) (prev, next string) {
+	if desc {
+		if hasMore {
+			prev = first.Encode()
+		}
+		if page.Before != nil {
+			next = last.Encode()
+		}
+		return prev, next
+	}
+	if hasMore {
+		next = last.Encode()
+	}
+	if page.After != nil {
+		prev = first.Encode()
+	}
+	return prev, next
+}
+
+// GetMessagesPage returns one page of a forum's general stream, or of a topic
+// when topicID is set. Without a cursor the newest messages are returned.
+// Messages are always ordered oldest first.
+func (r *ForumsRepo) GetMessagesPage(forumID int, topicID *int, page models.PageRequest) (*models.MessagePage, error) {
+	where := "forum_id = $1 AND topic_id IS NULL AND deleted_at IS NULL"
+	args := []interface{}{forumID}
+	if topicID != nil {
+		where = "forum_id = $1 AND topic_id = $2 AND deleted_at IS NULL"
+		args = append(args, *topicID)
+	}
+
+	cond, condArgs, desc := keyset(page, len(args)+1, true)
+	if cond != "" {
+		where += " AND " + cond
+	}
+	args = append(args, condArgs...)
+	args = append(args, page.Limit+1)
+
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM messages
+		WHERE %s
+		%s
+		LIMIT $%d`, messageColumns, where, orderBy(desc), len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := len(messages) > page.Limit
+	if hasMore {
+		messages = messages[:page.Limit]
+	}
+	if desc {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	result := &models.MessagePage{Messages: messages}
+	if n := len(messages); n > 0 {
+		first := models.Cursor{CreatedAt: messages[0].CreatedAt, ID: messages[0].ID}
+		last := models.Cursor{CreatedAt: messages[n-1].CreatedAt, ID: messages[n-1].ID}
+		result.Prev, result.Next = pageCursors(page, desc, hasMore, first, last)
+	}
+	return result, nil
+}
+
+// GetForumsPage returns one page of forums ordered oldest first. Without a
+// cursor the listing starts at the oldest forum. Pinned forums are left out;
+// they are listed separately by GetPinnedForums.
+func (r *ForumsRepo) GetForumsPage(page models.PageRequest) (*models.ForumPage, error) {
+	return r.forumsPage("deleted_at IS NULL AND NOT pinned", nil, page)
+}
+
+// forumsPage reads one page of the forums matching where, whose arguments
+// are given in args.
+func (r *ForumsRepo) forumsPage(where string, args []interface{}, page models.PageRequest) (*models.ForumPage, error) {
+	cond, condArgs, desc := keyset(page, len(args)+1, false)
+	where = "WHERE " + where
+	if cond != "" {
+		where += " AND " + cond
+	}
+	args = append(args, condArgs...)
+	args = append(args, page.Limit+1)
+
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		SELECT %s
+		FROM forums
+		%s
+		%s
+		LIMIT $%d`, forumColumns, where, orderBy(desc), len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := []models.Forum{}
+	for rows.Next() {
+		f, err := scanForum(rows)
+		if err != nil {
+			return nil, err
+		}
+		forums = append(forums, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	hasMore := len(forums) > page.Limit
+	if hasMore {
+		forums = forums[:page.Limit]
+	}
+	if desc {
+		for i, j := 0, len(forums)-1; i < j; i, j = i+1, j-1 {
+			forums[i], forums[j] = forums[j], forums[i]
+		}
+	}
+
+	result := &models.ForumPage{Forums: forums}
+	if n := len(forums); n > 0 {
+		first := models.Cursor{CreatedAt: forums[0].CreatedAt, ID: forums[0].ID}
+		last := models.Cursor{CreatedAt: forums[n-1].CreatedAt, ID: forums[n-1].ID}
+		result.Prev, result.Next = pageCursors(page, desc, hasMore, first, last)
+	}
+	return result, nil
+}
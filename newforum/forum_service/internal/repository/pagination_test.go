@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	c := models.Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123, time.UTC), ID: 42}
+
+	got, err := models.DecodeCursor(c.Encode())
+	assert.NoError(t, err)
+	assert.True(t, c.CreatedAt.Equal(got.CreatedAt))
+	assert.Equal(t, c.ID, got.ID)
+
+	_, err = models.DecodeCursor("not a cursor!")
+	assert.ErrorIs(t, err, models.ErrInvalidCursor)
+}
+
+func TestForumsRepo_GetMessagesPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	msg := func(id int) models.Message {
+		return models.Message{ID: id, ForumID: 1, Author: "user", Content: "message", CreatedAt: base.Add(time.Duration(id) * time.Minute)}
+	}
+	cursor := func(id int) *models.Cursor {
+		return &models.Cursor{CreatedAt: base.Add(time.Duration(id) * time.Minute), ID: id}
+	}
+	topicID := 3
+
+	tests := []struct {
+		name     string
+		topicID  *int
+		page     models.PageRequest
+		mock     func()
+		wantIDs  []int
+		wantPrev *models.Cursor
+		wantNext *models.Cursor
+		wantErr  bool
+	}{
+		{
+			name: "Latest Page",
+			page: models.PageRequest{Limit: 2},
+			mock: func() {
+				rows := sqlmock.NewRows(messageCols).
+					AddRow(messageRow(msg(5))...).
+					AddRow(messageRow(msg(4))...).
+					AddRow(messageRow(msg(3))...)
+				mock.ExpectQuery(`WHERE forum_id = \$1 AND topic_id IS NULL AND deleted_at IS NULL\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$2`).
+					WithArgs(1, 3).
+					WillReturnRows(rows)
+			},
+			wantIDs:  []int{4, 5},
+			wantPrev: cursor(4),
+		},
+		{
+			name: "Before Cursor",
+			page: models.PageRequest{Before: cursor(4), Limit: 2},
+			mock: func() {
+				rows := sqlmock.NewRows(messageCols).
+					AddRow(messageRow(msg(3))...).
+					AddRow(messageRow(msg(2))...)
+				mock.ExpectQuery(`AND \(created_at, id\) < \(\$2, \$3\)\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$4`).
+					WithArgs(1, cursor(4).CreatedAt, 4, 3).
+					WillReturnRows(rows)
+			},
+			wantIDs:  []int{2, 3},
+			wantNext: cursor(3),
+		},
+		{
+			name: "After Cursor",
+			page: models.PageRequest{After: cursor(1), Limit: 2},
+			mock: func() {
+				rows := sqlmock.NewRows(messageCols).
+					AddRow(messageRow(msg(2))...).
+					AddRow(messageRow(msg(3))...).
+					AddRow(messageRow(msg(4))...)
+				mock.ExpectQuery(`AND \(created_at, id\) > \(\$2, \$3\)\s+ORDER BY created_at, id\s+LIMIT \$4`).
+					WithArgs(1, cursor(1).CreatedAt, 1, 3).
+					WillReturnRows(rows)
+			},
+			wantIDs:  []int{2, 3},
+			wantPrev: cursor(2),
+			wantNext: cursor(3),
+		},
+		{
+			name:    "Topic Stream",
+			topicID: &topicID,
+			page:    models.PageRequest{Limit: 2},
+			mock: func() {
+				mock.ExpectQuery(`WHERE forum_id = \$1 AND topic_id = \$2 AND deleted_at IS NULL\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$3`).
+					WithArgs(1, 3, 3).
+					WillReturnRows(sqlmock.NewRows(messageCols))
+			},
+			wantIDs: []int{},
+		},
+		{
+			name: "Database Error",
+			page: models.PageRequest{Limit: 2},
+			mock: func() {
+				mock.ExpectQuery(`FROM messages`).
+					WillReturnError(errors.New("database error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Row Error",
+			page: models.PageRequest{Limit: 2},
+			mock: func() {
+				mock.ExpectQuery(`FROM messages`).
+					WillReturnRows(sqlmock.NewRows(messageCols).
+						AddRow(messageRow(msg(2))...).
+						RowError(0, errors.New("connection reset")))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.GetMessagesPage(1, tt.topicID, tt.page)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetMessagesPage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				ids := []int{}
+				for _, m := range got.Messages {
+					ids = append(ids, m.ID)
+				}
+				assert.Equal(t, tt.wantIDs, ids)
+				assertCursor(t, tt.wantPrev, got.Prev)
+				assertCursor(t, tt.wantNext, got.Next)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestForumsRepo_GetForumsPage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`FROM forums\s+WHERE deleted_at IS NULL AND NOT pinned\s+ORDER BY created_at, id\s+LIMIT \$1`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(forumCols).
+			AddRow(1, "Forum 1", "Desc 1", testTime, false, false, nil, nil, nil, 0, 0).
+			AddRow(2, "Forum 2", "Desc 2", testTime, false, false, nil, nil, nil, 0, 0))
+
+	got, err := repo.GetForumsPage(models.PageRequest{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Forum{{ID: 1, Title: "Forum 1", Description: "Desc 1", CreatedAt: testTime}}, got.Forums)
+	assert.Empty(t, got.Prev)
+	assertCursor(t, &models.Cursor{CreatedAt: testTime, ID: 1}, got.Next)
+
+	before := &models.Cursor{CreatedAt: testTime, ID: 2}
+	mock.ExpectQuery(`WHERE deleted_at IS NULL AND NOT pinned AND \(created_at, id\) < \(\$1, \$2\)\s+ORDER BY created_at DESC, id DESC\s+LIMIT \$3`).
+		WithArgs(testTime, 2, 2).
+		WillReturnRows(sqlmock.NewRows(forumCols).AddRow(1, "Forum 1", "Desc 1", testTime, false, false, nil, nil, nil, 0, 0))
+
+	got, err = repo.GetForumsPage(models.PageRequest{Before: before, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, got.Forums, 1)
+	assert.Empty(t, got.Prev)
+	assertCursor(t, &models.Cursor{CreatedAt: testTime, ID: 1}, got.Next)
+
+	mock.ExpectQuery(`FROM forums`).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.GetForumsPage(models.PageRequest{Limit: 1})
+	assert.Error(t, err)
+
+	mock.ExpectQuery(`FROM forums`).
+		WillReturnRows(sqlmock.NewRows(forumCols).
+			AddRow(1, "Forum 1", "Desc 1", testTime, false, false, nil, nil, nil, 0, 0).
+			RowError(0, errors.New("connection reset")))
+	_, err = repo.GetForumsPage(models.PageRequest{Limit: 1})
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func assertCursor(t *testing.T, want *models.Cursor, got string) {
+	t.Helper()
+	if want == nil {
+		assert.Empty(t, got)
+		return
+	}
+	c, err := models.DecodeCursor(got)
+	assert.NoError(t, err)
+	assert.True(t, want.CreatedAt.Equal(c.CreatedAt))
+	assert.Equal(t, want.ID, c.ID)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+var ErrPinLimit = errors.New("pinned message limit reached")
+
+// PinMessage pins a message, or changes whether an already pinned message is
+// an announcement. A forum holds at most limit pinned messages, counting
+// announcements; a limit of zero or less means no limit.
+func (r *ForumsRepo) PinMessage(messageID int, pinnedBy string, announcement bool, limit int) (*models.Message, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var forumID int
+	err = tx.QueryRow(`SELECT forum_id FROM messages WHERE id = $1 AND deleted_at IS NULL`, messageID).Scan(&forumID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if limit > 0 {
+		// Locking the forum row keeps two moderators from both taking the
+		// last free slot.
+		if _, err := tx.Exec(`SELECT id FROM forums WHERE id = $1 FOR UPDATE`, forumID); err != nil {
+			return nil, err
+		}
+		var pinned int
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM messages
+			WHERE forum_id = $1 AND pinned_at IS NOT NULL AND deleted_at IS NULL AND id <> $2`,
+			forumID, messageID).Scan(&pinned)
+		if err != nil {
+			return nil, err
+		}
+		if pinned >= limit {
+			return nil, ErrPinLimit
+		}
+	}
+
+	m, err := scanMessage(tx.QueryRow(`
+		UPDATE messages SET pinned_at = COALESCE(pinned_at, $2), pinned_by = $3, announcement = $4
+		WHERE id = $1
+		RETURNING `+messageColumns, messageID, time.Now(), pinnedBy, announcement))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &m, nil
+}
+
+func (r *ForumsRepo) UnpinMessage(messageID int) (*models.Message, error) {
+	m, err := scanMessage(r.DB.QueryRow(`
+		UPDATE messages SET pinned_at = NULL, pinned_by = '', announcement = FALSE
+		WHERE id = $1 AND pinned_at IS NOT NULL
+		RETURNING `+messageColumns, messageID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// GetPinnedMessages returns what is shown above a message stream: every
+// announcement in the forum, then the messages pinned in that stream. A nil
+// topicID selects the forum-level stream. Messages come in pin order.
+func (r *ForumsRepo) GetPinnedMessages(forumID int, topicID *int) ([]models.Message, error) {
+	rows, err := r.DB.Query(`
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE forum_id = $1 AND pinned_at IS NOT NULL AND deleted_at IS NULL
+			AND (announcement OR topic_id IS NOT DISTINCT FROM $2)
+		ORDER BY announcement DESC, pinned_at, id`, forumID, topicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_PinMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	pinned := models.Message{ID: 5, ForumID: 2, Author: "user1", Content: "Read the rules", CreatedAt: testTime,
+		PinnedAt: &testTime, PinnedBy: "mod", Announcement: true}
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT forum_id FROM messages WHERE id = \$1 AND deleted_at IS NULL`).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"forum_id"}).AddRow(2))
+		mock.ExpectExec(`SELECT id FROM forums WHERE id = \$1 FOR UPDATE`).
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM messages\s+WHERE forum_id = \$1 AND pinned_at IS NOT NULL AND deleted_at IS NULL AND id <> \$2`).
+			WithArgs(2, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(`UPDATE messages SET pinned_at = COALESCE\(pinned_at, \$2\), pinned_by = \$3, announcement = \$4`).
+			WithArgs(5, sqlmock.AnyArg(), "mod", true).
+			WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(pinned)...))
+		mock.ExpectCommit()
+
+		got, err := repo.PinMessage(5, "mod", true, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, &pinned, got)
+	})
+
+	t.Run("Limit Reached", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT forum_id FROM messages`).
+			WithArgs(6).
+			WillReturnRows(sqlmock.NewRows([]string{"forum_id"}).AddRow(2))
+		mock.ExpectExec(`FOR UPDATE`).
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM messages`).
+			WithArgs(2, 6).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectRollback()
+
+		_, err := repo.PinMessage(6, "mod", false, 3)
+		assert.ErrorIs(t, err, ErrPinLimit)
+	})
+
+	t.Run("No Limit", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT forum_id FROM messages`).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"forum_id"}).AddRow(2))
+		mock.ExpectQuery(`UPDATE messages SET pinned_at`).
+			WithArgs(5, sqlmock.AnyArg(), "mod", true).
+			WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(pinned)...))
+		mock.ExpectCommit()
+
+		_, err := repo.PinMessage(5, "mod", true, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Not Found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT forum_id FROM messages`).
+			WithArgs(999).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.PinMessage(999, "mod", false, 3)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_UnpinMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	unpinned := models.Message{ID: 5, ForumID: 2, Author: "user1", Content: "Old news", CreatedAt: time.Now()}
+
+	mock.ExpectQuery(`UPDATE messages SET pinned_at = NULL, pinned_by = '', announcement = FALSE\s+WHERE id = \$1 AND pinned_at IS NOT NULL`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(unpinned)...))
+	got, err := repo.UnpinMessage(5)
+	assert.NoError(t, err)
+	assert.Equal(t, &unpinned, got)
+
+	mock.ExpectQuery(`UPDATE messages SET pinned_at = NULL`).
+		WithArgs(6).
+		WillReturnError(sql.ErrNoRows)
+	_, err = repo.UnpinMessage(6)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_GetPinnedMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	topicID := 3
+	announcement := models.Message{ID: 1, ForumID: 2, Author: "mod", Content: "Maintenance tonight", CreatedAt: testTime,
+		PinnedAt: &testTime, PinnedBy: "mod", Announcement: true}
+	pin := models.Message{ID: 4, ForumID: 2, TopicID: &topicID, Author: "user1", Content: "FAQ", CreatedAt: testTime,
+		PinnedAt: &testTime, PinnedBy: "mod"}
+
+	mock.ExpectQuery(`WHERE forum_id = \$1 AND pinned_at IS NOT NULL AND deleted_at IS NULL\s+AND \(announcement OR topic_id IS NOT DISTINCT FROM \$2\)\s+ORDER BY announcement DESC, pinned_at, id`).
+		WithArgs(2, &topicID).
+		WillReturnRows(sqlmock.NewRows(messageCols).
+			AddRow(messageRow(announcement)...).
+			AddRow(messageRow(pin)...))
+	got, err := repo.GetPinnedMessages(2, &topicID)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Message{announcement, pin}, got)
+
+	mock.ExpectQuery(`FROM messages`).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.GetPinnedMessages(2, nil)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPollExists = errors.New("message already has a poll")
+	ErrPollClosed = errors.New("poll is closed")
+	ErrVoteFinal  = errors.New("votes in this poll cannot be changed")
+)
+
+// PollsRepository stores the polls attached to messages and their votes.
+type PollsRepository interface {
+	CreatePoll(p models.Poll) (*models.Poll, error)
+	GetPoll(messageID int, username string) (*models.Poll, error)
+	GetPolls(messageIDs []int, username string) (map[int]*models.Poll, error)
+	Vote(pollID int, username string, optionIDs []int) error
+	RetractVote(pollID int, username string) error
+}
+
+type PollsRepo struct {
+	DB *sql.DB
+}
+
+func NewPollsRepo(db *sql.DB) *PollsRepo {
+	return &PollsRepo{
+		DB: db,
+	}
+}
+
+// CreatePoll attaches p to its message. A message has at most one poll.
+func (r *PollsRepo) CreatePoll(p models.Poll) (*models.Poll, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	p.CreatedAt = time.Now()
+	err = tx.QueryRow(`
+		INSERT INTO polls (message_id, question, multiple_choice, anonymous, allow_change, closes_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (message_id) DO NOTHING
+		RETURNING id`,
+		p.MessageID, p.Question, p.MultipleChoice, p.Anonymous, p.AllowChange, p.ClosesAt, p.CreatedAt).Scan(&p.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPollExists
+		}
+		return nil, fmt.Errorf("failed to create poll: %w", err)
+	}
+
+	for i := range p.Options {
+		if err := tx.QueryRow(`
+			INSERT INTO poll_options (poll_id, position, text) VALUES ($1, $2, $3)
+			RETURNING id`, p.ID, i, p.Options[i].Text).Scan(&p.Options[i].ID); err != nil {
+			return nil, fmt.Errorf("failed to add poll option: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &p, nil
+}
+
+// GetPoll returns the poll of a message as username sees it.
+func (r *PollsRepo) GetPoll(messageID int, username string) (*models.Poll, error) {
+	polls, err := r.GetPolls([]int{messageID}, username)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := polls[messageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return p, nil
+}
+
+// GetPolls returns the polls of the given messages with their results,
+// keyed by message ID. Voted is filled in for username, and the voters of
+// each option only for public polls.
+func (r *PollsRepo) GetPolls(messageIDs []int, username string) (map[int]*models.Poll, error) {
+	rows, err := r.DB.Query(`
+		SELECT p.id, p.message_id, p.question, p.multiple_choice, p.anonymous, p.allow_change, p.closes_at, p.created_at,
+			(SELECT COUNT(DISTINCT v.username) FROM poll_votes v WHERE v.poll_id = p.id)
+		FROM polls p
+		WHERE p.message_id = ANY($1)`, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := make(map[int]*models.Poll)
+	byID := make(map[int]*models.Poll)
+	var ids []int
+	for rows.Next() {
+		p := &models.Poll{Options: []models.PollOption{}}
+		var closesAt sql.NullTime
+		if err := rows.Scan(&p.ID, &p.MessageID, &p.Question, &p.MultipleChoice, &p.Anonymous,
+			&p.AllowChange, &closesAt, &p.CreatedAt, &p.Voters); err != nil {
+			return nil, err
+		}
+		if closesAt.Valid {
+			p.ClosesAt = &closesAt.Time
+		}
+		polls[p.MessageID] = p
+		byID[p.ID] = p
+		ids = append(ids, p.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return polls, nil
+	}
+
+	options, err := r.DB.Query(`
+		SELECT o.poll_id, o.id, o.text, COUNT(v.username), COALESCE(BOOL_OR(v.username = $2), FALSE),
+			COALESCE(ARRAY_AGG(v.username ORDER BY v.created_at, v.username) FILTER (WHERE v.username IS NOT NULL), '{}')
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.poll_id = ANY($1)
+		GROUP BY o.poll_id, o.id
+		ORDER BY o.poll_id, o.position`, pq.Array(ids), username)
+	if err != nil {
+		return nil, err
+	}
+	defer options.Close()
+
+	for options.Next() {
+		var pollID int
+		var o models.PollOption
+		var voted bool
+		if err := options.Scan(&pollID, &o.ID, &o.Text, &o.Votes, &voted, pq.Array(&o.Voters)); err != nil {
+			return nil, err
+		}
+		p := byID[pollID]
+		if p.Anonymous || len(o.Voters) == 0 {
+			o.Voters = nil
+		}
+		if voted {
+			p.Voted = append(p.Voted, o.ID)
+		}
+		p.Options = append(p.Options, o)
+	}
+	return polls, options.Err()
+}
+
+// Vote records username's choice of optionIDs. A user votes once; voting
+// again replaces the earlier choice if the poll allows changing votes.
+func (r *PollsRepo) Vote(pollID int, username string, optionIDs []int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := clearVote(tx, pollID, username); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO poll_votes (poll_id, option_id, username, created_at)
+		SELECT $1, unnest($2::integer[]), $3, $4`, pollID, pq.Array(optionIDs), username, time.Now()); err != nil {
+		return fmt.Errorf("failed to save vote: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// RetractVote takes back username's vote in a poll that allows changing
+// votes.
+func (r *PollsRepo) RetractVote(pollID int, username string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var voted bool
+	if err := tx.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = $1 AND username = $2)`,
+		pollID, username).Scan(&voted); err != nil {
+		return err
+	}
+	if !voted {
+		return ErrNotFound
+	}
+	if err := clearVote(tx, pollID, username); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// clearVote removes username's earlier vote, if any, once the poll is
+// known to be open and to allow it. Locking the poll keeps concurrent votes
+// by the same user from both counting.
+func clearVote(tx *sql.Tx, pollID int, username string) error {
+	var allowChange bool
+	var closesAt sql.NullTime
+	err := tx.QueryRow(`SELECT allow_change, closes_at FROM polls WHERE id = $1 FOR UPDATE`, pollID).
+		Scan(&allowChange, &closesAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if closesAt.Valid && !time.Now().Before(closesAt.Time) {
+		return ErrPollClosed
+	}
+
+	result, err := tx.Exec(`DELETE FROM poll_votes WHERE poll_id = $1 AND username = $2`, pollID, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n > 0 && !allowChange {
+		return ErrVoteFinal
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var pollCols = []string{"id", "message_id", "question", "multiple_choice", "anonymous", "allow_change", "closes_at", "created_at", "voters"}
+
+func TestPollsRepo_CreatePoll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPollsRepo(db)
+
+	poll := models.Poll{
+		MessageID: 5,
+		Question:  "Lunch?",
+		Options:   []models.PollOption{{Text: "Pizza"}, {Text: "Sushi"}},
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO polls \(message_id, question, multiple_choice, anonymous, allow_change, closes_at, created_at\)`).
+		WithArgs(5, "Lunch?", false, false, false, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(`INSERT INTO poll_options \(poll_id, position, text\)`).
+		WithArgs(3, 0, "Pizza").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectQuery(`INSERT INTO poll_options \(poll_id, position, text\)`).
+		WithArgs(3, 1, "Sushi").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectCommit()
+
+	created, err := repo.CreatePoll(poll)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, created.ID)
+	assert.Equal(t, 8, created.Options[1].ID)
+
+	// ON CONFLICT DO NOTHING returns no row when the message has a poll.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO polls`).
+		WithArgs(5, "Lunch?", false, false, false, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err = repo.CreatePoll(poll)
+	assert.ErrorIs(t, err, ErrPollExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollsRepo_GetPolls(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPollsRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM polls p\s+WHERE p.message_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int{5, 6})).
+		WillReturnRows(sqlmock.NewRows(pollCols).
+			AddRow(3, 5, "Lunch?", false, false, true, nil, now, 2).
+			AddRow(4, 6, "Secret?", true, true, false, now.Add(time.Hour), now, 1))
+	mock.ExpectQuery(`FROM poll_options o\s+LEFT JOIN poll_votes v ON v.option_id = o.id`).
+		WithArgs(pq.Array([]int{3, 4}), "bob").
+		WillReturnRows(sqlmock.NewRows([]string{"poll_id", "id", "text", "count", "voted", "voters"}).
+			AddRow(3, 7, "Pizza", 1, true, "{bob}").
+			AddRow(3, 8, "Sushi", 1, false, "{alice}").
+			AddRow(4, 9, "Yes", 1, false, "{alice}").
+			AddRow(4, 10, "No", 0, false, "{}"))
+
+	polls, err := repo.GetPolls([]int{5, 6}, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{7}, polls[5].Voted)
+	assert.Equal(t, []string{"bob"}, polls[5].Options[0].Voters)
+	assert.Nil(t, polls[5].ClosesAt)
+	assert.Equal(t, 2, polls[5].Voters)
+
+	// Anonymous polls never name their voters.
+	assert.Nil(t, polls[6].Options[0].Voters)
+	assert.Equal(t, 1, polls[6].Options[0].Votes)
+	assert.Empty(t, polls[6].Voted)
+	assert.NotNil(t, polls[6].ClosesAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollsRepo_Vote(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPollsRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT allow_change, closes_at FROM polls WHERE id = \$1 FOR UPDATE`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"allow_change", "closes_at"}).AddRow(false, nil))
+	mock.ExpectExec(`DELETE FROM poll_votes WHERE poll_id = \$1 AND username = \$2`).
+		WithArgs(3, "bob").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO poll_votes \(poll_id, option_id, username, created_at\)\s+SELECT \$1, unnest\(\$2::integer\[\]\), \$3, \$4`).
+		WithArgs(3, pq.Array([]int{7}), "bob", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.Vote(3, "bob", []int{7}))
+
+	// A second vote is refused unless the poll allows changing it.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT allow_change, closes_at FROM polls`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"allow_change", "closes_at"}).AddRow(false, nil))
+	mock.ExpectExec(`DELETE FROM poll_votes`).
+		WithArgs(3, "bob").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.Vote(3, "bob", []int{8}), ErrVoteFinal)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT allow_change, closes_at FROM polls`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"allow_change", "closes_at"}).AddRow(true, time.Now().Add(-time.Minute)))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.Vote(3, "bob", []int{8}), ErrPollClosed)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT allow_change, closes_at FROM polls`).
+		WithArgs(4).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.Vote(4, "bob", []int{8}), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPollsRepo_RetractVote(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewPollsRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM poll_votes WHERE poll_id = \$1 AND username = \$2\)`).
+		WithArgs(3, "bob").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT allow_change, closes_at FROM polls`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"allow_change", "closes_at"}).AddRow(true, nil))
+	mock.ExpectExec(`DELETE FROM poll_votes`).
+		WithArgs(3, "bob").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.RetractVote(3, "bob"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs(3, "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.RetractVote(3, "alice"), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrReactionLimit = errors.New("reaction limit reached")
+
+// AddReaction records username's reaction to a message and returns how many
+// users now reacted with emoji. Reacting twice with the same emoji is a
+// no-op. A message carries at most limit distinct emoji; a limit of zero or
+// less means no limit.
+func (r *ForumsRepo) AddReaction(messageID int, username, emoji string, limit int) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Locking the message keeps two users from both adding the last
+	// distinct emoji.
+	var id int
+	err = tx.QueryRow(`SELECT id FROM messages WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, messageID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+
+	if limit > 0 {
+		var distinct int
+		var present bool
+		err = tx.QueryRow(`
+			SELECT COUNT(DISTINCT emoji), COALESCE(BOOL_OR(emoji = $2), FALSE)
+			FROM message_reactions WHERE message_id = $1`, messageID, emoji).Scan(&distinct, &present)
+		if err != nil {
+			return 0, err
+		}
+		if !present && distinct >= limit {
+			return 0, ErrReactionLimit
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO message_reactions (message_id, username, emoji) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, messageID, username, emoji); err != nil {
+		return 0, err
+	}
+
+	count, err := reactionCount(tx, messageID, emoji)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return count, nil
+}
+
+// RemoveReaction takes back username's reaction and returns how many users
+// still reacted to the message with emoji.
+func (r *ForumsRepo) RemoveReaction(messageID int, username, emoji string) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		DELETE FROM message_reactions WHERE message_id = $1 AND username = $2 AND emoji = $3`,
+		messageID, username, emoji)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, ErrNotFound
+	}
+
+	count, err := reactionCount(tx, messageID, emoji)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return count, nil
+}
+
+func reactionCount(tx *sql.Tx, messageID int, emoji string) (int, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM message_reactions WHERE message_id = $1 AND emoji = $2`,
+		messageID, emoji).Scan(&count)
+	return count, err
+}
+
+// GetReactions returns the reaction counts of the given messages, keyed by
+// message ID. Reacted is set on the emoji username reacted with. Emoji are
+// ordered by when they were first used on the message.
+func (r *ForumsRepo) GetReactions(messageIDs []int, username string) (map[int][]models.ReactionCount, error) {
+	rows, err := r.DB.Query(`
+		SELECT message_id, emoji, COUNT(*), BOOL_OR(username = $2)
+		FROM message_reactions
+		WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(created_at), emoji`, pq.Array(messageIDs), username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := make(map[int][]models.ReactionCount)
+	for rows.Next() {
+		var messageID int
+		var rc models.ReactionCount
+		if err := rows.Scan(&messageID, &rc.Emoji, &rc.Count, &rc.Reacted); err != nil {
+			return nil, err
+		}
+		reactions[messageID] = append(reactions[messageID], rc)
+	}
+	return reactions, rows.Err()
+}
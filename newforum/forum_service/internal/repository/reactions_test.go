@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_AddReaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM messages WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectQuery(`SELECT COUNT\(DISTINCT emoji\), COALESCE\(BOOL_OR\(emoji = \$2\), FALSE\)\s+FROM message_reactions WHERE message_id = \$1`).
+			WithArgs(5, "👍").
+			WillReturnRows(sqlmock.NewRows([]string{"count", "present"}).AddRow(2, true))
+		mock.ExpectExec(`INSERT INTO message_reactions \(message_id, username, emoji\) VALUES \(\$1, \$2, \$3\)\s+ON CONFLICT DO NOTHING`).
+			WithArgs(5, "alice", "👍").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM message_reactions WHERE message_id = \$1 AND emoji = \$2`).
+			WithArgs(5, "👍").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+		mock.ExpectCommit()
+
+		count, err := repo.AddReaction(5, "alice", "👍", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, count)
+	})
+
+	t.Run("Limit Reached", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM messages`).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectQuery(`SELECT COUNT\(DISTINCT emoji\)`).
+			WithArgs(5, "🎉").
+			WillReturnRows(sqlmock.NewRows([]string{"count", "present"}).AddRow(2, false))
+		mock.ExpectRollback()
+
+		_, err := repo.AddReaction(5, "alice", "🎉", 2)
+		assert.ErrorIs(t, err, ErrReactionLimit)
+	})
+
+	t.Run("Message Not Found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM messages`).
+			WithArgs(99).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := repo.AddReaction(99, "alice", "👍", 2)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_RemoveReaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM message_reactions WHERE message_id = \$1 AND username = \$2 AND emoji = \$3`).
+		WithArgs(5, "alice", "👍").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM message_reactions`).
+		WithArgs(5, "👍").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectCommit()
+	count, err := repo.RemoveReaction(5, "alice", "👍")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM message_reactions`).
+		WithArgs(5, "alice", "👍").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	_, err = repo.RemoveReaction(5, "alice", "👍")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_GetReactions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectQuery(`SELECT message_id, emoji, COUNT\(\*\), BOOL_OR\(username = \$2\)\s+FROM message_reactions\s+WHERE message_id = ANY\(\$1\)\s+GROUP BY message_id, emoji`).
+		WithArgs(pq.Array([]int{1, 2}), "alice").
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "emoji", "count", "reacted"}).
+			AddRow(1, "👍", 3, true).
+			AddRow(1, "🎉", 1, false).
+			AddRow(2, "❤️", 2, false))
+
+	reactions, err := repo.GetReactions([]int{1, 2}, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]models.ReactionCount{
+		1: {{Emoji: "👍", Count: 3, Reacted: true}, {Emoji: "🎉", Count: 1}},
+		2: {{Emoji: "❤️", Count: 2}},
+	}, reactions)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GetUnreadCounts returns, per forum, how many messages by other users
+// username has not read. Only forums in forumIDs are counted, or every
+// forum when forumIDs is nil. Forums without unread messages are left out.
+func (r *ForumsRepo) GetUnreadCounts(username string, forumIDs []int) (map[int]int, error) {
+	query := `
+		SELECT m.forum_id, COUNT(*)
+		FROM messages m
+		JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL
+		LEFT JOIN read_positions rp ON rp.forum_id = m.forum_id AND rp.username = $1
+		WHERE m.deleted_at IS NULL AND m.author <> $1
+			AND m.id > COALESCE(rp.last_read_message_id, 0)`
+	args := []interface{}{username}
+	if forumIDs != nil {
+		query += ` AND m.forum_id = ANY($2)`
+		args = append(args, pq.Array(forumIDs))
+	}
+	query += `
+		GROUP BY m.forum_id`
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var forumID, count int
+		if err := rows.Scan(&forumID, &count); err != nil {
+			return nil, err
+		}
+		counts[forumID] = count
+	}
+	return counts, rows.Err()
+}
+
+// GetReadPosition returns the id of the last message username has read in
+// a forum, or 0 if they have not read it yet.
+func (r *ForumsRepo) GetReadPosition(username string, forumID int) (int, error) {
+	var lastRead int
+	err := r.DB.QueryRow(`SELECT last_read_message_id FROM read_positions WHERE username = $1 AND forum_id = $2`,
+		username, forumID).Scan(&lastRead)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return lastRead, err
+}
+
+// AdvanceReadPosition marks a forum read up to messageID and returns the
+// resulting position. Positions only move forward, so reports from an
+// older tab cannot mark messages unread again.
+func (r *ForumsRepo) AdvanceReadPosition(username string, forumID, messageID int) (int, error) {
+	var lastRead int
+	err := r.DB.QueryRow(`
+		INSERT INTO read_positions (username, forum_id, last_read_message_id, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (username, forum_id) DO UPDATE SET
+			last_read_message_id = GREATEST(read_positions.last_read_message_id, EXCLUDED.last_read_message_id),
+			updated_at = EXCLUDED.updated_at
+		RETURNING last_read_message_id`,
+		username, forumID, messageID, time.Now()).Scan(&lastRead)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return lastRead, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_GetUnreadCounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectQuery(`SELECT m.forum_id, COUNT\(\*\)\s+FROM messages m\s+JOIN forums f ON f.id = m.forum_id AND f.deleted_at IS NULL\s+LEFT JOIN read_positions rp ON rp.forum_id = m.forum_id AND rp.username = \$1\s+WHERE m.deleted_at IS NULL AND m.author <> \$1\s+AND m.id > COALESCE\(rp.last_read_message_id, 0\)\s+GROUP BY m.forum_id`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"forum_id", "count"}).AddRow(1, 3).AddRow(4, 1))
+	counts, err := repo.GetUnreadCounts("bob", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 3, 4: 1}, counts)
+
+	mock.ExpectQuery(`AND m.forum_id = ANY\(\$2\)\s+GROUP BY m.forum_id`).
+		WithArgs("bob", pq.Array([]int{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"forum_id", "count"}))
+	counts, err = repo.GetUnreadCounts("bob", []int{1, 2})
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_ReadPosition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectQuery(`SELECT last_read_message_id FROM read_positions WHERE username = \$1 AND forum_id = \$2`).
+		WithArgs("bob", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id"}))
+	lastRead, err := repo.GetReadPosition("bob", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, lastRead)
+
+	mock.ExpectQuery(`INSERT INTO read_positions \(username, forum_id, last_read_message_id, updated_at\)\s+VALUES \(\$1, \$2, \$3, \$4\)\s+ON CONFLICT \(username, forum_id\) DO UPDATE SET\s+last_read_message_id = GREATEST\(read_positions.last_read_message_id, EXCLUDED.last_read_message_id\)`).
+		WithArgs("bob", 1, 10, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"last_read_message_id"}).AddRow(15))
+	lastRead, err = repo.AdvanceReadPosition("bob", 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 15, lastRead)
+
+	mock.ExpectQuery(`INSERT INTO read_positions`).
+		WithArgs("bob", 99, 10, sqlmock.AnyArg()).
+		WillReturnError(&pq.Error{Code: "23503"})
+	_, err = repo.AdvanceReadPosition("bob", 99, 10)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrAlreadyReported = errors.New("message already reported")
+
+// ReportsRepository stores user reports against messages and the actions
+// moderators take when resolving them.
+type ReportsRepository interface {
+	CreateReport(rep models.Report) (*models.Report, error)
+	GetReportQueue(forumID *int) ([]models.ReportQueueItem, error)
+	GetMessageReports(messageID int) ([]models.Report, error)
+	ResolveReports(messageID int, res models.ReportResolution) (*models.Message, error)
+}
+
+const reportColumns = `id, message_id, forum_id, reporter, reason, details, status, action, resolved_by, resolved_at, created_at`
+
+type ReportsRepo struct {
+	DB *sql.DB
+}
+
+func NewReportsRepo(db *sql.DB) *ReportsRepo {
+	return &ReportsRepo{
+		DB: db,
+	}
+}
+
+func scanReport(row rowScanner) (models.Report, error) {
+	var rep models.Report
+	err := row.Scan(&rep.ID, &rep.MessageID, &rep.ForumID, &rep.Reporter, &rep.Reason, &rep.Details,
+		&rep.Status, &rep.Action, &rep.ResolvedBy, &rep.ResolvedAt, &rep.CreatedAt)
+	return rep, err
+}
+
+// withColumns lets scanMessage read a row that carries extra columns after
+// the message ones.
+type withColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (s withColumns) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
+
+func (r *ReportsRepo) CreateReport(rep models.Report) (*models.Report, error) {
+	created, err := scanReport(r.DB.QueryRow(`
+		INSERT INTO reports (message_id, forum_id, reporter, reason, details)
+		SELECT id, forum_id, $2, $3, $4
+		FROM messages
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING `+reportColumns,
+		rep.MessageID, rep.Reporter, rep.Reason, rep.Details))
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return nil, ErrAlreadyReported
+		}
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetReportQueue groups open reports by message, most reported first. A nil
+// forumID returns the queue for every forum.
+func (r *ReportsRepo) GetReportQueue(forumID *int) ([]models.ReportQueueItem, error) {
+	where := "r.status = 'open'"
+	args := []interface{}{}
+	if forumID != nil {
+		where += " AND r.forum_id = $1"
+		args = append(args, *forumID)
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT `+qualifiedMessageColumns+`, COUNT(*), MIN(r.created_at), MAX(r.created_at), string_agg(r.reason, ',')
+		FROM reports r
+		JOIN messages m ON m.id = r.message_id
+		WHERE `+where+`
+		GROUP BY m.id
+		ORDER BY COUNT(*) DESC, MAX(r.created_at) DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load report queue: %w", err)
+	}
+	defer rows.Close()
+
+	queue := []models.ReportQueueItem{}
+	for rows.Next() {
+		var item models.ReportQueueItem
+		var reasons string
+		item.Message, err = scanMessage(withColumns{rows, []interface{}{
+			&item.ReportCount, &item.FirstReportedAt, &item.LastReportedAt, &reasons,
+		}})
+		if err != nil {
+			return nil, err
+		}
+		item.Reasons = map[string]int{}
+		for _, reason := range strings.Split(reasons, ",") {
+			item.Reasons[reason]++
+		}
+		queue = append(queue, item)
+	}
+	return queue, rows.Err()
+}
+
+// GetMessageReports returns every report filed against a message, newest first.
+func (r *ReportsRepo) GetMessageReports(messageID int) ([]models.Report, error) {
+	rows, err := r.DB.Query(`
+		SELECT `+reportColumns+`
+		FROM reports
+		WHERE message_id = $1
+		ORDER BY created_at DESC, id DESC`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		rep, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, rep)
+	}
+	return reports, rows.Err()
+}
+
+// ResolveReports applies the moderator's action to the message and closes all
+// of its open reports in one transaction. It returns the message as it is
+// afterwards, or ErrNotFound when the message has no open reports.
+func (r *ReportsRepo) ResolveReports(messageID int, res models.ReportResolution) (*models.Message, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	switch res.Action {
+	case models.ReportActionDelete:
+		_, err = tx.Exec(`UPDATE messages SET deleted_at = $2, deleted_by = $3 WHERE id = $1 AND deleted_at IS NULL`,
+			messageID, now, res.ResolvedBy)
+	case models.ReportActionHide:
+		_, err = tx.Exec(`UPDATE messages SET hidden = TRUE WHERE id = $1`, messageID)
+	case models.ReportActionWarn:
+		// The warning carries the reason most reporters picked.
+		_, err = tx.Exec(`
+			INSERT INTO user_warnings (username, message_id, reason, issued_by)
+			SELECT m.author, m.id, mode() WITHIN GROUP (ORDER BY r.reason), $2
+			FROM messages m
+			JOIN reports r ON r.message_id = m.id AND r.status = 'open'
+			WHERE m.id = $1
+			GROUP BY m.id`, messageID, res.ResolvedBy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply %s: %w", res.Action, err)
+	}
+
+	result, err := tx.Exec(`
+		UPDATE reports SET status = $2, action = $3, resolved_by = $4, resolved_at = $5
+		WHERE message_id = $1 AND status = 'open'`,
+		messageID, res.Status, res.Action, res.ResolvedBy, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reports: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+
+	msg, err := scanMessage(tx.QueryRow(`SELECT `+messageColumns+` FROM messages WHERE id = $1`, messageID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load message: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &msg, nil
+}
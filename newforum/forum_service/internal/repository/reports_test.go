@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var reportCols = []string{"id", "message_id", "forum_id", "reporter", "reason", "details", "status", "action", "resolved_by", "resolved_at", "created_at"}
+
+func TestReportsRepo_CreateReport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewReportsRepo(db)
+	testTime := time.Now()
+	report := models.Report{MessageID: 5, Reporter: "user1", Reason: "spam", Details: "ads"}
+
+	tests := []struct {
+		name    string
+		mock    func()
+		want    *models.Report
+		wantErr error
+	}{
+		{
+			name: "Success",
+			mock: func() {
+				mock.ExpectQuery(`INSERT INTO reports(.|\n)*FROM messages\s+WHERE id = \$1 AND deleted_at IS NULL`).
+					WithArgs(5, "user1", "spam", "ads").
+					WillReturnRows(sqlmock.NewRows(reportCols).
+						AddRow(1, 5, 2, "user1", "spam", "ads", "open", "", "", nil, testTime))
+			},
+			want: &models.Report{ID: 1, MessageID: 5, ForumID: 2, Reporter: "user1", Reason: "spam", Details: "ads", Status: "open", CreatedAt: testTime},
+		},
+		{
+			name: "Message Not Found",
+			mock: func() {
+				mock.ExpectQuery(`INSERT INTO reports`).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrNotFound,
+		},
+		{
+			name: "Already Reported",
+			mock: func() {
+				mock.ExpectQuery(`INSERT INTO reports`).
+					WillReturnError(&pq.Error{Code: "23505"})
+			},
+			wantErr: ErrAlreadyReported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.CreateReport(report)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReportsRepo_GetReportQueue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewReportsRepo(db)
+	testTime := time.Now()
+	msg := models.Message{ID: 5, ForumID: 2, Author: "user2", Content: "buy now", CreatedAt: testTime}
+	cols := append(append([]string{}, messageCols...), "count", "min", "max", "string_agg")
+
+	forumID := 2
+	mock.ExpectQuery(`WHERE r.status = 'open' AND r.forum_id = \$1\s+GROUP BY m.id\s+ORDER BY COUNT\(\*\) DESC`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(append(messageRow(msg), 3, testTime, testTime, "spam,spam,other")...))
+
+	got, err := repo.GetReportQueue(&forumID)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ReportQueueItem{{
+		Message:         msg,
+		ReportCount:     3,
+		Reasons:         map[string]int{"spam": 2, "other": 1},
+		FirstReportedAt: testTime,
+		LastReportedAt:  testTime,
+	}}, got)
+
+	mock.ExpectQuery(`FROM reports r`).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.GetReportQueue(nil)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportsRepo_GetMessageReports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewReportsRepo(db)
+	testTime := time.Now()
+
+	mock.ExpectQuery(`FROM reports\s+WHERE message_id = \$1`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows(reportCols).
+			AddRow(2, 5, 2, "user3", "other", "", "dismissed", "", "mod", testTime, testTime).
+			AddRow(1, 5, 2, "user1", "spam", "", "open", "", "", nil, testTime))
+
+	got, err := repo.GetMessageReports(5)
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, "dismissed", got[0].Status)
+	assert.Equal(t, &testTime, got[0].ResolvedAt)
+	assert.Nil(t, got[1].ResolvedAt)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReportsRepo_ResolveReports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewReportsRepo(db)
+	testTime := time.Now()
+	msg := models.Message{ID: 5, ForumID: 2, Author: "user2", Content: "buy now", CreatedAt: testTime}
+	hidden := msg
+	hidden.Hidden = true
+
+	tests := []struct {
+		name    string
+		res     models.ReportResolution
+		mock    func()
+		want    *models.Message
+		wantErr bool
+	}{
+		{
+			name: "Hide",
+			res:  models.ReportResolution{Status: models.ReportResolved, Action: models.ReportActionHide, ResolvedBy: "mod"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE messages SET hidden = TRUE WHERE id = \$1`).
+					WithArgs(5).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE reports SET status = \$2, action = \$3`).
+					WithArgs(5, "resolved", "hide", "mod", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectQuery(`SELECT id, forum_id, author(.|\n)*FROM messages WHERE id = \$1`).
+					WithArgs(5).
+					WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(hidden)...))
+				mock.ExpectCommit()
+			},
+			want: &hidden,
+		},
+		{
+			name: "Delete",
+			res:  models.ReportResolution{Status: models.ReportResolved, Action: models.ReportActionDelete, ResolvedBy: "mod"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE messages SET deleted_at = \$2, deleted_by = \$3`).
+					WithArgs(5, sqlmock.AnyArg(), "mod").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE reports`).
+					WithArgs(5, "resolved", "delete", "mod", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`FROM messages WHERE id = \$1`).
+					WithArgs(5).
+					WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(msg)...))
+				mock.ExpectCommit()
+			},
+			want: &msg,
+		},
+		{
+			name: "Warn",
+			res:  models.ReportResolution{Status: models.ReportResolved, Action: models.ReportActionWarn, ResolvedBy: "mod"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO user_warnings`).
+					WithArgs(5, "mod").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`UPDATE reports`).
+					WithArgs(5, "resolved", "warn", "mod", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`FROM messages WHERE id = \$1`).
+					WithArgs(5).
+					WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(msg)...))
+				mock.ExpectCommit()
+			},
+			want: &msg,
+		},
+		{
+			name: "No Open Reports",
+			res:  models.ReportResolution{Status: models.ReportDismissed, ResolvedBy: "mod"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE reports`).
+					WithArgs(5, "dismissed", "", "mod", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Action Fails",
+			res:  models.ReportResolution{Status: models.ReportResolved, Action: models.ReportActionHide, ResolvedBy: "mod"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE messages SET hidden`).
+					WillReturnError(errors.New("database error"))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.ResolveReports(5, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResolveReports() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package repository
+
+import "github.com/jaxxiy/newforum/forum_service/internal/models"
+
+// GetMessageRevisions returns the earlier versions of a message, oldest first.
+func (r *ForumsRepo) GetMessageRevisions(messageID int) ([]models.MessageRevision, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, message_id, content, edited_by, edited_at
+		FROM message_revisions
+		WHERE message_id = $1
+		ORDER BY edited_at, id`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.MessageRevision{}
+	for rows.Next() {
+		var rev models.MessageRevision
+		if err := rows.Scan(&rev.ID, &rev.MessageID, &rev.Content, &rev.EditedBy, &rev.EditedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
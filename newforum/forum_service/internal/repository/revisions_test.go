@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_GetMessageRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	cols := []string{"id", "message_id", "content", "edited_by", "edited_at"}
+
+	mock.ExpectQuery(`FROM message_revisions`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(1, 1, "first", "user1", testTime).
+			AddRow(2, 1, "second", "admin", testTime.Add(time.Minute)))
+
+	got, err := repo.GetMessageRevisions(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.MessageRevision{
+		{ID: 1, MessageID: 1, Content: "first", EditedBy: "user1", EditedAt: testTime},
+		{ID: 2, MessageID: 1, Content: "second", EditedBy: "admin", EditedAt: testTime.Add(time.Minute)},
+	}, got)
+
+	mock.ExpectQuery(`FROM message_revisions`).
+		WithArgs(2).
+		WillReturnError(errors.New("database error"))
+	_, err = repo.GetMessageRevisions(2)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+// SearchRepository runs full-text queries over forums and messages. It is kept
+// apart from ForumsRepository so another search engine can be plugged in.
+type SearchRepository interface {
+	Search(q models.SearchQuery) (*models.SearchPage, error)
+}
+
+// ts_headline marks matches with control characters so the snippet can be
+// HTML-escaped before the <mark> tags are put in.
+const (
+	highlightStart  = "\x02"
+	highlightStop   = "\x03"
+	headlineOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", ` +
+		`MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" ... "`
+)
+
+var highlighter = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+type PostgresSearchRepo struct {
+	DB *sql.DB
+}
+
+func NewSearchRepo(db *sql.DB) *PostgresSearchRepo {
+	return &PostgresSearchRepo{
+		DB: db,
+	}
+}
+
+func (r *PostgresSearchRepo) Search(q models.SearchQuery) (*models.SearchPage, error) {
+	args := []interface{}{q.Query, headlineOptions}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// The russian configuration stems ASCII words with the english stemmer,
+	// which makes it the better default for highlighting mixed content.
+	config := "'russian'"
+	tsquery := "websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1)"
+	if q.Language != "" {
+		config = arg(q.Language) + "::regconfig"
+		tsquery = fmt.Sprintf("websearch_to_tsquery(%s, $1)", config)
+	}
+
+	forumConds := []string{"f.search_vector @@ q.query", "f.deleted_at IS NULL"}
+	messageConds := []string{"m.search_vector @@ q.query", "m.deleted_at IS NULL", "NOT m.hidden", "f.deleted_at IS NULL"}
+	if q.ForumID != nil {
+		p := arg(*q.ForumID)
+		forumConds = append(forumConds, "f.id = "+p)
+		messageConds = append(messageConds, "m.forum_id = "+p)
+	}
+	if q.From != nil {
+		p := arg(*q.From)
+		forumConds = append(forumConds, "f.created_at >= "+p)
+		messageConds = append(messageConds, "m.created_at >= "+p)
+	}
+	if q.To != nil {
+		p := arg(*q.To)
+		forumConds = append(forumConds, "f.created_at <= "+p)
+		messageConds = append(messageConds, "m.created_at <= "+p)
+	}
+	if q.Author != "" {
+		messageConds = append(messageConds, "m.author = "+arg(q.Author))
+	}
+
+	var branches []string
+	// Forums have no author, so an author filter limits results to messages.
+	if q.Author == "" {
+		branches = append(branches, fmt.Sprintf(`
+			SELECT 'forum' AS kind, f.id, f.id AS forum_id, NULL::int AS topic_id, f.name AS title, '' AS author,
+			       ts_headline(%s, coalesce(nullif(f.description, ''), f.name), q.query, $2) AS snippet,
+			       ts_rank(f.search_vector, q.query) AS rank, f.created_at
+			FROM forums f, q
+			WHERE %s`, config, strings.Join(forumConds, " AND ")))
+	}
+	branches = append(branches, fmt.Sprintf(`
+			SELECT 'message' AS kind, m.id, m.forum_id, m.topic_id, f.name AS title, m.author,
+			       ts_headline(%s, m.content, q.query, $2) AS snippet,
+			       ts_rank(m.search_vector, q.query) AS rank, m.created_at
+			FROM messages m
+			JOIN forums f ON f.id = m.forum_id, q
+			WHERE %s`, config, strings.Join(messageConds, " AND ")))
+
+	limit, offset := arg(q.Limit), arg(q.Offset)
+	rows, err := r.DB.Query(fmt.Sprintf(`
+		WITH q AS (SELECT %s AS query)
+		SELECT kind, id, forum_id, topic_id, title, author, snippet, rank, created_at, COUNT(*) OVER()
+		FROM (%s) results
+		ORDER BY rank DESC, created_at DESC, id DESC
+		LIMIT %s OFFSET %s`, tsquery, strings.Join(branches, "\n\t\t\tUNION ALL"), limit, offset), args...)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	defer rows.Close()
+
+	page := &models.SearchPage{Results: []models.SearchResult{}, Limit: q.Limit, Offset: q.Offset}
+	for rows.Next() {
+		var res models.SearchResult
+		if err := rows.Scan(&res.Type, &res.ID, &res.ForumID, &res.TopicID, &res.Title, &res.Author,
+			&res.Snippet, &res.Rank, &res.CreatedAt, &page.Total); err != nil {
+			return nil, err
+		}
+		res.Snippet = highlighter.Replace(html.EscapeString(res.Snippet))
+		page.Results = append(page.Results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
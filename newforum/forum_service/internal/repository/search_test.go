@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchRepo_Search(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSearchRepo(db)
+
+	testTime := time.Now()
+	topicID := 3
+	forumID := 1
+	cols := []string{"kind", "id", "forum_id", "topic_id", "title", "author", "snippet", "rank", "created_at", "count"}
+
+	tests := []struct {
+		name    string
+		query   models.SearchQuery
+		mock    func()
+		want    *models.SearchPage
+		wantErr bool
+	}{
+		{
+			name:  "Both Languages",
+			query: models.SearchQuery{Query: "golang", Limit: 20},
+			mock: func() {
+				rows := sqlmock.NewRows(cols).
+					AddRow("forum", 1, 1, nil, "Go", "", "\x02Golang\x03 forum", 0.9, testTime, 2).
+					AddRow("message", 5, 1, topicID, "Go", "user1", "<b>\x02golang\x03</b> & more", 0.5, testTime, 2)
+				mock.ExpectQuery(`websearch_to_tsquery\('russian', \$1\) \|\| websearch_to_tsquery\('english', \$1\)(.|\n)*FROM forums f(.|\n)*UNION ALL(.|\n)*FROM messages m(.|\n)*LIMIT \$3 OFFSET \$4`).
+					WithArgs("golang", headlineOptions, 20, 0).
+					WillReturnRows(rows)
+			},
+			want: &models.SearchPage{
+				Results: []models.SearchResult{
+					{Type: "forum", ID: 1, ForumID: 1, Title: "Go", Snippet: "<mark>Golang</mark> forum", Rank: 0.9, CreatedAt: testTime},
+					{Type: "message", ID: 5, ForumID: 1, TopicID: &topicID, Title: "Go", Author: "user1",
+						Snippet: "&lt;b&gt;<mark>golang</mark>&lt;/b&gt; &amp; more", Rank: 0.5, CreatedAt: testTime},
+				},
+				Total: 2,
+				Limit: 20,
+			},
+		},
+		{
+			name: "Filters",
+			query: models.SearchQuery{
+				Query: "ошибка", Language: "russian", ForumID: &forumID, Author: "user1",
+				From: &testTime, To: &testTime, Limit: 10, Offset: 10,
+			},
+			mock: func() {
+				mock.ExpectQuery(`websearch_to_tsquery\(\$3::regconfig, \$1\)(.|\n)*m.forum_id = \$4 AND m.created_at >= \$5 AND m.created_at <= \$6 AND m.author = \$7(.|\n)*LIMIT \$8 OFFSET \$9`).
+					WithArgs("ошибка", headlineOptions, "russian", 1, testTime, testTime, "user1", 10, 10).
+					WillReturnRows(sqlmock.NewRows(cols))
+			},
+			want: &models.SearchPage{Results: []models.SearchResult{}, Limit: 10, Offset: 10},
+		},
+		{
+			name:  "Database Error",
+			query: models.SearchQuery{Query: "golang", Limit: 20},
+			mock: func() {
+				mock.ExpectQuery(`WITH q AS`).
+					WillReturnError(errors.New("database error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.Search(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Search() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/markdown"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+// SpamRepository stores what spam scoring needs: the activity of authors,
+// the messages held for review and the samples the classifier learns from.
+type SpamRepository interface {
+	GetAuthorActivity(author, content string, recentSince, duplicatesSince time.Time) (*models.AuthorActivity, error)
+	HoldMessage(held models.HeldMessage) (*models.HeldMessage, error)
+	GetHeldMessages(forumID *int) ([]models.HeldMessage, error)
+	ApproveHeldMessage(id int) (*models.Message, error)
+	RejectHeldMessage(id int) (*models.HeldMessage, error)
+	AddSpamSample(sample models.SpamSample) (*models.SpamSample, error)
+	GetSpamSamples() ([]models.SpamSample, error)
+}
+
+const (
+	heldMessageColumns = `id, forum_id, topic_id, reply_to, quote, author, content, score, signals, created_at`
+	spamSampleColumns  = `id, message_id, content, spam, marked_by, created_at`
+)
+
+type SpamRepo struct {
+	DB *sql.DB
+}
+
+func NewSpamRepo(db *sql.DB) *SpamRepo {
+	return &SpamRepo{
+		DB: db,
+	}
+}
+
+func scanHeldMessage(row rowScanner) (models.HeldMessage, error) {
+	var h models.HeldMessage
+	var signals []byte
+	err := row.Scan(&h.ID, &h.Message.ForumID, &h.Message.TopicID, &h.Message.ReplyTo, &h.Message.Quote,
+		&h.Message.Author, &h.Message.Content, &h.Spam.Score, &signals, &h.CreatedAt)
+	if err != nil {
+		return h, err
+	}
+	h.Message.CreatedAt = h.CreatedAt
+	h.Message.ContentHTML = markdown.Render(h.Message.Content)
+	return h, json.Unmarshal(signals, &h.Spam.Signals)
+}
+
+func scanSpamSample(row rowScanner) (models.SpamSample, error) {
+	var s models.SpamSample
+	err := row.Scan(&s.ID, &s.MessageID, &s.Content, &s.Spam, &s.MarkedBy, &s.CreatedAt)
+	return s, err
+}
+
+// GetAuthorActivity counts the author's messages since recentSince and the
+// messages with the same content since duplicatesSince, and looks up when
+// the author registered. Held messages count too, so a bot is not let
+// through by repeating a message that was held.
+func (r *SpamRepo) GetAuthorActivity(author, content string, recentSince, duplicatesSince time.Time) (*models.AuthorActivity, error) {
+	var a models.AuthorActivity
+	err := r.DB.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM messages WHERE author = $1 AND created_at > $3)
+				+ (SELECT COUNT(*) FROM held_messages WHERE author = $1 AND created_at > $3),
+			(SELECT COUNT(*) FROM messages WHERE md5(content) = md5($2) AND created_at > $4)
+				+ (SELECT COUNT(*) FROM held_messages WHERE md5(content) = md5($2) AND created_at > $4),
+			(SELECT created_at FROM users WHERE username = $1)`,
+		author, content, recentSince, duplicatesSince).Scan(&a.Recent, &a.Duplicates, &a.RegisteredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// HoldMessage saves a message and its attachments for review instead of
+// posting it.
+func (r *SpamRepo) HoldMessage(held models.HeldMessage) (*models.HeldMessage, error) {
+	signals, err := json.Marshal(held.Spam.Signals)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	msg := held.Message
+	now := time.Now()
+	saved, err := scanHeldMessage(tx.QueryRow(`
+		INSERT INTO held_messages (forum_id, topic_id, reply_to, quote, author, content, score, signals, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+heldMessageColumns,
+		msg.ForumID, msg.TopicID, msg.ReplyTo, msg.Quote, msg.Author, msg.Content, held.Spam.Score, signals, now))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			// The forum, topic or parent message is gone.
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to hold message: %w", err)
+	}
+
+	for _, a := range msg.Attachments {
+		a.CreatedAt = now
+		if err := tx.QueryRow(`
+			INSERT INTO held_attachments (held_message_id, uploader, filename, content_type, size, hash, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+			saved.ID, a.Uploader, a.Filename, a.ContentType, a.Size, a.Hash, now).Scan(&a.ID); err != nil {
+			return nil, fmt.Errorf("failed to hold attachment: %w", err)
+		}
+		saved.Message.Attachments = append(saved.Message.Attachments, a)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &saved, nil
+}
+
+// GetHeldMessages returns the messages waiting for review, oldest first,
+// in every forum or only in forumID.
+func (r *SpamRepo) GetHeldMessages(forumID *int) ([]models.HeldMessage, error) {
+	where := "TRUE"
+	args := []interface{}{}
+	if forumID != nil {
+		where = "forum_id = $1"
+		args = append(args, *forumID)
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT `+heldMessageColumns+`
+		FROM held_messages
+		WHERE `+where+`
+		ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	held := []models.HeldMessage{}
+	for rows.Next() {
+		h, err := scanHeldMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		held = append(held, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return held, r.heldAttachments(held)
+}
+
+// heldAttachments fills in the attachments of held messages.
+func (r *SpamRepo) heldAttachments(held []models.HeldMessage) error {
+	if len(held) == 0 {
+		return nil
+	}
+	ids := make([]int, len(held))
+	byID := make(map[int]*models.HeldMessage, len(held))
+	for i := range held {
+		ids[i] = held[i].ID
+		byID[held[i].ID] = &held[i]
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT a.held_message_id, a.id, a.uploader, a.filename, a.content_type, a.size, a.hash, b.thumbnail_type, a.created_at
+		FROM held_attachments a
+		JOIN blobs b ON b.hash = a.hash
+		WHERE a.held_message_id = ANY($1)
+		ORDER BY a.held_message_id, a.id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var heldID int
+		var a models.Attachment
+		if err := rows.Scan(&heldID, &a.ID, &a.Uploader, &a.Filename, &a.ContentType, &a.Size,
+			&a.Hash, &a.ThumbnailType, &a.CreatedAt); err != nil {
+			return err
+		}
+		h := byID[heldID]
+		h.Message.Attachments = append(h.Message.Attachments, a)
+	}
+	return rows.Err()
+}
+
+// ApproveHeldMessage posts a held message with its attachments, as of
+// now, and returns it. A message can only be approved once: the held
+// message is gone afterwards.
+func (r *SpamRepo) ApproveHeldMessage(id int) (*models.Message, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var msg models.Message
+	err = tx.QueryRow(`
+		SELECT forum_id, topic_id, reply_to, quote, author, content
+		FROM held_messages
+		WHERE id = $1
+		FOR UPDATE`, id).
+		Scan(&msg.ForumID, &msg.TopicID, &msg.ReplyTo, &msg.Quote, &msg.Author, &msg.Content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	msg.CreatedAt = time.Now()
+	msg.ContentHTML = markdown.Render(msg.Content)
+	if err := tx.QueryRow(`
+		INSERT INTO messages (forum_id, author, content, content_html, created_at, topic_id, reply_to, quote)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		msg.ForumID, msg.Author, msg.Content, msg.ContentHTML, msg.CreatedAt, msg.TopicID, msg.ReplyTo, msg.Quote).
+		Scan(&msg.ID); err != nil {
+		return nil, fmt.Errorf("failed to post held message: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		WITH a AS (
+			INSERT INTO attachments (message_id, uploader, filename, content_type, size, hash, created_at)
+			SELECT $2, uploader, filename, content_type, size, hash, $3
+			FROM held_attachments
+			WHERE held_message_id = $1
+			ORDER BY id
+			RETURNING *
+		)
+		SELECT `+attachmentColumns+`
+		FROM a
+		JOIN blobs b ON b.hash = a.hash
+		ORDER BY a.id`,
+		id, msg.ID, msg.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post held attachments: %w", err)
+	}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		msg.Attachments = append(msg.Attachments, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM held_messages WHERE id = $1", id); err != nil {
+		return nil, fmt.Errorf("failed to release held message: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return &msg, nil
+}
+
+// RejectHeldMessage deletes a held message and returns it. Its files are
+// left to the blob sweep.
+func (r *SpamRepo) RejectHeldMessage(id int) (*models.HeldMessage, error) {
+	h, err := scanHeldMessage(r.DB.QueryRow(`
+		DELETE FROM held_messages
+		WHERE id = $1
+		RETURNING `+heldMessageColumns, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &h, nil
+}
+
+// AddSpamSample records a training sample. A posted message has one
+// sample, which marking it again replaces; the sample it replaced is
+// returned so the classifier can forget it, or nil if there was none.
+func (r *SpamRepo) AddSpamSample(sample models.SpamSample) (*models.SpamSample, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous *models.SpamSample
+	if sample.MessageID != nil {
+		s, err := scanSpamSample(tx.QueryRow(`
+			DELETE FROM spam_samples
+			WHERE message_id = $1
+			RETURNING `+spamSampleColumns, *sample.MessageID))
+		switch {
+		case err == nil:
+			previous = &s
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO spam_samples (message_id, content, spam, marked_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		sample.MessageID, sample.Content, sample.Spam, sample.MarkedBy, time.Now()); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to add spam sample: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return previous, nil
+}
+
+// GetSpamSamples returns every training sample.
+func (r *SpamRepo) GetSpamSamples() ([]models.SpamSample, error) {
+	rows, err := r.DB.Query(`
+		SELECT ` + spamSampleColumns + `
+		FROM spam_samples
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []models.SpamSample{}
+	for rows.Next() {
+		s, err := scanSpamSample(rows)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
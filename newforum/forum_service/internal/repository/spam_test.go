@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	heldMessageCols = []string{"id", "forum_id", "topic_id", "reply_to", "quote", "author", "content", "score", "signals", "created_at"}
+	spamSampleCols  = []string{"id", "message_id", "content", "spam", "marked_by", "created_at"}
+)
+
+func TestSpamRepo_GetAuthorActivity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSpamRepo(db)
+
+	recent := time.Now().Add(-10 * time.Minute)
+	day := time.Now().Add(-24 * time.Hour)
+	mock.ExpectQuery(`FROM held_messages WHERE author = \$1 AND created_at > \$3.*md5\(content\) = md5\(\$2\) AND created_at > \$4`).
+		WithArgs("bob", "buy now", recent, day).
+		WillReturnRows(sqlmock.NewRows([]string{"recent", "duplicates", "registered_at"}).AddRow(4, 2, day))
+
+	activity, err := repo.GetAuthorActivity("bob", "buy now", recent, day)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.AuthorActivity{Recent: 4, Duplicates: 2, RegisteredAt: &day}, activity)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSpamRepo_HoldAndList(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSpamRepo(db)
+
+	now := time.Now()
+	signals := `{"links":1}`
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO held_messages \(forum_id, topic_id, reply_to, quote, author, content, score, signals, created_at\)`).
+		WithArgs(3, nil, nil, "", "bob", "see https://spam.example", 0.5, []byte(signals), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(heldMessageCols).
+			AddRow(1, 3, nil, nil, "", "bob", "see https://spam.example", 0.5, []byte(signals), now))
+	mock.ExpectQuery(`INSERT INTO held_attachments \(held_message_id, uploader, filename, content_type, size, hash, created_at\)`).
+		WithArgs(1, "bob", "a.txt", "text/plain", int64(3), "aa11", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectCommit()
+
+	held, err := repo.HoldMessage(models.HeldMessage{
+		Message: models.Message{ForumID: 3, Author: "bob", Content: "see https://spam.example", Attachments: []models.Attachment{
+			{Uploader: "bob", Filename: "a.txt", ContentType: "text/plain", Size: 3, Hash: "aa11"},
+		}},
+		Spam: models.SpamScore{Score: 0.5, Signals: map[string]float64{"links": 1}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, held.ID)
+	assert.Equal(t, map[string]float64{"links": 1}, held.Spam.Signals)
+	assert.Equal(t, 9, held.Message.Attachments[0].ID)
+
+	mock.ExpectQuery(`FROM held_messages\s+WHERE forum_id = \$1\s+ORDER BY created_at, id`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(heldMessageCols).
+			AddRow(1, 3, nil, nil, "", "bob", "see https://spam.example", 0.5, []byte(signals), now).
+			AddRow(2, 3, 4, nil, "", "eve", "hi", 0.8, []byte(`{}`), now))
+	mock.ExpectQuery(`FROM held_attachments a\s+JOIN blobs b ON b.hash = a.hash\s+WHERE a.held_message_id = ANY\(\$1\)`).
+		WithArgs(pq.Array([]int{1, 2})).
+		WillReturnRows(sqlmock.NewRows([]string{"held_message_id", "id", "uploader", "filename", "content_type", "size", "hash", "thumbnail_type", "created_at"}).
+			AddRow(1, 9, "bob", "a.txt", "text/plain", 3, "aa11", "", now))
+
+	forumID := 3
+	list, err := repo.GetHeldMessages(&forumID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "a.txt", list[0].Message.Attachments[0].Filename)
+	assert.Empty(t, list[1].Message.Attachments)
+	assert.Equal(t, 4, *list[1].Message.TopicID)
+	assert.Equal(t, now, list[1].Message.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSpamRepo_ApproveHeldMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSpamRepo(db)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT forum_id, topic_id, reply_to, quote, author, content\s+FROM held_messages\s+WHERE id = \$1\s+FOR UPDATE`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"forum_id", "topic_id", "reply_to", "quote", "author", "content"}).
+			AddRow(3, nil, nil, "", "bob", "`hello`"))
+	mock.ExpectQuery(`INSERT INTO messages \(forum_id, author, content, content_html, created_at, topic_id, reply_to, quote\)`).
+		WithArgs(3, "bob", "`hello`", "<p><code>hello</code></p>", sqlmock.AnyArg(), nil, nil, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(40))
+	mock.ExpectQuery(`INSERT INTO attachments .*\s+SELECT \$2, uploader, filename, content_type, size, hash, \$3\s+FROM held_attachments\s+WHERE held_message_id = \$1`).
+		WithArgs(1, 40, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(attachmentCols).
+			AddRow(12, 40, "bob", "a.png", "image/png", 9, "bb22", "image/jpeg", now))
+	mock.ExpectExec(`DELETE FROM held_messages WHERE id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM held_messages`).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"forum_id", "topic_id", "reply_to", "quote", "author", "content"}))
+	mock.ExpectRollback()
+
+	msg, err := repo.ApproveHeldMessage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, msg.ID)
+	assert.Equal(t, "<p><code>hello</code></p>", msg.ContentHTML)
+	assert.Equal(t, []models.Attachment{{
+		ID: 12, MessageID: 40, Uploader: "bob", Filename: "a.png", ContentType: "image/png",
+		Size: 9, Hash: "bb22", ThumbnailType: "image/jpeg", CreatedAt: now,
+	}}, msg.Attachments)
+
+	_, err = repo.ApproveHeldMessage(2)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSpamRepo_RejectHeldMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSpamRepo(db)
+
+	mock.ExpectQuery(`DELETE FROM held_messages\s+WHERE id = \$1\s+RETURNING`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(heldMessageCols).
+			AddRow(1, 3, nil, nil, "", "bob", "spam", 0.9, []byte(`{"repeated":1}`), time.Now()))
+	mock.ExpectQuery(`DELETE FROM held_messages`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(heldMessageCols))
+
+	held, err := repo.RejectHeldMessage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "spam", held.Message.Content)
+
+	_, err = repo.RejectHeldMessage(1)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSpamRepo_Samples(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSpamRepo(db)
+
+	now := time.Now()
+	messageID := 7
+	mock.ExpectBegin()
+	mock.ExpectQuery(`DELETE FROM spam_samples\s+WHERE message_id = \$1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(spamSampleCols).AddRow(1, 7, "hello", false, "mod", now))
+	mock.ExpectExec(`INSERT INTO spam_samples \(message_id, content, spam, marked_by, created_at\)`).
+		WithArgs(7, "hello", true, "admin", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	// Rejected held messages have no message to replace a sample of.
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO spam_samples`).
+		WithArgs(nil, "buy now", true, "mod", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`SELECT id, message_id, content, spam, marked_by, created_at\s+FROM spam_samples\s+ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows(spamSampleCols).
+			AddRow(2, 7, "hello", true, "admin", now).
+			AddRow(3, nil, "buy now", true, "mod", now))
+
+	previous, err := repo.AddSpamSample(models.SpamSample{MessageID: &messageID, Content: "hello", Spam: true, MarkedBy: "admin"})
+	assert.NoError(t, err)
+	assert.False(t, previous.Spam)
+
+	previous, err = repo.AddSpamSample(models.SpamSample{Content: "buy now", Spam: true, MarkedBy: "mod"})
+	assert.NoError(t, err)
+	assert.Nil(t, previous)
+
+	samples, err := repo.GetSpamSamples()
+	assert.NoError(t, err)
+	assert.Len(t, samples, 2)
+	assert.Nil(t, samples[1].MessageID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+// SubscriptionsRepository stores which forums users follow and how far
+// each subscriber has been mailed.
+type SubscriptionsRepository interface {
+	Subscribe(username string, forumID int, frequency string) (*models.Subscription, error)
+	Unsubscribe(username string, forumID int) error
+	GetSubscriptions(username string) ([]models.Subscription, error)
+	GetSubscribers(forumID int) ([]string, error)
+	GetDueSubscriptions(now time.Time) ([]models.Subscription, error)
+	GetDigestMessages(forumID int, username string, since, until time.Time, limit int) ([]models.Message, int, error)
+	MarkDigestSent(username string, forumIDs []int, sentAt time.Time) error
+}
+
+type SubscriptionsRepo struct {
+	DB *sql.DB
+}
+
+func NewSubscriptionsRepo(db *sql.DB) *SubscriptionsRepo {
+	return &SubscriptionsRepo{
+		DB: db,
+	}
+}
+
+// Subscribe makes the user follow a forum, or changes the frequency of an
+// existing subscription. A new subscription only covers messages posted
+// from now on.
+func (r *SubscriptionsRepo) Subscribe(username string, forumID int, frequency string) (*models.Subscription, error) {
+	now := time.Now()
+	s := models.Subscription{Username: username, ForumID: forumID}
+	err := r.DB.QueryRow(`
+		INSERT INTO forum_subscriptions (username, forum_id, frequency, created_at, last_sent_at)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (username, forum_id) DO UPDATE SET frequency = EXCLUDED.frequency
+		RETURNING frequency, created_at, last_sent_at`,
+		username, forumID, frequency, now).Scan(&s.Frequency, &s.CreatedAt, &s.LastSentAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SubscriptionsRepo) Unsubscribe(username string, forumID int) error {
+	result, err := r.DB.Exec(`DELETE FROM forum_subscriptions WHERE username = $1 AND forum_id = $2`,
+		username, forumID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetSubscriptions lists the forums a user follows, leaving out deleted
+// forums.
+func (r *SubscriptionsRepo) GetSubscriptions(username string) ([]models.Subscription, error) {
+	rows, err := r.DB.Query(`
+		SELECT s.username, s.forum_id, f.name, s.frequency, s.created_at, s.last_sent_at
+		FROM forum_subscriptions s
+		JOIN forums f ON f.id = s.forum_id AND f.deleted_at IS NULL
+		WHERE s.username = $1
+		ORDER BY f.name, s.forum_id`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []models.Subscription{}
+	for rows.Next() {
+		var s models.Subscription
+		if err := rows.Scan(&s.Username, &s.ForumID, &s.ForumTitle, &s.Frequency, &s.CreatedAt, &s.LastSentAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func (r *SubscriptionsRepo) GetSubscribers(forumID int) ([]string, error) {
+	rows, err := r.DB.Query(`SELECT username FROM forum_subscriptions WHERE forum_id = $1 ORDER BY username`, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// GetDueSubscriptions returns the subscriptions whose digest interval has
+// passed at now, grouped by subscriber. Subscribers without an email
+// address and deleted forums are left out.
+func (r *SubscriptionsRepo) GetDueSubscriptions(now time.Time) ([]models.Subscription, error) {
+	var conds []string
+	var args []interface{}
+	for _, f := range models.DigestFrequencies {
+		args = append(args, f, now.Add(-models.DigestInterval(f)))
+		conds = append(conds, fmt.Sprintf("(s.frequency = $%d AND s.last_sent_at <= $%d)", len(args)-1, len(args)))
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT s.username, u.email, s.forum_id, f.name, s.frequency, s.created_at, s.last_sent_at
+		FROM forum_subscriptions s
+		JOIN users u ON u.username = s.username
+		JOIN forums f ON f.id = s.forum_id AND f.deleted_at IS NULL
+		WHERE u.email <> '' AND (`+strings.Join(conds, " OR ")+`)
+		ORDER BY s.username, f.name, s.forum_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read due subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []models.Subscription{}
+	for rows.Next() {
+		var s models.Subscription
+		if err := rows.Scan(&s.Username, &s.Email, &s.ForumID, &s.ForumTitle, &s.Frequency, &s.CreatedAt, &s.LastSentAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// GetDigestMessages returns up to limit of the messages other users posted
+// in a forum after since and up to until, oldest first, along with how many
+// there are in total. Deleted and hidden messages are left out.
+func (r *SubscriptionsRepo) GetDigestMessages(forumID int, username string, since, until time.Time, limit int) ([]models.Message, int, error) {
+	const cond = `forum_id = $1 AND author <> $2 AND created_at > $3 AND created_at <= $4
+		AND deleted_at IS NULL AND NOT hidden`
+
+	var total int
+	if err := r.DB.QueryRow(`SELECT COUNT(*) FROM messages WHERE `+cond,
+		forumID, username, since, until).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []models.Message{}, 0, nil
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE `+cond+`
+		ORDER BY created_at, id
+		LIMIT $5`, forumID, username, since, until, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, total, rows.Err()
+}
+
+// MarkDigestSent records that the subscriber has been mailed everything
+// posted in the given forums up to sentAt.
+func (r *SubscriptionsRepo) MarkDigestSent(username string, forumIDs []int, sentAt time.Time) error {
+	_, err := r.DB.Exec(`
+		UPDATE forum_subscriptions SET last_sent_at = $3
+		WHERE username = $1 AND forum_id = ANY($2)`,
+		username, pq.Array(forumIDs), sentAt)
+	return err
+}
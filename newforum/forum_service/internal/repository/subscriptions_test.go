@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionsRepo_Subscribe(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSubscriptionsRepo(db)
+
+	testTime := time.Now()
+	mock.ExpectQuery(`INSERT INTO forum_subscriptions \(username, forum_id, frequency, created_at, last_sent_at\)\s+VALUES \(\$1, \$2, \$3, \$4, \$4\)\s+ON CONFLICT \(username, forum_id\) DO UPDATE SET frequency = EXCLUDED.frequency`).
+		WithArgs("bob", 1, models.DigestWeekly, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"frequency", "created_at", "last_sent_at"}).
+			AddRow(models.DigestWeekly, testTime, testTime))
+	sub, err := repo.Subscribe("bob", 1, models.DigestWeekly)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Subscription{Username: "bob", ForumID: 1, Frequency: models.DigestWeekly,
+		CreatedAt: testTime, LastSentAt: testTime}, sub)
+
+	mock.ExpectQuery(`INSERT INTO forum_subscriptions`).
+		WithArgs("bob", 99, models.DigestDaily, sqlmock.AnyArg()).
+		WillReturnError(&pq.Error{Code: "23503"})
+	_, err = repo.Subscribe("bob", 99, models.DigestDaily)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	mock.ExpectExec(`DELETE FROM forum_subscriptions WHERE username = \$1 AND forum_id = \$2`).
+		WithArgs("bob", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.Unsubscribe("bob", 2), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscriptionsRepo_GetDueSubscriptions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSubscriptionsRepo(db)
+
+	now := time.Now()
+	mock.ExpectQuery(`FROM forum_subscriptions s\s+JOIN users u ON u.username = s.username\s+JOIN forums f ON f.id = s.forum_id AND f.deleted_at IS NULL\s+WHERE u.email <> '' AND \(\(s.frequency = \$1 AND s.last_sent_at <= \$2\) OR \(s.frequency = \$3 AND s.last_sent_at <= \$4\) OR \(s.frequency = \$5 AND s.last_sent_at <= \$6\)\)\s+ORDER BY s.username`).
+		WithArgs(models.DigestImmediate, now, models.DigestDaily, now.Add(-24*time.Hour), models.DigestWeekly, now.Add(-7*24*time.Hour)).
+		WillReturnRows(sqlmock.NewRows([]string{"username", "email", "forum_id", "name", "frequency", "created_at", "last_sent_at"}).
+			AddRow("bob", "bob@example.com", 1, "Go", models.DigestDaily, now, now.Add(-25*time.Hour)))
+
+	due, err := repo.GetDueSubscriptions(now)
+	assert.NoError(t, err)
+	if assert.Len(t, due, 1) {
+		assert.Equal(t, "bob@example.com", due[0].Email)
+		assert.Equal(t, "Go", due[0].ForumTitle)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscriptionsRepo_GetDigestMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewSubscriptionsRepo(db)
+
+	until := time.Now()
+	since := until.Add(-time.Hour)
+
+	t.Run("New Messages", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM messages WHERE forum_id = \$1 AND author <> \$2 AND created_at > \$3 AND created_at <= \$4\s+AND deleted_at IS NULL AND NOT hidden`).
+			WithArgs(1, "bob", since, until).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery(`FROM messages\s+WHERE forum_id = \$1(.|\n)*ORDER BY created_at, id\s+LIMIT \$5`).
+			WithArgs(1, "bob", since, until, 2).
+			WillReturnRows(sqlmock.NewRows(messageCols).
+				AddRow(messageRow(models.Message{ID: 4, ForumID: 1, Author: "alice", Content: "first", CreatedAt: since})...).
+				AddRow(messageRow(models.Message{ID: 5, ForumID: 1, Author: "carol", Content: "second", CreatedAt: since})...))
+
+		messages, total, err := repo.GetDigestMessages(1, "bob", since, until, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, total)
+		assert.Len(t, messages, 2)
+	})
+
+	t.Run("Nothing New", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM messages`).
+			WithArgs(2, "bob", since, until).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		messages, total, err := repo.GetDigestMessages(2, "bob", since, until, 2)
+		assert.NoError(t, err)
+		assert.Zero(t, total)
+		assert.Empty(t, messages)
+	})
+
+	mock.ExpectExec(`UPDATE forum_subscriptions SET last_sent_at = \$3\s+WHERE username = \$1 AND forum_id = ANY\(\$2\)`).
+		WithArgs("bob", pq.Array([]int{1, 2}), until).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	assert.NoError(t, repo.MarkDigestSent("bob", []int{1, 2}, until))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
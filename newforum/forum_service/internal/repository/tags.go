@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+)
+
+var ErrTagExists = errors.New("a tag with this name already exists")
+
+// tagColumns reads a tag with the number of live forums and topics using it.
+const tagColumns = `t.id, t.name,
+		(SELECT COUNT(*) FROM forum_tags ft JOIN forums f ON f.id = ft.forum_id
+		 WHERE ft.tag_id = t.id AND f.deleted_at IS NULL) AS forum_count,
+		(SELECT COUNT(*) FROM topic_tags tt JOIN topics tp ON tp.id = tt.topic_id
+		 WHERE tt.tag_id = t.id AND tp.deleted_at IS NULL) AS topic_count`
+
+func scanTag(row rowScanner) (models.Tag, error) {
+	var t models.Tag
+	err := row.Scan(&t.ID, &t.Name, &t.ForumCount, &t.TopicCount)
+	return t, err
+}
+
+// SearchTags returns up to limit tags starting with prefix, most used first.
+// An empty prefix returns the most used tags overall.
+func (r *ForumsRepo) SearchTags(prefix string, limit int) ([]models.Tag, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, name, forum_count, topic_count
+		FROM (SELECT `+tagColumns+` FROM tags t WHERE t.name LIKE $1 || '%') s
+		ORDER BY forum_count + topic_count DESC, name
+		LIMIT $2`, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func (r *ForumsRepo) GetTag(name string) (*models.Tag, error) {
+	t, err := scanTag(r.DB.QueryRow(`SELECT `+tagColumns+` FROM tags t WHERE t.name = $1`, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *ForumsRepo) GetForumTags(forumID int) ([]string, error) {
+	return r.tagNames(`
+		SELECT t.name FROM forum_tags ft JOIN tags t ON t.id = ft.tag_id
+		WHERE ft.forum_id = $1
+		ORDER BY t.name`, forumID)
+}
+
+func (r *ForumsRepo) GetTopicTags(topicID int) ([]string, error) {
+	return r.tagNames(`
+		SELECT t.name FROM topic_tags tt JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.topic_id = $1
+		ORDER BY t.name`, topicID)
+}
+
+func (r *ForumsRepo) tagNames(query string, id int) ([]string, error) {
+	rows, err := r.DB.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SetForumTags replaces a forum's tags. Tags are expected to be normalised
+// already; unknown tags are created.
+func (r *ForumsRepo) SetForumTags(forumID int, tags []string) error {
+	return r.setTags(`SELECT id FROM forums WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`,
+		"forum_tags", "forum_id", forumID, tags)
+}
+
+// SetTopicTags replaces a topic's tags. Tags are expected to be normalised
+// already; unknown tags are created.
+func (r *ForumsRepo) SetTopicTags(topicID int, tags []string) error {
+	return r.setTags(`SELECT id FROM topics WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`,
+		"topic_tags", "topic_id", topicID, tags)
+}
+
+// setTags locks the owner row selected by lockQuery, then replaces its rows
+// in joinTable with tags.
+func (r *ForumsRepo) setTags(lockQuery, joinTable, ownerColumn string, ownerID int, tags []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRow(lockQuery, ownerID).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE %s = $1`, joinTable, ownerColumn), ownerID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// The no-op update makes RETURNING yield the id of an existing tag.
+		var tagID int
+		err := tx.QueryRow(`
+			INSERT INTO tags (name) VALUES ($1)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id`, tag).Scan(&tagID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s (%s, tag_id) VALUES ($1, $2)`, joinTable, ownerColumn),
+			ownerID, tagID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetTaggedForumsPage returns one page of the forums carrying tag, oldest
+// first. Pinned forums are listed along with the rest.
+func (r *ForumsRepo) GetTaggedForumsPage(tag string, page models.PageRequest) (*models.ForumPage, error) {
+	return r.forumsPage(`deleted_at IS NULL AND id IN (
+			SELECT ft.forum_id FROM forum_tags ft JOIN tags t ON t.id = ft.tag_id WHERE t.name = $1)`,
+		[]interface{}{tag}, page)
+}
+
+// GetTaggedTopics returns the topics carrying tag, newest first, leaving out
+// deleted topics and topics of deleted forums.
+func (r *ForumsRepo) GetTaggedTopics(tag string) ([]models.Topic, error) {
+	rows, err := r.DB.Query(`
+		SELECT tp.id, tp.forum_id, tp.title, tp.description, tp.author, tp.created_at
+		FROM topics tp
+		JOIN forums f ON f.id = tp.forum_id AND f.deleted_at IS NULL
+		JOIN topic_tags tt ON tt.topic_id = tp.id
+		JOIN tags t ON t.id = tt.tag_id
+		WHERE t.name = $1 AND tp.deleted_at IS NULL
+		ORDER BY tp.created_at DESC, tp.id DESC`, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []models.Topic{}
+	for rows.Next() {
+		var t models.Topic
+		var desc sql.NullString
+		if err := rows.Scan(&t.ID, &t.ForumID, &t.Title, &desc, &t.Author, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.Desc = desc.String
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+// RenameTag gives a tag a new, already normalised, name. Renaming onto an
+// existing tag fails with ErrTagExists; use MergeTags for that.
+func (r *ForumsRepo) RenameTag(name, newName string) error {
+	result, err := r.DB.Exec(`UPDATE tags SET name = $2 WHERE name = $1`, name, newName)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrTagExists
+		}
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MergeTags moves everything tagged source over to target and deletes
+// source.
+func (r *ForumsRepo) MergeTags(source, target string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var sourceID, targetID int
+	if err := tx.QueryRow(`SELECT id FROM tags WHERE name = $1 FOR UPDATE`, source).Scan(&sourceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := tx.QueryRow(`SELECT id FROM tags WHERE name = $1 FOR UPDATE`, target).Scan(&targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	for _, join := range []struct{ table, owner string }{{"forum_tags", "forum_id"}, {"topic_tags", "topic_id"}} {
+		if _, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %[1]s (%[2]s, tag_id)
+			SELECT %[2]s, $2 FROM %[1]s WHERE tag_id = $1
+			ON CONFLICT DO NOTHING`, join.table, join.owner), sourceID, targetID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM tags WHERE id = $1`, sourceID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var tagCols = []string{"id", "name", "forum_count", "topic_count"}
+
+func TestForumsRepo_SearchTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectQuery(`FROM \(SELECT t.id, t.name(.|\n)*FROM tags t WHERE t.name LIKE \$1 \|\| '%'\) s\s+ORDER BY forum_count \+ topic_count DESC, name\s+LIMIT \$2`).
+		WithArgs("go", 10).
+		WillReturnRows(sqlmock.NewRows(tagCols).
+			AddRow(1, "golang", 4, 10).
+			AddRow(2, "gorm", 0, 1))
+	tags, err := repo.SearchTags("go", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Tag{
+		{ID: 1, Name: "golang", ForumCount: 4, TopicCount: 10},
+		{ID: 2, Name: "gorm", TopicCount: 1},
+	}, tags)
+
+	mock.ExpectQuery(`WHERE t.name = \$1`).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+	_, err = repo.GetTag("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_SetForumTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	t.Run("Success", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM forums WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectExec(`DELETE FROM forum_tags WHERE forum_id = \$1`).
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(`INSERT INTO tags \(name\) VALUES \(\$1\)\s+ON CONFLICT \(name\) DO UPDATE SET name = EXCLUDED.name\s+RETURNING id`).
+			WithArgs("golang").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO forum_tags \(forum_id, tag_id\) VALUES \(\$1, \$2\)`).
+			WithArgs(3, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO tags`).
+			WithArgs("web-dev").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectExec(`INSERT INTO forum_tags`).
+			WithArgs(3, 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, repo.SetForumTags(3, []string{"golang", "web-dev"}))
+	})
+
+	t.Run("Topic Not Found", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT id FROM topics WHERE id = \$1 AND deleted_at IS NULL FOR UPDATE`).
+			WithArgs(99).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, repo.SetTopicTags(99, []string{"golang"}), ErrNotFound)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_GetTagged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+
+	mock.ExpectQuery(`FROM forums\s+WHERE deleted_at IS NULL AND id IN \(\s+SELECT ft.forum_id FROM forum_tags ft JOIN tags t ON t.id = ft.tag_id WHERE t.name = \$1\)\s+ORDER BY created_at, id\s+LIMIT \$2`).
+		WithArgs("golang", 21).
+		WillReturnRows(sqlmock.NewRows(forumCols).
+			AddRow(1, "Go", "", testTime, false, true, nil, nil, nil, 0, 0))
+	page, err := repo.GetTaggedForumsPage("golang", models.PageRequest{Limit: 20})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Forums, 1) {
+		assert.True(t, page.Forums[0].Pinned)
+	}
+
+	mock.ExpectQuery(`FROM topics tp(.|\n)*WHERE t.name = \$1 AND tp.deleted_at IS NULL\s+ORDER BY tp.created_at DESC, tp.id DESC`).
+		WithArgs("golang").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "forum_id", "title", "description", "author", "created_at"}).
+			AddRow(4, 1, "Modules", nil, "gopher", testTime))
+	topics, err := repo.GetTaggedTopics("golang")
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Topic{{ID: 4, ForumID: 1, Title: "Modules", Author: "gopher", CreatedAt: testTime}}, topics)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_RenameAndMergeTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectExec(`UPDATE tags SET name = \$2 WHERE name = \$1`).
+		WithArgs("golang", "go").
+		WillReturnError(&pq.Error{Code: "23505"})
+	assert.ErrorIs(t, repo.RenameTag("golang", "go"), ErrTagExists)
+
+	mock.ExpectExec(`UPDATE tags SET name`).
+		WithArgs("missing", "go").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.RenameTag("missing", "go"), ErrNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM tags WHERE name = \$1 FOR UPDATE`).
+		WithArgs("golang").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT id FROM tags WHERE name = \$1 FOR UPDATE`).
+		WithArgs("go").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`INSERT INTO forum_tags \(forum_id, tag_id\)\s+SELECT forum_id, \$2 FROM forum_tags WHERE tag_id = \$1\s+ON CONFLICT DO NOTHING`).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`INSERT INTO topic_tags \(topic_id, tag_id\)\s+SELECT topic_id, \$2 FROM topic_tags WHERE tag_id = \$1`).
+		WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectExec(`DELETE FROM tags WHERE id = \$1`).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.MergeTags("golang", "go"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM tags`).
+		WithArgs("golang").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery(`SELECT id FROM tags`).
+		WithArgs("nope").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.MergeTags("golang", "nope"), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+func (r *ForumsRepo) GetTopics(forumID int) ([]models.Topic, error) {
+	rows, err := r.DB.Query(`
+		SELECT t.id, t.forum_id, t.title, t.description, t.author, t.created_at,
+		       COUNT(m.id), MAX(m.created_at)
+		FROM topics t
+		LEFT JOIN messages m ON m.topic_id = t.id AND m.deleted_at IS NULL
+		WHERE t.forum_id = $1 AND t.deleted_at IS NULL
+		GROUP BY t.id
+		ORDER BY COALESCE(MAX(m.created_at), t.created_at) DESC`, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []models.Topic{}
+	for rows.Next() {
+		var t models.Topic
+		var desc sql.NullString
+		if err := rows.Scan(&t.ID, &t.ForumID, &t.Title, &desc, &t.Author, &t.CreatedAt,
+			&t.ReplyCount, &t.LastActivity); err != nil {
+			return nil, err
+		}
+		t.Desc = desc.String
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+func (r *ForumsRepo) GetTopicByID(id int) (*models.Topic, error) {
+	var t models.Topic
+	var desc sql.NullString
+	err := r.DB.QueryRow(`
+		SELECT id, forum_id, title, description, author, created_at
+		FROM topics
+		WHERE id = $1 AND deleted_at IS NULL`, id,
+	).Scan(&t.ID, &t.ForumID, &t.Title, &desc, &t.Author, &t.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	t.Desc = desc.String
+
+	return &t, nil
+}
+
+func (r *ForumsRepo) CreateTopic(t models.Topic) (int, error) {
+	var exists bool
+	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM forums WHERE id = $1 AND deleted_at IS NULL)", t.ForumID).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("forum check failed: %v", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("forum with ID %d not found", t.ForumID)
+	}
+
+	var id int
+	err = r.DB.QueryRow(`
+		INSERT INTO topics (forum_id, title, description, author, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		t.ForumID, t.Title, t.Desc, t.Author, t.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("insert topic failed: %w", err)
+	}
+
+	return id, nil
+}
+
+func (r *ForumsRepo) UpdateTopic(id int, t models.Topic) error {
+	result, err := r.DB.Exec(
+		`UPDATE topics SET title = $1, description = $2 WHERE id = $3 AND deleted_at IS NULL`,
+		t.Title, t.Desc, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteTopic moves a topic and its messages to the trash as deleted by
+// deletedBy. The messages get the topic's deletion time, so restoring the
+// topic brings back exactly the messages deleted with it.
+func (r *ForumsRepo) DeleteTopic(id int, deletedBy string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to delete topic: %w", err)
+	}
+	defer tx.Rollback()
+
+	deletedAt := time.Now()
+	result, err := tx.Exec(
+		`UPDATE topics SET deleted_at = $2, deleted_by = $3 WHERE id = $1 AND deleted_at IS NULL`,
+		id, deletedAt, deletedBy,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE messages SET deleted_at = $2, deleted_by = $3
+		WHERE topic_id = $1 AND deleted_at IS NULL`,
+		id, deletedAt, deletedBy,
+	); err != nil {
+		return fmt.Errorf("failed to trash topic messages: %w", err)
+	}
+	return tx.Commit()
+}
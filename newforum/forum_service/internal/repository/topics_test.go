@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_GetTopics(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	lastActivity := testTime.Add(time.Hour)
+	cols := []string{"id", "forum_id", "title", "description", "author", "created_at", "count", "max"}
+
+	tests := []struct {
+		name    string
+		forumID int
+		mock    func()
+		want    []models.Topic
+		wantErr bool
+	}{
+		{
+			name:    "Success",
+			forumID: 1,
+			mock: func() {
+				rows := sqlmock.NewRows(cols).
+					AddRow(1, 1, "Topic 1", "Desc 1", "user1", testTime, 3, lastActivity).
+					AddRow(2, 1, "Topic 2", nil, "user2", testTime, 0, nil)
+				mock.ExpectQuery(`FROM topics t`).
+					WithArgs(1).
+					WillReturnRows(rows)
+			},
+			want: []models.Topic{
+				{ID: 1, ForumID: 1, Title: "Topic 1", Desc: "Desc 1", Author: "user1", CreatedAt: testTime, ReplyCount: 3, LastActivity: &lastActivity},
+				{ID: 2, ForumID: 1, Title: "Topic 2", Author: "user2", CreatedAt: testTime},
+			},
+		},
+		{
+			name:    "Empty Result",
+			forumID: 2,
+			mock: func() {
+				mock.ExpectQuery(`FROM topics t`).
+					WithArgs(2).
+					WillReturnRows(sqlmock.NewRows(cols))
+			},
+			want: []models.Topic{},
+		},
+		{
+			name:    "Database Error",
+			forumID: 1,
+			mock: func() {
+				mock.ExpectQuery(`FROM topics t`).
+					WithArgs(1).
+					WillReturnError(errors.New("database error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.GetTopics(tt.forumID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetTopics() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr {
+				assert.Equal(t, tt.want, got)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestForumsRepo_GetTopicByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	cols := []string{"id", "forum_id", "title", "description", "author", "created_at"}
+
+	tests := []struct {
+		name    string
+		id      int
+		mock    func()
+		want    *models.Topic
+		wantErr error
+	}{
+		{
+			name: "Success",
+			id:   1,
+			mock: func() {
+				mock.ExpectQuery(`SELECT id, forum_id, title, description, author, created_at`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows(cols).AddRow(1, 2, "Topic", "Desc", "user1", testTime))
+			},
+			want: &models.Topic{ID: 1, ForumID: 2, Title: "Topic", Desc: "Desc", Author: "user1", CreatedAt: testTime},
+		},
+		{
+			name: "Not Found",
+			id:   999,
+			mock: func() {
+				mock.ExpectQuery(`SELECT id, forum_id, title, description, author, created_at`).
+					WithArgs(999).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.GetTopicByID(tt.id)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestForumsRepo_CreateTopic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	topic := models.Topic{ForumID: 1, Title: "Topic", Desc: "Desc", Author: "user1", CreatedAt: testTime}
+
+	tests := []struct {
+		name    string
+		topic   models.Topic
+		mock    func()
+		want    int
+		wantErr bool
+	}{
+		{
+			name:  "Success",
+			topic: topic,
+			mock: func() {
+				mock.ExpectQuery(`SELECT EXISTS`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+				mock.ExpectQuery(`INSERT INTO topics`).
+					WithArgs(1, "Topic", "Desc", "user1", testTime).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+			},
+			want: 5,
+		},
+		{
+			name:  "Forum Not Found",
+			topic: models.Topic{ForumID: 999},
+			mock: func() {
+				mock.ExpectQuery(`SELECT EXISTS`).
+					WithArgs(999).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			wantErr: true,
+		},
+		{
+			name:  "Database Error",
+			topic: topic,
+			mock: func() {
+				mock.ExpectQuery(`SELECT EXISTS`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+				mock.ExpectQuery(`INSERT INTO topics`).
+					WillReturnError(errors.New("database error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+
+			got, err := repo.CreateTopic(tt.topic)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CreateTopic() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("CreateTopic() got = %v, want %v", got, tt.want)
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestForumsRepo_UpdateTopic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	topic := models.Topic{Title: "New title", Desc: "New desc"}
+
+	mock.ExpectExec(`UPDATE topics`).
+		WithArgs("New title", "New desc", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.UpdateTopic(1, topic))
+
+	mock.ExpectExec(`UPDATE topics`).
+		WithArgs("New title", "New desc", 999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.UpdateTopic(999, topic), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// sameTime matches a time argument and requires every later argument it is
+// used for to carry the same time.
+type sameTime struct {
+	t *time.Time
+}
+
+func (s *sameTime) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	if s.t == nil {
+		s.t = &t
+	}
+	return s.t.Equal(t)
+}
+
+func TestForumsRepo_DeleteTopic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	// The topic and its messages share one deletion time.
+	deletedAt := &sameTime{}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE topics SET deleted_at = \$2, deleted_by = \$3 WHERE id = \$1 AND deleted_at IS NULL`).
+		WithArgs(1, deletedAt, "mod").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE messages SET deleted_at = \$2, deleted_by = \$3\s+WHERE topic_id = \$1 AND deleted_at IS NULL`).
+		WithArgs(1, deletedAt, "mod").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectCommit()
+	assert.NoError(t, repo.DeleteTopic(1, "mod"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE topics`).
+		WithArgs(999, sqlmock.AnyArg(), "mod").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, repo.DeleteTopic(999, "mod"), ErrNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE topics`).
+		WithArgs(1, sqlmock.AnyArg(), "mod").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE messages`).
+		WithArgs(1, sqlmock.AnyArg(), "mod").
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+	assert.Error(t, repo.DeleteTopic(1, "mod"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+func (r *ForumsRepo) Restore(id int) error {
+	result, err := r.DB.Exec(
+		`UPDATE forums SET deleted_at = NULL, deleted_by = '' WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RestoreMessage moves a message out of the trash. A message of a deleted
+// topic brings the topic back too, together with the messages deleted with
+// it, so it does not land in a topic nobody can see.
+func (r *ForumsRepo) RestoreMessage(id int) (*models.Message, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore message: %w", err)
+	}
+	defer tx.Rollback()
+
+	m, err := scanMessage(tx.QueryRow(`
+		UPDATE messages SET deleted_at = NULL, deleted_by = ''
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING `+messageColumns, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if m.TopicID != nil {
+		if _, err := restoreTopic(tx, *m.TopicID); err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to restore message: %w", err)
+	}
+	return &m, nil
+}
+
+// RestoreTopic moves a topic out of the trash together with the messages
+// that were deleted with it.
+func (r *ForumsRepo) RestoreTopic(id int) (*models.Topic, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore topic: %w", err)
+	}
+	defer tx.Rollback()
+
+	t, err := restoreTopic(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to restore topic: %w", err)
+	}
+	return t, nil
+}
+
+// restoreTopic restores topic id and the messages sharing its deletion time.
+// It returns ErrNotFound when the topic is not in the trash.
+func restoreTopic(tx *sql.Tx, id int) (*models.Topic, error) {
+	var deletedAt time.Time
+	err := tx.QueryRow(
+		`SELECT deleted_at FROM topics WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE`, id,
+	).Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	var t models.Topic
+	var desc sql.NullString
+	if err := tx.QueryRow(`
+		UPDATE topics SET deleted_at = NULL, deleted_by = ''
+		WHERE id = $1
+		RETURNING id, forum_id, title, description, author, created_at`, id,
+	).Scan(&t.ID, &t.ForumID, &t.Title, &desc, &t.Author, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	t.Desc = desc.String
+
+	if _, err := tx.Exec(`
+		UPDATE messages SET deleted_at = NULL, deleted_by = ''
+		WHERE topic_id = $1 AND deleted_at = $2`,
+		id, deletedAt,
+	); err != nil {
+		return nil, fmt.Errorf("failed to restore topic messages: %w", err)
+	}
+	return &t, nil
+}
+
+// GetDeletedForums returns the forums in the trash, most recently deleted first.
+func (r *ForumsRepo) GetDeletedForums() ([]models.Forum, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, name, description, created_at, deleted_at, deleted_by
+		FROM forums
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forums := []models.Forum{}
+	for rows.Next() {
+		var f models.Forum
+		if err := rows.Scan(&f.ID, &f.Title, &f.Description, &f.CreatedAt, &f.DeletedAt, &f.DeletedBy); err != nil {
+			return nil, err
+		}
+		forums = append(forums, f)
+	}
+	return forums, nil
+}
+
+// GetDeletedTopics returns the topics in the trash, most recently deleted first.
+func (r *ForumsRepo) GetDeletedTopics() ([]models.Topic, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, forum_id, title, description, author, created_at, deleted_at, deleted_by
+		FROM topics
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	topics := []models.Topic{}
+	for rows.Next() {
+		var t models.Topic
+		var desc sql.NullString
+		if err := rows.Scan(&t.ID, &t.ForumID, &t.Title, &desc, &t.Author, &t.CreatedAt, &t.DeletedAt, &t.DeletedBy); err != nil {
+			return nil, err
+		}
+		t.Desc = desc.String
+		topics = append(topics, t)
+	}
+	return topics, rows.Err()
+}
+
+// GetDeletedMessages returns the messages in the trash, most recently deleted first.
+func (r *ForumsRepo) GetDeletedMessages() ([]models.Message, error) {
+	rows, err := r.DB.Query(`
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// PurgeDeleted permanently removes forums, topics and messages deleted
+// before the given time and returns how many rows were removed.
+func (r *ForumsRepo) PurgeDeleted(before time.Time) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("purge failed: %w", err)
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	for _, query := range []string{
+		`DELETE FROM messages WHERE deleted_at < $1`,
+		`DELETE FROM topics WHERE deleted_at < $1`,
+		`DELETE FROM forums WHERE deleted_at < $1`,
+	} {
+		result, err := tx.Exec(query, before)
+		if err != nil {
+			return 0, fmt.Errorf("purge failed: %w", err)
+		}
+		n, _ := result.RowsAffected()
+		purged += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("purge failed: %w", err)
+	}
+	return purged, nil
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForumsRepo_Restore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	mock.ExpectExec(`UPDATE forums SET deleted_at = NULL`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.NoError(t, repo.Restore(1))
+
+	mock.ExpectExec(`UPDATE forums SET deleted_at = NULL`).
+		WithArgs(999).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, repo.Restore(999), ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_RestoreMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	restored := models.Message{ID: 1, ForumID: 1, Author: "user1", Content: "message", CreatedAt: testTime}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE messages SET deleted_at = NULL`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(restored)...))
+	mock.ExpectCommit()
+	got, err := repo.RestoreMessage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, &restored, got)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE messages SET deleted_at = NULL`).
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = repo.RestoreMessage(999)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	t.Run("Live Topic", func(t *testing.T) {
+		topicID := 3
+		inTopic := models.Message{ID: 2, ForumID: 1, TopicID: &topicID, Author: "user1", Content: "reply", CreatedAt: testTime}
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE messages SET deleted_at = NULL`).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(inTopic)...))
+		mock.ExpectQuery(`SELECT deleted_at FROM topics WHERE id = \$1 AND deleted_at IS NOT NULL FOR UPDATE`).
+			WithArgs(3).
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectCommit()
+		_, err := repo.RestoreMessage(2)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Deleted Topic", func(t *testing.T) {
+		topicID := 4
+		inTopic := models.Message{ID: 5, ForumID: 1, TopicID: &topicID, Author: "user1", Content: "reply", CreatedAt: testTime}
+		deletedAt := testTime.Add(time.Hour)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`UPDATE messages SET deleted_at = NULL`).
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(inTopic)...))
+		mock.ExpectQuery(`SELECT deleted_at FROM topics`).
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deletedAt))
+		mock.ExpectQuery(`UPDATE topics SET deleted_at = NULL, deleted_by = ''\s+WHERE id = \$1`).
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows(topicCols).AddRow(4, 1, "Topic", "Desc", "user1", testTime))
+		mock.ExpectExec(`UPDATE messages SET deleted_at = NULL, deleted_by = ''\s+WHERE topic_id = \$1 AND deleted_at = \$2`).
+			WithArgs(4, deletedAt).
+			WillReturnResult(sqlmock.NewResult(0, 6))
+		mock.ExpectCommit()
+		got, err := repo.RestoreMessage(5)
+		assert.NoError(t, err)
+		assert.Equal(t, &inTopic, got)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// topicCols are the columns restoreTopic reads back.
+var topicCols = []string{"id", "forum_id", "title", "description", "author", "created_at"}
+
+func TestForumsRepo_RestoreTopic(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	deletedAt := testTime.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT deleted_at FROM topics`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(deletedAt))
+	mock.ExpectQuery(`UPDATE topics SET deleted_at = NULL`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows(topicCols).AddRow(4, 1, "Topic", nil, "user1", testTime))
+	mock.ExpectExec(`UPDATE messages SET deleted_at = NULL`).
+		WithArgs(4, deletedAt).
+		WillReturnResult(sqlmock.NewResult(0, 6))
+	mock.ExpectCommit()
+	got, err := repo.RestoreTopic(4)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Topic{ID: 4, ForumID: 1, Title: "Topic", Author: "user1", CreatedAt: testTime}, got)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT deleted_at FROM topics`).
+		WithArgs(999).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	_, err = repo.RestoreTopic(999)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_GetDeletedTopics(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	deletedAt := testTime.Add(time.Hour)
+	cols := append(topicCols, "deleted_at", "deleted_by")
+
+	mock.ExpectQuery(`FROM topics\s+WHERE deleted_at IS NOT NULL\s+ORDER BY deleted_at DESC`).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(4, 1, "Topic", "Desc", "user1", testTime, deletedAt, "mod"))
+
+	got, err := repo.GetDeletedTopics()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Topic{
+		{ID: 4, ForumID: 1, Title: "Topic", Desc: "Desc", Author: "user1", CreatedAt: testTime, DeletedAt: &deletedAt, DeletedBy: "mod"},
+	}, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_GetDeletedForums(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	deletedAt := testTime.Add(time.Hour)
+	cols := []string{"id", "name", "description", "created_at", "deleted_at", "deleted_by"}
+
+	mock.ExpectQuery(`FROM forums\s+WHERE deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, "Forum", "Desc", testTime, deletedAt, "admin"))
+
+	got, err := repo.GetDeletedForums()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Forum{
+		{ID: 1, Title: "Forum", Description: "Desc", CreatedAt: testTime, DeletedAt: &deletedAt, DeletedBy: "admin"},
+	}, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_GetDeletedMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	testTime := time.Now()
+	deleted := models.Message{ID: 1, ForumID: 1, Author: "user1", Content: "message", CreatedAt: testTime, DeletedAt: &testTime, DeletedBy: "user1"}
+
+	mock.ExpectQuery(`FROM messages\s+WHERE deleted_at IS NOT NULL`).
+		WillReturnRows(sqlmock.NewRows(messageCols).AddRow(messageRow(deleted)...))
+
+	got, err := repo.GetDeletedMessages()
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Message{deleted}, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForumsRepo_PurgeDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	repo := NewForumsRepo(db)
+
+	before := time.Now().Add(-24 * time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM messages WHERE deleted_at < \$1`).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`DELETE FROM topics WHERE deleted_at < \$1`).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM forums WHERE deleted_at < \$1`).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	purged, err := repo.PurgeDeleted(before)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), purged)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM messages`).
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	_, err = repo.PurgeDeleted(before)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
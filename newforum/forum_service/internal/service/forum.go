@@ -105,11 +105,11 @@ func (s *ForumService) UpdateForum(id int, forum models.Forum) error {
 	return s.repo.Update(id, forum)
 }
 
-func (s *ForumService) DeleteForum(id int) error {
+func (s *ForumService) DeleteForum(id int, deletedBy string) error {
 	if id <= 0 {
 		return fmt.Errorf("invalid forum ID: %d", id)
 	}
-	return s.repo.Delete(id)
+	return s.repo.Delete(id, deletedBy)
 }
 
 func (s *ForumService) GetMessages(forumID int) ([]models.Message, error) {
@@ -133,7 +133,7 @@ func (s *ForumService) GetMessageByID(id int) (*models.Message, error) {
 	return s.repo.GetMessageByID(id)
 }
 
-func (s *ForumService) UpdateMessage(id int, content string) (*models.Message, error) {
+func (s *ForumService) UpdateMessage(id int, content, editedBy string) (*models.Message, error) {
 	if id <= 0 {
 		return nil, fmt.Errorf("invalid message ID: %d", id)
 	}
@@ -143,14 +143,14 @@ func (s *ForumService) UpdateMessage(id int, content string) (*models.Message, e
 	if len(content) > MaxContentLength {
 		return nil, ErrContentTooLong
 	}
-	return s.repo.PutMessage(id, content)
+	return s.repo.PutMessage(id, content, editedBy)
 }
 
-func (s *ForumService) DeleteMessage(id int) error {
+func (s *ForumService) DeleteMessage(id int, deletedBy string) error {
 	if id <= 0 {
 		return fmt.Errorf("invalid message ID: %d", id)
 	}
-	return s.repo.DeleteMessage(id)
+	return s.repo.DeleteMessage(id, deletedBy)
 }
 
 func (s *ForumService) GetUserByID(id int) (*models.User, error) {
@@ -38,8 +38,8 @@ func (m *MockForumRepo) Update(id int, forum models.Forum) error {
 	return args.Error(0)
 }
 
-func (m *MockForumRepo) Delete(id int) error {
-	args := m.Called(id)
+func (m *MockForumRepo) Delete(id int, deletedBy string) error {
+	args := m.Called(id, deletedBy)
 	return args.Error(0)
 }
 
@@ -61,16 +61,16 @@ func (m *MockForumRepo) GetMessageByID(id int) (*models.Message, error) {
 	return args.Get(0).(*models.Message), args.Error(1)
 }
 
-func (m *MockForumRepo) PutMessage(id int, content string) (*models.Message, error) {
-	args := m.Called(id, content)
+func (m *MockForumRepo) PutMessage(id int, content, editedBy string) (*models.Message, error) {
+	args := m.Called(id, content, editedBy)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.Message), args.Error(1)
 }
 
-func (m *MockForumRepo) DeleteMessage(id int) error {
-	args := m.Called(id)
+func (m *MockForumRepo) DeleteMessage(id int, deletedBy string) error {
+	args := m.Called(id, deletedBy)
 	return args.Error(0)
 }
 
@@ -92,6 +92,332 @@ func (m *MockForumRepo) GetGlobalChatHistory(limit int) ([]models.GlobalMessage,
 	return args.Get(0).([]models.GlobalMessage), args.Error(1)
 }
 
+func (m *MockForumRepo) GetTopics(forumID int) ([]models.Topic, error) {
+	args := m.Called(forumID)
+	return args.Get(0).([]models.Topic), args.Error(1)
+}
+
+func (m *MockForumRepo) GetTopicByID(id int) (*models.Topic, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Topic), args.Error(1)
+}
+
+func (m *MockForumRepo) CreateTopic(topic models.Topic) (int, error) {
+	args := m.Called(topic)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumRepo) UpdateTopic(id int, topic models.Topic) error {
+	args := m.Called(id, topic)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) DeleteTopic(id int, deletedBy string) error {
+	args := m.Called(id, deletedBy)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) GetMessageThread(messageID int) ([]models.Message, error) {
+	args := m.Called(messageID)
+	return args.Get(0).([]models.Message), args.Error(1)
+}
+
+func (m *MockForumRepo) GetMessagesPage(forumID int, topicID *int, page models.PageRequest) (*models.MessagePage, error) {
+	args := m.Called(forumID, topicID, page)
+	return args.Get(0).(*models.MessagePage), args.Error(1)
+}
+
+func (m *MockForumRepo) GetForumsPage(page models.PageRequest) (*models.ForumPage, error) {
+	args := m.Called(page)
+	return args.Get(0).(*models.ForumPage), args.Error(1)
+}
+
+func (m *MockForumRepo) GetMessageRevisions(messageID int) ([]models.MessageRevision, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.MessageRevision), args.Error(1)
+}
+
+func (m *MockForumRepo) Restore(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) RestoreMessage(id int) (*models.Message, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockForumRepo) GetDeletedForums() ([]models.Forum, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Forum), args.Error(1)
+}
+
+func (m *MockForumRepo) RestoreTopic(id int) (*models.Topic, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Topic), args.Error(1)
+}
+
+func (m *MockForumRepo) GetDeletedTopics() ([]models.Topic, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Topic), args.Error(1)
+}
+
+func (m *MockForumRepo) GetDeletedMessages() ([]models.Message, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Message), args.Error(1)
+}
+
+func (m *MockForumRepo) PurgeDeleted(before time.Time) (int64, error) {
+	args := m.Called(before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockForumRepo) GetPinnedForums() ([]models.Forum, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Forum), args.Error(1)
+}
+
+func (m *MockForumRepo) SetForumState(id int, change models.ForumStateChange) (*models.Forum, error) {
+	args := m.Called(id, change)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Forum), args.Error(1)
+}
+
+func (m *MockForumRepo) ArchiveInactiveForums(before time.Time) ([]int, error) {
+	args := m.Called(before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockForumRepo) PinMessage(messageID int, pinnedBy string, announcement bool, limit int) (*models.Message, error) {
+	args := m.Called(messageID, pinnedBy, announcement, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockForumRepo) UnpinMessage(messageID int) (*models.Message, error) {
+	args := m.Called(messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Message), args.Error(1)
+}
+
+func (m *MockForumRepo) GetPinnedMessages(forumID int, topicID *int) ([]models.Message, error) {
+	args := m.Called(forumID, topicID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Message), args.Error(1)
+}
+
+func (m *MockForumRepo) GetCategories() ([]models.Category, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Category), args.Error(1)
+}
+
+func (m *MockForumRepo) CreateCategory(c models.Category) (int, error) {
+	args := m.Called(c)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumRepo) UpdateCategory(id int, c models.Category) error {
+	args := m.Called(id, c)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) DeleteCategory(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) ReorderCategories(ids []int) error {
+	args := m.Called(ids)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) MoveForums(p models.ForumPlacement) error {
+	args := m.Called(p)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) GetForumTree() (*models.ForumTree, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ForumTree), args.Error(1)
+}
+
+func (m *MockForumRepo) SearchTags(prefix string, limit int) ([]models.Tag, error) {
+	args := m.Called(prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Tag), args.Error(1)
+}
+
+func (m *MockForumRepo) GetTag(name string) (*models.Tag, error) {
+	args := m.Called(name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Tag), args.Error(1)
+}
+
+func (m *MockForumRepo) GetForumTags(forumID int) ([]string, error) {
+	args := m.Called(forumID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumRepo) GetTopicTags(topicID int) ([]string, error) {
+	args := m.Called(topicID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumRepo) SetForumTags(forumID int, tags []string) error {
+	args := m.Called(forumID, tags)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) SetTopicTags(topicID int, tags []string) error {
+	args := m.Called(topicID, tags)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) GetTaggedForumsPage(tag string, page models.PageRequest) (*models.ForumPage, error) {
+	args := m.Called(tag, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ForumPage), args.Error(1)
+}
+
+func (m *MockForumRepo) GetTaggedTopics(tag string) ([]models.Topic, error) {
+	args := m.Called(tag)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Topic), args.Error(1)
+}
+
+func (m *MockForumRepo) RenameTag(name, newName string) error {
+	args := m.Called(name, newName)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) MergeTags(source, target string) error {
+	args := m.Called(source, target)
+	return args.Error(0)
+}
+
+func (m *MockForumRepo) AddReaction(messageID int, username, emoji string, limit int) (int, error) {
+	args := m.Called(messageID, username, emoji, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumRepo) RemoveReaction(messageID int, username, emoji string) (int, error) {
+	args := m.Called(messageID, username, emoji)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumRepo) GetReactions(messageIDs []int, username string) (map[int][]models.ReactionCount, error) {
+	args := m.Called(messageIDs, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]models.ReactionCount), args.Error(1)
+}
+
+func (m *MockForumRepo) ResolveUsernames(names []string) ([]string, error) {
+	args := m.Called(names)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumRepo) SearchUsernames(prefix string, limit int) ([]string, error) {
+	args := m.Called(prefix, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumRepo) SetMessageMentions(messageID int, usernames []string) ([]string, error) {
+	args := m.Called(messageID, usernames)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockForumRepo) GetMentions(messageIDs []int) (map[int][]string, error) {
+	args := m.Called(messageIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int][]string), args.Error(1)
+}
+
+func (m *MockForumRepo) GetUnreadCounts(username string, forumIDs []int) (map[int]int, error) {
+	args := m.Called(username, forumIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]int), args.Error(1)
+}
+
+func (m *MockForumRepo) GetReadPosition(username string, forumID int) (int, error) {
+	args := m.Called(username, forumID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockForumRepo) AdvanceReadPosition(username string, forumID, messageID int) (int, error) {
+	args := m.Called(username, forumID, messageID)
+	return args.Int(0), args.Error(1)
+}
+
 func TestNewForumService(t *testing.T) {
 	mockRepo := new(MockForumRepo)
 	service := NewForumService(mockRepo)
@@ -160,9 +486,9 @@ func TestDeleteForum(t *testing.T) {
 	mockRepo := new(MockForumRepo)
 	service := NewForumService(mockRepo)
 
-	mockRepo.On("Delete", 1).Return(nil)
+	mockRepo.On("Delete", 1, "Admin").Return(nil)
 
-	err := service.DeleteForum(1)
+	err := service.DeleteForum(1, "Admin")
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
@@ -233,9 +559,9 @@ func TestUpdateMessage(t *testing.T) {
 		Content: "Updated Message",
 	}
 
-	mockRepo.On("PutMessage", 1, "Updated Message").Return(expectedMessage, nil)
+	mockRepo.On("PutMessage", 1, "Updated Message", "User1").Return(expectedMessage, nil)
 
-	message, err := service.UpdateMessage(1, "Updated Message")
+	message, err := service.UpdateMessage(1, "Updated Message", "User1")
 	assert.NoError(t, err)
 	assert.Equal(t, expectedMessage, message)
 	mockRepo.AssertExpectations(t)
@@ -245,9 +571,9 @@ func TestDeleteMessage(t *testing.T) {
 	mockRepo := new(MockForumRepo)
 	service := NewForumService(mockRepo)
 
-	mockRepo.On("DeleteMessage", 1).Return(nil)
+	mockRepo.On("DeleteMessage", 1, "User1").Return(nil)
 
-	err := service.DeleteMessage(1)
+	err := service.DeleteMessage(1, "User1")
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
@@ -337,8 +663,8 @@ func TestErrorCases(t *testing.T) {
 	})
 
 	t.Run("DeleteForum Error", func(t *testing.T) {
-		mockRepo.On("Delete", 1).Return(assert.AnError)
-		err := service.DeleteForum(1)
+		mockRepo.On("Delete", 1, "Admin").Return(assert.AnError)
+		err := service.DeleteForum(1, "Admin")
 		assert.Error(t, err)
 	})
 
@@ -362,14 +688,14 @@ func TestErrorCases(t *testing.T) {
 	})
 
 	t.Run("UpdateMessage Error", func(t *testing.T) {
-		mockRepo.On("PutMessage", 1, "Error").Return((*models.Message)(nil), assert.AnError)
-		_, err := service.UpdateMessage(1, "Error")
+		mockRepo.On("PutMessage", 1, "Error", "User1").Return((*models.Message)(nil), assert.AnError)
+		_, err := service.UpdateMessage(1, "Error", "User1")
 		assert.Error(t, err)
 	})
 
 	t.Run("DeleteMessage Error", func(t *testing.T) {
-		mockRepo.On("DeleteMessage", 1).Return(assert.AnError)
-		err := service.DeleteMessage(1)
+		mockRepo.On("DeleteMessage", 1, "User1").Return(assert.AnError)
+		err := service.DeleteMessage(1, "User1")
 		assert.Error(t, err)
 	})
 
@@ -590,8 +916,8 @@ func TestForumOperationsWithTransaction(t *testing.T) {
 	})
 
 	t.Run("Delete Forum with Messages", func(t *testing.T) {
-		mockRepo.On("Delete", 1).Return(nil)
-		err := service.DeleteForum(1)
+		mockRepo.On("Delete", 1, "Admin").Return(nil)
+		err := service.DeleteForum(1, "Admin")
 		assert.NoError(t, err)
 	})
 }
@@ -0,0 +1,247 @@
+// Package spam scores how likely a message is to be spam.
+//
+// A score combines heuristics about the message and its author with a
+// naive Bayes classifier trained on the messages moderators marked as spam
+// or not. Each of them yields a signal between 0 and 1 that is weighed and
+// combined as independent evidence, so several weak signals together can
+// outweigh a single strong one.
+package spam
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+// MinTrainingSamples is how many spam and how many other messages the
+// classifier needs before its verdict counts.
+const MinTrainingSamples = 5
+
+const (
+	minTokenLength = 2
+	maxTokenLength = 24
+	// topTokens is how many of the most telling tokens Model lists.
+	topTokens = 10
+)
+
+const (
+	ham = iota
+	spam
+)
+
+// linkRe finds linked hosts; tokens and the link heuristic both use it.
+var linkRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)([a-z0-9][a-z0-9.-]*)`)
+
+// Classifier is a naive Bayes classifier over the words and linked
+// domains of messages. It is safe for concurrent use.
+type Classifier struct {
+	mu sync.RWMutex
+	// counts holds how often each token occurs in each class and total
+	// how many tokens the class has.
+	counts    [2]map[string]int
+	total     [2]int
+	samples   [2]int
+	trainedAt time.Time
+}
+
+func NewClassifier() *Classifier {
+	c := &Classifier{}
+	c.reset()
+	return c
+}
+
+func (c *Classifier) reset() {
+	c.counts = [2]map[string]int{make(map[string]int), make(map[string]int)}
+	c.total = [2]int{}
+	c.samples = [2]int{}
+}
+
+func class(isSpam bool) int {
+	if isSpam {
+		return spam
+	}
+	return ham
+}
+
+// Train replaces what the classifier has learnt with the given samples.
+func (c *Classifier) Train(samples []models.SpamSample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reset()
+	for _, s := range samples {
+		c.add(s.Content, class(s.Spam), 1)
+	}
+	c.trainedAt = time.Now()
+}
+
+// Learn adds a message marked as spam or not.
+func (c *Classifier) Learn(content string, isSpam bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(content, class(isSpam), 1)
+}
+
+// Forget takes back a message learnt earlier, such as when a moderator
+// changes their mind about it.
+func (c *Classifier) Forget(content string, isSpam bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(content, class(isSpam), -1)
+}
+
+func (c *Classifier) add(content string, k, n int) {
+	c.samples[k] = max(c.samples[k]+n, 0)
+	for _, t := range tokens(content) {
+		count := c.counts[k][t] + n
+		if count <= 0 {
+			delete(c.counts[k], t)
+		} else {
+			c.counts[k][t] = count
+		}
+		c.total[k] = max(c.total[k]+n, 0)
+	}
+}
+
+// Ready reports whether the classifier has seen enough of both classes.
+func (c *Classifier) Ready() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ready()
+}
+
+func (c *Classifier) ready() bool {
+	return c.samples[spam] >= MinTrainingSamples && c.samples[ham] >= MinTrainingSamples
+}
+
+// SpamProbability returns how likely content is spam, from 0 to 1, and
+// false if the classifier is not ready. Both classes are taken to be
+// equally likely up front: how many samples moderators happened to mark
+// says little about how much of the forum is spam.
+func (c *Classifier) SpamProbability(content string) (float64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.ready() {
+		return 0, false
+	}
+
+	vocabulary := c.vocabulary()
+	var logOdds float64
+	seen := make(map[string]bool)
+	for _, t := range tokens(content) {
+		// Each token counts once, so a word repeated all over a
+		// message does not drown out the rest, and tokens neither
+		// class has seen say nothing.
+		if seen[t] || (c.counts[spam][t] == 0 && c.counts[ham][t] == 0) {
+			continue
+		}
+		seen[t] = true
+		logOdds += c.logLikelihood(t, spam, vocabulary) - c.logLikelihood(t, ham, vocabulary)
+	}
+	return 1 / (1 + math.Exp(-logOdds)), true
+}
+
+// logLikelihood is the log probability of the token in class k, with
+// Laplace smoothing.
+func (c *Classifier) logLikelihood(token string, k, vocabulary int) float64 {
+	return math.Log(float64(c.counts[k][token]+1) / float64(c.total[k]+vocabulary))
+}
+
+func (c *Classifier) vocabulary() int {
+	n := len(c.counts[spam])
+	for t := range c.counts[ham] {
+		if c.counts[spam][t] == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Model describes what the classifier has learnt, with the tokens that
+// most strongly suggest spam and most strongly suggest otherwise.
+func (c *Classifier) Model() models.SpamModel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	vocabulary := c.vocabulary()
+	type weighed struct {
+		token string
+		odds  float64
+	}
+	var list []weighed
+	for k, counts := range c.counts {
+		for t, n := range counts {
+			// Tokens seen once are mostly noise; tokens in both
+			// classes are weighed when going over the spam ones.
+			if k == ham && c.counts[spam][t] > 0 || n+c.counts[1-k][t] < 2 {
+				continue
+			}
+			list = append(list, weighed{t, c.logLikelihood(t, spam, vocabulary) - c.logLikelihood(t, ham, vocabulary)})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].odds != list[j].odds {
+			return list[i].odds > list[j].odds
+		}
+		return list[i].token < list[j].token
+	})
+
+	m := models.SpamModel{
+		SpamSamples: c.samples[spam],
+		HamSamples:  c.samples[ham],
+		Vocabulary:  vocabulary,
+		Ready:       c.ready(),
+		SpamTokens:  []string{},
+		HamTokens:   []string{},
+		TrainedAt:   c.trainedAt,
+	}
+	for i := 0; i < len(list) && list[i].odds > 0 && len(m.SpamTokens) < topTokens; i++ {
+		m.SpamTokens = append(m.SpamTokens, list[i].token)
+	}
+	for i := len(list) - 1; i >= 0 && list[i].odds < 0 && len(m.HamTokens) < topTokens; i-- {
+		m.HamTokens = append(m.HamTokens, list[i].token)
+	}
+	return m
+}
+
+// tokens splits content into lowercase words and link:domain tokens for
+// the hosts it links to. Very short and very long words, such as hashes,
+// are left out.
+func tokens(content string) []string {
+	var list []string
+	for _, host := range linkedHosts(content) {
+		list = append(list, "link:"+host)
+	}
+	for _, word := range words(linkRe.ReplaceAllString(content, " ")) {
+		if n := utf8.RuneCountInString(word); n >= minTokenLength && n <= maxTokenLength {
+			list = append(list, word)
+		}
+	}
+	return list
+}
+
+func words(content string) []string {
+	return strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// linkedHosts returns the hosts content links to, without www.
+func linkedHosts(content string) []string {
+	var hosts []string
+	for _, m := range linkRe.FindAllStringSubmatch(content, -1) {
+		host := strings.TrimPrefix(strings.ToLower(strings.TrimRight(m[1], ".-")), "www.")
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,102 @@
+package spam
+
+import (
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+)
+
+const (
+	// VelocityWindow is how far back an author's messages count towards
+	// their posting velocity, and DuplicateWindow how far back messages
+	// with the same content count as repeats.
+	VelocityWindow  = 10 * time.Minute
+	DuplicateWindow = 24 * time.Hour
+
+	// freeMessages is how many messages an author can post within
+	// VelocityWindow before it starts to look like flooding, and
+	// floodMessages how many more make it certain.
+	freeMessages  = 2
+	floodMessages = 8
+	// maxDuplicates is how many earlier copies of a message make it
+	// certainly repeated.
+	maxDuplicates = 3
+	// newAccount is how old an account must be before its age no longer
+	// counts against it; it counts fully for the first day.
+	newAccount = 7 * 24 * time.Hour
+)
+
+// weights say how far each signal can push a score on its own. The
+// classifier is trusted most; a new account alone is never enough.
+var weights = map[string]float64{
+	models.SpamSignalClassifier: 0.9,
+	models.SpamSignalLinks:      0.5,
+	models.SpamSignalRepeated:   0.6,
+	models.SpamSignalNewAccount: 0.3,
+	models.SpamSignalVelocity:   0.5,
+}
+
+// Input is a message to score and what is known about its author.
+type Input struct {
+	Content string
+	// AccountAge is how long the author has been registered, zero when
+	// it is not known.
+	AccountAge time.Duration
+	// Activity does not include the message itself.
+	Activity models.AuthorActivity
+}
+
+// Score rates how likely a message is to be spam. c may be nil or not
+// ready yet, in which case only the heuristics count.
+//
+// Each signal s with weight w is taken as independent evidence that the
+// message is spam with probability w*s, so the score is the chance that
+// at least one of them is right: 1 - Π(1 - w*s).
+func Score(in Input, c *Classifier) models.SpamScore {
+	signals := map[string]float64{
+		models.SpamSignalLinks:      linkDensity(in.Content),
+		models.SpamSignalRepeated:   clamp(float64(in.Activity.Duplicates) / maxDuplicates),
+		models.SpamSignalNewAccount: accountAge(in.AccountAge),
+		models.SpamSignalVelocity:   clamp(float64(in.Activity.Recent-freeMessages) / floodMessages),
+	}
+	if c != nil {
+		if p, ok := c.SpamProbability(in.Content); ok {
+			// Anything the classifier finds no more likely spam than
+			// not is no evidence either way.
+			signals[models.SpamSignalClassifier] = clamp(2*p - 1)
+		}
+	}
+
+	notSpam := 1.0
+	for name, s := range signals {
+		notSpam *= 1 - weights[name]*s
+	}
+	return models.SpamScore{Score: 1 - notSpam, Signals: signals}
+}
+
+// linkDensity grows with the share of links among the words of a message.
+// Short messages are not penalised for their one link as much as the
+// share alone would: a bare link scores 0.9, a link in a ten-word message
+// 0.5.
+func linkDensity(content string) float64 {
+	links := len(linkedHosts(content))
+	if links == 0 {
+		return 0
+	}
+	n := len(words(linkRe.ReplaceAllString(content, " ")))
+	return clamp(float64(links*10) / float64(n+links+10))
+}
+
+func accountAge(age time.Duration) float64 {
+	switch {
+	case age <= 0 || age >= newAccount:
+		return 0
+	case age <= 24*time.Hour:
+		return 1
+	}
+	return float64(newAccount-age) / float64(newAccount-24*time.Hour)
+}
+
+func clamp(v float64) float64 {
+	return max(0, min(1, v))
+}
@@ -0,0 +1,127 @@
+package spam
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jaxxiy/newforum/forum_service/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func trainingSamples() []models.SpamSample {
+	var samples []models.SpamSample
+	for i := 0; i < MinTrainingSamples; i++ {
+		samples = append(samples,
+			models.SpamSample{Content: fmt.Sprintf("Cheap pills %d, buy now at https://pills.example", i), Spam: true},
+			models.SpamSample{Content: fmt.Sprintf("Has anyone tried the new release %d of the compiler?", i)},
+		)
+	}
+	return samples
+}
+
+func TestTokens(t *testing.T) {
+	assert.Equal(t,
+		[]string{"link:spam.example", "link:shop.io", "visit", "buy", "now", "and", "привет"},
+		tokens("Visit https://www.Spam.example/buy-NOW and www.shop.io. Привет x"))
+}
+
+func TestClassifier(t *testing.T) {
+	c := NewClassifier()
+	_, ok := c.SpamProbability("cheap pills")
+	assert.False(t, ok, "an untrained classifier has no verdict")
+
+	c.Train(trainingSamples())
+	assert.True(t, c.Ready())
+
+	p, ok := c.SpamProbability("buy cheap pills")
+	assert.True(t, ok)
+	assert.Greater(t, p, 0.9)
+
+	p, _ = c.SpamProbability("the compiler release notes")
+	assert.Less(t, p, 0.1)
+
+	p, _ = c.SpamProbability("completely unrelated words")
+	assert.Equal(t, 0.5, p)
+
+	m := c.Model()
+	assert.Equal(t, MinTrainingSamples, m.SpamSamples)
+	assert.Equal(t, MinTrainingSamples, m.HamSamples)
+	assert.Contains(t, m.SpamTokens, "link:pills.example")
+	assert.Contains(t, m.HamTokens, "compiler")
+
+	c.Forget("Cheap pills 0, buy now at https://pills.example", true)
+	assert.False(t, c.Ready())
+	c.Learn("more cheap pills", true)
+	assert.True(t, c.Ready())
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   Input
+		held    bool
+		signals map[string]float64
+	}{
+		{
+			name:  "Ordinary Message",
+			input: Input{Content: "I think the second option is better", AccountAge: 30 * 24 * time.Hour},
+			signals: map[string]float64{
+				models.SpamSignalLinks:      0,
+				models.SpamSignalRepeated:   0,
+				models.SpamSignalNewAccount: 0,
+				models.SpamSignalVelocity:   0,
+			},
+		},
+		{
+			name:  "New Account Alone",
+			input: Input{Content: "hello everyone", AccountAge: time.Hour},
+			signals: map[string]float64{
+				models.SpamSignalLinks:      0,
+				models.SpamSignalRepeated:   0,
+				models.SpamSignalNewAccount: 1,
+				models.SpamSignalVelocity:   0,
+			},
+		},
+		{
+			name: "Repeated Links From New Account",
+			input: Input{
+				Content:    "https://a.example https://b.example https://c.example",
+				AccountAge: time.Hour,
+				Activity:   models.AuthorActivity{Recent: 4, Duplicates: 3},
+			},
+			held: true,
+			signals: map[string]float64{
+				models.SpamSignalLinks:      1,
+				models.SpamSignalRepeated:   1,
+				models.SpamSignalNewAccount: 1,
+				models.SpamSignalVelocity:   0.25,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := Score(tt.input, nil)
+			assert.Equal(t, tt.signals, score.Signals)
+			assert.Equal(t, tt.held, score.Score >= 0.7, "score %.2f", score.Score)
+		})
+	}
+}
+
+func TestScoreWithClassifier(t *testing.T) {
+	c := NewClassifier()
+	c.Train(trainingSamples())
+
+	score := Score(Input{Content: "buy cheap pills now", AccountAge: 30 * 24 * time.Hour}, c)
+	assert.Greater(t, score.Signals[models.SpamSignalClassifier], 0.9)
+	assert.Greater(t, score.Score, 0.8)
+
+	// Looking like the messages marked as not spam is no evidence either
+	// way.
+	score = Score(Input{Content: "a new compiler release", AccountAge: time.Hour}, c)
+	assert.Equal(t, 0.0, score.Signals[models.SpamSignalClassifier])
+	assert.InDelta(t, 0.3, score.Score, 1e-9)
+
+	assert.Equal(t, 0.5, accountAge(4*24*time.Hour))
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_messages_topic_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS topic_id;
+
+DROP INDEX IF EXISTS idx_topics_forum_id;
+DROP TABLE IF EXISTS topics;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS topics (
+    id SERIAL PRIMARY KEY,
+    forum_id INTEGER NOT NULL REFERENCES forums(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    author VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_topics_forum_id ON topics(forum_id);
+
+-- Messages without a topic stay in the forum's general stream
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_messages_topic_id ON messages(topic_id);
@@ -0,0 +1,4 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to INTEGER REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS quote TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_messages_reply_to ON messages(reply_to);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_forums_created;
+DROP INDEX IF EXISTS idx_messages_topic_created;
+DROP INDEX IF EXISTS idx_messages_forum_created;
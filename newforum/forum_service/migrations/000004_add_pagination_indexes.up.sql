@@ -0,0 +1,4 @@
+-- Keyset pagination walks (created_at, id) inside a forum, a topic or the forum list
+CREATE INDEX IF NOT EXISTS idx_messages_forum_created ON messages(forum_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_messages_topic_created ON messages(topic_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_forums_created ON forums(created_at, id);
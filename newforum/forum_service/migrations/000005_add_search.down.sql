@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_messages_search;
+DROP INDEX IF EXISTS idx_forums_search;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE forums DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,18 @@
+-- Content is mixed Russian and English, so every document is indexed under
+-- both configurations and a query can match either stemming.
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(description, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        to_tsvector('russian', coalesce(content, '')) ||
+        to_tsvector('english', coalesce(content, ''))
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_forums_search ON forums USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search_vector);
@@ -0,0 +1,4 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS edit_count;
+ALTER TABLE messages DROP COLUMN IF EXISTS edited_at;
+
+DROP TABLE IF EXISTS message_revisions;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_messages_deleted_at;
+DROP INDEX IF EXISTS idx_forums_deleted_at;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE forums DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE forums DROP COLUMN IF EXISTS deleted_at;
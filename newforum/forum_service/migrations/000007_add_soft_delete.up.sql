@@ -0,0 +1,9 @@
+-- Deleted forums and messages stay in place until the purge job removes them
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(255) NOT NULL DEFAULT '';
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_forums_deleted_at ON forums(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS hidden;
+
+DROP TABLE IF EXISTS user_warnings;
+DROP TABLE IF EXISTS reports;
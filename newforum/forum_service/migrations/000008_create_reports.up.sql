@@ -0,0 +1,30 @@
+-- Users flag messages; moderators work through the open reports per message
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    forum_id INTEGER NOT NULL REFERENCES forums(id) ON DELETE CASCADE,
+    reporter VARCHAR(255) NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    action VARCHAR(16) NOT NULL DEFAULT '',
+    resolved_by VARCHAR(255) NOT NULL DEFAULT '',
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- A user has at most one open report per message
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open ON reports(message_id, reporter) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS user_warnings (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+    reason VARCHAR(32) NOT NULL,
+    issued_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_warnings_username ON user_warnings(username);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS hidden BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS moderation_log_append_only ON moderation_log;
+DROP FUNCTION IF EXISTS moderation_log_append_only();
+
+DROP TABLE IF EXISTS moderation_log;
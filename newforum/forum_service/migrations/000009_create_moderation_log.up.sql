@@ -0,0 +1,28 @@
+-- Every moderation action with the state of its target before and after
+CREATE TABLE IF NOT EXISTS moderation_log (
+    id SERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id INTEGER NOT NULL,
+    forum_id INTEGER,
+    reason TEXT NOT NULL DEFAULT '',
+    before JSONB,
+    after JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_log_created_at ON moderation_log(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_moderation_log_target ON moderation_log(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_moderation_log_actor ON moderation_log(actor);
+
+-- The log is append-only: rows can never be changed or removed
+CREATE OR REPLACE FUNCTION moderation_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'moderation_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER moderation_log_append_only
+    BEFORE UPDATE OR DELETE ON moderation_log
+    FOR EACH ROW EXECUTE FUNCTION moderation_log_append_only();
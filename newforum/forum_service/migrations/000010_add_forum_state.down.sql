@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_forums_pinned;
+
+ALTER TABLE forums DROP COLUMN IF EXISTS unarchived_at;
+ALTER TABLE forums DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE forums DROP COLUMN IF EXISTS pinned;
+ALTER TABLE forums DROP COLUMN IF EXISTS locked;
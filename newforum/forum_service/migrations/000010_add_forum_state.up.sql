@@ -0,0 +1,8 @@
+-- Locked and archived forums are read-only; pinned forums are listed first
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+-- Unarchiving restarts the inactivity period of the archiver
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS unarchived_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_forums_pinned ON forums(created_at, id) WHERE pinned;
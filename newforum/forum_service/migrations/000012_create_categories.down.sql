@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_forums_parent_id;
+DROP INDEX IF EXISTS idx_forums_category_id;
+
+ALTER TABLE forums DROP COLUMN IF EXISTS position;
+ALTER TABLE forums DROP COLUMN IF EXISTS parent_id;
+ALTER TABLE forums DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Subforums point at their parent; forums without a category are listed
+-- after the categorised ones
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES forums(id) ON DELETE SET NULL;
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_forums_category_id ON forums(category_id);
+CREATE INDEX IF NOT EXISTS idx_forums_parent_id ON forums(parent_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_topic_tags_tag_id;
+DROP INDEX IF EXISTS idx_forum_tags_tag_id;
+
+DROP TABLE IF EXISTS topic_tags;
+DROP TABLE IF EXISTS forum_tags;
+DROP TABLE IF EXISTS tags;
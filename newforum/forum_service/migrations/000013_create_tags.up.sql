@@ -0,0 +1,22 @@
+-- Tag names are stored normalised (lower case, words joined by dashes), so
+-- the unique constraint also catches spelling variants
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS forum_tags (
+    forum_id INTEGER NOT NULL REFERENCES forums(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (forum_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS topic_tags (
+    topic_id INTEGER NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (topic_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_forum_tags_tag_id ON forum_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_topic_tags_tag_id ON topic_tags(tag_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notifications_username;
+DROP TABLE IF EXISTS notifications;
+
+DROP INDEX IF EXISTS idx_message_mentions_username;
+DROP TABLE IF EXISTS message_mentions;
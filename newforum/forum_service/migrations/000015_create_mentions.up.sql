@@ -0,0 +1,23 @@
+-- Users mentioned with @username in a message
+CREATE TABLE IF NOT EXISTS message_mentions (
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    username VARCHAR(255) NOT NULL,
+    PRIMARY KEY (message_id, username)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_mentions_username ON message_mentions(username);
+
+-- Notifications are addressed by username, like message authors
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    forum_id INTEGER REFERENCES forums(id) ON DELETE CASCADE,
+    topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+    message_id INTEGER REFERENCES messages(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_username ON notifications(username, created_at DESC, id DESC);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS notification_preferences;
+
+DROP INDEX IF EXISTS idx_notifications_unread;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS detail;
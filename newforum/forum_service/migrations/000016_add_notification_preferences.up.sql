@@ -0,0 +1,13 @@
+-- Detail adds context to a notification: the emoji of a reaction or the
+-- moderation action taken
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS detail VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(username) WHERE read_at IS NULL;
+
+-- Types without a row here are delivered
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    username VARCHAR(255) NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (username, type)
+);
@@ -0,0 +1,13 @@
+-- last_sent_at marks how far the subscriber has been mailed; the next digest
+-- covers the messages posted after it
+CREATE TABLE IF NOT EXISTS forum_subscriptions (
+    username VARCHAR(255) NOT NULL,
+    forum_id INTEGER NOT NULL REFERENCES forums(id) ON DELETE CASCADE,
+    frequency VARCHAR(16) NOT NULL CHECK (frequency IN ('immediate', 'daily', 'weekly')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_sent_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (username, forum_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_forum_subscriptions_due ON forum_subscriptions(frequency, last_sent_at);
+CREATE INDEX IF NOT EXISTS idx_forum_subscriptions_forum ON forum_subscriptions(forum_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_forum_id_id;
+
+DROP TABLE IF EXISTS read_positions;
@@ -0,0 +1,10 @@
+-- A user has read every message of a forum up to last_read_message_id
+CREATE TABLE IF NOT EXISTS read_positions (
+    username VARCHAR(255) NOT NULL,
+    forum_id INTEGER NOT NULL REFERENCES forums(id) ON DELETE CASCADE,
+    last_read_message_id INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (username, forum_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_forum_id_id ON messages(forum_id, id) WHERE deleted_at IS NULL;
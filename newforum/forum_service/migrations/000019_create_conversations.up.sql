@@ -0,0 +1,41 @@
+-- Private conversations between two or more users. updated_at moves with
+-- every new message so the conversation list shows recent ones first
+CREATE TABLE IF NOT EXISTS conversations (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- A participant has read every message up to last_read_message_id
+CREATE TABLE IF NOT EXISTS conversation_participants (
+    conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    username VARCHAR(255) NOT NULL,
+    joined_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_read_message_id INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (conversation_id, username)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_participants_username ON conversation_participants(username);
+
+CREATE TABLE IF NOT EXISTS conversation_messages (
+    id SERIAL PRIMARY KEY,
+    conversation_id INTEGER NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    author VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation ON conversation_messages(conversation_id, created_at, id);
+
+-- blocker does not accept private messages from blocked
+CREATE TABLE IF NOT EXISTS user_blocks (
+    blocker VARCHAR(255) NOT NULL,
+    blocked VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (blocker, blocked),
+    CHECK (blocker <> blocked)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON user_blocks(blocked);
@@ -0,0 +1,8 @@
+-- Messages by ignored users are collapsed for the ignorer
+CREATE TABLE IF NOT EXISTS user_ignores (
+    ignorer VARCHAR(255) NOT NULL,
+    ignored VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (ignorer, ignored),
+    CHECK (ignorer <> ignored)
+);
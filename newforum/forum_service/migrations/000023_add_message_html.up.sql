@@ -0,0 +1,4 @@
+-- Messages are written in Markdown; content_html caches the sanitised
+-- HTML rendered from content. Existing messages are rendered when the
+-- service starts
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_html TEXT NOT NULL DEFAULT '';
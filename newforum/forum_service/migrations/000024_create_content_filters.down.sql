@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS link_rules;
+DROP TABLE IF EXISTS word_filters;
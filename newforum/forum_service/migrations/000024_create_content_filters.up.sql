@@ -0,0 +1,23 @@
+-- Banned words and link rules; a NULL forum_id applies to every forum and
+-- the global chat
+CREATE TABLE IF NOT EXISTS word_filters (
+    id SERIAL PRIMARY KEY,
+    forum_id INTEGER REFERENCES forums(id) ON DELETE CASCADE,
+    pattern VARCHAR(100) NOT NULL,
+    action VARCHAR(8) NOT NULL CHECK (action IN ('block', 'mask', 'flag')),
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_word_filters_pattern ON word_filters(COALESCE(forum_id, 0), pattern);
+
+CREATE TABLE IF NOT EXISTS link_rules (
+    id SERIAL PRIMARY KEY,
+    forum_id INTEGER REFERENCES forums(id) ON DELETE CASCADE,
+    domain VARCHAR(253) NOT NULL,
+    policy VARCHAR(8) NOT NULL CHECK (policy IN ('allow', 'deny')),
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_link_rules_domain ON link_rules(COALESCE(forum_id, 0), domain);
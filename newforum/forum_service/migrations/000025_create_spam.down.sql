@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_messages_content_hash;
+DROP INDEX IF EXISTS idx_messages_author_created;
+DROP TABLE IF EXISTS held_attachments;
+DROP TABLE IF EXISTS held_messages;
+DROP TABLE IF EXISTS spam_samples;
@@ -0,0 +1,51 @@
+-- Messages moderators marked as spam or not, which the spam classifier is
+-- trained on; rejected held messages were never posted and have no
+-- message_id
+CREATE TABLE IF NOT EXISTS spam_samples (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    spam BOOLEAN NOT NULL,
+    marked_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_spam_samples_message ON spam_samples(message_id) WHERE message_id IS NOT NULL;
+
+-- Messages that scored as spam wait here for a moderator rather than in
+-- messages, so nothing that reads messages can show them by accident
+CREATE TABLE IF NOT EXISTS held_messages (
+    id SERIAL PRIMARY KEY,
+    forum_id INTEGER NOT NULL REFERENCES forums(id) ON DELETE CASCADE,
+    topic_id INTEGER REFERENCES topics(id) ON DELETE CASCADE,
+    reply_to INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+    quote TEXT NOT NULL DEFAULT '',
+    author VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    signals JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_held_messages_forum ON held_messages(forum_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_held_messages_author ON held_messages(author, created_at);
+
+CREATE TABLE IF NOT EXISTS held_attachments (
+    id SERIAL PRIMARY KEY,
+    held_message_id INTEGER NOT NULL REFERENCES held_messages(id) ON DELETE CASCADE,
+    uploader VARCHAR(255) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    hash CHAR(64) NOT NULL REFERENCES blobs(hash),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_held_attachments_message ON held_attachments(held_message_id);
+CREATE INDEX IF NOT EXISTS idx_held_attachments_hash ON held_attachments(hash);
+
+-- Every new message counts its author's recent messages and earlier copies
+-- of its content, compared by hash
+CREATE INDEX IF NOT EXISTS idx_messages_author_created ON messages(author, created_at);
+CREATE INDEX IF NOT EXISTS idx_messages_content_hash ON messages(md5(content), created_at);
+CREATE INDEX IF NOT EXISTS idx_held_messages_content_hash ON held_messages(md5(content), created_at);
@@ -0,0 +1 @@
+ALTER TABLE forums DROP COLUMN IF EXISTS slow_mode;
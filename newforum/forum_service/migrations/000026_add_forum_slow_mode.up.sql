@@ -0,0 +1,2 @@
+-- Seconds each user has to wait between messages in a forum; 0 is off
+ALTER TABLE forums ADD COLUMN IF NOT EXISTS slow_mode INTEGER NOT NULL DEFAULT 0 CHECK (slow_mode >= 0);
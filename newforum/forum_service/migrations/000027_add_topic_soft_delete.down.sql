@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_topics_deleted_at;
+
+ALTER TABLE topics DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE topics DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+-- Deleted topics stay in place with their messages in the trash until the
+-- purge job removes them
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE topics ADD COLUMN IF NOT EXISTS deleted_by VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_topics_deleted_at ON topics(deleted_at) WHERE deleted_at IS NOT NULL;